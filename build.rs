@@ -0,0 +1,141 @@
+//! Records which build this is, for `/about`: the git commit, the build time
+//! and the versions of the main libraries, as compile-time environment
+//! variables. `BUILD_COMMIT` can be set instead when building outside a git
+//! checkout, e.g. in a container.
+
+use std::{
+    env, fs,
+    path::Path,
+    process::Command,
+    time::{SystemTime, UNIX_EPOCH},
+};
+
+/// Libraries whose resolved versions are worth reporting.
+const LIBRARIES: &[&str] = &["serenity", "tokio", "reqwest", "serde_json", "chrono"];
+/// `/explain` pages, as `<locale>/<topic>.md`.
+const EXPLAIN_DIR: &str = "content/explain";
+/// The locale whose pages define the topics and their names.
+const DEFAULT_LOCALE: &str = "en-US";
+
+fn main() {
+    println!("cargo:rerun-if-env-changed=BUILD_COMMIT");
+    println!("cargo:rerun-if-env-changed=SOURCE_DATE_EPOCH");
+    println!("cargo:rerun-if-changed=.git/HEAD");
+    println!("cargo:rerun-if-changed=.git/refs/heads");
+    println!("cargo:rerun-if-changed=Cargo.lock");
+
+    let commit = env::var("BUILD_COMMIT")
+        .ok()
+        .filter(|commit| !commit.is_empty())
+        .or_else(git_commit)
+        .unwrap_or_else(|| "unknown".to_string());
+    println!("cargo:rustc-env=BUILD_COMMIT={}", commit);
+
+    // Reproducible builds pin the date through SOURCE_DATE_EPOCH.
+    let built_at = env::var("SOURCE_DATE_EPOCH")
+        .ok()
+        .and_then(|epoch| epoch.parse::<u64>().ok())
+        .unwrap_or_else(|| {
+            SystemTime::now()
+                .duration_since(UNIX_EPOCH)
+                .map(|d| d.as_secs())
+                .unwrap_or_default()
+        });
+    println!("cargo:rustc-env=BUILD_TIMESTAMP={}", built_at);
+
+    println!(
+        "cargo:rustc-env=BUILD_LIBRARIES={}",
+        library_versions().join(", ")
+    );
+
+    explain_pages();
+}
+
+/// Embeds every page under `EXPLAIN_DIR` through `$OUT_DIR/explain.rs`, so
+/// topics and translations are added by adding files. A topic exists when it
+/// has a default-locale page, whose `# ` heading names it.
+fn explain_pages() {
+    println!("cargo:rerun-if-changed={}", EXPLAIN_DIR);
+    let root = Path::new(&env::var("CARGO_MANIFEST_DIR").unwrap_or_default()).join(EXPLAIN_DIR);
+    let mut pages = Vec::new();
+    for locale in fs::read_dir(&root).into_iter().flatten().flatten() {
+        let locale_name = locale.file_name().to_string_lossy().into_owned();
+        for page in fs::read_dir(locale.path()).into_iter().flatten().flatten() {
+            let path = page.path();
+            if path.extension().map_or(false, |extension| extension == "md") {
+                let topic = path
+                    .file_stem()
+                    .map(|stem| stem.to_string_lossy().into_owned())
+                    .unwrap_or_default();
+                pages.push((locale_name.clone(), topic, path));
+            }
+        }
+    }
+    pages.sort();
+
+    let mut code = String::from("pub const PAGES: &[(&str, &str, &str)] = &[\n");
+    for (locale, topic, path) in &pages {
+        code.push_str(&format!(
+            "    ({:?}, {:?}, include_str!({:?})),\n",
+            locale,
+            topic,
+            path.display().to_string()
+        ));
+    }
+    code.push_str("];\n\npub const TOPICS: &[(&str, &str)] = &[\n");
+    for (_, topic, path) in pages.iter().filter(|(locale, ..)| locale == DEFAULT_LOCALE) {
+        let contents = fs::read_to_string(path).unwrap_or_default();
+        let title = contents
+            .lines()
+            .find_map(|line| line.strip_prefix("# "))
+            .unwrap_or(topic)
+            .trim();
+        code.push_str(&format!("    ({:?}, {:?}),\n", title, topic));
+    }
+    code.push_str("];\n");
+
+    let out = Path::new(&env::var("OUT_DIR").unwrap_or_default()).join("explain.rs");
+    if let Err(error) = fs::write(&out, code) {
+        panic!("Error writing {}: {}", out.display(), error);
+    }
+}
+
+/// The checked-out commit's short hash, marked `-dirty` when there are
+/// uncommitted changes.
+fn git_commit() -> Option<String> {
+    let git = |args: &[&str]| {
+        Command::new("git")
+            .args(args)
+            .output()
+            .ok()
+            .filter(|output| output.status.success())
+            .map(|output| String::from_utf8_lossy(&output.stdout).trim().to_string())
+    };
+    let commit = git(&["rev-parse", "--short=12", "HEAD"]).filter(|commit| !commit.is_empty())?;
+    let dirty = git(&["status", "--porcelain", "--untracked-files=no"])
+        .map_or(false, |status| !status.is_empty());
+    Some(if dirty {
+        format!("{}-dirty", commit)
+    } else {
+        commit
+    })
+}
+
+/// `name version` for each of `LIBRARIES`, as resolved in `Cargo.lock`.
+fn library_versions() -> Vec<String> {
+    let lock = fs::read_to_string("Cargo.lock").unwrap_or_default();
+    let mut versions = Vec::new();
+    let mut name = None;
+    for line in lock.lines() {
+        if let Some(value) = line.strip_prefix("name = ") {
+            name = Some(value.trim_matches('"'));
+        } else if let Some(version) = line.strip_prefix("version = ") {
+            if let Some(name) = name.take().filter(|name| LIBRARIES.contains(name)) {
+                versions.push(format!("{} {}", name, version.trim_matches('"')));
+            }
+        }
+    }
+    versions.sort();
+    versions.dedup();
+    versions
+}
@@ -0,0 +1,166 @@
+pub const MIN_ROBUX: f64 = 100.0;
+pub const MAX_ROBUX: f64 = 1_000_000.0;
+pub const MIN_MONEY: f64 = 0.01;
+pub const MAX_MONEY: f64 = 100_000.0;
+
+/// Parses an amount as people type it: `1500`, `1,500`, `1.5k`, `2.5m` or `1b`,
+/// optionally with a `£`, `$` or `R$` marker.
+pub fn parse(input: &str) -> Result<f64, String> {
+    let normalized: String = input
+        .trim()
+        .to_lowercase()
+        .chars()
+        .filter(|c| !matches!(c, ',' | '_' | ' '))
+        .collect();
+    let normalized = normalized
+        .trim_start_matches(['£', '$'])
+        .trim_end_matches("r$")
+        .trim_end_matches("robux");
+
+    let (number, multiplier) = match normalized.char_indices().last() {
+        Some((index, suffix)) => match multiplier(suffix) {
+            Some(multiplier) => (&normalized[..index], multiplier),
+            None => (normalized, 1.0),
+        },
+        None => return Err("Enter an amount, e.g. 1500 or 1.5k".to_string()),
+    };
+
+    number
+        .parse::<f64>()
+        .map(|value| value * multiplier)
+        .map_err(|_| {
+            format!(
+                "Invalid amount '{}'. Use a number like 1500, 1.5k or 2m.",
+                input.trim()
+            )
+        })
+}
+
+/// Parses a Robux amount, which must be a whole number within the supported range.
+pub fn parse_robux(input: &str) -> Result<f64, String> {
+    validate_whole_robux(validate_robux(parse(input)?)?)
+}
+
+/// Rejects Robux amounts that aren't whole numbers, such as 150.5.
+pub fn validate_whole_robux(robux: f64) -> Result<f64, String> {
+    if robux.fract() != 0.0 {
+        return Err(format!(
+            "Robux amounts must be whole numbers, not {}",
+            robux
+        ));
+    }
+    Ok(robux)
+}
+
+/// Parses a GBP or USD amount within the supported range.
+pub fn parse_money(input: &str) -> Result<f64, String> {
+    let amount = parse(input)?;
+    if !amount.is_finite() || !(MIN_MONEY..=MAX_MONEY).contains(&amount) {
+        return Err(format!(
+            "Amount must be between {:.2} and {}",
+            MIN_MONEY,
+            group_thousands(MAX_MONEY)
+        ));
+    }
+    Ok(amount)
+}
+
+/// Rejects Robux amounts that are not finite or outside the supported range.
+pub fn validate_robux(robux: f64) -> Result<f64, String> {
+    if !robux.is_finite() || !(MIN_ROBUX..=MAX_ROBUX).contains(&robux) {
+        return Err(format!(
+            "Amount must be between {} and {} R$",
+            group_thousands(MIN_ROBUX),
+            group_thousands(MAX_ROBUX)
+        ));
+    }
+    Ok(robux)
+}
+
+/// Formats a whole number with thousands separators, e.g. `1,000,000`.
+pub fn group_thousands(value: f64) -> String {
+    let digits = (value.round() as i64).unsigned_abs().to_string();
+    let mut grouped = String::new();
+    for (index, digit) in digits.chars().enumerate() {
+        if index > 0 && (digits.len() - index) % 3 == 0 {
+            grouped.push(',');
+        }
+        grouped.push(digit);
+    }
+
+    if value < 0.0 {
+        format!("-{}", grouped)
+    } else {
+        grouped
+    }
+}
+
+/// The value of a shorthand suffix such as `k`.
+pub fn multiplier(suffix: char) -> Option<f64> {
+    match suffix.to_ascii_lowercase() {
+        'k' => Some(1_000.0),
+        'm' => Some(1_000_000.0),
+        'b' => Some(1_000_000_000.0),
+        _ => None,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn suffixes_and_markers_scale_the_amount() {
+        assert_eq!(parse("1500"), Ok(1_500.0));
+        assert_eq!(parse(" 1,500 "), Ok(1_500.0));
+        assert_eq!(parse("1.5k"), Ok(1_500.0));
+        assert_eq!(parse("2.5M"), Ok(2_500_000.0));
+        assert_eq!(parse("1b"), Ok(1_000_000_000.0));
+        assert_eq!(parse("£12.50"), Ok(12.5));
+        assert_eq!(parse("10k R$"), Ok(10_000.0));
+    }
+
+    #[test]
+    fn malformed_amounts_are_rejected() {
+        for input in ["", "   ", "k", "abc", "1.2.3", "1kk", "1x", "--5"] {
+            assert!(parse(input).is_err(), "{:?} parsed", input);
+        }
+    }
+
+    #[test]
+    fn robux_must_be_whole_and_in_range() {
+        assert_eq!(parse_robux("100"), Ok(MIN_ROBUX));
+        assert_eq!(parse_robux("1m"), Ok(MAX_ROBUX));
+        assert!(parse_robux("99").is_err());
+        assert!(parse_robux("1,000,001").is_err());
+        assert!(parse_robux("150.5").is_err());
+        assert!(parse_robux("1.0005k").is_err());
+        assert_eq!(parse_robux("1.5k"), Ok(1_500.0));
+    }
+
+    #[test]
+    fn money_must_be_in_range() {
+        assert_eq!(parse_money("0.01"), Ok(MIN_MONEY));
+        assert_eq!(parse_money("100k"), Ok(MAX_MONEY));
+        assert!(parse_money("0.009").is_err());
+        assert!(parse_money("0").is_err());
+        assert!(parse_money("100,000.01").is_err());
+    }
+
+    #[test]
+    fn validation_rejects_non_finite_amounts() {
+        assert!(validate_robux(f64::NAN).is_err());
+        assert!(validate_robux(f64::INFINITY).is_err());
+        assert!(validate_whole_robux(100.5).is_err());
+        assert_eq!(validate_whole_robux(100.0), Ok(100.0));
+    }
+
+    #[test]
+    fn thousands_are_grouped() {
+        assert_eq!(group_thousands(0.0), "0");
+        assert_eq!(group_thousands(999.0), "999");
+        assert_eq!(group_thousands(1_000.0), "1,000");
+        assert_eq!(group_thousands(1_000_000.0), "1,000,000");
+        assert_eq!(group_thousands(-12_345.0), "-12,345");
+    }
+}
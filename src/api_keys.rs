@@ -0,0 +1,163 @@
+use crate::store::{JsonStore, Partition};
+use chrono::Utc;
+use serde::{Deserialize, Serialize};
+use std::{collections::HashMap, env};
+
+const KEY_USAGE_FILE: &str = "api_keys.json";
+
+/// Calls made with one key during the current month, persisted so the local count
+/// survives restarts.
+#[derive(Serialize, Deserialize, Clone, Default)]
+pub struct KeyUsage {
+    pub month: String,
+    pub calls: u64,
+    /// Remaining calls as last reported by the API's response headers.
+    pub reported_remaining: Option<u64>,
+    pub exhausted: bool,
+}
+
+/// A snapshot of one key's quota for display.
+pub struct KeyStatus {
+    pub label: String,
+    pub usage: KeyUsage,
+    pub remaining: Option<u64>,
+    pub active: bool,
+    pub exhausted: bool,
+}
+
+/// The exchange API keys configured in `RATE_API_KEYS` (comma-separated) or
+/// `RATE_API_KEY`, used in order until each reaches its monthly quota.
+pub struct KeyRing {
+    keys: Vec<String>,
+    monthly_quota: Option<u64>,
+    usage: JsonStore<HashMap<String, KeyUsage>>,
+}
+
+impl KeyRing {
+    pub fn from_env(partition: &Partition, monthly_quota: Option<u64>) -> Result<Self, String> {
+        let keys = env::var("RATE_API_KEYS")
+            .or_else(|_| env::var("RATE_API_KEY"))
+            .unwrap_or_default()
+            .split(',')
+            .map(str::trim)
+            .filter(|key| !key.is_empty())
+            .map(String::from)
+            .collect();
+
+        Ok(Self {
+            keys,
+            monthly_quota,
+            usage: JsonStore::open(partition, KEY_USAGE_FILE)?,
+        })
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.keys.is_empty()
+    }
+
+    /// The first key that has not hit its quota this month.
+    pub async fn active(&self) -> Option<String> {
+        let usage = self.usage.read().await;
+        self.first_available(&usage, &current_month()).cloned()
+    }
+
+    /// Counts a call made with `key`, recording the remaining quota if the API
+    /// reported one.
+    pub async fn record_call(&self, key: &str, reported_remaining: Option<u64>) {
+        let label = label(&self.keys, key);
+        self.update(&label, |usage| {
+            usage.calls += 1;
+            if reported_remaining.is_some() {
+                usage.reported_remaining = reported_remaining;
+            }
+        })
+        .await;
+    }
+
+    /// Takes `key` out of rotation until the month rolls over.
+    pub async fn mark_exhausted(&self, key: &str) {
+        let label = label(&self.keys, key);
+        log::warn!("Exchange API key {} reached its monthly quota", label);
+        self.update(&label, |usage| usage.exhausted = true).await;
+    }
+
+    pub async fn statuses(&self) -> Vec<KeyStatus> {
+        let month = current_month();
+        let usage = self.usage.read().await;
+        let active = self.first_available(&usage, &month);
+
+        self.keys
+            .iter()
+            .map(|key| {
+                let label = label(&self.keys, key);
+                let usage = usage
+                    .get(&label)
+                    .filter(|usage| usage.month == month)
+                    .cloned()
+                    .unwrap_or_default();
+                let remaining = usage.reported_remaining.or_else(|| {
+                    self.monthly_quota
+                        .map(|quota| quota.saturating_sub(usage.calls))
+                });
+
+                KeyStatus {
+                    label,
+                    exhausted: self.is_exhausted(&usage),
+                    usage,
+                    remaining,
+                    active: active == Some(key),
+                }
+            })
+            .collect()
+    }
+
+    fn first_available(&self, usage: &HashMap<String, KeyUsage>, month: &str) -> Option<&String> {
+        self.keys
+            .iter()
+            .find(|key| match usage.get(&label(&self.keys, key)) {
+                Some(usage) if usage.month == month => !self.is_exhausted(usage),
+                _ => true,
+            })
+    }
+
+    fn is_exhausted(&self, usage: &KeyUsage) -> bool {
+        usage.exhausted
+            || usage.reported_remaining == Some(0)
+            || self.monthly_quota.is_some_and(|quota| usage.calls >= quota)
+    }
+
+    async fn update(&self, label: &str, f: impl FnOnce(&mut KeyUsage)) {
+        let month = current_month();
+        let result = self
+            .usage
+            .update(|usage| {
+                let entry = usage.entry(label.to_string()).or_default();
+                if entry.month != month {
+                    *entry = KeyUsage {
+                        month,
+                        ..KeyUsage::default()
+                    };
+                }
+                f(entry);
+            })
+            .await;
+
+        if let Err(error) = result {
+            log::error!("Error saving API key usage: {}", error);
+        }
+    }
+}
+
+/// Identifies a key without persisting or displaying the secret itself.
+fn label(keys: &[String], key: &str) -> String {
+    let position = keys.iter().position(|k| k == key).unwrap_or_default();
+    let suffix: String = key
+        .chars()
+        .skip(key.chars().count().saturating_sub(4))
+        .collect();
+    format!("#{} (…{})", position + 1, suffix)
+}
+
+fn current_month() -> String {
+    Utc::now().format("%Y-%m").to_string()
+}
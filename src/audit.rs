@@ -0,0 +1,79 @@
+use crate::store::{self, JsonStore, Partition};
+use serde::{Deserialize, Serialize};
+use serenity::prelude::*;
+use std::sync::Arc;
+
+const AUDIT_FILE: &str = "audit.json";
+
+/// A change to a guild's pricing settings: who made it, what it was before and
+/// what it became.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct Change {
+    pub guild_id: u64,
+    /// The setting that changed, e.g. `Markup` or `FX Margin`.
+    pub setting: String,
+    pub actor_id: u64,
+    pub actor_name: String,
+    pub old_value: String,
+    pub new_value: String,
+    /// Unix timestamp of the change.
+    pub changed_at: u64,
+}
+
+#[derive(Serialize, Deserialize, Default)]
+struct AuditLog {
+    changes: Vec<Change>,
+}
+
+/// Every pricing change, persisted in the data directory so disputes about
+/// past rates can be settled from the record.
+pub struct AuditStore {
+    log: JsonStore<AuditLog>,
+}
+
+impl AuditStore {
+    pub fn open(partition: &Partition) -> Result<Self, String> {
+        Ok(Self {
+            log: JsonStore::open(partition, AUDIT_FILE)?,
+        })
+    }
+
+    /// Stamps `change` with the current time and persists it.
+    pub async fn record(&self, change: Change) -> Result<(), String> {
+        self.log
+            .update(|log| {
+                log.changes.push(Change {
+                    changed_at: store::now(),
+                    ..change
+                })
+            })
+            .await
+    }
+
+    /// Changes made in `guild_id`, oldest first.
+    pub async fn for_guild(&self, guild_id: u64) -> Vec<Change> {
+        self.log
+            .read()
+            .await
+            .changes
+            .iter()
+            .filter(|change| change.guild_id == guild_id)
+            .cloned()
+            .collect()
+    }
+}
+
+pub struct AuditKey;
+
+impl TypeMapKey for AuditKey {
+    type Value = Arc<AuditStore>;
+}
+
+pub async fn store(ctx: &Context) -> Result<Arc<AuditStore>, String> {
+    ctx.data
+        .read()
+        .await
+        .get::<AuditKey>()
+        .cloned()
+        .ok_or_else(|| "Audit log unavailable".to_string())
+}
@@ -0,0 +1,211 @@
+use crate::store::{self, Partition};
+use ring::{
+    aead::{self, Aad, LessSafeKey, Nonce, UnboundKey},
+    pbkdf2,
+    rand::{SecureRandom, SystemRandom},
+};
+use serde::{Deserialize, Serialize};
+use std::{
+    collections::{BTreeMap, HashSet},
+    env, fs,
+    num::NonZeroU32,
+};
+
+/// The documents a backup holds: a bot's settings and business records.
+/// Caches such as exchange rates and registered commands are rebuilt on their
+/// own and left out.
+pub const FILES: &[&str] = &[
+    "settings.json",
+    "orders.json",
+    "stock.json",
+    "audit.json",
+    "disputes.json",
+    "links.json",
+    "flags.json",
+    "giveaways.json",
+    "calculations.json",
+];
+/// Largest backup `/backup restore` will read.
+pub const MAX_ARCHIVE_BYTES: u64 = 25 * 1024 * 1024;
+/// Where the data a restore replaces is kept, inside the partition.
+const SAFETY_DIR: &str = "backups";
+/// Identifies a backup file and its format.
+const MAGIC: &[u8] = b"RCBACKUP1";
+const SALT_BYTES: usize = 16;
+const PBKDF2_ITERATIONS: u32 = 100_000;
+
+/// A snapshot of a bot's documents, as it is stored encrypted.
+#[derive(Serialize, Deserialize)]
+pub struct Archive {
+    /// The tenant it was taken from, empty for the unnamed bot.
+    pub tenant: String,
+    /// Unix timestamp of the snapshot.
+    pub created_at: u64,
+    /// Each document's file name and contents.
+    files: BTreeMap<String, serde_json::Value>,
+}
+
+impl Archive {
+    /// Snapshots `partition`'s documents as they are on disk.
+    pub fn create(tenant: &str, partition: &Partition) -> Result<Self, String> {
+        let mut files = BTreeMap::new();
+        for file in FILES {
+            let path = partition.dir().join(file);
+            let contents = match fs::read_to_string(&path) {
+                Ok(contents) => contents,
+                Err(e) if e.kind() == std::io::ErrorKind::NotFound => continue,
+                Err(e) => return Err(format!("Error reading {}: {}", path.display(), e)),
+            };
+            let document = serde_json::from_str(&contents)
+                .map_err(|e| format!("Error parsing {}: {}", path.display(), e))?;
+            files.insert(file.to_string(), document);
+        }
+        Ok(Self {
+            tenant: tenant.to_string(),
+            created_at: store::now(),
+            files,
+        })
+    }
+
+    pub fn file_count(&self) -> usize {
+        self.files.len()
+    }
+
+    /// The archive encrypted with the key derived from `BACKUP_PASSPHRASE`.
+    pub fn seal(&self) -> Result<Vec<u8>, String> {
+        let mut data =
+            serde_json::to_vec(self).map_err(|e| format!("Error serializing backup: {}", e))?;
+        let mut salt = [0; SALT_BYTES];
+        let mut nonce = [0; aead::NONCE_LEN];
+        let random = SystemRandom::new();
+        random
+            .fill(&mut salt)
+            .and_then(|()| random.fill(&mut nonce))
+            .map_err(|_| "Error generating backup key".to_string())?;
+        key(&salt)?
+            .seal_in_place_append_tag(
+                Nonce::assume_unique_for_key(nonce),
+                Aad::from(MAGIC),
+                &mut data,
+            )
+            .map_err(|_| "Error encrypting backup".to_string())?;
+
+        let mut sealed = Vec::with_capacity(MAGIC.len() + SALT_BYTES + nonce.len() + data.len());
+        sealed.extend_from_slice(MAGIC);
+        sealed.extend_from_slice(&salt);
+        sealed.extend_from_slice(&nonce);
+        sealed.extend_from_slice(&data);
+        Ok(sealed)
+    }
+
+    /// Decrypts a file made by [`Archive::seal`], which fails if it was made
+    /// with another passphrase or altered since.
+    pub fn open(sealed: &[u8]) -> Result<Self, String> {
+        let rest = sealed
+            .strip_prefix(MAGIC)
+            .ok_or("That file isn't a backup made by /backup create")?;
+        if rest.len() < SALT_BYTES + aead::NONCE_LEN {
+            return Err("The backup is truncated".to_string());
+        }
+        let (salt, rest) = rest.split_at(SALT_BYTES);
+        let (nonce, ciphertext) = rest.split_at(aead::NONCE_LEN);
+        let nonce = Nonce::try_assume_unique_for_key(nonce)
+            .map_err(|_| "The backup is truncated".to_string())?;
+        let mut data = ciphertext.to_vec();
+        let plaintext = key(salt)?
+            .open_in_place(nonce, Aad::from(MAGIC), &mut data)
+            .map_err(|_| {
+                "The backup couldn't be decrypted; it was made with another passphrase or has been altered"
+                    .to_string()
+            })?;
+        serde_json::from_slice(plaintext).map_err(|e| format!("Invalid backup: {}", e))
+    }
+
+    /// Documents in `partition` holding records the archive lacks, such as
+    /// orders placed or settings changed since it was made, which a restore
+    /// would lose. Documents the archive doesn't have are left alone by a
+    /// restore, so aren't listed.
+    pub fn newer_files(&self, partition: &Partition) -> Vec<&'static str> {
+        FILES
+            .iter()
+            .copied()
+            .filter(|file| {
+                let archived = match self.files.get(*file) {
+                    Some(archived) => archived,
+                    None => return false,
+                };
+                fs::read_to_string(partition.dir().join(file))
+                    .ok()
+                    .and_then(|contents| serde_json::from_str(&contents).ok())
+                    .map_or(false, |current| lacks(archived, &current))
+            })
+            .collect()
+    }
+
+    /// Replaces `partition`'s documents with the archive's, first saving the
+    /// current ones as a backup in the partition's `backups` directory, whose
+    /// path is returned. Documents missing from the archive are left alone.
+    pub fn restore(&self, tenant: &str, partition: &Partition) -> Result<String, String> {
+        let current = Self::create(tenant, partition)?.seal()?;
+        let safety_path = partition
+            .dir()
+            .join(SAFETY_DIR)
+            .join(format!("before-restore-{}.rcbackup", store::now()));
+        if let Some(parent) = safety_path.parent() {
+            fs::create_dir_all(parent)
+                .map_err(|e| format!("Error creating {}: {}", parent.display(), e))?;
+        }
+        fs::write(&safety_path, current)
+            .map_err(|e| format!("Error writing {}: {}", safety_path.display(), e))?;
+
+        for (file, document) in &self.files {
+            if !FILES.contains(&file.as_str()) {
+                log::warn!("Skipped unexpected file {} in backup", file);
+                continue;
+            }
+            store::write_atomically(&partition.dir().join(file), document)?;
+        }
+        Ok(safety_path.display().to_string())
+    }
+}
+
+/// Whether `archived` lacks anything in `current`: a record in one of its
+/// lists, an entry or a value. Records and entries `current` has dropped don't
+/// count, as a restore brings them back rather than losing them.
+fn lacks(archived: &serde_json::Value, current: &serde_json::Value) -> bool {
+    use serde_json::Value;
+    match (archived, current) {
+        (Value::Object(archived), Value::Object(current)) => current.iter().any(|(key, value)| {
+            archived
+                .get(key)
+                .map_or(true, |archived| lacks(archived, value))
+        }),
+        (Value::Array(archived), Value::Array(current)) => {
+            let archived: HashSet<String> = archived.iter().map(Value::to_string).collect();
+            current
+                .iter()
+                .any(|record| !archived.contains(&record.to_string()))
+        }
+        (archived, current) => archived != current,
+    }
+}
+
+/// The key for a backup salted with `salt`, derived from `BACKUP_PASSPHRASE`.
+fn key(salt: &[u8]) -> Result<LessSafeKey, String> {
+    let passphrase = env::var("BACKUP_PASSPHRASE")
+        .ok()
+        .filter(|passphrase| !passphrase.is_empty())
+        .ok_or("Set BACKUP_PASSPHRASE to encrypt and decrypt backups")?;
+    let iterations = NonZeroU32::new(PBKDF2_ITERATIONS).ok_or("Invalid iteration count")?;
+    let mut key = [0; 32];
+    pbkdf2::derive(
+        pbkdf2::PBKDF2_HMAC_SHA256,
+        iterations,
+        salt,
+        passphrase.as_bytes(),
+        &mut key,
+    );
+    let key = UnboundKey::new(&aead::CHACHA20_POLY1305, &key)
+        .map_err(|_| "Error deriving backup key".to_string())?;
+    Ok(LessSafeKey::new(key))
+}
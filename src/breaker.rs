@@ -0,0 +1,88 @@
+use std::{
+    sync::Mutex,
+    time::{Duration, Instant},
+};
+
+#[derive(Clone, Copy, PartialEq, Eq)]
+pub enum BreakerState {
+    Closed,
+    Open,
+    HalfOpen,
+}
+
+impl BreakerState {
+    pub fn label(self) -> &'static str {
+        match self {
+            BreakerState::Closed => "Closed",
+            BreakerState::Open => "Open",
+            BreakerState::HalfOpen => "Half-open",
+        }
+    }
+}
+
+struct Inner {
+    consecutive_failures: u32,
+    opened_at: Option<Instant>,
+    trial_in_flight: bool,
+}
+
+/// Stops calls to a failing upstream after `failure_threshold` consecutive
+/// failures. Once `cooldown` has passed a single trial call is let through; its
+/// outcome decides whether the breaker closes again or stays open.
+pub struct CircuitBreaker {
+    failure_threshold: u32,
+    cooldown: Duration,
+    inner: Mutex<Inner>,
+}
+
+impl CircuitBreaker {
+    pub fn new(failure_threshold: u32, cooldown: Duration) -> Self {
+        Self {
+            failure_threshold,
+            cooldown,
+            inner: Mutex::new(Inner {
+                consecutive_failures: 0,
+                opened_at: None,
+                trial_in_flight: false,
+            }),
+        }
+    }
+
+    /// Returns whether a call to the upstream should be attempted.
+    pub fn allow(&self) -> bool {
+        let mut inner = self.inner.lock().unwrap();
+        match inner.opened_at {
+            None => true,
+            Some(opened_at) if opened_at.elapsed() >= self.cooldown && !inner.trial_in_flight => {
+                inner.trial_in_flight = true;
+                true
+            }
+            Some(_) => false,
+        }
+    }
+
+    pub fn record_success(&self) {
+        let mut inner = self.inner.lock().unwrap();
+        inner.consecutive_failures = 0;
+        inner.opened_at = None;
+        inner.trial_in_flight = false;
+    }
+
+    pub fn record_failure(&self) {
+        let mut inner = self.inner.lock().unwrap();
+        inner.consecutive_failures += 1;
+        inner.trial_in_flight = false;
+        if inner.opened_at.is_some() || inner.consecutive_failures >= self.failure_threshold {
+            inner.opened_at = Some(Instant::now());
+        }
+    }
+
+    pub fn state(&self) -> BreakerState {
+        let inner = self.inner.lock().unwrap();
+        match inner.opened_at {
+            None => BreakerState::Closed,
+            Some(opened_at) if opened_at.elapsed() >= self.cooldown => BreakerState::HalfOpen,
+            Some(_) => BreakerState::Open,
+        }
+    }
+}
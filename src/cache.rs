@@ -0,0 +1,306 @@
+use crate::redis::{Redis, Reply};
+use serenity::prelude::*;
+use std::{
+    collections::HashMap,
+    env, process,
+    sync::{
+        atomic::{AtomicBool, AtomicU64, Ordering},
+        Arc,
+    },
+    time::{Duration, Instant, SystemTime, UNIX_EPOCH},
+};
+
+/// Entries kept in memory before expired ones are swept out.
+const SWEEP_THRESHOLD: usize = 10_000;
+/// How often a held lock is retried while waiting for it.
+const LOCK_RETRY: Duration = Duration::from_millis(50);
+/// Releases a lease only if it is still the holder's, so a lease that expired
+/// and was taken by another instance isn't released from under it.
+const RELEASE_SCRIPT: &str =
+    "if redis.call('GET', KEYS[1]) == ARGV[1] then return redis.call('DEL', KEYS[1]) else return 0 end";
+
+/// Short-lived state that bot instances may share: exchange rate tables and
+/// command cooldowns. It is kept in memory unless `CACHE_URL` points at Redis,
+/// in which case every instance pointed at the same server (e.g. the shards of
+/// one bot) sees the same entries.
+///
+/// It also hands out leases, so that only one instance at a time runs a
+/// scheduled job or writes a shared document.
+///
+/// The cache never fails a command: while Redis is unreachable, lookups miss
+/// and writes are dropped. Leases are the exception: none are granted then, so
+/// scheduled jobs wait and shared documents refuse updates rather than letting
+/// every instance in at once.
+#[derive(Clone)]
+pub struct Cache {
+    backend: Arc<Backend>,
+    /// Prepended to every key, so bots sharing a server keep apart.
+    prefix: String,
+}
+
+enum Backend {
+    Memory(Mutex<HashMap<String, (String, Instant)>>),
+    Redis {
+        redis: Redis,
+        /// Only the first of a run of failures is logged, so an outage doesn't
+        /// log on every command.
+        failing: AtomicBool,
+    },
+}
+
+impl Cache {
+    /// The cache configured by `CACHE_URL` (e.g. `redis://localhost:6379/0`),
+    /// or an in-memory one when it is unset.
+    pub fn from_env() -> Result<Self, String> {
+        let backend = match env::var("CACHE_URL") {
+            Ok(url) if !url.trim().is_empty() => {
+                let redis = Redis::from_url(url.trim())?;
+                log::info!("Caching in Redis");
+                Backend::Redis {
+                    redis,
+                    failing: AtomicBool::new(false),
+                }
+            }
+            _ => Backend::Memory(Mutex::new(HashMap::new())),
+        };
+        Ok(Self {
+            backend: Arc::new(backend),
+            prefix: format!("{}:", env!("CARGO_PKG_NAME")),
+        })
+    }
+
+    /// The same cache with keys kept apart under `scope`, such as a tenant's
+    /// name.
+    pub fn scoped(&self, scope: &str) -> Self {
+        Self {
+            backend: self.backend.clone(),
+            prefix: format!("{}{}:", self.prefix, scope),
+        }
+    }
+
+    /// The value stored under `key`, unless it is missing or has expired.
+    pub async fn get(&self, key: &str) -> Option<String> {
+        let key = self.key(key);
+        match &*self.backend {
+            Backend::Memory(entries) => entries
+                .lock()
+                .await
+                .get(&key)
+                .filter(|(_, expires)| *expires > Instant::now())
+                .map(|(value, _)| value.clone()),
+            Backend::Redis { redis, .. } => {
+                match self.checked(redis.command(&["GET", &key]).await)? {
+                    Reply::Bulk(value) => String::from_utf8(value).ok(),
+                    _ => None,
+                }
+            }
+        }
+    }
+
+    /// Stores `value` under `key` for `ttl`, replacing any previous value.
+    pub async fn set(&self, key: &str, value: &str, ttl: Duration) {
+        let key = self.key(key);
+        match &*self.backend {
+            Backend::Memory(entries) => {
+                insert(&mut *entries.lock().await, key, value, ttl);
+            }
+            Backend::Redis { redis, .. } => {
+                let millis = millis(ttl);
+                self.checked(redis.command(&["SET", &key, value, "PX", &millis]).await);
+            }
+        }
+    }
+
+    /// Stores `value` under `key` for `ttl` unless the key already holds a
+    /// value. Returns whether it was stored, which is also assumed when the
+    /// cache is unreachable.
+    pub async fn set_new(&self, key: &str, value: &str, ttl: Duration) -> bool {
+        let key = self.key(key);
+        match &*self.backend {
+            Backend::Memory(entries) => {
+                let mut entries = entries.lock().await;
+                if entries
+                    .get(&key)
+                    .map_or(false, |(_, expires)| *expires > Instant::now())
+                {
+                    return false;
+                }
+                insert(&mut entries, key, value, ttl);
+                true
+            }
+            Backend::Redis { redis, .. } => {
+                let millis = millis(ttl);
+                let reply = redis
+                    .command(&["SET", &key, value, "NX", "PX", &millis])
+                    .await;
+                !matches!(self.checked(reply), Some(Reply::Nil))
+            }
+        }
+    }
+
+    /// How long until the value under `key` expires, or `None` if there is
+    /// none.
+    pub async fn expires_in(&self, key: &str) -> Option<Duration> {
+        let key = self.key(key);
+        match &*self.backend {
+            Backend::Memory(entries) => entries
+                .lock()
+                .await
+                .get(&key)
+                .and_then(|(_, expires)| expires.checked_duration_since(Instant::now())),
+            Backend::Redis { redis, .. } => match self
+                .checked(redis.command(&["PTTL", &key]).await)?
+            {
+                Reply::Integer(millis) if millis > 0 => Some(Duration::from_millis(millis as u64)),
+                _ => None,
+            },
+        }
+    }
+
+    /// Whether other instances can see this cache, i.e. it is in Redis.
+    pub fn is_shared(&self) -> bool {
+        matches!(&*self.backend, Backend::Redis { .. })
+    }
+
+    /// Takes the lease on `name` for `ttl` if no one holds it. Leases that
+    /// aren't released expire after `ttl`, so a scheduled job can take one for
+    /// its whole interval to run at most once per interval across instances.
+    /// `None` while Redis is unreachable, as no one can tell who holds it.
+    pub async fn lease(&self, name: &str, ttl: Duration) -> Option<Lease> {
+        self.try_lease(name, ttl).await.ok().flatten()
+    }
+
+    /// Like `lease`, but an unreachable Redis is an error rather than a lease
+    /// someone else holds.
+    async fn try_lease(&self, name: &str, ttl: Duration) -> Result<Option<Lease>, String> {
+        let key = format!("lease:{}", name);
+        let token = lease_token();
+        let taken = match &*self.backend {
+            Backend::Memory(_) => self.set_new(&key, &token, ttl).await,
+            Backend::Redis { redis, .. } => {
+                let millis = millis(ttl);
+                let reply = redis
+                    .command(&["SET", &self.key(&key), &token, "NX", "PX", &millis])
+                    .await;
+                match self.checked(reply) {
+                    Some(Reply::Nil) => false,
+                    Some(_) => true,
+                    None => return Err("the Redis cache is unreachable".to_string()),
+                }
+            }
+        };
+        Ok(taken.then(|| Lease {
+            cache: self.clone(),
+            key,
+            token,
+        }))
+    }
+
+    /// Waits up to `wait` for the lease on `name`, then holds it for at most
+    /// `ttl`. Fails at once while Redis is unreachable.
+    pub async fn lock(&self, name: &str, ttl: Duration, wait: Duration) -> Result<Lease, String> {
+        let deadline = Instant::now() + wait;
+        loop {
+            let lease = self
+                .try_lease(name, ttl)
+                .await
+                .map_err(|e| format!("Can't take the {} lock: {}", name, e))?;
+            if let Some(lease) = lease {
+                return Ok(lease);
+            }
+            if Instant::now() >= deadline {
+                return Err(format!("Timed out waiting for the {} lock", name));
+            }
+            tokio::time::sleep(LOCK_RETRY).await;
+        }
+    }
+
+    fn key(&self, key: &str) -> String {
+        format!("{}{}", self.prefix, key)
+    }
+
+    /// The reply to a Redis command, logging the error that starts a run of
+    /// failures.
+    fn checked(&self, reply: Result<Reply, String>) -> Option<Reply> {
+        let failing = match &*self.backend {
+            Backend::Redis { failing, .. } => failing,
+            Backend::Memory(_) => return reply.ok(),
+        };
+        match reply {
+            Ok(reply) => {
+                if failing.swap(false, Ordering::Relaxed) {
+                    log::info!("Redis cache reachable again");
+                }
+                Some(reply)
+            }
+            Err(error) => {
+                if !failing.swap(true, Ordering::Relaxed) {
+                    log::error!("Redis cache unavailable: {}", error);
+                }
+                None
+            }
+        }
+    }
+}
+
+/// The right to run a job or write a document until it is released or
+/// expires.
+pub struct Lease {
+    cache: Cache,
+    key: String,
+    /// Identifies this holder, so only it can release the lease.
+    token: String,
+}
+
+impl Lease {
+    pub async fn release(self) {
+        let key = self.cache.key(&self.key);
+        match &*self.cache.backend {
+            Backend::Memory(entries) => {
+                let mut entries = entries.lock().await;
+                if entries.get(&key).map(|(token, _)| token) == Some(&self.token) {
+                    entries.remove(&key);
+                }
+            }
+            Backend::Redis { redis, .. } => {
+                let reply = redis
+                    .command(&["EVAL", RELEASE_SCRIPT, "1", &key, &self.token])
+                    .await;
+                self.cache.checked(reply);
+            }
+        }
+    }
+}
+
+/// A value no other lease holder, in this process or another, will use.
+fn lease_token() -> String {
+    static NEXT: AtomicU64 = AtomicU64::new(0);
+    let nanos = SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map(|d| d.as_nanos())
+        .unwrap_or_default();
+    format!(
+        "{}-{}-{}",
+        process::id(),
+        nanos,
+        NEXT.fetch_add(1, Ordering::Relaxed)
+    )
+}
+
+fn insert(
+    entries: &mut HashMap<String, (String, Instant)>,
+    key: String,
+    value: &str,
+    ttl: Duration,
+) {
+    let now = Instant::now();
+    if entries.len() >= SWEEP_THRESHOLD {
+        entries.retain(|_, (_, expires)| *expires > now);
+    }
+    entries.insert(key, (value.to_string(), now + ttl));
+}
+
+/// `ttl` in whole milliseconds for Redis, which refuses an expiry of zero.
+fn millis(ttl: Duration) -> String {
+    ttl.as_millis().max(1).to_string()
+}
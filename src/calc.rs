@@ -0,0 +1,247 @@
+use crate::{
+    amount,
+    pricing::{PriceType, RateCard},
+};
+
+const MAX_TERMS: usize = 20;
+
+/// One term of a calculation: a Robux amount priced before or after tax, or a
+/// percentage of the running total.
+pub enum Term {
+    Robux { robux: f64, price_type: PriceType },
+    Percent(f64),
+}
+
+pub struct Item {
+    pub negative: bool,
+    pub term: Term,
+}
+
+/// An itemized line of an evaluated calculation.
+pub struct Line {
+    pub label: String,
+    pub gbp: f64,
+}
+
+enum Token {
+    Plus,
+    Minus,
+    Number(f64),
+    Percent,
+    Word(String),
+}
+
+/// Parses expressions such as `10k a/t + 5000 b/t - 10%`. Robux amounts default
+/// to before tax; a percentage applies to the total of the terms before it.
+pub fn parse(expression: &str) -> Result<Vec<Item>, String> {
+    let mut tokens = tokenize(expression)?.into_iter().peekable();
+    let mut items = Vec::new();
+    let mut negative = false;
+
+    if let Some(Token::Minus) = tokens.peek() {
+        return Err("The expression cannot start with a subtraction".to_string());
+    }
+    if let Some(Token::Plus) = tokens.peek() {
+        tokens.next();
+    }
+
+    loop {
+        let value = match tokens.next() {
+            Some(Token::Number(value)) => value,
+            Some(_) => return Err("Expected a number".to_string()),
+            None => return Err("Expected a number at the end of the expression".to_string()),
+        };
+
+        tokens.next_if(|token| matches!(token, Token::Word(word) if is_robux_unit(word)));
+        let term = match tokens.next_if(|token| matches!(token, Token::Percent | Token::Word(_))) {
+            Some(Token::Word(word)) => Term::Robux {
+                robux: value,
+                price_type: PriceType::parse(&word)
+                    .ok_or_else(|| format!("Unknown price type '{}'. Use b/t or a/t.", word))?,
+            },
+            Some(_) => Term::Percent(value),
+            None => Term::Robux {
+                robux: value,
+                price_type: PriceType::BeforeTax,
+            },
+        };
+
+        match term {
+            Term::Percent(_) if items.is_empty() => {
+                return Err("A percentage needs an amount before it".to_string());
+            }
+            Term::Percent(percent) if !(percent > 0.0 && percent <= 100.0) => {
+                return Err("Percentages must be between 0% and 100%".to_string());
+            }
+            Term::Robux { robux, .. } => {
+                amount::validate_whole_robux(amount::validate_robux(robux)?)?;
+            }
+            _ => {}
+        }
+        items.push(Item { negative, term });
+        if items.len() > MAX_TERMS {
+            return Err(format!("Expressions are limited to {} terms", MAX_TERMS));
+        }
+
+        negative = match tokens.next() {
+            Some(Token::Plus) => false,
+            Some(Token::Minus) => true,
+            Some(_) => return Err("Expected + or - between terms".to_string()),
+            None => return Ok(items),
+        };
+    }
+}
+
+/// Prices each item in GBP from `card`, returning the itemized lines and the total.
+pub fn evaluate(items: &[Item], card: &RateCard) -> (Vec<Line>, f64) {
+    let mut lines = Vec::new();
+    let mut total = 0.0;
+
+    for item in items {
+        let sign = if item.negative { -1.0 } else { 1.0 };
+        let (label, gbp) = match item.term {
+            Term::Robux { robux, price_type } => (
+                format!("{} R$ {}", robux, price_type.label()),
+                card.gbp_price(robux, price_type),
+            ),
+            Term::Percent(percent) => (format!("{}%", percent), total * percent / 100.0),
+        };
+
+        total += sign * gbp;
+        lines.push(Line {
+            label: format!("{} {}", if item.negative { "-" } else { "+" }, label),
+            gbp: sign * gbp,
+        });
+    }
+
+    (lines, total)
+}
+
+fn is_robux_unit(word: &str) -> bool {
+    matches!(word.to_lowercase().as_str(), "r$" | "rbx" | "robux")
+}
+
+fn tokenize(expression: &str) -> Result<Vec<Token>, String> {
+    let mut tokens = Vec::new();
+    let mut chars = expression.chars().peekable();
+
+    while let Some(&c) = chars.peek() {
+        match c {
+            c if c.is_whitespace() => {
+                chars.next();
+            }
+            '+' => {
+                chars.next();
+                tokens.push(Token::Plus);
+            }
+            '-' => {
+                chars.next();
+                tokens.push(Token::Minus);
+            }
+            '%' => {
+                chars.next();
+                tokens.push(Token::Percent);
+            }
+            c if c.is_ascii_digit() || c == '.' => {
+                let mut number = String::new();
+                while let Some(&c) = chars.peek() {
+                    if c.is_ascii_digit() || c == '.' {
+                        number.push(c);
+                    } else if c != ',' && c != '_' {
+                        break;
+                    }
+                    chars.next();
+                }
+                let mut value = number
+                    .parse::<f64>()
+                    .map_err(|_| format!("Invalid number '{}'", number))?;
+
+                // A shorthand suffix directly after the number, as in `1.5k`.
+                let mut rest = chars.clone();
+                if let Some(multiplier) = rest.next().and_then(amount::multiplier) {
+                    if !rest
+                        .peek()
+                        .is_some_and(|c| c.is_alphanumeric() || *c == '/')
+                    {
+                        value *= multiplier;
+                        chars.next();
+                    }
+                }
+                tokens.push(Token::Number(value));
+            }
+            c if c.is_alphabetic() => {
+                let mut word = String::new();
+                while let Some(&c) = chars.peek() {
+                    if !c.is_alphanumeric() && c != '/' && c != '$' {
+                        break;
+                    }
+                    word.push(c);
+                    chars.next();
+                }
+                tokens.push(Token::Word(word));
+            }
+            c => return Err(format!("Unexpected character '{}'", c)),
+        }
+    }
+
+    Ok(tokens)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn robux(item: &Item) -> (bool, f64, PriceType) {
+        match item.term {
+            Term::Robux { robux, price_type } => (item.negative, robux, price_type),
+            Term::Percent(_) => panic!("expected a Robux term"),
+        }
+    }
+
+    #[test]
+    fn terms_keep_their_sign_price_type_and_suffix() {
+        let items = parse("10k a/t + 5,000 b/t - 1.5k robux").unwrap();
+        assert_eq!(items.len(), 3);
+        assert!(robux(&items[0]) == (false, 10_000.0, PriceType::AfterTax));
+        assert!(robux(&items[1]) == (false, 5_000.0, PriceType::BeforeTax));
+        assert!(robux(&items[2]) == (true, 1_500.0, PriceType::BeforeTax));
+    }
+
+    #[test]
+    fn percentages_follow_an_amount() {
+        let items = parse("1000 - 10%").unwrap();
+        assert!(matches!(items[1].term, Term::Percent(percent) if percent == 10.0));
+        assert!(items[1].negative);
+        assert!(parse("10% + 1000").is_err());
+        assert!(parse("1000 + 0%").is_err());
+        assert!(parse("1000 + 101%").is_err());
+    }
+
+    #[test]
+    fn robux_terms_use_the_amount_rules() {
+        assert!(parse("100").is_ok());
+        assert!(parse("1m").is_ok());
+        assert!(parse("99").is_err());
+        assert!(parse("1000001").is_err());
+        assert!(parse("150.5").is_err());
+        assert!(parse("1000 + 100.25 a/t").is_err());
+    }
+
+    #[test]
+    fn malformed_expressions_are_rejected() {
+        for expression in [
+            "",
+            "- 1000",
+            "1000 +",
+            "1000 1000",
+            "1000 x/t",
+            "1000 + + 1000",
+            "1000 * 2",
+            "1.2.3k",
+        ] {
+            assert!(parse(expression).is_err(), "{:?} parsed", expression);
+        }
+        let too_many = vec!["100"; MAX_TERMS + 1].join(" + ");
+        assert!(parse(&too_many).is_err());
+    }
+}
@@ -0,0 +1,78 @@
+use crate::{
+    rates::RateSnapshot,
+    store::{self, JsonStore, Partition},
+};
+use serde::{Deserialize, Serialize};
+use serenity::prelude::*;
+use std::sync::Arc;
+
+const CALCULATIONS_FILE: &str = "calculations.json";
+
+/// A conversion answered by the bot, with the exact rates it used, so it can
+/// be reproduced if a buyer disputes it later. `/price` quotes are recorded as
+/// orders instead.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct Calculation {
+    pub id: u64,
+    #[serde(default)]
+    pub guild_id: Option<u64>,
+    pub user_id: u64,
+    /// The command that was run, e.g. `convert`.
+    pub command: String,
+    /// What was asked, e.g. `25 GBP`.
+    pub input: String,
+    /// What the bot answered, e.g. `31.78 USD`.
+    pub result: String,
+    pub rates: Vec<RateSnapshot>,
+    pub at: u64,
+}
+
+#[derive(Serialize, Deserialize, Default)]
+struct CalculationLog {
+    next_id: u64,
+    calculations: Vec<Calculation>,
+}
+
+/// Every conversion answered, persisted in the data directory.
+pub struct CalculationStore {
+    log: JsonStore<CalculationLog>,
+}
+
+impl CalculationStore {
+    pub fn open(partition: &Partition) -> Result<Self, String> {
+        Ok(Self {
+            log: JsonStore::open(partition, CALCULATIONS_FILE)?,
+        })
+    }
+
+    /// Numbers and timestamps `calculation`, then persists it.
+    pub async fn record(&self, calculation: Calculation) -> Result<Calculation, String> {
+        self.log
+            .update(|log| {
+                log.next_id += 1;
+                let calculation = Calculation {
+                    id: log.next_id,
+                    at: store::now(),
+                    ..calculation
+                };
+                log.calculations.push(calculation.clone());
+                calculation
+            })
+            .await
+    }
+}
+
+pub struct CalculationsKey;
+
+impl TypeMapKey for CalculationsKey {
+    type Value = Arc<CalculationStore>;
+}
+
+pub async fn store(ctx: &Context) -> Result<Arc<CalculationStore>, String> {
+    ctx.data
+        .read()
+        .await
+        .get::<CalculationsKey>()
+        .cloned()
+        .ok_or_else(|| "Calculation log unavailable".to_string())
+}
@@ -0,0 +1,231 @@
+/// Width and height of a glyph in the built-in font, in font pixels.
+const GLYPH_WIDTH: u32 = 5;
+const GLYPH_HEIGHT: u32 = 7;
+/// Glyph width plus the gap before the next glyph.
+const GLYPH_ADVANCE: u32 = GLYPH_WIDTH + 1;
+const PNG_SIGNATURE: &[u8] = &[0x89, b'P', b'N', b'G', b'\r', b'\n', 0x1a, b'\n'];
+/// Largest block a stored (uncompressed) deflate block can hold.
+const MAX_STORED_BLOCK: usize = 0xffff;
+
+/// A palette image drawn with flat rectangles and a built-in bitmap font, and
+/// encoded as PNG without any image dependencies. Enough for tables and cards;
+/// not a general-purpose renderer.
+pub struct Canvas {
+    width: u32,
+    height: u32,
+    palette: Vec<u32>,
+    pixels: Vec<u8>,
+}
+
+impl Canvas {
+    /// A blank canvas filled with `background`, given as `0xRRGGBB`.
+    pub fn new(width: u32, height: u32, background: u32) -> Self {
+        Self {
+            width,
+            height,
+            palette: vec![background],
+            pixels: vec![0; (width * height) as usize],
+        }
+    }
+
+    /// The palette index for `rgb`, adding it on first use. Palettes hold 256
+    /// colors; past that the last color is reused.
+    fn color(&mut self, rgb: u32) -> u8 {
+        match self.palette.iter().position(|&color| color == rgb) {
+            Some(index) => index as u8,
+            None if self.palette.len() < 256 => {
+                self.palette.push(rgb);
+                (self.palette.len() - 1) as u8
+            }
+            None => 255,
+        }
+    }
+
+    /// Fills a rectangle, clipped to the canvas.
+    pub fn fill_rect(&mut self, x: u32, y: u32, width: u32, height: u32, rgb: u32) {
+        let color = self.color(rgb);
+        for row in y..(y + height).min(self.height) {
+            let start = (row * self.width + x.min(self.width)) as usize;
+            let end = (row * self.width + (x + width).min(self.width)) as usize;
+            self.pixels[start..end].fill(color);
+        }
+    }
+
+    /// Draws `text` with its top-left corner at `x`, `y`, each font pixel
+    /// `scale` pixels square. Lowercase letters are drawn as capitals and
+    /// characters the font lacks as `?`.
+    pub fn text(&mut self, x: u32, y: u32, text: &str, scale: u32, rgb: u32) {
+        for (index, c) in text.chars().enumerate() {
+            let left = x + index as u32 * GLYPH_ADVANCE * scale;
+            for (row, bits) in glyph(c).iter().enumerate() {
+                for column in 0..GLYPH_WIDTH {
+                    if bits & (1 << (GLYPH_WIDTH - 1 - column)) != 0 {
+                        self.fill_rect(
+                            left + column * scale,
+                            y + row as u32 * scale,
+                            scale,
+                            scale,
+                            rgb,
+                        );
+                    }
+                }
+            }
+        }
+    }
+
+    /// Encodes the canvas as an 8-bit palette PNG.
+    pub fn png(&self) -> Vec<u8> {
+        let mut header = Vec::with_capacity(13);
+        header.extend_from_slice(&self.width.to_be_bytes());
+        header.extend_from_slice(&self.height.to_be_bytes());
+        // Bit depth 8, color type 3 (palette), default compression, filter
+        // and interlacing.
+        header.extend_from_slice(&[8, 3, 0, 0, 0]);
+
+        let palette: Vec<u8> = self
+            .palette
+            .iter()
+            .flat_map(|rgb| [(rgb >> 16) as u8, (rgb >> 8) as u8, *rgb as u8])
+            .collect();
+
+        // Each scanline starts with its filter type, 0 for none.
+        let mut scanlines = Vec::with_capacity(self.pixels.len() + self.height as usize);
+        for row in self.pixels.chunks(self.width as usize) {
+            scanlines.push(0);
+            scanlines.extend_from_slice(row);
+        }
+
+        let mut png = PNG_SIGNATURE.to_vec();
+        write_chunk(&mut png, b"IHDR", &header);
+        write_chunk(&mut png, b"PLTE", &palette);
+        write_chunk(&mut png, b"IDAT", &zlib_stored(&scanlines));
+        write_chunk(&mut png, b"IEND", &[]);
+        png
+    }
+}
+
+/// The width in pixels `text` takes up when drawn at `scale`.
+pub fn text_width(text: &str, scale: u32) -> u32 {
+    match text.chars().count() as u32 {
+        0 => 0,
+        chars => (chars * GLYPH_ADVANCE - 1) * scale,
+    }
+}
+
+/// The height in pixels of a line of text drawn at `scale`.
+pub fn text_height(scale: u32) -> u32 {
+    GLYPH_HEIGHT * scale
+}
+
+fn write_chunk(png: &mut Vec<u8>, kind: &[u8; 4], data: &[u8]) {
+    png.extend_from_slice(&(data.len() as u32).to_be_bytes());
+    let start = png.len();
+    png.extend_from_slice(kind);
+    png.extend_from_slice(data);
+    let crc = crc32(&png[start..]);
+    png.extend_from_slice(&crc.to_be_bytes());
+}
+
+/// Wraps `data` in a zlib stream of stored deflate blocks. The images are
+/// mostly flat color, so this trades size for not needing a compressor.
+fn zlib_stored(data: &[u8]) -> Vec<u8> {
+    let mut stream = vec![0x78, 0x01];
+    let mut blocks = data.chunks(MAX_STORED_BLOCK).peekable();
+    if blocks.peek().is_none() {
+        stream.extend_from_slice(&[1, 0, 0, 0xff, 0xff]);
+    }
+    while let Some(block) = blocks.next() {
+        let last = blocks.peek().is_none();
+        let len = block.len() as u16;
+        stream.push(last as u8);
+        stream.extend_from_slice(&len.to_le_bytes());
+        stream.extend_from_slice(&(!len).to_le_bytes());
+        stream.extend_from_slice(block);
+    }
+    stream.extend_from_slice(&adler32(data).to_be_bytes());
+    stream
+}
+
+fn crc32(data: &[u8]) -> u32 {
+    !data.iter().fold(!0u32, |crc, &byte| {
+        (0..8).fold(crc ^ byte as u32, |crc, _| {
+            if crc & 1 != 0 {
+                (crc >> 1) ^ 0xedb88320
+            } else {
+                crc >> 1
+            }
+        })
+    })
+}
+
+fn adler32(data: &[u8]) -> u32 {
+    let (a, b) = data.iter().fold((1u32, 0u32), |(a, b), &byte| {
+        let a = (a + byte as u32) % 65521;
+        (a, (b + a) % 65521)
+    });
+    (b << 16) | a
+}
+
+/// Rows of a 5x7 glyph, top to bottom, with the leftmost pixel in bit 4.
+fn glyph(c: char) -> [u8; 7] {
+    match c.to_ascii_uppercase() {
+        ' ' => [0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00],
+        '0' => [0x0e, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0e],
+        '1' => [0x04, 0x0c, 0x04, 0x04, 0x04, 0x04, 0x0e],
+        '2' => [0x0e, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1f],
+        '3' => [0x1f, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0e],
+        '4' => [0x02, 0x06, 0x0a, 0x12, 0x1f, 0x02, 0x02],
+        '5' => [0x1f, 0x10, 0x1e, 0x01, 0x01, 0x11, 0x0e],
+        '6' => [0x06, 0x08, 0x10, 0x1e, 0x11, 0x11, 0x0e],
+        '7' => [0x1f, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08],
+        '8' => [0x0e, 0x11, 0x11, 0x0e, 0x11, 0x11, 0x0e],
+        '9' => [0x0e, 0x11, 0x11, 0x0f, 0x01, 0x02, 0x0c],
+        'A' => [0x0e, 0x11, 0x11, 0x11, 0x1f, 0x11, 0x11],
+        'B' => [0x1e, 0x11, 0x11, 0x1e, 0x11, 0x11, 0x1e],
+        'C' => [0x0e, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0e],
+        'D' => [0x1c, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1c],
+        'E' => [0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x1f],
+        'F' => [0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x10],
+        'G' => [0x0e, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0f],
+        'H' => [0x11, 0x11, 0x11, 0x1f, 0x11, 0x11, 0x11],
+        'I' => [0x0e, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0e],
+        'J' => [0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0c],
+        'K' => [0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11],
+        'L' => [0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1f],
+        'M' => [0x11, 0x1b, 0x15, 0x15, 0x11, 0x11, 0x11],
+        'N' => [0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11],
+        'O' => [0x0e, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e],
+        'P' => [0x1e, 0x11, 0x11, 0x1e, 0x10, 0x10, 0x10],
+        'Q' => [0x0e, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0d],
+        'R' => [0x1e, 0x11, 0x11, 0x1e, 0x14, 0x12, 0x11],
+        'S' => [0x0f, 0x10, 0x10, 0x0e, 0x01, 0x01, 0x1e],
+        'T' => [0x1f, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04],
+        'U' => [0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e],
+        'V' => [0x11, 0x11, 0x11, 0x11, 0x11, 0x0a, 0x04],
+        'W' => [0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0a],
+        'X' => [0x11, 0x11, 0x0a, 0x04, 0x0a, 0x11, 0x11],
+        'Y' => [0x11, 0x11, 0x11, 0x0a, 0x04, 0x04, 0x04],
+        'Z' => [0x1f, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1f],
+        '.' => [0x00, 0x00, 0x00, 0x00, 0x00, 0x0c, 0x0c],
+        ',' => [0x00, 0x00, 0x00, 0x00, 0x0c, 0x04, 0x08],
+        ':' => [0x00, 0x0c, 0x0c, 0x00, 0x0c, 0x0c, 0x00],
+        '/' => [0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00],
+        '%' => [0x18, 0x19, 0x02, 0x04, 0x08, 0x13, 0x03],
+        '$' => [0x04, 0x0f, 0x14, 0x0e, 0x05, 0x1e, 0x04],
+        '£' => [0x06, 0x09, 0x08, 0x1c, 0x08, 0x08, 0x1f],
+        '€' => [0x07, 0x08, 0x1e, 0x08, 0x1e, 0x08, 0x07],
+        '-' => [0x00, 0x00, 0x00, 0x1f, 0x00, 0x00, 0x00],
+        '+' => [0x00, 0x04, 0x04, 0x1f, 0x04, 0x04, 0x00],
+        '=' => [0x00, 0x00, 0x1f, 0x00, 0x1f, 0x00, 0x00],
+        '(' => [0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02],
+        ')' => [0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08],
+        '\'' => [0x0c, 0x04, 0x08, 0x00, 0x00, 0x00, 0x00],
+        '&' => [0x0c, 0x12, 0x14, 0x08, 0x15, 0x12, 0x0d],
+        '#' => [0x0a, 0x0a, 0x1f, 0x0a, 0x1f, 0x0a, 0x0a],
+        '@' => [0x0e, 0x11, 0x01, 0x0d, 0x15, 0x15, 0x0e],
+        '!' => [0x04, 0x04, 0x04, 0x04, 0x04, 0x00, 0x04],
+        '_' => [0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1f],
+        '•' => [0x00, 0x00, 0x0e, 0x0e, 0x0e, 0x00, 0x00],
+        _ => [0x0e, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04],
+    }
+}
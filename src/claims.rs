@@ -0,0 +1,82 @@
+use crate::{cache::Cache, orders, outbound, settings::SettingsKey, store};
+use serenity::{
+    builder::CreateEmbed,
+    model::id::{GuildId, UserId},
+    prelude::*,
+};
+use std::time::Duration;
+
+/// How often claims are checked for inactivity.
+const CHECK_INTERVAL_SECS: u64 = 600;
+
+/// Starts releasing orders whose claim has gone stale, so they show up as
+/// unassigned again. Call it once per bot; instances sharing `cache` take
+/// turns.
+pub fn start(ctx: Context, cache: Cache) {
+    tokio::spawn(async move {
+        let interval = Duration::from_secs(CHECK_INTERVAL_SECS);
+        loop {
+            if cache.lease("claims", interval).await.is_some() {
+                if let Err(error) = release_stale(&ctx).await {
+                    log::error!("Error releasing stale claims: {}", error);
+                }
+            }
+            tokio::time::sleep(interval).await;
+        }
+    });
+}
+
+async fn release_stale(ctx: &Context) -> Result<(), String> {
+    let settings = match ctx.data.read().await.get::<SettingsKey>().cloned() {
+        Some(settings) => settings,
+        None => return Ok(()),
+    };
+    let released = {
+        let settings = settings.read().await;
+        orders::store(ctx)
+            .await?
+            .unclaim_stale(store::now(), |guild_id| {
+                settings.guild(guild_id.map(GuildId)).claim_timeout_secs()
+            })
+            .await?
+    };
+
+    for order in released {
+        let staff_id = match order.claimed_by {
+            Some(staff_id) => staff_id,
+            None => continue,
+        };
+        let embed = CreateEmbed::default()
+            .title(format!("Order #{} Unclaimed", order.id))
+            .description(format!(
+                "Nothing happened on <@{}>'s order for {} R$ since <t:{}:R>, so it's back in the queue. \
+                 Claim it again with `/order claim id:{}` if you're still on it.",
+                order.buyer_id,
+                order.robux,
+                order.last_activity(),
+                order.id
+            ))
+            .color(0xFFA500)
+            .clone();
+
+        let result = match UserId(staff_id).create_dm_channel(&ctx.http).await {
+            Ok(channel) => outbound::send(&ctx.http, || {
+                channel
+                    .id
+                    .send_message(&ctx.http, |message| message.set_embed(embed.clone()))
+            })
+            .await
+            .map(|_| ()),
+            Err(error) => Err(error),
+        };
+        if let Err(error) = result {
+            log::error!(
+                "Error telling {} order #{} was unclaimed: {:?}",
+                staff_id,
+                order.id,
+                error
+            );
+        }
+    }
+    Ok(())
+}
@@ -0,0 +1,1829 @@
+use crate::{
+    explain, i18n, MAX_FX_MARGIN_PERCENT, MAX_MARKUP_PERCENT, MAX_PAYMENT_FEE_GBP,
+    MAX_PAYMENT_SURCHARGE_PERCENT,
+};
+use serenity::{
+    builder::{CreateApplicationCommand, CreateApplicationCommandOption},
+    model::{application::command::CommandOptionType, permissions::Permissions},
+};
+
+/// Who may run a command.
+#[derive(Clone, Copy, PartialEq, Eq)]
+pub enum Access {
+    Everyone,
+    /// Members with the Manage Server permission.
+    ManageGuild,
+    /// The user configured in `OWNER_ID`.
+    Owner,
+}
+
+impl Access {
+    pub fn label(self, locale: &str) -> &'static str {
+        let (key, label) = match self {
+            Access::Everyone => ("access.everyone", "Everyone"),
+            Access::ManageGuild => ("access.manage_guild", "Members with Manage Server"),
+            Access::Owner => ("access.owner", "Bot owner only"),
+        };
+        i18n::t_or(locale, key, label)
+    }
+}
+
+pub struct OptionSpec {
+    pub name: &'static str,
+    pub description: &'static str,
+    pub kind: CommandOptionType,
+    pub required: bool,
+    pub choices: &'static [(&'static str, &'static str)],
+    pub min: Option<f64>,
+    pub max: Option<f64>,
+    /// Options of a subcommand.
+    pub options: &'static [OptionSpec],
+}
+
+impl OptionSpec {
+    const fn new(name: &'static str, description: &'static str, kind: CommandOptionType) -> Self {
+        Self {
+            name,
+            description,
+            kind,
+            required: false,
+            choices: &[],
+            min: None,
+            max: None,
+            options: &[],
+        }
+    }
+
+    const fn required(self) -> Self {
+        Self {
+            required: true,
+            ..self
+        }
+    }
+
+    const fn choices(self, choices: &'static [(&'static str, &'static str)]) -> Self {
+        Self { choices, ..self }
+    }
+
+    const fn range(self, min: f64, max: f64) -> Self {
+        Self {
+            min: Some(min),
+            max: Some(max),
+            ..self
+        }
+    }
+
+    const fn options(self, options: &'static [OptionSpec]) -> Self {
+        Self { options, ..self }
+    }
+
+    /// The catalog key prefix of this option under the command or subcommand
+    /// key `parent`.
+    pub fn key(&self, parent: &str) -> String {
+        format!("{}.options.{}", parent, self.name)
+    }
+
+    pub fn localized_name(&self, parent: &str, locale: &str) -> &'static str {
+        localized(&self.key(parent), "name", locale, self.name)
+    }
+
+    pub fn localized_description(&self, parent: &str, locale: &str) -> &'static str {
+        localized(&self.key(parent), "description", locale, self.description)
+    }
+
+    fn register<'a>(
+        &self,
+        parent: &str,
+        option: &'a mut CreateApplicationCommandOption,
+    ) -> &'a mut CreateApplicationCommandOption {
+        let key = self.key(parent);
+        option
+            .name(self.name)
+            .description(self.description)
+            .kind(self.kind)
+            .required(self.required);
+        for (locale, name) in i18n::translations(&format!("{}.name", key)) {
+            option.name_localized(locale, name);
+        }
+        for (locale, description) in i18n::translations(&format!("{}.description", key)) {
+            option.description_localized(locale, description);
+        }
+        for (name, value) in self.choices {
+            option.add_string_choice(name, value);
+        }
+        let integer = self.kind == CommandOptionType::Integer;
+        match self.min {
+            Some(min) if integer => option.min_int_value(min as i64),
+            Some(min) => option.min_number_value(min),
+            None => option,
+        };
+        match self.max {
+            Some(max) if integer => option.max_int_value(max as i64),
+            Some(max) => option.max_number_value(max),
+            None => option,
+        };
+        for sub_option in self.options {
+            option.create_sub_option(|option| sub_option.register(&key, option));
+        }
+        option
+    }
+}
+
+/// A slash command's definition. Registration and `/help` are both generated
+/// from this, so the help pages always describe what Discord actually shows.
+pub struct CommandSpec {
+    pub name: &'static str,
+    pub description: &'static str,
+    pub access: Access,
+    pub guild_only: bool,
+    pub options: &'static [OptionSpec],
+    pub examples: &'static [&'static str],
+}
+
+impl CommandSpec {
+    /// The catalog key prefix of this command, e.g. `commands.price`.
+    pub fn key(&self) -> String {
+        format!("commands.{}", self.name)
+    }
+
+    pub fn localized_name(&self, locale: &str) -> &'static str {
+        localized(&self.key(), "name", locale, self.name)
+    }
+
+    pub fn localized_description(&self, locale: &str) -> &'static str {
+        localized(&self.key(), "description", locale, self.description)
+    }
+
+    /// Whether server managers may turn the command off with `/serverconfig`.
+    /// Owner commands control the whole bot, so they stay available everywhere.
+    pub fn can_disable(&self) -> bool {
+        self.access != Access::Owner && self.name != "serverconfig"
+    }
+
+    /// Registers the command with a name and description localization for every
+    /// catalog that translates them. Interactions still carry the canonical
+    /// names, so dispatch is unaffected.
+    pub fn register<'a>(
+        &self,
+        command: &'a mut CreateApplicationCommand,
+    ) -> &'a mut CreateApplicationCommand {
+        let key = self.key();
+        command.name(self.name).description(self.description);
+        for (locale, name) in i18n::translations(&format!("{}.name", key)) {
+            command.name_localized(locale, name);
+        }
+        for (locale, description) in i18n::translations(&format!("{}.description", key)) {
+            command.description_localized(locale, description);
+        }
+        if self.access == Access::ManageGuild {
+            command.default_member_permissions(Permissions::MANAGE_GUILD);
+        }
+        if self.guild_only {
+            command.dm_permission(false);
+        }
+        for option in self.options {
+            command.create_option(|command_option| option.register(&key, command_option));
+        }
+        command
+    }
+}
+
+/// The translation of `key.field` for `locale`, or `default` if there is none.
+fn localized(key: &str, field: &str, locale: &str, default: &'static str) -> &'static str {
+    i18n::t_or(locale, &format!("{}.{}", key, field), default)
+}
+
+const CURRENCY_CHOICES: &[(&str, &str)] = &[("GBP", "GBP"), ("USD", "USD")];
+
+pub static COMMANDS: &[CommandSpec] = &[
+    CommandSpec {
+        name: "help",
+        description: "Display the available commands and their usage",
+        access: Access::Everyone,
+        guild_only: false,
+        options: &[],
+        examples: &["/help"],
+    },
+    CommandSpec {
+        name: "price",
+        description: "Calculate the price of an amount of Robux in GBP, USD or your own currency",
+        access: Access::Everyone,
+        guild_only: false,
+        options: &[
+            OptionSpec::new(
+                "type",
+                "Conversion type (b/t or a/t)",
+                CommandOptionType::String,
+            )
+            .required()
+            .choices(&[("b/t", "b/t"), ("a/t", "a/t")]),
+            OptionSpec::new(
+                "amount",
+                "Amount of Robux, e.g. 1500 or 1.5k",
+                CommandOptionType::String,
+            )
+            .required(),
+            OptionSpec::new(
+                "seller",
+                "Seller whose rate card to use (see /seller list)",
+                CommandOptionType::String,
+            ),
+            OptionSpec::new(
+                "method",
+                "How the Robux are delivered (defaults to gamepass)",
+                CommandOptionType::String,
+            )
+            .choices(&[
+                ("Gamepass", "gamepass"),
+                ("Group payout", "group"),
+                ("Gift", "gift"),
+            ]),
+            OptionSpec::new(
+                "currency",
+                "Also show the price in this currency, e.g. EUR, € or euros",
+                CommandOptionType::String,
+            ),
+            OptionSpec::new(
+                "show_math",
+                "Show each step of the calculation",
+                CommandOptionType::Boolean,
+            ),
+            OptionSpec::new(
+                "payment",
+                "How you'll pay, e.g. PayPal, adding its fee (see /ratecard view)",
+                CommandOptionType::String,
+            ),
+        ],
+        examples: &[
+            "/price type:b/t amount:1000",
+            "/price type:a/t amount:10k currency:EUR",
+            "/price type:a/t amount:12.5k seller:Alex",
+            "/price type:a/t amount:5k method:group",
+            "/price type:a/t amount:1k show_math:True",
+            "/price type:a/t amount:5k payment:PayPal",
+        ],
+    },
+    CommandSpec {
+        name: "compare",
+        description: "Compare what an amount of Robux costs through each payment method",
+        access: Access::Everyone,
+        guild_only: true,
+        options: &[
+            OptionSpec::new(
+                "amount",
+                "Amount of Robux, e.g. 1500 or 1.5k",
+                CommandOptionType::String,
+            )
+            .required(),
+            OptionSpec::new(
+                "type",
+                "Conversion type (defaults to b/t)",
+                CommandOptionType::String,
+            )
+            .choices(&[("b/t", "b/t"), ("a/t", "a/t")]),
+        ],
+        examples: &["/compare amount:10k", "/compare amount:5000 type:a/t"],
+    },
+    CommandSpec {
+        name: "devex",
+        description: "Check whether you can cash out Robux through DevEx",
+        access: Access::Everyone,
+        guild_only: false,
+        options: &[OptionSpec::new(
+            "check",
+            "Check the DevEx requirements and estimate the payout",
+            CommandOptionType::SubCommand,
+        )
+        .options(&[
+            OptionSpec::new(
+                "earned",
+                "Earned Robux you'd cash out, e.g. 45k",
+                CommandOptionType::String,
+            )
+            .required(),
+            OptionSpec::new(
+                "roblox",
+                "Roblox username to check (defaults to your linked account)",
+                CommandOptionType::String,
+            ),
+            OptionSpec::new(
+                "over_13",
+                "Whether you're 13 or older",
+                CommandOptionType::Boolean,
+            ),
+            OptionSpec::new(
+                "verified_email",
+                "Whether your Roblox account has a verified email address",
+                CommandOptionType::Boolean,
+            ),
+            OptionSpec::new(
+                "good_standing",
+                "Whether your account is free of moderation action",
+                CommandOptionType::Boolean,
+            ),
+        ])],
+        examples: &[
+            "/devex check earned:45k",
+            "/devex check earned:120000 over_13:True verified_email:True",
+        ],
+    },
+    CommandSpec {
+        name: "limited",
+        description: "Look up a limited item's RAP and value on Rolimons in GBP and USD",
+        access: Access::Everyone,
+        guild_only: false,
+        options: &[OptionSpec::new(
+            "item",
+            "Item name, acronym or ID, e.g. Dominus Empyreus or DE",
+            CommandOptionType::String,
+        )
+        .required()],
+        examples: &["/limited item:Dominus Empyreus", "/limited item:DE"],
+    },
+    CommandSpec {
+        name: "rap",
+        description: "Total a Roblox account's limited RAP and its cash value",
+        access: Access::Everyone,
+        guild_only: false,
+        options: &[
+            OptionSpec::new(
+                "username",
+                "Roblox username (defaults to your linked account)",
+                CommandOptionType::String,
+            ),
+            OptionSpec::new(
+                "top",
+                "How many of the most valuable items to list (defaults to 10)",
+                CommandOptionType::Integer,
+            )
+            .range(1.0, 25.0),
+        ],
+        examples: &["/rap username:builderman", "/rap top:5"],
+    },
+    CommandSpec {
+        name: "tradevalue",
+        description: "Compare the value of two sides of a limited trade",
+        access: Access::Everyone,
+        guild_only: false,
+        options: &[
+            OptionSpec::new(
+                "offer",
+                "Items and Robux offered, comma-separated, e.g. 2x DE, 15k",
+                CommandOptionType::String,
+            )
+            .required(),
+            OptionSpec::new(
+                "request",
+                "Items and Robux asked for, comma-separated",
+                CommandOptionType::String,
+            )
+            .required(),
+        ],
+        examples: &[
+            "/tradevalue offer:Dominus Empyreus request:Valkyrie Helm, 50k",
+            "/tradevalue offer:2x DE request:Sparkle Time Fedora x3, 10k",
+        ],
+    },
+    CommandSpec {
+        name: "calc",
+        description: "Total a mixed-rate quote, e.g. 10000 a/t + 5000 b/t - 10%",
+        access: Access::Everyone,
+        guild_only: false,
+        options: &[OptionSpec::new(
+            "expression",
+            "Robux amounts with b/t or a/t, joined by + or -, and percentages",
+            CommandOptionType::String,
+        )
+        .required()],
+        examples: &["/calc expression:10k a/t + 5000 b/t - 10%"],
+    },
+    CommandSpec {
+        name: "convert",
+        description: "Convert between GBP and USD",
+        access: Access::Everyone,
+        guild_only: false,
+        options: &[
+            OptionSpec::new(
+                "currency",
+                "Currency to convert from (GBP or USD)",
+                CommandOptionType::String,
+            )
+            .required()
+            .choices(CURRENCY_CHOICES),
+            OptionSpec::new(
+                "amount",
+                "Amount to convert, e.g. 25 or 1.5k",
+                CommandOptionType::String,
+            )
+            .required(),
+        ],
+        examples: &["/convert currency:GBP amount:25"],
+    },
+    CommandSpec {
+        name: "robux",
+        description: "Convert an amount of money, e.g. GBP, USD or EUR, to Robux",
+        access: Access::Everyone,
+        guild_only: false,
+        options: &[
+            OptionSpec::new(
+                "currency",
+                "Currency to convert from, e.g. GBP, $ or euros",
+                CommandOptionType::String,
+            )
+            .required(),
+            OptionSpec::new(
+                "amount",
+                "Amount to convert, e.g. 25 or 1.5k",
+                CommandOptionType::String,
+            )
+            .required(),
+        ],
+        examples: &[
+            "/robux currency:USD amount:50",
+            "/robux currency:EUR amount:20",
+        ],
+    },
+    CommandSpec {
+        name: "beforetax",
+        description: "Show how much Robux a gamepass price pays out after Roblox's fee",
+        access: Access::Everyone,
+        guild_only: false,
+        options: &[
+            OptionSpec::new(
+                "robux",
+                "Gamepass price, e.g. 1429 or 1.5k",
+                CommandOptionType::String,
+            )
+            .required(),
+            OptionSpec::new(
+                "show_math",
+                "Show each step of the calculation",
+                CommandOptionType::Boolean,
+            ),
+        ],
+        examples: &["/beforetax robux:1429", "/beforetax robux:1429 show_math:True"],
+    },
+    CommandSpec {
+        name: "aftertax",
+        description: "Show the gamepass price needed to receive an amount of Robux",
+        access: Access::Everyone,
+        guild_only: false,
+        options: &[
+            OptionSpec::new(
+                "robux",
+                "Robux to receive, e.g. 1000 or 1k",
+                CommandOptionType::String,
+            )
+            .required(),
+            OptionSpec::new(
+                "show_math",
+                "Show each step of the calculation",
+                CommandOptionType::Boolean,
+            ),
+        ],
+        examples: &["/aftertax robux:1000", "/aftertax robux:1000 show_math:True"],
+    },
+    CommandSpec {
+        name: "rate",
+        description: "Show the current exchange rate and where it came from",
+        access: Access::Everyone,
+        guild_only: false,
+        options: &[OptionSpec::new(
+            "pair",
+            "Currency pair, e.g. GBP/USD or £/€",
+            CommandOptionType::String,
+        )
+        .required()],
+        examples: &[
+            "/rate pair:GBP/USD",
+            "/rate pair:eur-gbp",
+            "/rate pair:pounds to euros",
+        ],
+    },
+    CommandSpec {
+        name: "history",
+        description: "List your past orders and quotes",
+        access: Access::Everyone,
+        guild_only: false,
+        options: &[],
+        examples: &["/history"],
+    },
+    CommandSpec {
+        name: "remindme",
+        description: "Get pinged about an open quote later, with staff handling it",
+        access: Access::Everyone,
+        guild_only: true,
+        options: &[
+            OptionSpec::new(
+                "quote",
+                "The quote number from the price embed's footer",
+                CommandOptionType::Integer,
+            )
+            .required(),
+            OptionSpec::new(
+                "in",
+                "When to remind, e.g. 30m, 2h or 1d",
+                CommandOptionType::String,
+            )
+            .required(),
+        ],
+        examples: &["/remindme quote:42 in:2h"],
+    },
+    CommandSpec {
+        name: "leaderboard",
+        description: "Show the server's top buyers",
+        access: Access::Everyone,
+        guild_only: true,
+        options: &[
+            OptionSpec::new("view", "Show the top buyers", CommandOptionType::SubCommand).options(
+                &[
+                    OptionSpec::new(
+                        "by",
+                        "Rank by Robux purchased or total spend",
+                        CommandOptionType::String,
+                    )
+                    .choices(&[("Robux", "robux"), ("Spend", "spend")]),
+                    OptionSpec::new(
+                        "period",
+                        "e.g. all (default), 7d, this-month or 2024-05",
+                        CommandOptionType::String,
+                    ),
+                ],
+            ),
+            OptionSpec::new(
+                "opt-out",
+                "Hide yourself from the leaderboard",
+                CommandOptionType::SubCommand,
+            ),
+            OptionSpec::new(
+                "opt-in",
+                "Show yourself on the leaderboard again",
+                CommandOptionType::SubCommand,
+            ),
+        ],
+        examples: &[
+            "/leaderboard view by:spend period:this-month",
+            "/leaderboard opt-out",
+        ],
+    },
+    CommandSpec {
+        name: "explain",
+        description: "Learn how b/t and a/t, Roblox's fee, group payouts and DevEx work",
+        access: Access::Everyone,
+        guild_only: false,
+        options: &[OptionSpec::new("topic", "What to explain", CommandOptionType::String)
+            .required()
+            .choices(explain::TOPICS)],
+        examples: &["/explain topic:b/t vs a/t"],
+    },
+    CommandSpec {
+        name: "about",
+        description: "Show which version of the bot is running",
+        access: Access::Everyone,
+        guild_only: false,
+        options: &[],
+        examples: &["/about"],
+    },
+    CommandSpec {
+        name: "ping",
+        description: "Show the bot's latency, uptime, memory use and exchange rate health",
+        access: Access::ManageGuild,
+        guild_only: true,
+        options: &[],
+        examples: &["/ping"],
+    },
+    CommandSpec {
+        name: "stats",
+        description: "Show statistics for this server",
+        access: Access::ManageGuild,
+        guild_only: true,
+        options: &[
+            OptionSpec::new(
+                "sales",
+                "Summarize Robux sold and revenue over a period",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "period",
+                "e.g. 7d, 30d (default), this-month, last-month, all or 2024-05",
+                CommandOptionType::String,
+            )]),
+            OptionSpec::new(
+                "service",
+                "Show buyers' ratings and recent feedback over a period",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "period",
+                "e.g. 7d, 30d (default), this-month, last-month, all or 2024-05",
+                CommandOptionType::String,
+            )]),
+        ],
+        examples: &[
+            "/stats sales period:last-month",
+            "/stats service period:all",
+        ],
+    },
+    CommandSpec {
+        name: "fxmargin",
+        description: "Set the FX margin added to the mid-market rate in conversions",
+        access: Access::ManageGuild,
+        guild_only: true,
+        options: &[OptionSpec::new(
+            "percent",
+            "Margin in percent, e.g. 1.5",
+            CommandOptionType::Number,
+        )
+        .required()
+        .range(0.0, MAX_FX_MARGIN_PERCENT)],
+        examples: &["/fxmargin percent:1.5"],
+    },
+    CommandSpec {
+        name: "setmarkup",
+        description: "Set the markup used to gross up a/t prices in this server",
+        access: Access::ManageGuild,
+        guild_only: true,
+        options: &[OptionSpec::new(
+            "percent",
+            "Markup in percent, e.g. 30",
+            CommandOptionType::Number,
+        )
+        .required()
+        .range(0.0, MAX_MARKUP_PERCENT)],
+        examples: &["/setmarkup percent:30"],
+    },
+    CommandSpec {
+        name: "setrate",
+        description: "Set the GBP price per 1,000 Robux (b/t) in this server",
+        access: Access::ManageGuild,
+        guild_only: true,
+        options: &[OptionSpec::new(
+            "rate",
+            "GBP per 1,000 Robux before tax, e.g. 3.5",
+            CommandOptionType::Number,
+        )
+        .required()
+        .range(0.01, 1000.0)],
+        examples: &["/setrate rate:3.5"],
+    },
+    CommandSpec {
+        name: "ephemeral",
+        description: "Choose whether calculation results are only visible to their requester",
+        access: Access::ManageGuild,
+        guild_only: true,
+        options: &[OptionSpec::new(
+            "enabled",
+            "Show calculation results privately",
+            CommandOptionType::Boolean,
+        )
+        .required()],
+        examples: &["/ephemeral enabled:True"],
+    },
+    CommandSpec {
+        name: "tax",
+        description: "Show tax as a separate line on prices in this server",
+        access: Access::ManageGuild,
+        guild_only: true,
+        options: &[
+            OptionSpec::new(
+                "rate",
+                "Tax rate in percent, e.g. 20; 0 disables tax lines",
+                CommandOptionType::Number,
+            )
+            .required()
+            .range(0.0, 100.0),
+            OptionSpec::new(
+                "label",
+                "Name of the tax (default VAT)",
+                CommandOptionType::String,
+            ),
+        ],
+        examples: &["/tax rate:20 label:VAT", "/tax rate:0"],
+    },
+    CommandSpec {
+        name: "rolepricing",
+        description: "Configure pricing for members with particular roles",
+        access: Access::ManageGuild,
+        guild_only: true,
+        options: &[
+            OptionSpec::new(
+                "set",
+                "Set the discount and/or currency for a role",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new("role", "Role to price", CommandOptionType::Role).required(),
+                OptionSpec::new(
+                    "discount",
+                    "Discount in percent, e.g. 5",
+                    CommandOptionType::Number,
+                )
+                .range(0.0, 99.0),
+                OptionSpec::new(
+                    "currency",
+                    "Extra currency to show prices in, e.g. EUR or €",
+                    CommandOptionType::String,
+                ),
+            ]),
+            OptionSpec::new(
+                "remove",
+                "Remove the pricing for a role",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "role",
+                "Role to remove",
+                CommandOptionType::Role,
+            )
+            .required()]),
+            OptionSpec::new(
+                "list",
+                "List the configured role pricing",
+                CommandOptionType::SubCommand,
+            ),
+        ],
+        examples: &[
+            "/rolepricing set role:@VIP discount:5",
+            "/rolepricing set role:@EU currency:EUR",
+        ],
+    },
+    CommandSpec {
+        name: "spendtiers",
+        description: "Give buyers roles as their total spend grows",
+        access: Access::ManageGuild,
+        guild_only: true,
+        options: &[
+            OptionSpec::new(
+                "set",
+                "Give a role to buyers who have spent an amount in total",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new("role", "Role for the tier", CommandOptionType::Role).required(),
+                OptionSpec::new(
+                    "spend",
+                    "Total GBP spent to reach the tier, e.g. 50",
+                    CommandOptionType::String,
+                )
+                .required(),
+            ]),
+            OptionSpec::new(
+                "remove",
+                "Remove the tier for a role",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "role",
+                "Role to remove",
+                CommandOptionType::Role,
+            )
+            .required()]),
+            OptionSpec::new(
+                "list",
+                "List the spend tiers",
+                CommandOptionType::SubCommand,
+            ),
+            OptionSpec::new(
+                "channel",
+                "Announce tier upgrades in a channel",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new(
+                    "channel",
+                    "Channel to announce upgrades in",
+                    CommandOptionType::Channel,
+                ),
+                OptionSpec::new(
+                    "enabled",
+                    "Set to False to stop announcing upgrades",
+                    CommandOptionType::Boolean,
+                ),
+            ]),
+        ],
+        examples: &[
+            "/spendtiers set role:@Bronze spend:50",
+            "/spendtiers set role:@Gold spend:200",
+            "/spendtiers channel channel:#vip-lounge",
+        ],
+    },
+    CommandSpec {
+        name: "link",
+        description: "Link your Roblox account so orders use it automatically",
+        access: Access::Everyone,
+        guild_only: false,
+        options: &[OptionSpec::new(
+            "username",
+            "Your Roblox username",
+            CommandOptionType::String,
+        )
+        .required()],
+        examples: &["/link username:Builderman"],
+    },
+    CommandSpec {
+        name: "timezone",
+        description: "Set the timezone times are written in for you, or show it",
+        access: Access::Everyone,
+        guild_only: false,
+        options: &[OptionSpec::new(
+            "offset",
+            "Your UTC offset, e.g. UTC+1 or UTC-5 ('default' for the server's)",
+            CommandOptionType::String,
+        )],
+        examples: &["/timezone offset:UTC+1", "/timezone"],
+    },
+    CommandSpec {
+        name: "order",
+        description: "Manage customer orders",
+        access: Access::ManageGuild,
+        guild_only: true,
+        options: &[
+            OptionSpec::new(
+                "create",
+                "Open an order for a buyer and check their gamepass price",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new("buyer", "Who the order is for", CommandOptionType::User)
+                    .required(),
+                OptionSpec::new(
+                    "amount",
+                    "Amount of Robux, e.g. 5000 or 5k",
+                    CommandOptionType::String,
+                )
+                .required(),
+                OptionSpec::new(
+                    "type",
+                    "Conversion type (defaults to a/t)",
+                    CommandOptionType::String,
+                )
+                .choices(&[("b/t", "b/t"), ("a/t", "a/t")]),
+                OptionSpec::new(
+                    "gamepass",
+                    "The buyer's gamepass link or ID",
+                    CommandOptionType::String,
+                ),
+                OptionSpec::new(
+                    "seller",
+                    "Seller whose rate card to use (see /seller list)",
+                    CommandOptionType::String,
+                ),
+            ]),
+            OptionSpec::new(
+                "verify",
+                "Check on Roblox that the gamepass was bought and mark the order delivered",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new("id", "Order number", CommandOptionType::Integer).required(),
+                OptionSpec::new(
+                    "roblox",
+                    "Roblox username that bought the gamepass (defaults to the seller's)",
+                    CommandOptionType::String,
+                ),
+            ]),
+            OptionSpec::new(
+                "status",
+                "Move an order to its next status, or show its timeline",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new("id", "Order number", CommandOptionType::Integer).required(),
+                OptionSpec::new("status", "New status", CommandOptionType::String).choices(&[
+                    ("Paid", "paid"),
+                    ("Delivering", "delivering"),
+                    ("Delivered", "delivered"),
+                    ("Completed", "completed"),
+                    ("Cancelled", "cancelled"),
+                ]),
+            ]),
+            OptionSpec::new(
+                "refund",
+                "Record a full or partial refund and send the buyer a receipt",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new("id", "Order number", CommandOptionType::Integer).required(),
+                OptionSpec::new(
+                    "amount",
+                    "GBP refunded (defaults to everything not yet refunded)",
+                    CommandOptionType::String,
+                ),
+                OptionSpec::new(
+                    "reason",
+                    "Why the refund was given",
+                    CommandOptionType::String,
+                ),
+            ]),
+            OptionSpec::new(
+                "confirm",
+                "Clear a high-risk order for delivery (administrators only)",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "id",
+                "Order number",
+                CommandOptionType::Integer,
+            )
+            .required()]),
+            OptionSpec::new(
+                "confirmpayment",
+                "Record a bank transfer or Cash App payment by its reference",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new("id", "Order number", CommandOptionType::Integer).required(),
+                OptionSpec::new(
+                    "reference",
+                    "Payment reference the buyer used, e.g. RBX-42-YN",
+                    CommandOptionType::String,
+                )
+                .required(),
+                OptionSpec::new("method", "How they paid", CommandOptionType::String)
+                    .choices(&[("Bank transfer", "Bank transfer"), ("Cash App", "Cash App")]),
+            ]),
+            OptionSpec::new(
+                "paylink",
+                "Send the buyer a Stripe checkout link for an order",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "id",
+                "Order number",
+                CommandOptionType::Integer,
+            )
+            .required()]),
+            OptionSpec::new(
+                "claim",
+                "Take ownership of an order",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "id",
+                "Order number",
+                CommandOptionType::Integer,
+            )
+            .required()]),
+            OptionSpec::new(
+                "unclaim",
+                "Release an order back to the queue",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "id",
+                "Order number",
+                CommandOptionType::Integer,
+            )
+            .required()]),
+            OptionSpec::new(
+                "queue",
+                "List unassigned orders and each staff member's workload",
+                CommandOptionType::SubCommand,
+            ),
+        ],
+        examples: &[
+            "/order create buyer:@Sam amount:5k",
+            "/order create buyer:@Sam amount:5k gamepass:https://www.roblox.com/game-pass/123456",
+            "/order verify id:42",
+            "/order status id:42 status:paid",
+            "/order status id:42",
+            "/order refund id:42 amount:5 reason:Delivered 1k R$ short",
+            "/order claim id:42",
+            "/order queue",
+        ],
+    },
+    CommandSpec {
+        name: "escrow",
+        description: "Track funds a middleman holds for an order",
+        access: Access::ManageGuild,
+        guild_only: true,
+        options: &[
+            OptionSpec::new(
+                "hold",
+                "Record funds held for an order and what releases them",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new("id", "Order number", CommandOptionType::Integer).required(),
+                OptionSpec::new(
+                    "holder",
+                    "Middleman holding the funds",
+                    CommandOptionType::User,
+                )
+                .required(),
+                OptionSpec::new(
+                    "amount",
+                    "Amount held (defaults to the order total)",
+                    CommandOptionType::String,
+                ),
+                OptionSpec::new(
+                    "currency",
+                    "Currency of the amount (defaults to GBP)",
+                    CommandOptionType::String,
+                )
+                .choices(CURRENCY_CHOICES),
+                OptionSpec::new(
+                    "conditions",
+                    "What has to happen before the funds are released",
+                    CommandOptionType::String,
+                ),
+            ]),
+            OptionSpec::new(
+                "release",
+                "Record that an order's funds were released",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new("id", "Order number", CommandOptionType::Integer).required(),
+                OptionSpec::new(
+                    "note",
+                    "Who the funds went to or why",
+                    CommandOptionType::String,
+                ),
+            ]),
+            OptionSpec::new(
+                "status",
+                "Show an order's escrow, or all funds currently held",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "id",
+                "Order number",
+                CommandOptionType::Integer,
+            )]),
+        ],
+        examples: &[
+            "/escrow hold id:42 holder:@Middleman conditions:Buyer confirms the Robux arrived",
+            "/escrow release id:42 note:Paid out to the seller",
+            "/escrow status",
+        ],
+    },
+    CommandSpec {
+        name: "flag",
+        description: "Mark members as a chargeback risk",
+        access: Access::ManageGuild,
+        guild_only: true,
+        options: &[
+            OptionSpec::new(
+                "add",
+                "Flag a member so their orders need an admin's confirmation",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new("user", "Member to flag", CommandOptionType::User).required(),
+                OptionSpec::new(
+                    "reason",
+                    "Why they are a risk, e.g. a past chargeback",
+                    CommandOptionType::String,
+                )
+                .required(),
+            ]),
+            OptionSpec::new(
+                "remove",
+                "Remove a member's flag",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "user",
+                "Member to unflag",
+                CommandOptionType::User,
+            )
+            .required()]),
+            OptionSpec::new(
+                "list",
+                "List the flagged members",
+                CommandOptionType::SubCommand,
+            ),
+        ],
+        examples: &[
+            "/flag add user:@someone reason:Charged back a PayPal payment",
+            "/flag remove user:@someone",
+        ],
+    },
+    CommandSpec {
+        name: "giveaway",
+        description: "Give away Robux to a random member",
+        access: Access::ManageGuild,
+        guild_only: true,
+        options: &[
+            OptionSpec::new(
+                "start",
+                "Start a giveaway in this channel",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new(
+                    "robux",
+                    "Robux the winner receives",
+                    CommandOptionType::String,
+                )
+                .required(),
+                OptionSpec::new(
+                    "duration",
+                    "How long entries are open, e.g. 30m, 2h or 1d",
+                    CommandOptionType::String,
+                )
+                .required(),
+                OptionSpec::new(
+                    "role",
+                    "Role members need to enter, e.g. Customer",
+                    CommandOptionType::Role,
+                ),
+            ]),
+            OptionSpec::new(
+                "end",
+                "End a giveaway early and draw its winner",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "id",
+                "Giveaway number",
+                CommandOptionType::Integer,
+            )
+            .required()]),
+            OptionSpec::new(
+                "reroll",
+                "Draw a new winner for an ended giveaway",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "id",
+                "Giveaway number",
+                CommandOptionType::Integer,
+            )
+            .required()]),
+        ],
+        examples: &[
+            "/giveaway start robux:1k duration:1d role:@Customer",
+            "/giveaway reroll id:3",
+        ],
+    },
+    CommandSpec {
+        name: "dispute",
+        description: "Raise and settle disagreements over orders",
+        access: Access::Everyone,
+        guild_only: true,
+        options: &[
+            OptionSpec::new(
+                "open",
+                "Open a private dispute thread for an order",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new("order", "Order number", CommandOptionType::Integer).required(),
+                OptionSpec::new("reason", "What went wrong", CommandOptionType::String).required(),
+            ]),
+            OptionSpec::new(
+                "evidence",
+                "Add a screenshot, file or statement to this dispute",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new(
+                    "file",
+                    "Screenshot or other file",
+                    CommandOptionType::Attachment,
+                ),
+                OptionSpec::new("note", "What it shows", CommandOptionType::String),
+            ]),
+            OptionSpec::new(
+                "resolve",
+                "Decide this dispute (server managers)",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new(
+                    "decision",
+                    "Who the dispute is decided in favour of",
+                    CommandOptionType::String,
+                )
+                .required()
+                .choices(&[
+                    ("Buyer", "buyer"),
+                    ("Seller", "seller"),
+                    ("Split", "split"),
+                ]),
+                OptionSpec::new(
+                    "note",
+                    "Reasoning behind the decision",
+                    CommandOptionType::String,
+                ),
+            ]),
+            OptionSpec::new(
+                "reputation",
+                "Show a member's dispute record",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "user",
+                "Member to look up (defaults to you)",
+                CommandOptionType::User,
+            )]),
+        ],
+        examples: &[
+            "/dispute open order:42 reason:Only received 4k of 5k R$",
+            "/dispute evidence file:<screenshot> note:Transaction page",
+            "/dispute resolve decision:buyer note:Screenshots show 1k R$ missing",
+            "/dispute reputation user:@Sam",
+        ],
+    },
+    CommandSpec {
+        name: "seller",
+        description: "Manage seller profiles, their rate cards and stock",
+        access: Access::ManageGuild,
+        guild_only: true,
+        options: &[
+            OptionSpec::new(
+                "set",
+                "Add a seller or change their rate card",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new("name", "Seller name, e.g. Alex", CommandOptionType::String)
+                    .required(),
+                OptionSpec::new(
+                    "rate",
+                    "GBP per 1,000 Robux before tax, e.g. 3.5",
+                    CommandOptionType::Number,
+                )
+                .required()
+                .range(0.01, 1000.0),
+                OptionSpec::new(
+                    "markup",
+                    "a/t markup in percent (defaults to the server's)",
+                    CommandOptionType::Number,
+                )
+                .range(0.0, MAX_MARKUP_PERCENT),
+                OptionSpec::new(
+                    "roblox",
+                    "Roblox username the seller buys gamepasses with",
+                    CommandOptionType::String,
+                ),
+            ]),
+            OptionSpec::new("remove", "Remove a seller", CommandOptionType::SubCommand).options(&[
+                OptionSpec::new("name", "Seller to remove", CommandOptionType::String).required(),
+            ]),
+            OptionSpec::new(
+                "stock",
+                "Add Robux to a seller's stock, or remove it with a negative amount",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new("name", "Seller", CommandOptionType::String).required(),
+                OptionSpec::new(
+                    "amount",
+                    "Robux to add, e.g. 50000 or -1200",
+                    CommandOptionType::Integer,
+                )
+                .required()
+                .range(-10_000_000.0, 10_000_000.0),
+                OptionSpec::new(
+                    "note",
+                    "What the change is for, e.g. restock",
+                    CommandOptionType::String,
+                ),
+            ]),
+            OptionSpec::new(
+                "list",
+                "List the sellers with their rates and stock",
+                CommandOptionType::SubCommand,
+            ),
+        ],
+        examples: &[
+            "/seller set name:Alex rate:3.5",
+            "/seller stock name:Alex amount:50000 note:restock",
+        ],
+    },
+    CommandSpec {
+        name: "paymentmethod",
+        description: "Manage the ways buyers can pay and the fees they add",
+        access: Access::ManageGuild,
+        guild_only: true,
+        options: &[
+            OptionSpec::new(
+                "set",
+                "Add a payment method or change its fees",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new("name", "Method name, e.g. PayPal", CommandOptionType::String)
+                    .required(),
+                OptionSpec::new(
+                    "surcharge",
+                    "Percentage added to the price, e.g. 3.4",
+                    CommandOptionType::Number,
+                )
+                .range(0.0, MAX_PAYMENT_SURCHARGE_PERCENT),
+                OptionSpec::new(
+                    "fee",
+                    "GBP added after the surcharge, e.g. 0.2",
+                    CommandOptionType::Number,
+                )
+                .range(0.0, MAX_PAYMENT_FEE_GBP),
+                OptionSpec::new(
+                    "currencies",
+                    "Currencies it takes, e.g. GBP EUR (defaults to any)",
+                    CommandOptionType::String,
+                ),
+                OptionSpec::new(
+                    "enabled",
+                    "Set to False to stop offering it (defaults to True)",
+                    CommandOptionType::Boolean,
+                ),
+            ]),
+            OptionSpec::new(
+                "remove",
+                "Remove a payment method",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "name",
+                "Payment method to remove",
+                CommandOptionType::String,
+            )
+            .required()]),
+            OptionSpec::new(
+                "list",
+                "List the payment methods with their fees",
+                CommandOptionType::SubCommand,
+            ),
+        ],
+        examples: &[
+            "/paymentmethod set name:PayPal surcharge:3.4 fee:0.2",
+            "/paymentmethod set name:Bank transfer currencies:GBP",
+            "/paymentmethod set name:Cash App enabled:False",
+        ],
+    },
+    CommandSpec {
+        name: "ratecard",
+        description: "Show this server's pricing",
+        access: Access::Everyone,
+        guild_only: true,
+        options: &[
+            OptionSpec::new(
+                "view",
+                "Show the full pricing table with sample prices",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "seller",
+                "Seller whose rate card to show (see /seller list)",
+                CommandOptionType::String,
+            )]),
+            OptionSpec::new(
+                "image",
+                "Post the rate card as an image for announcements and social posts",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "seller",
+                "Seller whose rate card to show (see /seller list)",
+                CommandOptionType::String,
+            )]),
+            OptionSpec::new(
+                "history",
+                "Browse past changes to rates, markup, tax and role pricing",
+                CommandOptionType::SubCommand,
+            ),
+            OptionSpec::new(
+                "export",
+                "Download the pricing configuration as a file (Manage Server)",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "format",
+                "File format (default JSON)",
+                CommandOptionType::String,
+            )
+            .choices(&[("JSON", "json"), ("CSV", "csv")])]),
+            OptionSpec::new(
+                "import",
+                "Load pricing from a JSON export, previewing it first (Manage Server)",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new(
+                    "file",
+                    "A file made by /ratecard export",
+                    CommandOptionType::Attachment,
+                )
+                .required(),
+                OptionSpec::new(
+                    "apply",
+                    "Save the changes; without this only a preview is shown",
+                    CommandOptionType::Boolean,
+                ),
+            ]),
+        ],
+        examples: &[
+            "/ratecard view",
+            "/ratecard view seller:Alex",
+            "/ratecard image",
+            "/ratecard history",
+            "/ratecard export format:CSV",
+            "/ratecard import file:ratecard.json",
+            "/ratecard import file:ratecard.json apply:True",
+        ],
+    },
+    CommandSpec {
+        name: "serverconfig",
+        description: "Configure the bot for this server",
+        access: Access::ManageGuild,
+        guild_only: true,
+        options: &[
+            OptionSpec::new(
+                "commands",
+                "Enable or disable a command, or list the disabled ones",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new(
+                    "command",
+                    "Command to change, e.g. price",
+                    CommandOptionType::String,
+                ),
+                OptionSpec::new(
+                    "enabled",
+                    "Whether members can use the command",
+                    CommandOptionType::Boolean,
+                ),
+            ]),
+            OptionSpec::new(
+                "cooldowns",
+                "Set how often members may use each command and who is exempt, or show it",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new(
+                    "seconds",
+                    "Seconds between uses of the same command (0 turns cooldowns off)",
+                    CommandOptionType::Integer,
+                )
+                .range(0.0, 3600.0),
+                OptionSpec::new(
+                    "role",
+                    "Role to exempt from cooldowns, e.g. staff or boosters",
+                    CommandOptionType::Role,
+                ),
+                OptionSpec::new(
+                    "bypass",
+                    "Set to False to stop exempting the role",
+                    CommandOptionType::Boolean,
+                ),
+            ]),
+            OptionSpec::new(
+                "announcements",
+                "Post rate changes to a channel, or show where they go",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new(
+                    "channel",
+                    "Channel to announce rate changes in",
+                    CommandOptionType::Channel,
+                ),
+                OptionSpec::new(
+                    "role",
+                    "Role to mention in announcements",
+                    CommandOptionType::Role,
+                ),
+                OptionSpec::new(
+                    "ping",
+                    "Whether announcements mention the role",
+                    CommandOptionType::Boolean,
+                ),
+                OptionSpec::new(
+                    "enabled",
+                    "Set to False to stop announcing rate changes",
+                    CommandOptionType::Boolean,
+                ),
+            ]),
+            OptionSpec::new(
+                "claims",
+                "Set how long a claimed order may go untouched, or show it",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "hours",
+                "Hours without activity before a claim is released",
+                CommandOptionType::Integer,
+            )
+            .range(1.0, 720.0)]),
+            OptionSpec::new(
+                "customer",
+                "Give buyers a role once an order is completed, or show which",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new(
+                    "role",
+                    "Role for buyers with a completed order",
+                    CommandOptionType::Role,
+                ),
+                OptionSpec::new(
+                    "enabled",
+                    "Set to False to stop giving the role",
+                    CommandOptionType::Boolean,
+                ),
+            ]),
+            OptionSpec::new(
+                "receipts",
+                "Set the vouch reminder and PDF invoices on receipts, or show them",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new(
+                    "vouch",
+                    "Vouch reminder; {staff} mentions who served the buyer, 'default' resets it",
+                    CommandOptionType::String,
+                ),
+                OptionSpec::new(
+                    "pdf",
+                    "Whether receipts come with a PDF invoice",
+                    CommandOptionType::Boolean,
+                ),
+            ]),
+            OptionSpec::new(
+                "currencies",
+                "Choose the currencies /price shows, or show them",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "list",
+                "Currency codes in order, e.g. GBP, USD, EUR, CAD ('default' for GBP and USD)",
+                CommandOptionType::String,
+            )]),
+            OptionSpec::new(
+                "language",
+                "Choose the language for members whose Discord language isn't translated, or show it",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "code",
+                "Language code, e.g. en-US, de, es-ES or fr ('default' for English)",
+                CommandOptionType::String,
+            )]),
+            OptionSpec::new(
+                "timezone",
+                "Set the timezone times are written in for members without their own, or show it",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "offset",
+                "UTC offset, e.g. UTC+1 or UTC-5 ('default' for UTC)",
+                CommandOptionType::String,
+            )]),
+            OptionSpec::new(
+                "payments",
+                "Choose where automatically received payments are reported, or show it",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new(
+                    "channel",
+                    "Channel payments are reported in",
+                    CommandOptionType::Channel,
+                ),
+                OptionSpec::new(
+                    "enabled",
+                    "Set to False to stop reporting payments in a channel",
+                    CommandOptionType::Boolean,
+                ),
+            ]),
+            OptionSpec::new(
+                "risk",
+                "Set when orders are marked high-risk, or show it",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new(
+                    "account_days",
+                    "Discord accounts younger than this are high-risk (0 turns it off)",
+                    CommandOptionType::Integer,
+                )
+                .range(0.0, 365.0),
+                OptionSpec::new(
+                    "first_order",
+                    "First purchases over this many GBP are high-risk (0 turns it off)",
+                    CommandOptionType::String,
+                ),
+            ]),
+            OptionSpec::new(
+                "limits",
+                "Set when Robux amounts are flagged as unusually large, or show it",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "large_amount",
+                "Amounts over this many R$ are flagged (0 turns it off)",
+                CommandOptionType::Integer,
+            )
+            .range(0.0, 1_000_000.0)]),
+            OptionSpec::new(
+                "packages",
+                "Set the Roblox packs /price shows savings against, or show them",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "list",
+                "Packs as R$=price in GBP, e.g. 400=4.99, 800=9.99 ('default' or 'off')",
+                CommandOptionType::String,
+            )]),
+            OptionSpec::new(
+                "sla",
+                "Set a delivery deadline for paid orders, or show it",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new(
+                    "hours",
+                    "Hours after payment orders must be delivered in",
+                    CommandOptionType::Integer,
+                )
+                .range(1.0, 720.0),
+                OptionSpec::new(
+                    "channel",
+                    "Channel overdue orders are escalated to",
+                    CommandOptionType::Channel,
+                ),
+                OptionSpec::new(
+                    "enabled",
+                    "Set to False to stop enforcing a deadline",
+                    CommandOptionType::Boolean,
+                ),
+            ]),
+            OptionSpec::new(
+                "identity",
+                "Look up unlinked members' Roblox accounts through Bloxlink or RoVer",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new(
+                    "provider",
+                    "Verification bot this server uses",
+                    CommandOptionType::String,
+                )
+                .choices(&[
+                    ("Bloxlink", "bloxlink"),
+                    ("RoVer", "rover"),
+                    ("None", "none"),
+                ]),
+                OptionSpec::new(
+                    "key",
+                    "The provider's API key for this server",
+                    CommandOptionType::String,
+                ),
+            ]),
+        ],
+        examples: &[
+            "/serverconfig commands command:leaderboard enabled:False",
+            "/serverconfig commands",
+            "/serverconfig announcements channel:#prices role:@Buyers ping:True",
+            "/serverconfig announcements enabled:False",
+            "/serverconfig identity provider:bloxlink key:<api key>",
+            "/serverconfig identity provider:none",
+            "/serverconfig claims hours:12",
+            "/serverconfig sla hours:48 channel:#staff-alerts",
+            "/serverconfig receipts vouch:Vouch for {staff} in #vouches pdf:True",
+            "/serverconfig customer role:@Customer",
+            "/serverconfig packages list:400=4.99, 800=9.99, 1700=19.99",
+        ],
+    },
+    CommandSpec {
+        name: "export",
+        description: "Export bot data",
+        access: Access::Owner,
+        guild_only: false,
+        options: &[OptionSpec::new(
+            "orders",
+            "Export orders and quotes as CSV",
+            CommandOptionType::SubCommand,
+        )
+        .options(&[OptionSpec::new(
+            "period",
+            "e.g. all, 7d, this-month, last-month, 2024-05 or 2024-05-01..2024-05-31",
+            CommandOptionType::String,
+        )])],
+        examples: &["/export orders period:last-month"],
+    },
+    CommandSpec {
+        name: "backup",
+        description: "Back up or restore the bot's settings and records",
+        access: Access::Owner,
+        guild_only: false,
+        options: &[
+            OptionSpec::new(
+                "create",
+                "Download an encrypted backup of settings, orders, stock and other records",
+                CommandOptionType::SubCommand,
+            ),
+            OptionSpec::new(
+                "restore",
+                "Replace the bot's data with a backup's",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[
+                OptionSpec::new(
+                    "file",
+                    "A file made by /backup create",
+                    CommandOptionType::Attachment,
+                )
+                .required(),
+                OptionSpec::new(
+                    "overwrite",
+                    "Restore even if data has changed since the backup was made",
+                    CommandOptionType::Boolean,
+                ),
+            ]),
+        ],
+        examples: &[
+            "/backup create",
+            "/backup restore file:backup.rcbackup",
+        ],
+    },
+    CommandSpec {
+        name: "metrics",
+        description: "Show how quickly and reliably each command has been answered",
+        access: Access::Owner,
+        guild_only: false,
+        options: &[],
+        examples: &["/metrics"],
+    },
+    CommandSpec {
+        name: "loglevel",
+        description: "Change how much the bot logs until it restarts",
+        access: Access::Owner,
+        guild_only: false,
+        options: &[OptionSpec::new(
+            "level",
+            "The most detailed kind of message to log",
+            CommandOptionType::String,
+        )
+        .required()
+        .choices(&[
+            ("Errors", "error"),
+            ("Warnings", "warn"),
+            ("Info", "info"),
+            ("Debug", "debug"),
+            ("Trace", "trace"),
+        ])],
+        examples: &["/loglevel level:debug"],
+    },
+    CommandSpec {
+        name: "quota",
+        description: "Show the remaining exchange API calls for each key",
+        access: Access::Owner,
+        guild_only: false,
+        options: &[],
+        examples: &["/quota"],
+    },
+    CommandSpec {
+        name: "reload",
+        description: "Re-read the configuration and re-register the slash commands",
+        access: Access::Owner,
+        guild_only: false,
+        options: &[],
+        examples: &["/reload"],
+    },
+    CommandSpec {
+        name: "shutdown",
+        description: "Disconnect from Discord and stop the bot",
+        access: Access::Owner,
+        guild_only: false,
+        options: &[],
+        examples: &["/shutdown"],
+    },
+    CommandSpec {
+        name: "maintenance",
+        description: "Answer everyone else with a maintenance notice",
+        access: Access::Owner,
+        guild_only: false,
+        options: &[
+            OptionSpec::new(
+                "on",
+                "Turn maintenance mode on",
+                CommandOptionType::SubCommand,
+            )
+            .options(&[OptionSpec::new(
+                "message",
+                "Notice shown to users, e.g. Back in 10 minutes",
+                CommandOptionType::String,
+            )]),
+            OptionSpec::new(
+                "off",
+                "Turn maintenance mode off",
+                CommandOptionType::SubCommand,
+            ),
+        ],
+        examples: &[
+            "/maintenance on message:Switching rate providers, back in 10 minutes",
+            "/maintenance off",
+        ],
+    },
+];
+
+/// Looks a command up by name, with or without the leading slash.
+pub fn find(name: &str) -> Option<&'static CommandSpec> {
+    let name = name.trim().trim_start_matches('/').to_lowercase();
+    COMMANDS.iter().find(|spec| spec.name == name)
+}
@@ -0,0 +1,220 @@
+use crate::store::{self, JsonStore, Partition};
+use ring::{
+    hmac,
+    rand::{SecureRandom, SystemRandom},
+};
+use serde::{Deserialize, Serialize};
+use std::{env, fmt::Display, str::FromStr, sync::OnceLock};
+
+const SECRET_FILE: &str = "components.json";
+/// Discord's limit on a custom ID's length.
+const MAX_CUSTOM_ID_LEN: usize = 100;
+/// Bytes of the HMAC kept in a custom ID, hex-encoded.
+const SIGNATURE_BYTES: usize = 8;
+
+static KEY: OnceLock<hmac::Key> = OnceLock::new();
+
+/// A kind of button, select menu or modal, with the payload its custom ID
+/// carries.
+pub struct ComponentSpec {
+    pub kind: &'static str,
+    /// How many `:`-separated values follow the kind.
+    pub args: usize,
+    /// Seconds a custom ID stays valid after it's made, or `None` for
+    /// components on long-lived messages.
+    pub ttl_secs: Option<u64>,
+}
+
+/// Every component the bot handles. A custom ID must be made by
+/// [`custom_id`] for one of these to be accepted.
+pub const COMPONENTS: &[ComponentSpec] = &[
+    ComponentSpec {
+        kind: "history",
+        args: 3,
+        ttl_secs: Some(86_400),
+    },
+    ComponentSpec {
+        kind: "help",
+        args: 3,
+        ttl_secs: Some(86_400),
+    },
+    ComponentSpec {
+        kind: "audit",
+        args: 3,
+        ttl_secs: Some(86_400),
+    },
+    ComponentSpec {
+        kind: "queue",
+        args: 3,
+        ttl_secs: Some(86_400),
+    },
+    ComponentSpec {
+        kind: "leaderboard",
+        args: 5,
+        ttl_secs: Some(86_400),
+    },
+    ComponentSpec {
+        kind: "link",
+        args: 1,
+        ttl_secs: Some(86_400),
+    },
+    ComponentSpec {
+        kind: "gamepass",
+        args: 1,
+        ttl_secs: None,
+    },
+    ComponentSpec {
+        kind: "feedback",
+        args: 2,
+        ttl_secs: None,
+    },
+    ComponentSpec {
+        kind: "giveaway",
+        args: 1,
+        ttl_secs: None,
+    },
+    ComponentSpec {
+        kind: "proof",
+        args: 1,
+        ttl_secs: None,
+    },
+];
+
+/// The key custom IDs are signed with, kept so buttons keep working across
+/// restarts.
+#[derive(Serialize, Deserialize, Default)]
+struct Secret {
+    secret: String,
+}
+
+/// Loads the signing key from `COMPONENT_SECRET`, or from the data directory,
+/// generating one there on first run. Instances sharing the data directory
+/// share the key.
+pub async fn init() -> Result<(), String> {
+    let secret = match env::var("COMPONENT_SECRET") {
+        Ok(secret) if !secret.is_empty() => secret,
+        _ => {
+            let store: JsonStore<Secret> = JsonStore::open(&Partition::shared(), SECRET_FILE)?;
+            let secret = store.read().await.secret.clone();
+            if secret.is_empty() {
+                let secret = hex(&random_bytes()?);
+                let saved = secret.clone();
+                store.update(|stored| stored.secret = saved).await?;
+                secret
+            } else {
+                secret
+            }
+        }
+    };
+    let _ = KEY.set(hmac::Key::new(hmac::HMAC_SHA256, secret.as_bytes()));
+    Ok(())
+}
+
+fn key() -> &'static hmac::Key {
+    KEY.get_or_init(|| {
+        // Only reached if `init` failed, so IDs won't outlive this run.
+        log::error!("Component signing key unavailable; using a temporary one");
+        let bytes = random_bytes().unwrap_or_default();
+        hmac::Key::new(hmac::HMAC_SHA256, &bytes)
+    })
+}
+
+fn random_bytes() -> Result<[u8; 32], String> {
+    let mut bytes = [0; 32];
+    SystemRandom::new()
+        .fill(&mut bytes)
+        .map_err(|_| "Error generating component signing key".to_string())?;
+    Ok(bytes)
+}
+
+fn hex(bytes: &[u8]) -> String {
+    bytes.iter().map(|byte| format!("{:02x}", byte)).collect()
+}
+
+fn spec(kind: &str) -> Option<&'static ComponentSpec> {
+    COMPONENTS.iter().find(|spec| spec.kind == kind)
+}
+
+/// A signed custom ID for a `kind` component carrying `args`:
+/// `<kind>:<args…>:<expiry>:<signature>`, with an expiry of 0 for none.
+pub fn custom_id(kind: &str, args: &[&dyn Display]) -> String {
+    let expires_at = match spec(kind) {
+        Some(spec) => spec.ttl_secs.map_or(0, |ttl| store::now() + ttl),
+        None => {
+            log::error!("Component {} isn't registered", kind);
+            0
+        }
+    };
+    let mut payload = kind.to_string();
+    for arg in args {
+        payload.push_str(&format!(":{}", arg));
+    }
+    payload.push_str(&format!(":{}", expires_at));
+    let signature = sign(&payload);
+    let custom_id = format!("{}:{}", payload, signature);
+    if custom_id.len() > MAX_CUSTOM_ID_LEN {
+        log::error!(
+            "Custom ID for {} is over {} characters",
+            kind,
+            MAX_CUSTOM_ID_LEN
+        );
+    }
+    custom_id
+}
+
+fn sign(payload: &str) -> String {
+    hex(&hmac::sign(key(), payload.as_bytes()).as_ref()[..SIGNATURE_BYTES])
+}
+
+/// A verified custom ID.
+pub struct ComponentId {
+    pub kind: &'static str,
+    args: Vec<String>,
+}
+
+impl ComponentId {
+    /// The `index`th value the ID carries.
+    pub fn arg<T: FromStr>(&self, index: usize) -> Result<T, String> {
+        self.args
+            .get(index)
+            .and_then(|arg| arg.parse().ok())
+            .ok_or_else(|| format!("Invalid {} button", self.kind))
+    }
+}
+
+/// Checks `custom_id` was made by this bot for a registered component and
+/// hasn't expired.
+pub fn parse(custom_id: &str) -> Result<ComponentId, String> {
+    let parts: Vec<&str> = custom_id.split(':').collect();
+    let spec = spec(parts[0]).ok_or_else(|| format!("Unknown component: {}", custom_id))?;
+    if parts.len() != spec.args + 3 {
+        return Err("This button is no longer valid; run the command again".to_string());
+    }
+    let (payload, signature) = custom_id.rsplit_once(':').ok_or("Invalid component")?;
+    let expected = sign(payload);
+    // Compared without stopping at the first difference, so the time
+    // taken doesn't give the signature away.
+    let valid = signature.len() == expected.len()
+        && signature
+            .bytes()
+            .zip(expected.bytes())
+            .fold(0, |diff, (a, b)| diff | (a ^ b))
+            == 0;
+    if !valid {
+        log::warn!("Rejected a tampered custom ID: {}", custom_id);
+        return Err("This button isn't valid".to_string());
+    }
+    let expires_at: u64 = parts[spec.args + 1]
+        .parse()
+        .map_err(|_| "This button isn't valid".to_string())?;
+    if expires_at != 0 && store::now() > expires_at {
+        return Err("This button has expired; run the command again".to_string());
+    }
+    Ok(ComponentId {
+        kind: spec.kind,
+        args: parts[1..=spec.args]
+            .iter()
+            .map(|part| part.to_string())
+            .collect(),
+    })
+}
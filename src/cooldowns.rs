@@ -0,0 +1,46 @@
+use crate::cache::Cache;
+use serenity::prelude::*;
+use std::{sync::Arc, time::Duration};
+
+/// When each member may next use each command, keyed by user ID and command
+/// name. Cooldowns live in the bot's cache: in memory, so a restart clears
+/// them, unless the cache is in Redis, where every instance of the bot shares
+/// them.
+pub struct Cooldowns {
+    cache: Cache,
+}
+
+impl Cooldowns {
+    pub fn new(cache: Cache) -> Self {
+        Self { cache }
+    }
+
+    /// Seconds `user_id` still has to wait before using `command` again. When
+    /// they don't have to wait, the use is recorded, starting a new cooldown of
+    /// `cooldown_secs`, and `None` is returned.
+    pub async fn check(&self, user_id: u64, command: &str, cooldown_secs: u64) -> Option<u64> {
+        let key = format!("cooldown:{}:{}", user_id, command);
+        let ttl = Duration::from_secs(cooldown_secs);
+        if self.cache.set_new(&key, "1", ttl).await {
+            return None;
+        }
+        // Rounded up, so a member isn't told to retry before they can.
+        let wait = self.cache.expires_in(&key).await?;
+        Some(wait.as_secs() + u64::from(wait.subsec_nanos() > 0))
+    }
+}
+
+pub struct CooldownsKey;
+
+impl TypeMapKey for CooldownsKey {
+    type Value = Arc<Cooldowns>;
+}
+
+pub async fn cooldowns(ctx: &Context) -> Result<Arc<Cooldowns>, String> {
+    ctx.data
+        .read()
+        .await
+        .get::<CooldownsKey>()
+        .cloned()
+        .ok_or_else(|| "Command cooldowns unavailable".to_string())
+}
@@ -0,0 +1,88 @@
+/// How a currency's amounts are written.
+pub struct Currency {
+    pub code: &'static str,
+    pub symbol: &'static str,
+    /// Digits after the decimal point, e.g. 0 for JPY and 3 for BHD.
+    pub decimals: usize,
+    /// Whether the symbol goes before the amount (`£5.00`) or after it
+    /// (`5.00 zł`).
+    pub symbol_first: bool,
+}
+
+/// Currencies buyers commonly pay in. Symbols shared by several currencies
+/// (`$`, `kr`) are only given to the one people mean by default; the others
+/// are written with a prefix or their code.
+const CURRENCIES: &[Currency] = &[
+    Currency::new("GBP", "£", 2, true),
+    Currency::new("USD", "$", 2, true),
+    Currency::new("EUR", "€", 2, true),
+    Currency::new("CAD", "C$", 2, true),
+    Currency::new("AUD", "A$", 2, true),
+    Currency::new("NZD", "NZ$", 2, true),
+    Currency::new("SGD", "S$", 2, true),
+    Currency::new("HKD", "HK$", 2, true),
+    Currency::new("MXN", "MX$", 2, true),
+    Currency::new("JPY", "¥", 0, true),
+    Currency::new("KRW", "₩", 0, true),
+    Currency::new("INR", "₹", 2, true),
+    Currency::new("PHP", "₱", 2, true),
+    Currency::new("TRY", "₺", 2, true),
+    Currency::new("PLN", "zł", 2, false),
+    Currency::new("SEK", "kr", 2, false),
+    Currency::new("NOK", "NOK", 2, false),
+    Currency::new("DKK", "DKK", 2, false),
+    Currency::new("CZK", "Kč", 2, false),
+    Currency::new("HUF", "Ft", 0, false),
+    Currency::new("VND", "₫", 0, false),
+    Currency::new("IDR", "Rp", 0, true),
+    Currency::new("CLP", "CLP", 0, false),
+    Currency::new("ISK", "ISK", 0, false),
+    Currency::new("BHD", "BHD", 3, false),
+    Currency::new("KWD", "KWD", 3, false),
+    Currency::new("OMR", "OMR", 3, false),
+    Currency::new("JOD", "JOD", 3, false),
+];
+
+impl Currency {
+    const fn new(
+        code: &'static str,
+        symbol: &'static str,
+        decimals: usize,
+        symbol_first: bool,
+    ) -> Self {
+        Self {
+            code,
+            symbol,
+            decimals,
+            symbol_first,
+        }
+    }
+}
+
+/// What's known about `code`, if anything.
+pub fn lookup(code: &str) -> Option<&'static Currency> {
+    CURRENCIES
+        .iter()
+        .find(|currency| currency.code.eq_ignore_ascii_case(code))
+}
+
+/// `amount` written the way `code` is, e.g. `£12.50`, `¥1235` or `1.235 BHD`.
+/// Unknown currencies get two decimals and their code.
+pub fn format(code: &str, amount: f64) -> String {
+    let (symbol, decimals, symbol_first) = match lookup(code) {
+        Some(currency) => (currency.symbol, currency.decimals, currency.symbol_first),
+        None => (code, 2, false),
+    };
+    let digits = format!("{:.*}", decimals, amount.abs());
+    // Amounts that round to zero aren't written as `-£0.00`.
+    let sign = if amount < 0.0 && digits.chars().any(|c| matches!(c, '1'..='9')) {
+        "-"
+    } else {
+        ""
+    };
+    if symbol_first {
+        format!("{}{}{}", sign, symbol, digits)
+    } else {
+        format!("{}{} {}", sign, digits, symbol)
+    }
+}
@@ -0,0 +1,209 @@
+use crate::{
+    metrics, store,
+    webhooks::{self, Request, Response},
+};
+use serenity::prelude::*;
+use std::{
+    env, fs,
+    sync::{
+        atomic::{AtomicBool, Ordering},
+        OnceLock,
+    },
+    time::{Duration, Instant},
+};
+use tokio::net::{TcpListener, TcpStream};
+
+/// Longest `/debug/cpu` sample, so a request can't hold a connection open
+/// indefinitely.
+const MAX_CPU_SAMPLE_SECS: u64 = 60;
+const DEFAULT_CPU_SAMPLE_SECS: u64 = 10;
+/// Linux reports CPU time to userspace in ticks of 1/100 s on every
+/// architecture the bot runs on.
+const TICKS_PER_SEC: f64 = 100.0;
+
+static STARTED: AtomicBool = AtomicBool::new(false);
+static STARTED_AT: OnceLock<Instant> = OnceLock::new();
+
+/// Starts the uptime clock, and the diagnostics listener on `DEBUG_PORT` if
+/// it is set. It only ever binds to 127.0.0.1, so it is reachable from the
+/// bot's own machine (e.g. through an SSH tunnel) and adds nothing to its
+/// public surface. Later calls (e.g. after a reconnect) are no-ops.
+pub fn start(ctx: Context) {
+    STARTED_AT.get_or_init(Instant::now);
+    let port = match env::var("DEBUG_PORT")
+        .ok()
+        .and_then(|port| port.parse::<u16>().ok())
+    {
+        Some(port) => port,
+        None => return,
+    };
+    if STARTED.swap(true, Ordering::SeqCst) {
+        return;
+    }
+
+    tokio::spawn(async move {
+        let listener = match TcpListener::bind(("127.0.0.1", port)).await {
+            Ok(listener) => listener,
+            Err(error) => {
+                log::error!(
+                    "Error listening for diagnostics on port {}: {}",
+                    port,
+                    error
+                );
+                return;
+            }
+        };
+        log::info!("Serving diagnostics on 127.0.0.1:{}", port);
+        loop {
+            let stream = match listener.accept().await {
+                Ok((stream, _)) => stream,
+                Err(error) => {
+                    log::error!("Error accepting diagnostics connection: {}", error);
+                    continue;
+                }
+            };
+            let ctx = ctx.clone();
+            tokio::spawn(async move {
+                if let Err(error) = serve(&ctx, stream).await {
+                    log::error!("Error serving diagnostics: {}", error);
+                }
+            });
+        }
+    });
+}
+
+async fn serve(ctx: &Context, mut stream: TcpStream) -> Result<(), String> {
+    let response =
+        match tokio::time::timeout(webhooks::READ_TIMEOUT, webhooks::read_request(&mut stream))
+            .await
+        {
+            Ok(Ok(request)) => route(ctx, &request).await,
+            Ok(Err(_)) => Response::new(400, "Bad Request"),
+            Err(_) => Response::new(408, "Request Timeout"),
+        };
+    webhooks::write_response(&mut stream, &response).await
+}
+
+async fn route(ctx: &Context, request: &Request) -> Response {
+    if request.method != "GET" {
+        return Response::new(405, "Method Not Allowed");
+    }
+    match request.path.as_str() {
+        "/debug" => Response::new(
+            200,
+            "/debug/process  memory, threads and CPU time so far\n\
+             /debug/cpu?seconds=10  CPU usage sampled over a few seconds\n\
+             /debug/commands  per-command latency over the last hour\n",
+        ),
+        "/debug/process" => Response::new(200, &process_report()),
+        "/debug/cpu" => cpu_report(request).await,
+        "/debug/commands" => commands_report(ctx).await,
+        _ => Response::new(404, "Not Found"),
+    }
+}
+
+/// How long ago the bot first connected to Discord.
+pub fn uptime() -> Duration {
+    STARTED_AT
+        .get()
+        .map_or(Duration::ZERO, |started_at| started_at.elapsed())
+}
+
+/// The process's resident memory in kB, from `/proc/self/status`.
+pub fn resident_memory_kb() -> Option<u64> {
+    fs::read_to_string("/proc/self/status")
+        .ok()?
+        .lines()
+        .find_map(|line| line.strip_prefix("VmRSS:"))?
+        .trim()
+        .trim_end_matches("kB")
+        .trim()
+        .parse()
+        .ok()
+}
+
+/// Memory and thread figures from `/proc/self/status`, with uptime and CPU
+/// time so far.
+fn process_report() -> String {
+    let mut report = format!("Uptime: {}s\n", uptime().as_secs());
+    match fs::read_to_string("/proc/self/status") {
+        Ok(status) => {
+            for line in status.lines() {
+                if ["VmRSS:", "VmHWM:", "VmSize:", "Threads:"]
+                    .iter()
+                    .any(|field| line.starts_with(field))
+                {
+                    report.push_str(&line.split_whitespace().collect::<Vec<_>>().join(" "));
+                    report.push('\n');
+                }
+            }
+        }
+        Err(_) => report.push_str("Process figures are only available on Linux\n"),
+    }
+    if let Some((user, system)) = cpu_seconds() {
+        report.push_str(&format!("CPU: {:.2}s user, {:.2}s system\n", user, system));
+    }
+    report
+}
+
+/// The process's CPU usage over `?seconds=` (10 by default), as a percentage
+/// of one core.
+async fn cpu_report(request: &Request) -> Response {
+    let seconds = request
+        .query
+        .split('&')
+        .find_map(|pair| pair.strip_prefix("seconds="))
+        .and_then(|seconds| seconds.parse::<u64>().ok())
+        .unwrap_or(DEFAULT_CPU_SAMPLE_SECS)
+        .clamp(1, MAX_CPU_SAMPLE_SECS);
+
+    let before = match cpu_seconds() {
+        Some(before) => before,
+        None => return Response::new(500, "CPU figures are only available on Linux"),
+    };
+    tokio::time::sleep(Duration::from_secs(seconds)).await;
+    let after = match cpu_seconds() {
+        Some(after) => after,
+        None => return Response::new(500, "CPU figures are only available on Linux"),
+    };
+
+    let percent = |before: f64, after: f64| (after - before) * 100.0 / seconds as f64;
+    Response::new(
+        200,
+        &format!(
+            "CPU over {}s: {:.1}% user, {:.1}% system (100% is one full core)\n",
+            seconds,
+            percent(before.0, after.0),
+            percent(before.1, after.1)
+        ),
+    )
+}
+
+/// User and system CPU seconds used so far, from `/proc/self/stat`.
+fn cpu_seconds() -> Option<(f64, f64)> {
+    let stat = fs::read_to_string("/proc/self/stat").ok()?;
+    // The command name can contain spaces, so fields are counted from its end.
+    let fields: Vec<_> = stat.rsplit_once(')')?.1.split_whitespace().collect();
+    let ticks = |index: usize| fields.get(index)?.parse::<f64>().ok();
+    Some((ticks(11)? / TICKS_PER_SEC, ticks(12)? / TICKS_PER_SEC))
+}
+
+async fn commands_report(ctx: &Context) -> Response {
+    let metrics = match metrics::metrics(ctx).await {
+        Ok(metrics) => metrics,
+        Err(error) => return Response::new(500, &error),
+    };
+    let since = store::now().saturating_sub(metrics::WINDOW_SECS);
+    let report = metrics
+        .stats(since)
+        .await
+        .iter()
+        .map(|stats| {
+            format!(
+                "/{} calls={} errors={} p50={}ms p95={}ms p99={}ms\n",
+                stats.command, stats.calls, stats.errors, stats.p50_ms, stats.p95_ms, stats.p99_ms
+            )
+        })
+        .collect::<String>();
+    Response::new(200, &report)
+}
@@ -0,0 +1,101 @@
+use crate::amount;
+use std::env;
+
+/// Earned Robux needed before Roblox accepts a cash-out request.
+pub const MIN_ROBUX: u64 = 30_000;
+/// USD Roblox pays per earned Robux when `DEVEX_USD_PER_ROBUX` isn't set.
+const DEFAULT_USD_PER_ROBUX: f64 = 0.0038;
+
+/// USD Roblox pays per earned Robux. Roblox sets the rate, so it can be
+/// updated through `DEVEX_USD_PER_ROBUX` without a release.
+pub fn usd_per_robux() -> f64 {
+    env::var("DEVEX_USD_PER_ROBUX")
+        .ok()
+        .and_then(|rate| rate.trim().parse::<f64>().ok())
+        .filter(|rate| rate.is_finite() && *rate > 0.0)
+        .unwrap_or(DEFAULT_USD_PER_ROBUX)
+}
+
+/// Whether a requirement is met, as far as the bot can tell.
+#[derive(Clone, Copy, PartialEq, Eq)]
+pub enum Status {
+    Met,
+    NotMet,
+    /// Neither answered nor checkable on Roblox.
+    Unknown,
+}
+
+impl Status {
+    pub fn from_answer(answer: Option<bool>) -> Self {
+        match answer {
+            Some(true) => Status::Met,
+            Some(false) => Status::NotMet,
+            None => Status::Unknown,
+        }
+    }
+
+    pub fn icon(self) -> &'static str {
+        match self {
+            Status::Met => "✅",
+            Status::NotMet => "❌",
+            Status::Unknown => "❔",
+        }
+    }
+}
+
+/// One of Roblox's DevEx requirements and how the member stands against it.
+pub struct Requirement {
+    pub name: &'static str,
+    pub status: Status,
+    pub detail: String,
+}
+
+impl Requirement {
+    /// The earned Robux requirement for a balance of `earned`.
+    pub fn earned(earned: u64) -> Self {
+        Self {
+            name: "30,000 earned R$",
+            status: if earned >= MIN_ROBUX {
+                Status::Met
+            } else {
+                Status::NotMet
+            },
+            detail: if earned >= MIN_ROBUX {
+                format!("{} R$ earned", group(earned))
+            } else {
+                format!(
+                    "{} R$ earned, {} R$ short",
+                    group(earned),
+                    group(MIN_ROBUX - earned)
+                )
+            },
+        }
+    }
+
+    /// e.g. `✅ **30,000 earned R$**: 45,000 R$ earned`.
+    pub fn line(&self) -> String {
+        format!("{} **{}**: {}", self.status.icon(), self.name, self.detail)
+    }
+}
+
+/// `Some(true)` when every requirement is met, `Some(false)` when any isn't,
+/// and `None` when the rest can't be told.
+pub fn eligible(requirements: &[Requirement]) -> Option<bool> {
+    if requirements
+        .iter()
+        .any(|requirement| requirement.status == Status::NotMet)
+    {
+        Some(false)
+    } else if requirements
+        .iter()
+        .all(|requirement| requirement.status == Status::Met)
+    {
+        Some(true)
+    } else {
+        None
+    }
+}
+
+fn group(robux: u64) -> String {
+    amount::group_thousands(robux as f64)
+}
@@ -0,0 +1,235 @@
+use crate::store::{self, JsonStore, Partition};
+use serde::{Deserialize, Serialize};
+use serenity::prelude::*;
+use std::sync::Arc;
+
+const DISPUTES_FILE: &str = "disputes.json";
+
+/// Who a dispute was settled in favour of.
+#[derive(Serialize, Deserialize, Clone, Copy, PartialEq, Eq)]
+#[serde(rename_all = "snake_case")]
+pub enum Decision {
+    Buyer,
+    Seller,
+    /// Neither side was clearly in the wrong, e.g. a partial refund.
+    Split,
+}
+
+impl Decision {
+    pub fn parse(value: &str) -> Option<Self> {
+        match value.to_lowercase().as_str() {
+            "buyer" => Some(Decision::Buyer),
+            "seller" => Some(Decision::Seller),
+            "split" => Some(Decision::Split),
+            _ => None,
+        }
+    }
+
+    pub fn label(self) -> &'static str {
+        match self {
+            Decision::Buyer => "In the buyer's favour",
+            Decision::Seller => "In the seller's favour",
+            Decision::Split => "Split",
+        }
+    }
+}
+
+/// A file or statement submitted to a dispute.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct Evidence {
+    pub user_id: u64,
+    #[serde(default)]
+    pub url: Option<String>,
+    #[serde(default)]
+    pub note: Option<String>,
+    pub submitted_at: u64,
+}
+
+#[derive(Serialize, Deserialize, Clone)]
+pub struct Resolution {
+    pub decision: Decision,
+    pub note: String,
+    pub resolved_by: u64,
+    pub resolved_at: u64,
+}
+
+/// A disagreement over an order, argued in a private thread.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct Dispute {
+    pub id: u64,
+    pub guild_id: u64,
+    pub order_id: u64,
+    /// The thread evidence is collected in.
+    pub thread_id: u64,
+    pub opened_by: u64,
+    pub buyer_id: u64,
+    /// Staff member who handled the order, if it was claimed.
+    #[serde(default)]
+    pub seller_id: Option<u64>,
+    pub reason: String,
+    pub opened_at: u64,
+    #[serde(default)]
+    pub evidence: Vec<Evidence>,
+    #[serde(default)]
+    pub resolution: Option<Resolution>,
+}
+
+impl Dispute {
+    /// Whether `user_id` is one of the two sides.
+    pub fn is_party(&self, user_id: u64) -> bool {
+        self.buyer_id == user_id || self.seller_id == Some(user_id) || self.opened_by == user_id
+    }
+}
+
+/// A user's dispute record across every order they were a party to.
+#[derive(Default)]
+pub struct Reputation {
+    pub won: usize,
+    pub lost: usize,
+    pub split: usize,
+    pub open: usize,
+}
+
+#[derive(Serialize, Deserialize, Default)]
+struct DisputeBook {
+    next_id: u64,
+    disputes: Vec<Dispute>,
+}
+
+/// Every dispute and its outcome, persisted in the data directory.
+pub struct DisputeStore {
+    book: JsonStore<DisputeBook>,
+}
+
+impl DisputeStore {
+    pub fn open(partition: &Partition) -> Result<Self, String> {
+        Ok(Self {
+            book: JsonStore::open(partition, DISPUTES_FILE)?,
+        })
+    }
+
+    /// Assigns `dispute` the next ID and opening time, then persists it.
+    /// Fails if the order already has an unresolved dispute.
+    pub async fn open_dispute(&self, dispute: Dispute) -> Result<Dispute, String> {
+        self.book
+            .update(|book| {
+                if let Some(existing) = book.disputes.iter().find(|existing| {
+                    existing.order_id == dispute.order_id && existing.resolution.is_none()
+                }) {
+                    return Err(format!(
+                        "Order #{} is already disputed in <#{}>",
+                        dispute.order_id, existing.thread_id
+                    ));
+                }
+                book.next_id += 1;
+                let dispute = Dispute {
+                    id: book.next_id,
+                    opened_at: store::now(),
+                    ..dispute
+                };
+                book.disputes.push(dispute.clone());
+                Ok(dispute)
+            })
+            .await?
+    }
+
+    /// The dispute argued in thread `thread_id`, if any.
+    pub async fn in_thread(&self, thread_id: u64) -> Option<Dispute> {
+        self.book
+            .read()
+            .await
+            .disputes
+            .iter()
+            .find(|dispute| dispute.thread_id == thread_id)
+            .cloned()
+    }
+
+    /// Adds `evidence` to the open dispute with `id`.
+    pub async fn add_evidence(&self, id: u64, evidence: Evidence) -> Result<Dispute, String> {
+        self.book
+            .update(|book| {
+                let dispute = book
+                    .disputes
+                    .iter_mut()
+                    .find(|dispute| dispute.id == id)
+                    .ok_or_else(|| format!("Dispute #{} doesn't exist", id))?;
+                if dispute.resolution.is_some() {
+                    return Err(format!("Dispute #{} has already been resolved", id));
+                }
+                dispute.evidence.push(evidence);
+                Ok(dispute.clone())
+            })
+            .await?
+    }
+
+    /// Settles the open dispute with `id`.
+    pub async fn resolve(&self, id: u64, resolution: Resolution) -> Result<Dispute, String> {
+        self.book
+            .update(|book| {
+                let dispute = book
+                    .disputes
+                    .iter_mut()
+                    .find(|dispute| dispute.id == id)
+                    .ok_or_else(|| format!("Dispute #{} doesn't exist", id))?;
+                if dispute.resolution.is_some() {
+                    return Err(format!("Dispute #{} has already been resolved", id));
+                }
+                dispute.resolution = Some(resolution);
+                Ok(dispute.clone())
+            })
+            .await?
+    }
+
+    /// How many disputes `buyer_id` has had over their orders in `guild_id`.
+    pub async fn count_as_buyer(&self, guild_id: u64, buyer_id: u64) -> usize {
+        self.book
+            .read()
+            .await
+            .disputes
+            .iter()
+            .filter(|dispute| dispute.guild_id == guild_id && dispute.buyer_id == buyer_id)
+            .count()
+    }
+
+    /// `user_id`'s disputes in `guild_id`, tallied by outcome for their side.
+    pub async fn reputation(&self, guild_id: u64, user_id: u64) -> Reputation {
+        let mut reputation = Reputation::default();
+        for dispute in self.book.read().await.disputes.iter() {
+            if dispute.guild_id != guild_id
+                || (dispute.buyer_id != user_id && dispute.seller_id != Some(user_id))
+            {
+                continue;
+            }
+            let winner = match &dispute.resolution {
+                Some(resolution) => resolution.decision,
+                None => {
+                    reputation.open += 1;
+                    continue;
+                }
+            };
+            let is_buyer = dispute.buyer_id == user_id;
+            match winner {
+                Decision::Split => reputation.split += 1,
+                Decision::Buyer if is_buyer => reputation.won += 1,
+                Decision::Seller if !is_buyer => reputation.won += 1,
+                _ => reputation.lost += 1,
+            }
+        }
+        reputation
+    }
+}
+
+pub struct DisputesKey;
+
+impl TypeMapKey for DisputesKey {
+    type Value = Arc<DisputeStore>;
+}
+
+pub async fn store(ctx: &Context) -> Result<Arc<DisputeStore>, String> {
+    ctx.data
+        .read()
+        .await
+        .get::<DisputesKey>()
+        .cloned()
+        .ok_or_else(|| "Disputes unavailable".to_string())
+}
@@ -0,0 +1,49 @@
+use crate::i18n;
+use serenity::builder::CreateEmbed;
+
+// Generated by build.rs from `content/explain`: `PAGES` holds each page as
+// `(locale, topic, markdown)`, and `TOPICS` each topic as `(name, topic)`.
+include!(concat!(env!("OUT_DIR"), "/explain.rs"));
+
+/// The page on `topic` for `locale`, falling back to the same language in
+/// another region and then to the default locale, as catalogs do.
+fn page(topic: &str, locale: &str) -> Option<&'static str> {
+    let find = |matches: &dyn Fn(&str) -> bool| {
+        PAGES
+            .iter()
+            .find(|(page_locale, page_topic, _)| *page_topic == topic && matches(page_locale))
+            .map(|(_, _, markdown)| *markdown)
+    };
+    find(&|page_locale| page_locale == locale)
+        .or_else(|| find(&|page_locale| i18n::language(page_locale) == i18n::language(locale)))
+        .or_else(|| find(&|page_locale| page_locale == i18n::DEFAULT_LOCALE))
+}
+
+/// `topic`'s page as an embed. A page is Markdown whose `# ` line is the
+/// title, whose text up to the first `## ` heading is the description, and
+/// whose `## ` sections become fields.
+pub fn embed(topic: &str, locale: &str) -> Option<CreateEmbed> {
+    let page = page(topic, locale)?;
+    let mut embed = CreateEmbed::default();
+    let mut description = String::new();
+    let mut fields: Vec<(String, String)> = Vec::new();
+    for line in page.lines() {
+        if let Some(title) = line.strip_prefix("# ") {
+            embed.title(title.trim());
+        } else if let Some(name) = line.strip_prefix("## ") {
+            fields.push((name.trim().to_string(), String::new()));
+        } else {
+            let text = match fields.last_mut() {
+                Some((_, value)) => value,
+                None => &mut description,
+            };
+            text.push_str(line);
+            text.push('\n');
+        }
+    }
+    embed.description(description.trim()).color(0x0096FF);
+    for (name, value) in fields {
+        embed.field(name, value.trim(), false);
+    }
+    Some(embed)
+}
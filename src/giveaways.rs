@@ -0,0 +1,274 @@
+use crate::{
+    cache::Cache,
+    store::{self, JsonStore, Partition},
+};
+use serde::{Deserialize, Serialize};
+use serenity::prelude::*;
+use std::{
+    collections::hash_map::RandomState,
+    hash::{BuildHasher, Hasher},
+    sync::Arc,
+    time::Duration,
+};
+
+const GIVEAWAYS_FILE: &str = "giveaways.json";
+/// How often running giveaways are checked for having ended.
+const CHECK_INTERVAL_SECS: u64 = 30;
+const MIN_DURATION_SECS: u64 = 60;
+const MAX_DURATION_SECS: u64 = 30 * 24 * 3600;
+
+/// A Robux giveaway members enter with a button on its message.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct Giveaway {
+    pub id: u64,
+    pub guild_id: u64,
+    pub channel_id: u64,
+    /// The message with the entry button, once it has been posted.
+    #[serde(default)]
+    pub message_id: Option<u64>,
+    pub host_id: u64,
+    /// Robux the winner receives.
+    pub robux: u64,
+    /// Role members need to enter, e.g. the customer role.
+    #[serde(default)]
+    pub required_role_id: Option<u64>,
+    pub started_at: u64,
+    pub ends_at: u64,
+    #[serde(default)]
+    pub entrants: Vec<u64>,
+    /// Everyone drawn so far, oldest first. The last one is the current
+    /// winner; earlier ones were re-rolled.
+    #[serde(default)]
+    pub winners: Vec<u64>,
+    #[serde(default)]
+    pub ended: bool,
+    /// The zero-priced order the prize is delivered through.
+    #[serde(default)]
+    pub order_id: Option<u64>,
+}
+
+impl Giveaway {
+    pub fn winner(&self) -> Option<u64> {
+        self.winners.last().copied()
+    }
+}
+
+#[derive(Serialize, Deserialize, Default)]
+struct GiveawayBook {
+    next_id: u64,
+    giveaways: Vec<Giveaway>,
+}
+
+/// Giveaways and their entrants, persisted in the data directory.
+pub struct GiveawayStore {
+    book: JsonStore<GiveawayBook>,
+}
+
+impl GiveawayStore {
+    pub fn open(partition: &Partition) -> Result<Self, String> {
+        Ok(Self {
+            book: JsonStore::open(partition, GIVEAWAYS_FILE)?,
+        })
+    }
+
+    /// Stores a new giveaway, assigning its ID.
+    pub async fn create(&self, giveaway: Giveaway) -> Result<Giveaway, String> {
+        self.book
+            .update(|book| {
+                book.next_id += 1;
+                let giveaway = Giveaway {
+                    id: book.next_id,
+                    ..giveaway
+                };
+                book.giveaways.push(giveaway.clone());
+                giveaway
+            })
+            .await
+    }
+
+    /// The giveaway with `id`, if any.
+    pub async fn get(&self, id: u64) -> Option<Giveaway> {
+        self.book
+            .read()
+            .await
+            .giveaways
+            .iter()
+            .find(|giveaway| giveaway.id == id)
+            .cloned()
+    }
+
+    /// Applies `change` to giveaway `id`, returning the updated giveaway.
+    pub async fn update(
+        &self,
+        id: u64,
+        change: impl FnOnce(&mut Giveaway),
+    ) -> Result<Giveaway, String> {
+        self.book
+            .update(|book| {
+                let giveaway = book
+                    .giveaways
+                    .iter_mut()
+                    .find(|giveaway| giveaway.id == id)?;
+                change(giveaway);
+                Some(giveaway.clone())
+            })
+            .await?
+            .ok_or_else(|| format!("Giveaway #{} doesn't exist", id))
+    }
+
+    /// Enters `user_id` into giveaway `id`. Returns false if they had already
+    /// entered.
+    pub async fn enter(&self, id: u64, user_id: u64) -> Result<(Giveaway, bool), String> {
+        self.book
+            .update(|book| {
+                let giveaway = book
+                    .giveaways
+                    .iter_mut()
+                    .find(|giveaway| giveaway.id == id)
+                    .ok_or_else(|| format!("Giveaway #{} doesn't exist", id))?;
+                if giveaway.ended || giveaway.ends_at <= store::now() {
+                    return Err("This giveaway has ended".to_string());
+                }
+                if giveaway.entrants.contains(&user_id) {
+                    return Ok((giveaway.clone(), false));
+                }
+                giveaway.entrants.push(user_id);
+                Ok((giveaway.clone(), true))
+            })
+            .await?
+    }
+
+    /// Ends giveaway `id` and draws a winner among the entrants who haven't
+    /// been drawn before, so drawing again re-rolls. Fails if nobody is left.
+    pub async fn draw(&self, id: u64) -> Result<Giveaway, String> {
+        self.book
+            .update(|book| {
+                let giveaway = book
+                    .giveaways
+                    .iter_mut()
+                    .find(|giveaway| giveaway.id == id)
+                    .ok_or_else(|| format!("Giveaway #{} doesn't exist", id))?;
+                giveaway.ended = true;
+                let eligible: Vec<u64> = giveaway
+                    .entrants
+                    .iter()
+                    .filter(|entrant| !giveaway.winners.contains(entrant))
+                    .copied()
+                    .collect();
+                if eligible.is_empty() {
+                    return Err(if giveaway.entrants.is_empty() {
+                        format!("Nobody entered giveaway #{}", id)
+                    } else {
+                        format!("Everyone in giveaway #{} has already been drawn", id)
+                    });
+                }
+                giveaway
+                    .winners
+                    .push(eligible[random_index(eligible.len())]);
+                Ok(giveaway.clone())
+            })
+            .await?
+    }
+
+    /// Running giveaways whose time is up at `now`.
+    pub async fn due(&self, now: u64) -> Vec<Giveaway> {
+        self.book
+            .read()
+            .await
+            .giveaways
+            .iter()
+            .filter(|giveaway| !giveaway.ended && giveaway.ends_at <= now)
+            .cloned()
+            .collect()
+    }
+}
+
+/// A uniformly random index below `len`.
+fn random_index(len: usize) -> usize {
+    // RandomState is seeded randomly per process, so draws can't be predicted.
+    // Rejecting the top of the range keeps every index equally likely.
+    let len = len as u64;
+    let limit = u64::MAX - u64::MAX % len;
+    loop {
+        let bits = RandomState::new().build_hasher().finish();
+        if bits < limit {
+            return (bits % len) as usize;
+        }
+    }
+}
+
+/// Parses a duration such as `30m`, `2h`, `1d` or `1h30m` into seconds,
+/// between a minute and 30 days.
+pub fn parse_duration(input: &str) -> Result<u64, String> {
+    let invalid = || {
+        format!(
+            "Invalid duration '{}'. Use minutes, hours and days like 30m, 2h or 1d12h.",
+            input.trim()
+        )
+    };
+
+    let mut secs = 0u64;
+    let mut digits = String::new();
+    for c in input.trim().to_lowercase().chars() {
+        if c.is_ascii_digit() {
+            digits.push(c);
+            continue;
+        }
+        let unit = match c {
+            's' => 1,
+            'm' => 60,
+            'h' => 3600,
+            'd' => 24 * 3600,
+            'w' => 7 * 24 * 3600,
+            ' ' if digits.is_empty() => continue,
+            _ => return Err(invalid()),
+        };
+        let count: u64 = digits.parse().map_err(|_| invalid())?;
+        secs = secs.saturating_add(count.saturating_mul(unit));
+        digits.clear();
+    }
+    if !digits.is_empty() || secs == 0 {
+        return Err(invalid());
+    }
+
+    if !(MIN_DURATION_SECS..=MAX_DURATION_SECS).contains(&secs) {
+        return Err("Giveaways must run for between a minute and 30 days".to_string());
+    }
+    Ok(secs)
+}
+
+/// Starts drawing winners for giveaways as they end. Call it once per bot;
+/// instances sharing `cache` take turns, so winners are drawn once.
+pub fn start(ctx: Context, cache: Cache) {
+    tokio::spawn(async move {
+        let interval = Duration::from_secs(CHECK_INTERVAL_SECS);
+        loop {
+            if cache.lease("giveaways", interval).await.is_some() {
+                match store(&ctx).await {
+                    Ok(giveaways) => {
+                        for giveaway in giveaways.due(store::now()).await {
+                            crate::finish_giveaway(&ctx, giveaway.id).await;
+                        }
+                    }
+                    Err(error) => log::error!("Error ending giveaways: {}", error),
+                }
+            }
+            tokio::time::sleep(interval).await;
+        }
+    });
+}
+
+pub struct GiveawaysKey;
+
+impl TypeMapKey for GiveawaysKey {
+    type Value = Arc<GiveawayStore>;
+}
+
+pub async fn store(ctx: &Context) -> Result<Arc<GiveawayStore>, String> {
+    ctx.data
+        .read()
+        .await
+        .get::<GiveawaysKey>()
+        .cloned()
+        .ok_or_else(|| "Giveaways unavailable".to_string())
+}
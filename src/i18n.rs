@@ -0,0 +1,98 @@
+use std::{collections::HashMap, sync::OnceLock};
+
+/// The locale the built-in strings are written in. Its catalog holds response
+/// text; command names and descriptions come from the command registry.
+pub const DEFAULT_LOCALE: &str = "en-US";
+
+/// Catalogs keyed by Discord locale code. Keys are flat, e.g. `help.title` or
+/// `commands.price.options.amount.description`.
+const CATALOGS: &[(&str, &str)] = &[
+    ("en-US", include_str!("../locales/en-US.json")),
+    ("de", include_str!("../locales/de.json")),
+    ("es-ES", include_str!("../locales/es-ES.json")),
+    ("fr", include_str!("../locales/fr.json")),
+];
+
+type Catalog = HashMap<String, String>;
+
+fn catalogs() -> &'static [(&'static str, Catalog)] {
+    static PARSED: OnceLock<Vec<(&'static str, Catalog)>> = OnceLock::new();
+    PARSED.get_or_init(|| {
+        CATALOGS
+            .iter()
+            .filter_map(|(locale, json)| match serde_json::from_str(json) {
+                Ok(catalog) => Some((*locale, catalog)),
+                Err(error) => {
+                    log::error!("Error parsing the {} catalog: {}", locale, error);
+                    None
+                }
+            })
+            .collect()
+    })
+}
+
+/// Looks `key` up for `locale`, falling back to the same language in another
+/// region (`es-419` uses `es-ES`) and then to the default locale.
+pub fn get(locale: &str, key: &str) -> Option<&'static str> {
+    lookup(key, |catalog| catalog == locale)
+        .or_else(|| lookup(key, |catalog| language(catalog) == language(locale)))
+        .or_else(|| lookup(key, |catalog| catalog == DEFAULT_LOCALE))
+}
+
+fn lookup(key: &str, matches: impl Fn(&str) -> bool) -> Option<&'static str> {
+    catalogs()
+        .iter()
+        .filter(|(locale, _)| matches(locale))
+        .find_map(|(_, catalog)| catalog.get(key).map(String::as_str))
+}
+
+/// The language part of a locale code, e.g. `es` for `es-419`.
+pub fn language(locale: &str) -> &str {
+    locale.split('-').next().unwrap_or(locale)
+}
+
+/// The catalog locale serving `code`, matched exactly or by language (`es`
+/// and `es-419` are served by `es-ES`), or `None` if there's no catalog for it.
+pub fn supported(code: &str) -> Option<&'static str> {
+    let code = code.trim();
+    locales()
+        .find(|locale| locale.eq_ignore_ascii_case(code))
+        .or_else(|| locales().find(|locale| language(locale).eq_ignore_ascii_case(language(code))))
+}
+
+/// Every locale with a catalog, the default first.
+pub fn locales() -> impl Iterator<Item = &'static str> {
+    catalogs().iter().map(|(locale, _)| *locale)
+}
+
+/// The locale to respond in: the user's own Discord language when a catalog
+/// covers it, then the language their server chose, then the default.
+pub fn resolve(user_locale: &str, guild_locale: Option<&str>) -> &'static str {
+    supported(user_locale)
+        .or_else(|| guild_locale.and_then(supported))
+        .unwrap_or(DEFAULT_LOCALE)
+}
+
+/// The text for `key` in `locale`, or `fallback` if no catalog has it.
+pub fn t_or<'a>(locale: &str, key: &str, fallback: &'a str) -> &'a str {
+    get(locale, key).unwrap_or(fallback)
+}
+
+/// The text for `key` in `locale` with `{name}` placeholders filled in from
+/// `args`. Unknown keys render as the key itself so they are easy to spot.
+pub fn t(locale: &str, key: &str, args: &[(&str, &str)]) -> String {
+    let mut text = get(locale, key).unwrap_or(key).to_string();
+    for (name, value) in args {
+        text = text.replace(&format!("{{{}}}", name), value);
+    }
+    text
+}
+
+/// Every non-default locale with a translation for `key`, for Discord's
+/// name and description localizations.
+pub fn translations(key: &str) -> impl Iterator<Item = (&'static str, &'static str)> + '_ {
+    catalogs()
+        .iter()
+        .filter(|(locale, _)| *locale != DEFAULT_LOCALE)
+        .filter_map(move |(locale, catalog)| catalog.get(key).map(|text| (*locale, text.as_str())))
+}
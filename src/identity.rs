@@ -0,0 +1,92 @@
+use serde::{Deserialize, Serialize};
+use serde_json::Value;
+use std::{sync::OnceLock, time::Duration};
+
+const BLOXLINK_URL: &str = "https://api.blox.link/v4/public/guilds";
+const ROVER_URL: &str = "https://registry.rover.link/api/guilds";
+const REQUEST_TIMEOUT: Duration = Duration::from_secs(10);
+
+fn client() -> &'static reqwest::Client {
+    static CLIENT: OnceLock<reqwest::Client> = OnceLock::new();
+    CLIENT.get_or_init(|| {
+        reqwest::Client::builder()
+            .timeout(REQUEST_TIMEOUT)
+            .build()
+            .unwrap_or_default()
+    })
+}
+
+/// A verification bot whose public API maps Discord users to Roblox accounts.
+#[derive(Serialize, Deserialize, Clone, Copy, PartialEq, Eq)]
+#[serde(rename_all = "snake_case")]
+pub enum Provider {
+    Bloxlink,
+    Rover,
+}
+
+impl Provider {
+    pub fn parse(value: &str) -> Option<Self> {
+        match value.to_lowercase().as_str() {
+            "bloxlink" => Some(Provider::Bloxlink),
+            "rover" => Some(Provider::Rover),
+            _ => None,
+        }
+    }
+
+    pub fn label(self) -> &'static str {
+        match self {
+            Provider::Bloxlink => "Bloxlink",
+            Provider::Rover => "RoVer",
+        }
+    }
+
+    /// The Roblox user ID `discord_id` verified with the provider in
+    /// `guild_id`, or `None` if they haven't. `api_key` is the guild's key for
+    /// the provider's API.
+    pub async fn roblox_id(
+        self,
+        api_key: &str,
+        guild_id: u64,
+        discord_id: u64,
+    ) -> Result<Option<u64>, String> {
+        let request = match self {
+            Provider::Bloxlink => client()
+                .get(format!(
+                    "{}/{}/discord-to-roblox/{}",
+                    BLOXLINK_URL, guild_id, discord_id
+                ))
+                .header("Authorization", api_key),
+            Provider::Rover => client()
+                .get(format!(
+                    "{}/{}/discord-to-roblox/{}",
+                    ROVER_URL, guild_id, discord_id
+                ))
+                .bearer_auth(api_key),
+        };
+        let response = request
+            .send()
+            .await
+            .map_err(|e| format!("Error contacting {}: {}", self.label(), e))?;
+
+        let status = response.status();
+        if status.as_u16() == 404 {
+            return Ok(None);
+        }
+        if !status.is_success() {
+            return Err(format!("{} returned {}", self.label(), status));
+        }
+
+        let body: Value = response
+            .json()
+            .await
+            .map_err(|e| format!("Error reading {} response: {}", self.label(), e))?;
+        // Bloxlink sends `robloxID` as a string, RoVer `robloxId` as a number.
+        let id = match self {
+            Provider::Bloxlink => &body["robloxID"],
+            Provider::Rover => &body["robloxId"],
+        };
+        Ok(id
+            .as_u64()
+            .or_else(|| id.as_str().and_then(|id| id.parse().ok())))
+    }
+}
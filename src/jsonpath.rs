@@ -0,0 +1,75 @@
+use serde_json::Value;
+
+/// Resolves a JSONPath-style expression against `value`.
+///
+/// Supports the subset needed to map API responses: an optional leading `$`,
+/// dotted field names (`$.data.rates`), bracketed field names (`$['error-type']`)
+/// and array indices (`$.results[0]`).
+pub fn extract<'a>(value: &'a Value, path: &str) -> Option<&'a Value> {
+    let mut current = value;
+    let mut rest = path.trim().strip_prefix('$').unwrap_or(path.trim());
+
+    while !rest.is_empty() {
+        if let Some(bracketed) = rest.strip_prefix('[') {
+            let end = bracketed.find(']')?;
+            let segment = &bracketed[..end];
+            rest = &bracketed[end + 1..];
+
+            current = match segment.trim_matches(|c| c == '\'' || c == '"') {
+                quoted if quoted.len() != segment.len() => current.get(quoted)?,
+                index => current.get(index.parse::<usize>().ok()?)?,
+            };
+        } else {
+            let field = rest.strip_prefix('.').unwrap_or(rest);
+            let end = field.find(['.', '[']).unwrap_or(field.len());
+            if end == 0 {
+                return None;
+            }
+
+            current = current.get(&field[..end])?;
+            rest = &field[end..];
+        }
+    }
+
+    Some(current)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    #[test]
+    fn fields_and_indices_resolve() {
+        let value = json!({
+            "data": { "rates": { "USD": 1.27 } },
+            "results": [{ "price": 5 }, { "price": 7 }],
+            "error-type": "quota",
+        });
+        assert_eq!(extract(&value, "$.data.rates.USD"), Some(&json!(1.27)));
+        assert_eq!(extract(&value, "data.rates.USD"), Some(&json!(1.27)));
+        assert_eq!(extract(&value, "$.results[1].price"), Some(&json!(7)));
+        assert_eq!(extract(&value, "$['error-type']"), Some(&json!("quota")));
+        assert_eq!(
+            extract(&value, "$[\"data\"]['rates']"),
+            Some(&json!({ "USD": 1.27 }))
+        );
+        assert_eq!(extract(&value, " $ "), Some(&value));
+    }
+
+    #[test]
+    fn missing_or_malformed_paths_resolve_to_nothing() {
+        let value = json!({ "results": [{ "price": 5 }] });
+        for path in [
+            "$.missing",
+            "$.results[1]",
+            "$.results[-1]",
+            "$.results[x]",
+            "$.results[0",
+            "$..results",
+            "$.results.",
+        ] {
+            assert_eq!(extract(&value, path), None, "{:?} resolved", path);
+        }
+    }
+}
@@ -0,0 +1,167 @@
+use crate::{
+    roblox,
+    store::{self, JsonStore, Partition},
+};
+use serde::{Deserialize, Serialize};
+use serenity::prelude::*;
+use std::{
+    collections::hash_map::RandomState,
+    hash::{BuildHasher, Hash, Hasher},
+    sync::Arc,
+    time::SystemTime,
+};
+
+const LINKS_FILE: &str = "links.json";
+/// Words verification codes are made of. Roblox censors long numbers in
+/// profiles, so codes avoid digits.
+const CODE_WORDS: &[&str] = &[
+    "apple", "banana", "cactus", "dolphin", "ember", "falcon", "garden", "harbor", "island",
+    "jungle", "kettle", "lantern", "meadow", "nectar", "orbit", "pepper", "quartz", "river",
+    "saddle", "tiger", "umbrella", "velvet", "willow", "yonder", "zephyr", "acorn", "breeze",
+    "canyon", "daisy", "echo", "forest", "glacier",
+];
+const CODE_LENGTH: usize = 5;
+
+/// A Discord user's verified Roblox account.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct Link {
+    pub discord_id: u64,
+    pub roblox_id: u64,
+    pub roblox_username: String,
+    /// Unix timestamp of when the account was verified.
+    pub linked_at: u64,
+}
+
+/// A link waiting for its code to appear in the Roblox profile.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct PendingLink {
+    pub discord_id: u64,
+    pub roblox_id: u64,
+    pub roblox_username: String,
+    pub code: String,
+}
+
+#[derive(Serialize, Deserialize, Default)]
+struct LinkBook {
+    links: Vec<Link>,
+    pending: Vec<PendingLink>,
+}
+
+/// Discord users' linked Roblox accounts, persisted in the data directory.
+pub struct LinkStore {
+    book: JsonStore<LinkBook>,
+}
+
+impl LinkStore {
+    pub fn open(partition: &Partition) -> Result<Self, String> {
+        Ok(Self {
+            book: JsonStore::open(partition, LINKS_FILE)?,
+        })
+    }
+
+    /// Starts linking `discord_id` to `account`, returning the code to put in
+    /// the account's profile. Asking again for the same account keeps the code.
+    pub async fn start(
+        &self,
+        discord_id: u64,
+        account: &roblox::User,
+    ) -> Result<PendingLink, String> {
+        self.book
+            .update(|book| {
+                if let Some(pending) = book.pending.iter().find(|pending| {
+                    pending.discord_id == discord_id && pending.roblox_id == account.id
+                }) {
+                    return pending.clone();
+                }
+                book.pending
+                    .retain(|pending| pending.discord_id != discord_id);
+                let pending = PendingLink {
+                    discord_id,
+                    roblox_id: account.id,
+                    roblox_username: account.name.clone(),
+                    code: verification_code(discord_id, account.id),
+                };
+                book.pending.push(pending.clone());
+                pending
+            })
+            .await
+    }
+
+    /// The link `discord_id` has started but not verified.
+    pub async fn pending(&self, discord_id: u64) -> Option<PendingLink> {
+        self.book
+            .read()
+            .await
+            .pending
+            .iter()
+            .find(|pending| pending.discord_id == discord_id)
+            .cloned()
+    }
+
+    /// Turns `discord_id`'s pending link into their link, replacing any
+    /// previous one.
+    pub async fn confirm(&self, discord_id: u64) -> Result<Link, String> {
+        self.book
+            .update(|book| {
+                let index = book
+                    .pending
+                    .iter()
+                    .position(|pending| pending.discord_id == discord_id)?;
+                let pending = book.pending.remove(index);
+                let link = Link {
+                    discord_id,
+                    roblox_id: pending.roblox_id,
+                    roblox_username: pending.roblox_username,
+                    linked_at: store::now(),
+                };
+                book.links.retain(|link| link.discord_id != discord_id);
+                book.links.push(link.clone());
+                Some(link)
+            })
+            .await?
+            .ok_or_else(|| "No link is waiting to be verified".to_string())
+    }
+
+    /// The Roblox account `discord_id` has linked, if any.
+    pub async fn get(&self, discord_id: u64) -> Option<Link> {
+        self.book
+            .read()
+            .await
+            .links
+            .iter()
+            .find(|link| link.discord_id == discord_id)
+            .cloned()
+    }
+}
+
+/// A phrase like `tiger meadow echo orbit river`, hard to guess and unlikely
+/// to be in a profile already.
+fn verification_code(discord_id: u64, roblox_id: u64) -> String {
+    // RandomState is seeded randomly per process, so codes can't be predicted
+    // from the IDs.
+    let mut hasher = RandomState::new().build_hasher();
+    (discord_id, roblox_id, SystemTime::now()).hash(&mut hasher);
+    let mut bits = hasher.finish();
+
+    let mut words = Vec::with_capacity(CODE_LENGTH);
+    for _ in 0..CODE_LENGTH {
+        words.push(CODE_WORDS[(bits % CODE_WORDS.len() as u64) as usize]);
+        bits /= CODE_WORDS.len() as u64;
+    }
+    words.join(" ")
+}
+
+pub struct LinksKey;
+
+impl TypeMapKey for LinksKey {
+    type Value = Arc<LinkStore>;
+}
+
+pub async fn store(ctx: &Context) -> Result<Arc<LinkStore>, String> {
+    ctx.data
+        .read()
+        .await
+        .get::<LinksKey>()
+        .cloned()
+        .ok_or_else(|| "Account links unavailable".to_string())
+}
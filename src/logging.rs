@@ -0,0 +1,232 @@
+use chrono::{DateTime, Utc};
+use log::{Level, LevelFilter, Log, Metadata, Record};
+use std::{
+    env,
+    fs::{self, File, OpenOptions},
+    io::Write,
+    path::PathBuf,
+    sync::Mutex,
+};
+
+const DEFAULT_MAX_MB: u64 = 10;
+const DEFAULT_KEEP: usize = 7;
+
+/// When the log file is started afresh, besides on reaching its size limit.
+#[derive(Clone, Copy, PartialEq)]
+enum Rotation {
+    Daily,
+    Hourly,
+    /// Only when the size limit is reached.
+    Size,
+}
+
+impl Rotation {
+    fn parse(input: &str) -> Option<Self> {
+        match input.trim().to_lowercase().as_str() {
+            "daily" => Some(Rotation::Daily),
+            "hourly" => Some(Rotation::Hourly),
+            "size" => Some(Rotation::Size),
+            _ => None,
+        }
+    }
+
+    /// The period `at` falls in; the file is rotated when this changes.
+    fn period(self, at: DateTime<Utc>) -> String {
+        match self {
+            Rotation::Daily => at.format("%Y-%m-%d").to_string(),
+            Rotation::Hourly => at.format("%Y-%m-%d %H").to_string(),
+            Rotation::Size => String::new(),
+        }
+    }
+}
+
+/// A log file that is moved aside and started afresh when it grows past
+/// `max_bytes` or its period ends, keeping the newest `keep` old files.
+struct RotatingFile {
+    path: PathBuf,
+    file: File,
+    written: u64,
+    period: String,
+    max_bytes: u64,
+    rotation: Rotation,
+    keep: usize,
+}
+
+impl RotatingFile {
+    fn open(
+        path: PathBuf,
+        max_bytes: u64,
+        rotation: Rotation,
+        keep: usize,
+    ) -> Result<Self, String> {
+        if let Some(parent) = path
+            .parent()
+            .filter(|parent| !parent.as_os_str().is_empty())
+        {
+            fs::create_dir_all(parent)
+                .map_err(|e| format!("Error creating {}: {}", parent.display(), e))?;
+        }
+        let file = append(&path)?;
+        let metadata = file.metadata().ok();
+        let written = metadata.as_ref().map_or(0, |metadata| metadata.len());
+        // A file left from an earlier run belongs to the period it was last
+        // written in, so a restart on a new day still rotates it.
+        let modified = metadata
+            .and_then(|metadata| metadata.modified().ok())
+            .map(DateTime::<Utc>::from)
+            .unwrap_or_else(Utc::now);
+        Ok(Self {
+            path,
+            file,
+            written,
+            period: rotation.period(modified),
+            max_bytes,
+            rotation,
+            keep,
+        })
+    }
+
+    fn write_line(&mut self, line: &str) {
+        let now = Utc::now();
+        let period = self.rotation.period(now);
+        if self.written > 0
+            && (self.written + line.len() as u64 > self.max_bytes || period != self.period)
+        {
+            if let Err(error) = self.rotate(now) {
+                eprintln!("Error rotating the log file: {}", error);
+            }
+        }
+        self.period = period;
+        if self.file.write_all(line.as_bytes()).is_ok() {
+            self.written += line.len() as u64;
+        }
+    }
+
+    /// Moves the current file to `<name>.<timestamp>`, opens a new one and
+    /// deletes old files beyond the retention limit.
+    fn rotate(&mut self, now: DateTime<Utc>) -> Result<(), String> {
+        let rotated = self.rotated_name(&now.format("%Y%m%d-%H%M%S").to_string());
+        fs::rename(&self.path, &rotated)
+            .map_err(|e| format!("Error moving {}: {}", self.path.display(), e))?;
+        self.file = append(&self.path)?;
+        self.written = 0;
+
+        let prefix = self.rotated_name("");
+        let prefix = prefix.file_name().unwrap_or_default().to_string_lossy();
+        let directory = match self.path.parent() {
+            Some(parent) if !parent.as_os_str().is_empty() => parent.to_path_buf(),
+            _ => PathBuf::from("."),
+        };
+        let mut old: Vec<_> = fs::read_dir(&directory)
+            .map_err(|e| format!("Error listing {}: {}", directory.display(), e))?
+            .filter_map(|entry| entry.ok())
+            .filter(|entry| entry.file_name().to_string_lossy().starts_with(&*prefix))
+            .map(|entry| entry.path())
+            .collect();
+        // Timestamps sort in age order, so the oldest files come first.
+        old.sort();
+        let excess = old.len().saturating_sub(self.keep);
+        for path in &old[..excess] {
+            if let Err(error) = fs::remove_file(path) {
+                eprintln!("Error removing old log {}: {}", path.display(), error);
+            }
+        }
+        Ok(())
+    }
+
+    fn rotated_name(&self, suffix: &str) -> PathBuf {
+        let mut name = self.path.clone().into_os_string();
+        name.push(".");
+        name.push(suffix);
+        PathBuf::from(name)
+    }
+}
+
+fn append(path: &PathBuf) -> Result<File, String> {
+    OpenOptions::new()
+        .create(true)
+        .append(true)
+        .open(path)
+        .map_err(|e| format!("Error opening {}: {}", path.display(), e))
+}
+
+/// Writes the bot's own messages, and warnings and errors from its
+/// dependencies, to stdout and stderr, and to the log file if there is one.
+struct Logger {
+    file: Option<Mutex<RotatingFile>>,
+}
+
+impl Log for Logger {
+    fn enabled(&self, metadata: &Metadata) -> bool {
+        metadata.level() <= log::max_level()
+            && (metadata.target().starts_with(env!("CARGO_CRATE_NAME"))
+                || metadata.level() <= Level::Warn)
+    }
+
+    fn log(&self, record: &Record) {
+        if !self.enabled(record.metadata()) {
+            return;
+        }
+        if record.level() <= Level::Warn {
+            eprintln!("{}", record.args());
+        } else {
+            println!("{}", record.args());
+        }
+        if let Some(file) = &self.file {
+            let line = format!(
+                "{} {:<5} {}\n",
+                Utc::now().format("%Y-%m-%dT%H:%M:%SZ"),
+                record.level(),
+                record.args()
+            );
+            if let Ok(mut file) = file.lock() {
+                file.write_line(&line);
+            }
+        }
+    }
+
+    fn flush(&self) {
+        if let Some(file) = &self.file {
+            if let Ok(mut file) = file.lock() {
+                let _ = file.file.flush();
+            }
+        }
+    }
+}
+
+/// Installs the logger, at `LOG_LEVEL` (`info` by default). When `LOG_FILE` is
+/// set, messages are also appended to it, rotated when it reaches `LOG_MAX_MB`
+/// (10 by default) and at each `LOG_ROTATE` period (`daily`, `hourly` or
+/// `size` for size only), with the newest `LOG_KEEP` (7) old files kept.
+pub fn init() {
+    let level = env::var("LOG_LEVEL")
+        .ok()
+        .and_then(|level| level.parse::<LevelFilter>().ok())
+        .unwrap_or(LevelFilter::Info);
+    let file = env::var("LOG_FILE").ok().and_then(|path| {
+        let max_mb = env::var("LOG_MAX_MB")
+            .ok()
+            .and_then(|max_mb| max_mb.parse::<u64>().ok())
+            .filter(|&max_mb| max_mb > 0)
+            .unwrap_or(DEFAULT_MAX_MB);
+        let rotation = env::var("LOG_ROTATE")
+            .ok()
+            .and_then(|rotation| Rotation::parse(&rotation))
+            .unwrap_or(Rotation::Daily);
+        let keep = env::var("LOG_KEEP")
+            .ok()
+            .and_then(|keep| keep.parse::<usize>().ok())
+            .unwrap_or(DEFAULT_KEEP);
+        match RotatingFile::open(PathBuf::from(path), max_mb * 1024 * 1024, rotation, keep) {
+            Ok(file) => Some(Mutex::new(file)),
+            Err(error) => {
+                eprintln!("File logging disabled: {}", error);
+                None
+            }
+        }
+    });
+
+    if log::set_boxed_logger(Box::new(Logger { file })).is_ok() {
+        log::set_max_level(level);
+    }
+}
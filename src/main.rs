@@ -1,48 +1,334 @@
 use application_command::ApplicationCommandInteraction;
 use command::CommandOptionType;
+use component::ButtonStyle;
 use dotenv::dotenv;
+use message_component::MessageComponentInteraction;
 use serenity::{
     async_trait,
-    builder::{CreateApplicationCommand, CreateEmbed},
+    builder::{CreateComponents, CreateEmbed},
+    client::bridge::gateway::{ShardId, ShardManager},
+    http::AttachmentType,
     model::{
         application::interaction::{Interaction, InteractionResponseType},
         gateway::Ready,
+        guild::{Guild, UnavailableGuild},
         id::GuildId,
         prelude::*,
     },
     prelude::*,
 };
-use std::env;
+use std::{
+    borrow::Cow,
+    collections::{HashMap, HashSet},
+    env,
+    sync::Arc,
+    time::{Duration, Instant, SystemTime, UNIX_EPOCH},
+};
+
+mod amount;
+mod api_keys;
+mod audit;
+mod backup;
+mod breaker;
+mod cache;
+mod calc;
+mod calculations;
+mod canvas;
+mod claims;
+mod commands;
+mod components;
+mod cooldowns;
+mod currency;
+mod debug;
+mod devex;
+mod disputes;
+mod explain;
+mod giveaways;
+mod i18n;
+mod identity;
+mod jsonpath;
+mod links;
+mod logging;
+mod metrics;
+mod offsite;
+mod orders;
+mod outbound;
+mod pagination;
+mod paypal;
+mod pdf;
+mod period;
+mod presence;
+mod pricing;
+mod proofs;
+mod rate_provider;
+mod ratecard;
+mod rates;
+mod redis;
+mod registration;
+mod reminders;
+mod reports;
+mod risk;
+mod roblox;
+mod rolimons;
+mod settings;
+mod singleflight;
+mod sla;
+mod stock;
+mod store;
+mod stripe;
+mod tenant;
+mod timezone;
+mod trace;
+mod trade;
+mod updates;
+mod webhooks;
 
 const ROBUX_TO_GBP_RATE: f64 = 0.0035;
-const GBP_TO_USD_RATE: f64 = 1.38;
 const ROBUX_MARKUP_RATE: f64 = 0.3;
+const MAX_FX_MARGIN_PERCENT: f64 = 20.0;
+const MAX_MARKUP_PERCENT: f64 = 90.0;
+const MAX_PAYMENT_SURCHARGE_PERCENT: f64 = 50.0;
+const MAX_PAYMENT_FEE_GBP: f64 = 100.0;
+/// Items `/rap` lists unless asked for more or fewer.
+const DEFAULT_RAP_TOP_ITEMS: usize = 10;
+/// Largest earned balance `/devex check` takes.
+const MAX_DEVEX_ROBUX: f64 = 10_000_000_000.0;
+/// Most Roblox packs a server can list for `/price`'s savings.
+const MAX_OFFICIAL_PACKAGES: usize = 12;
+/// Changes listed by `/ratecard import`, keeping its embed under Discord's limit.
+const IMPORT_PREVIEW_LINES: usize = 25;
+const LEADERBOARD_PAGE_SIZE: usize = 10;
+const HISTORY_PAGE_SIZE: usize = 5;
+const AUDIT_PAGE_SIZE: usize = 10;
+/// Unassigned orders listed on each page of `/order queue`.
+const QUEUE_PAGE_SIZE: usize = 15;
+/// An open order for the same buyer and amount created this recently is
+/// flagged as a possible duplicate.
+const DUPLICATE_WINDOW_SECS: u64 = 15 * 60;
+/// Longest comment accepted with a rating.
+const FEEDBACK_COMMENT_LENGTH: u64 = 500;
+/// Comments listed by `/stats service`, and how much of each is shown so
+/// they fit in one embed field.
+const RECENT_FEEDBACK_SIZE: usize = 5;
+const FEEDBACK_PREVIEW_LENGTH: usize = 150;
+/// Discord only accepts the initial response to an interaction within this window.
+const INTERACTION_RESPONSE_WINDOW_MS: u64 = 3000;
+const DISCORD_EPOCH_MS: u64 = 1_420_070_400_000;
+/// How long handled interaction IDs are remembered. Interactions can't be
+/// answered after 15 minutes, so a later redelivery fails on its own.
+const INTERACTION_DEDUP_SECS: u64 = 15 * 60;
+/// Furthest ahead a quote reminder can be set.
+const MAX_REMINDER_SECS: u64 = 7 * 24 * 60 * 60;
+/// How often warming the rate cache is tried after a boot or `/reload`, and
+/// how long to wait between tries while the exchange API is unavailable.
+const RATE_WARM_ATTEMPTS: u32 = 3;
+const RATE_WARM_RETRY_SECS: u64 = 30;
+/// Where buyers create gamepasses, and Roblox's walkthrough with screenshots.
+const GAMEPASS_CREATE_URL: &str = "https://create.roblox.com/dashboard/creations";
+const GAMEPASS_GUIDE_URL: &str =
+    "https://create.roblox.com/docs/production/monetization/game-passes";
+
+const NOT_SERVED_NOTICE: &str = "This bot isn't set up for this server.";
 
 struct Handler;
 
+/// Lets `/shutdown` stop every shard, the same as Ctrl+C does.
+struct ShardManagerKey;
+
+impl TypeMapKey for ShardManagerKey {
+    type Value = Arc<Mutex<ShardManager>>;
+}
+
 #[async_trait]
 impl EventHandler for Handler {
     async fn interaction_create(&self, ctx: Context, interaction: Interaction) {
-        if let Interaction::ApplicationCommand(command) = interaction {
-            let result = match command.data.name.as_str() {
-                "price" => handle_price_command(&ctx, &command).await,
-                "convert" => handle_convert_command(&ctx, &command).await,
-                "robux" => handle_robux_command(&ctx, &command).await,
-                "help" => handle_help_command(&ctx, &command).await,
-                _ => Err(format!("Unknown command: {}", command.data.name)),
-            };
+        if !first_delivery(&ctx, &interaction).await {
+            return;
+        }
+        match interaction {
+            Interaction::ApplicationCommand(command) => {
+                let tenant = match tenant::tenant(&ctx).await {
+                    Ok(tenant) => tenant,
+                    Err(error) => {
+                        log::error!("{}", error);
+                        return;
+                    }
+                };
+                let result = if !tenant.serves(command.guild_id) {
+                    respond_ephemeral(&ctx, &command, NOT_SERVED_NOTICE).await
+                } else if let Some(notice) = maintenance_notice(&ctx, command.user.id).await {
+                    respond_ephemeral(&ctx, &command, &notice).await
+                } else if is_disabled(&ctx, &command).await {
+                    let locale = response_locale(&ctx, command.guild_id, &command.locale).await;
+                    let notice = i18n::t(
+                        locale,
+                        "errors.command_disabled",
+                        &[("command", &command.data.name)],
+                    );
+                    respond_ephemeral(&ctx, &command, &notice).await
+                } else if let Some(notice) = cooldown_notice(&ctx, &command).await {
+                    respond_ephemeral(&ctx, &command, &notice).await
+                } else {
+                    let started = Instant::now();
+                    let result = trace::span(
+                        format!("/{}", command.data.name),
+                        trace::Kind::Server,
+                        vec![
+                            ("discord.command", command.data.name.clone()),
+                            (
+                                "discord.guild_id",
+                                command
+                                    .guild_id
+                                    .map(|guild_id| guild_id.to_string())
+                                    .unwrap_or_default(),
+                            ),
+                            ("discord.user_id", command.user.id.to_string()),
+                            ("bot.tenant", tenant.label().to_string()),
+                        ],
+                        dispatch_command(&ctx, &command),
+                    )
+                    .await;
+                    log::info!(
+                        "[{}] /{} by {} in {} took {} ({})",
+                        tenant.label(),
+                        command.data.name,
+                        command.user.id,
+                        command
+                            .guild_id
+                            .map_or_else(|| "DMs".to_string(), |guild_id| guild_id.to_string()),
+                        metrics::format_millis(started.elapsed().as_millis() as u64),
+                        if result.is_ok() { "ok" } else { "error" }
+                    );
+                    if let Ok(metrics) = metrics::metrics(&ctx).await {
+                        metrics
+                            .record(&command.data.name, started.elapsed(), result.is_ok())
+                            .await;
+                    }
+                    result
+                };
 
-            if let Err(error) = result {
-                eprintln!("Error handling command: {}", error);
-                respond_with_error(&ctx, &command, &error).await;
+                if let Err(error) = result {
+                    log::error!("Error handling command: {}", error);
+                    respond_with_error(&ctx, &command, &error).await;
+                }
             }
+            Interaction::MessageComponent(component) => {
+                if !serves(&ctx, component.guild_id).await {
+                    respond_to_component_with_error(&ctx, &component, NOT_SERVED_NOTICE).await;
+                    return;
+                }
+                if let Some(notice) = maintenance_notice(&ctx, component.user.id).await {
+                    respond_to_component_with_error(&ctx, &component, &notice).await;
+                    return;
+                }
+
+                let result = match components::parse(&component.data.custom_id) {
+                    Ok(id) => match id.kind {
+                        "history" => handle_history_page(&ctx, &component, &id).await,
+                        "help" => handle_help_page(&ctx, &component, &id).await,
+                        "audit" => handle_audit_page(&ctx, &component, &id).await,
+                        "queue" => handle_queue_page(&ctx, &component, &id).await,
+                        "leaderboard" => handle_leaderboard_page(&ctx, &component, &id).await,
+                        "link" => handle_link_verify(&ctx, &component, &id).await,
+                        "gamepass" => handle_gamepass_setup(&ctx, &component, &id).await,
+                        "feedback" => handle_feedback_rating(&ctx, &component, &id).await,
+                        "giveaway" => handle_giveaway_entry(&ctx, &component, &id).await,
+                        "proof" => handle_proof_request(&ctx, &component, &id).await,
+                        _ => Err(format!("Unknown component: {}", component.data.custom_id)),
+                    },
+                    Err(error) => Err(error),
+                };
+
+                if let Err(error) = result {
+                    log::error!("Error handling component: {}", error);
+                    respond_to_component_with_error(&ctx, &component, &error).await;
+                }
+            }
+            Interaction::ModalSubmit(modal) => {
+                if !serves(&ctx, modal.guild_id).await {
+                    respond_to_modal_with_error(&ctx, &modal, NOT_SERVED_NOTICE).await;
+                    return;
+                }
+                if let Some(notice) = maintenance_notice(&ctx, modal.user.id).await {
+                    respond_to_modal_with_error(&ctx, &modal, &notice).await;
+                    return;
+                }
+                let result = match components::parse(&modal.data.custom_id) {
+                    Ok(id) => match id.kind {
+                        "gamepass" => handle_gamepass_submit(&ctx, &modal, &id).await,
+                        "feedback" => handle_feedback_submit(&ctx, &modal, &id).await,
+                        _ => Err(format!("Unknown modal: {}", modal.data.custom_id)),
+                    },
+                    Err(error) => Err(error),
+                };
+
+                if let Err(error) = result {
+                    log::error!("Error handling modal: {}", error);
+                    respond_to_modal_with_error(&ctx, &modal, &error).await;
+                }
+            }
+            _ => {}
+        }
+    }
+
+    async fn message(&self, ctx: Context, msg: Message) {
+        // Only DMs matter: that's where buyers send payment proof.
+        if msg.guild_id.is_some() || msg.author.bot {
+            return;
+        }
+        let reply = match maintenance_notice(&ctx, msg.author.id).await {
+            Some(notice) => notice,
+            None => match handle_payment_proof(&ctx, &msg).await {
+                Ok(()) => return,
+                Err(error) => {
+                    log::error!("Error handling payment proof: {}", error);
+                    error
+                }
+            },
+        };
+        if let Err(why) = outbound::send(&ctx.http, || msg.channel_id.say(&ctx.http, &reply)).await
+        {
+            log::error!("Cannot reply to message: {:?}", why);
         }
     }
 
     async fn ready(&self, ctx: Context, ready: Ready) {
-        println!("{} is connected!", ready.user.name);
-        if let Err(error) = register_commands(&ctx).await {
-            eprintln!("Error registering commands: {}", error);
+        log::info!("{} is connected!", ready.user.name);
+        if let Err(error) = register_commands(&ctx, false).await {
+            log::error!("Error registering commands: {}", error);
+        }
+        let tenant = match tenant::tenant(&ctx).await {
+            Ok(tenant) => tenant,
+            Err(error) => {
+                log::error!("{}", error);
+                return;
+            }
+        };
+        if !tenant.first_ready() {
+            return;
+        }
+        presence::start(ctx.clone());
+        reports::start(ctx.clone(), &tenant.partition, tenant.cache.clone());
+        claims::start(ctx.clone(), tenant.cache.clone());
+        metrics::start(ctx.clone());
+        giveaways::start(ctx.clone(), tenant.cache.clone());
+        webhooks::start(ctx.clone(), &tenant.name);
+        debug::start(ctx.clone());
+        updates::start(ctx.clone());
+        offsite::start(tenant.clone());
+        reminders::start(ctx.clone(), tenant.cache.clone());
+        sla::start(ctx, tenant.cache.clone());
+    }
+
+    async fn guild_create(&self, ctx: Context, guild: Guild) {
+        if let Some(guilds) = ctx.data.read().await.get::<presence::GuildsKey>() {
+            guilds.write().await.insert(guild.id);
+        }
+    }
+
+    async fn guild_delete(&self, ctx: Context, incomplete: UnavailableGuild) {
+        if let Some(guilds) = ctx.data.read().await.get::<presence::GuildsKey>() {
+            guilds.write().await.remove(&incomplete.id);
         }
     }
 }
@@ -50,15 +336,294 @@ impl EventHandler for Handler {
 #[tokio::main]
 async fn main() -> Result<(), Box<dyn std::error::Error>> {
     dotenv().ok();
-    let token = env::var("DISCORD_TOKEN")?;
-    let intents = GatewayIntents::GUILD_MESSAGES | GatewayIntents::MESSAGE_CONTENT;
+    logging::init();
+    trace::start();
+    components::init().await?;
+
+    let cache = cache::Cache::from_env()?;
+    let mut clients = Vec::new();
+    for tenant in tenant::configured(&cache)? {
+        clients.push(client(tenant).await?);
+    }
+
+    let shard_managers: Vec<_> = clients
+        .iter()
+        .map(|client| client.shard_manager.clone())
+        .collect();
+    tokio::spawn(async move {
+        if tokio::signal::ctrl_c().await.is_ok() {
+            log::info!("Shutting down");
+            for shard_manager in shard_managers {
+                shard_manager.lock().await.shutdown_all().await;
+            }
+        }
+    });
+
+    // Each bot runs until it is shut down; one failing leaves the others up.
+    let mut running = tokio::task::JoinSet::new();
+    for mut client in clients {
+        running.spawn(async move { client.start().await });
+    }
+    let mut failure = None;
+    while let Some(result) = running.join_next().await {
+        match result {
+            Ok(Ok(())) => {}
+            Ok(Err(error)) => {
+                log::error!("Client error: {:?}", error);
+                failure.get_or_insert(error);
+            }
+            Err(error) => log::error!("Client task failed: {}", error),
+        }
+    }
+    match failure {
+        Some(error) => Err(error.into()),
+        None => Ok(()),
+    }
+}
+
+/// Builds the client for one bot, with its stores opened in its own data
+/// partition.
+async fn client(tenant: tenant::Tenant) -> Result<Client, Box<dyn std::error::Error>> {
+    let intents = GatewayIntents::GUILDS
+        | GatewayIntents::GUILD_MESSAGES
+        | GatewayIntents::DIRECT_MESSAGES
+        | GatewayIntents::MESSAGE_CONTENT;
+    let tenant = Arc::new(tenant);
+    let partition = &tenant.partition;
+    let settings = settings::open(partition)?;
+    let rate_service = Arc::new(rate_service(&tenant, &*settings.read().await).await?);
+    warm_rate_cache(rate_service.clone(), settings.read().await.currency_pairs());
 
-    let mut client = Client::builder(&token, intents)
+    let client = Client::builder(&tenant.token, intents)
         .event_handler(Handler)
+        .type_map_insert::<tenant::TenantKey>(tenant.clone())
+        .type_map_insert::<settings::SettingsKey>(Arc::new(settings))
+        .type_map_insert::<rates::RatesKey>(rate_service)
+        .type_map_insert::<orders::OrdersKey>(Arc::new(orders::OrderStore::open(
+            partition,
+            &tenant.cache,
+        )?))
+        .type_map_insert::<calculations::CalculationsKey>(Arc::new(
+            calculations::CalculationStore::open(partition)?,
+        ))
+        .type_map_insert::<audit::AuditKey>(Arc::new(audit::AuditStore::open(partition)?))
+        .type_map_insert::<stock::StockKey>(Arc::new(stock::StockStore::open(
+            partition,
+            &tenant.cache,
+        )?))
+        .type_map_insert::<links::LinksKey>(Arc::new(links::LinkStore::open(partition)?))
+        .type_map_insert::<disputes::DisputesKey>(Arc::new(disputes::DisputeStore::open(
+            partition,
+        )?))
+        .type_map_insert::<proofs::ProofRequestsKey>(Arc::new(proofs::ProofRequests::default()))
+        .type_map_insert::<cooldowns::CooldownsKey>(Arc::new(cooldowns::Cooldowns::new(
+            tenant.cache.clone(),
+        )))
+        .type_map_insert::<metrics::MetricsKey>(Arc::new(metrics::Metrics::default()))
+        .type_map_insert::<risk::FlagsKey>(Arc::new(risk::FlagStore::open(partition)?))
+        .type_map_insert::<giveaways::GiveawaysKey>(Arc::new(giveaways::GiveawayStore::open(
+            partition,
+        )?))
+        .type_map_insert::<presence::GuildsKey>(Arc::new(RwLock::new(HashSet::new())))
         .await?;
 
-    client.start().await?;
-    Ok(())
+    let shard_manager = client.shard_manager.clone();
+    client
+        .data
+        .write()
+        .await
+        .insert::<ShardManagerKey>(shard_manager);
+    Ok(client)
+}
+
+/// Fetches the rates commands will need in the background, so the first
+/// `/price` after a boot or `/reload` isn't slowed down or failed by the
+/// exchange API. Retries while any pair can only be served from fallbacks.
+fn warm_rate_cache(rates: Arc<rates::RateService>, pairs: Vec<(String, String)>) {
+    tokio::spawn(async move {
+        for attempt in 1..=RATE_WARM_ATTEMPTS {
+            let missed = rates.warm(&pairs).await;
+            if missed.is_empty() {
+                log::info!("Cached {} exchange rates", pairs.len());
+                return;
+            }
+            log::error!(
+                "Couldn't cache exchange rates for {} (attempt {} of {})",
+                missed.join(", "),
+                attempt,
+                RATE_WARM_ATTEMPTS
+            );
+            if attempt < RATE_WARM_ATTEMPTS {
+                tokio::time::sleep(Duration::from_secs(RATE_WARM_RETRY_SECS)).await;
+            }
+        }
+    });
+}
+
+/// Builds the exchange rate service from the configured provider and keys,
+/// keeping its state in `tenant`'s partition and cache.
+async fn rate_service(
+    tenant: &tenant::Tenant,
+    settings: &settings::Settings,
+) -> Result<rates::RateService, String> {
+    let provider = rate_provider::RateProvider::from_settings(&settings.rate_provider)?;
+    let keys =
+        api_keys::KeyRing::from_env(&tenant.partition, settings.rate_provider.monthly_quota)?;
+    rates::RateService::new(
+        &tenant.partition,
+        tenant.cache.clone(),
+        provider,
+        keys,
+        settings.fallback_rates.clone(),
+    )
+    .await
+}
+
+async fn dispatch_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    match command.data.name.as_str() {
+        "price" => handle_price_command(ctx, command).await,
+        "convert" => handle_convert_command(ctx, command).await,
+        "calc" => handle_calc_command(ctx, command).await,
+        "robux" => handle_robux_command(ctx, command).await,
+        "beforetax" => {
+            handle_tax_conversion_command(ctx, command, pricing::PriceType::BeforeTax).await
+        }
+        "aftertax" => {
+            handle_tax_conversion_command(ctx, command, pricing::PriceType::AfterTax).await
+        }
+        "rate" => handle_rate_command(ctx, command).await,
+        "quota" => handle_quota_command(ctx, command).await,
+        "metrics" => handle_metrics_command(ctx, command).await,
+        "ping" => handle_ping_command(ctx, command).await,
+        "about" => handle_about_command(ctx, command).await,
+        "loglevel" => handle_loglevel_command(ctx, command).await,
+        "maintenance" => handle_maintenance_command(ctx, command).await,
+        "reload" => handle_reload_command(ctx, command).await,
+        "shutdown" => handle_shutdown_command(ctx, command).await,
+        "fxmargin" => handle_fxmargin_command(ctx, command).await,
+        "setmarkup" => handle_setmarkup_command(ctx, command).await,
+        "setrate" => handle_setrate_command(ctx, command).await,
+        "tax" => handle_tax_command(ctx, command).await,
+        "ephemeral" => handle_ephemeral_command(ctx, command).await,
+        "rolepricing" => handle_role_pricing_command(ctx, command).await,
+        "spendtiers" => handle_spend_tiers_command(ctx, command).await,
+        "ratecard" => handle_ratecard_command(ctx, command).await,
+        "seller" => handle_seller_command(ctx, command).await,
+        "paymentmethod" => handle_payment_method_command(ctx, command).await,
+        "compare" => handle_compare_command(ctx, command).await,
+        "devex" => handle_devex_command(ctx, command).await,
+        "limited" => handle_limited_command(ctx, command).await,
+        "rap" => handle_rap_command(ctx, command).await,
+        "tradevalue" => handle_tradevalue_command(ctx, command).await,
+        "order" => handle_order_command(ctx, command).await,
+        "link" => handle_link_command(ctx, command).await,
+        "timezone" => handle_timezone_command(ctx, command).await,
+        "escrow" => handle_escrow_command(ctx, command).await,
+        "dispute" => handle_dispute_command(ctx, command).await,
+        "giveaway" => handle_giveaway_command(ctx, command).await,
+        "flag" => handle_flag_command(ctx, command).await,
+        "serverconfig" => handle_serverconfig_command(ctx, command).await,
+        "export" => handle_export_command(ctx, command).await,
+        "backup" => handle_backup_command(ctx, command).await,
+        "stats" => handle_stats_command(ctx, command).await,
+        "leaderboard" => handle_leaderboard_command(ctx, command).await,
+        "history" => handle_history_command(ctx, command).await,
+        "explain" => handle_explain_command(ctx, command).await,
+        "remindme" => handle_remindme_command(ctx, command).await,
+        "help" => handle_help_command(ctx, command).await,
+        _ => Err(format!("Unknown command: {}", command.data.name)),
+    }
+}
+
+/// Whether this is the first time `interaction` has arrived. Discord
+/// occasionally delivers one twice, and handling both would record an order or
+/// move stock twice. Instances sharing a cache also see each other's.
+async fn first_delivery(ctx: &Context, interaction: &Interaction) -> bool {
+    let tenant = match tenant::tenant(ctx).await {
+        Ok(tenant) => tenant,
+        Err(_) => return true,
+    };
+    let id = interaction.id();
+    let first = tenant
+        .cache
+        .set_new(
+            &format!("interaction:{}", id),
+            "1",
+            Duration::from_secs(INTERACTION_DEDUP_SECS),
+        )
+        .await;
+    if !first {
+        log::warn!("[{}] Dropped duplicate interaction {}", tenant.label(), id);
+    }
+    first
+}
+
+/// Whether this bot serves `guild_id`, when it is limited to a group of
+/// servers. Other servers get `NOT_SERVED_NOTICE`, so one community's bot
+/// can't be used, or see its data, from outside that community.
+async fn serves(ctx: &Context, guild_id: Option<GuildId>) -> bool {
+    tenant::tenant(ctx)
+        .await
+        .map_or(false, |tenant| tenant.serves(guild_id))
+}
+
+/// The notice to answer `user_id` with while maintenance mode is on. The bot
+/// owner is never blocked, so they can still test and turn it off.
+async fn maintenance_notice(ctx: &Context, user_id: UserId) -> Option<String> {
+    if owner_id() == Some(user_id.0) {
+        return None;
+    }
+    let settings = settings::store(ctx).await.ok()?;
+    let settings = settings.read().await;
+    settings
+        .maintenance
+        .enabled
+        .then(|| settings.maintenance.notice())
+}
+
+/// The notice to answer `command` with while its invoker is on cooldown for
+/// it. Members with a bypass role and the bot owner are never held back.
+async fn cooldown_notice(ctx: &Context, command: &ApplicationCommandInteraction) -> Option<String> {
+    if owner_id() == Some(command.user.id.0) {
+        return None;
+    }
+    let roles: Vec<u64> = command
+        .member
+        .as_ref()
+        .map(|member| member.roles.iter().map(|role| role.0).collect())
+        .unwrap_or_default();
+    let seconds = guild_settings(ctx, command)
+        .await
+        .ok()?
+        .cooldowns
+        .seconds_for(&roles)?;
+    let wait = cooldowns::cooldowns(ctx)
+        .await
+        .ok()?
+        .check(command.user.id.0, &command.data.name, seconds)
+        .await?;
+    let now = store::now();
+
+    let locale = response_locale(ctx, command.guild_id, &command.locale).await;
+    Some(i18n::t(
+        locale,
+        "errors.cooldown",
+        &[
+            ("command", &command.data.name),
+            ("retry", &format!("<t:{}:R>", now + wait)),
+        ],
+    ))
+}
+
+/// Whether the invoking guild has turned the command off with `/serverconfig`.
+async fn is_disabled(ctx: &Context, command: &ApplicationCommandInteraction) -> bool {
+    guild_settings(ctx, command)
+        .await
+        .map(|settings| settings.disabled_commands.contains(&command.data.name))
+        .unwrap_or(false)
 }
 
 async fn handle_price_command(
@@ -77,264 +642,8217 @@ async fn handle_price_command(
         .ok_or("Missing price type")?
         .as_str()
         .ok_or("Invalid price type")?;
-    let amount = options[1]
-        .value
+    let amount = amount::parse_robux(
+        options[1]
+            .value
+            .as_ref()
+            .ok_or("Missing amount")?
+            .as_str()
+            .ok_or("Invalid amount")?,
+    )?;
+
+    let price_type =
+        pricing::PriceType::parse(price_type).ok_or("Invalid type. Use 'b/t' or 'a/t'.")?;
+
+    let guild_settings = guild_settings(ctx, command).await?;
+    let roles: Vec<u64> = command
+        .member
+        .as_ref()
+        .map(|member| member.roles.iter().map(|role| role.0).collect())
+        .unwrap_or_default();
+    let role_pricing = guild_settings.role_pricing_for(&roles).cloned();
+    let discount_percent = role_pricing
         .as_ref()
-        .ok_or("Missing amount")?
-        .as_u64()
-        .ok_or("Invalid amount")? as f64;
+        .map_or(0.0, |pricing| pricing.discount_percent);
 
-    let (rate, is_after_tax) = match price_type {
-        "b/t" => (ROBUX_TO_GBP_RATE, false),
-        "a/t" => (ROBUX_TO_GBP_RATE / (1.0 - ROBUX_MARKUP_RATE), true),
-        _ => return Err("Invalid type. Use 'b/t' or 'a/t'.".to_string()),
+    let seller = match command
+        .data
+        .options
+        .iter()
+        .find(|option| option.name == "seller")
+        .and_then(|option| option.value.as_ref())
+        .and_then(|seller| seller.as_str())
+    {
+        Some(name) => Some(
+            guild_settings
+                .seller(name)
+                .cloned()
+                .ok_or_else(|| format!("Unknown seller '{}'. See /seller list.", name))?,
+        ),
+        None => None,
+    };
+    let method = match command
+        .data
+        .options
+        .iter()
+        .find(|option| option.name == "method")
+        .and_then(|option| option.value.as_ref())
+        .and_then(|method| method.as_str())
+    {
+        Some(method) => pricing::DeliveryMethod::parse(method)
+            .ok_or("Invalid method. Use 'gamepass', 'group' or 'gift'.")?,
+        None => pricing::DeliveryMethod::Gamepass,
+    };
+    let payment = match command
+        .data
+        .options
+        .iter()
+        .find(|option| option.name == "payment")
+        .and_then(|option| option.value.as_ref())
+        .and_then(|payment| payment.as_str())
+    {
+        Some(name) => {
+            let payment = guild_settings
+                .payment_method(name)
+                .cloned()
+                .ok_or_else(|| format!("Unknown payment method '{}'. See /ratecard view.", name))?;
+            if !payment.enabled {
+                return Err(format!("{} isn't accepted at the moment", payment.name));
+            }
+            Some(payment)
+        }
+        None => None,
+    };
+    let card = match &seller {
+        Some(seller) => guild_settings.seller_rate_card(seller),
+        None => guild_settings.rate_card(),
+    };
+    let discount = 1.0 - discount_percent / 100.0;
+    // Payment fees go on after discounts, since they cover what is charged.
+    let with_payment_fee = |gbp: f64| payment.as_ref().map_or(gbp, |payment| payment.total(gbp));
+    let net_gbp = card.gbp_price_via(amount, price_type, method) * discount;
+    let gbp_amount = with_payment_fee(net_gbp);
+    let gbp_to_usd = guild_rate(ctx, command, "GBP", "USD").await?;
+    // A currency the buyer asked for comes first, before the guild's usual ones.
+    let mut display_currencies = guild_settings.display_currencies();
+    let requested_currency = match command
+        .data
+        .options
+        .iter()
+        .find(|option| option.name == "currency")
+        .and_then(|option| option.value.as_ref())
+        .and_then(|currency| currency.as_str())
+    {
+        Some(currency) => {
+            let currency = rates::parse_currency(currency)?;
+            display_currencies.retain(|code| code != &currency);
+            display_currencies.insert(0, currency.clone());
+            Some(currency)
+        }
+        None => None,
+    };
+    if let Some(payment) = payment
+        .as_ref()
+        .filter(|payment| !payment.currencies.is_empty())
+    {
+        if let Some(currency) = &requested_currency {
+            if !payment.accepts(currency) {
+                return Err(format!(
+                    "{} only takes {}",
+                    payment.name,
+                    payment.currencies.join(", ")
+                ));
+            }
+        }
+        display_currencies.retain(|code| payment.accepts(code));
+        if display_currencies.is_empty() {
+            display_currencies = payment.currencies.clone();
+        }
+    }
+    let large_amount_threshold = guild_settings.large_amount_threshold();
+    let official_packages = guild_settings.official_packages();
+    let tax = guild_settings.tax;
+    let tax_gbp = tax.as_ref().map_or(0.0, |tax| tax.on(gbp_amount));
+    let gross_gbp = gbp_amount + tax_gbp;
+    let robux_spent = card.robux_spent(amount, price_type, method);
+    let (gamepass_price, fee_robux) = match method {
+        pricing::DeliveryMethod::Gamepass => (robux_spent, card.marketplace_fee(robux_spent)),
+        _ => (0, 0),
     };
 
-    let gbp_amount = amount * rate;
-    let gamepass_price = if is_after_tax {
-        (amount / (1.0 - ROBUX_MARKUP_RATE)).round() as i64
-    } else {
-        amount as i64
+    let display_rates = guild_rates(ctx, command, "GBP", &display_currencies).await?;
+    if let Some(currency) = &requested_currency {
+        if !display_rates.iter().any(|rate| &rate.quote == currency) {
+            return Err(format!("No exchange rate is available for {}", currency));
+        }
+    }
+    let role_rate = match role_pricing
+        .as_ref()
+        .and_then(|pricing| pricing.currency.as_ref())
+        .filter(|currency| !display_currencies.contains(&currency.to_uppercase()))
+        .filter(|currency| {
+            payment
+                .as_ref()
+                .map_or(true, |payment| payment.accepts(&currency.to_uppercase()))
+        }) {
+        Some(currency) => Some(guild_rate(ctx, command, "GBP", currency).await?),
+        None => None,
     };
+    let mut rate_snapshots = vec![gbp_to_usd.snapshot()];
+    rate_snapshots.extend(
+        display_rates
+            .iter()
+            .chain(&role_rate)
+            .filter(|rate| rate.base != rate.quote && rate.quote != gbp_to_usd.quote)
+            .map(|rate| rate.snapshot()),
+    );
 
-    let embed = CreateEmbed::default()
+    let buyer_roblox = roblox_username(ctx, command.guild_id, command.user.id.0).await;
+
+    let order = orders::store(ctx)
+        .await?
+        .record(orders::Order {
+            id: 0,
+            guild_id: command.guild_id.map(|guild_id| guild_id.0),
+            buyer_id: command.user.id.0,
+            buyer_name: command.user.name.clone(),
+            buyer_roblox,
+            robux: amount as u64,
+            gamepass_price,
+            after_tax: price_type.is_after_tax(),
+            fee_robux,
+            robux_to_gbp_rate: card.gbp_rate(price_type),
+            gbp_to_usd_rate: gbp_to_usd.value,
+            fx_margin_percent: gbp_to_usd.margin_percent,
+            discount_percent,
+            tax_gbp,
+            total_gbp: gross_gbp,
+            total_usd: gross_gbp * gbp_to_usd.value,
+            status: orders::OrderStatus::Quoted,
+            seller: seller.as_ref().map(|seller| seller.name.clone()),
+            method,
+            payment_method: payment.as_ref().map(|payment| payment.name.clone()),
+            gamepass_id: None,
+            listed_price: None,
+            created_at: 0,
+            history: Vec::new(),
+            delivery_proof: None,
+            refunds: Vec::new(),
+            feedback: None,
+            dispute_id: None,
+            dispute_decision: None,
+            escrow: None,
+            sla_reminded_at: None,
+            sla_escalated_at: None,
+            risk: None,
+            payment_proofs: Vec::new(),
+            payment: None,
+            payment_link: None,
+            rate_snapshots,
+            claimed_by: None,
+            claimed_at: None,
+            reminder: None,
+        })
+        .await;
+
+    let mut description = format!(
+        "**Conversion Type:** {}\n**Amount of Robux:** {}\n**Markup:** {}\n**Delivery:** {}",
+        price_type.label(),
+        amount as i64,
+        card.markup_percent(),
+        method.label()
+    );
+    if let Some(payment) = &payment {
+        description.push_str(&format!(
+            "\n**Payment:** {} ({}, £{:.2} added)",
+            payment.name,
+            payment.fee(),
+            gbp_amount - net_gbp
+        ));
+    }
+    let mut embed = CreateEmbed::default()
         .title("Price Calculation")
-        .description(format!(
-            "**Conversion Type:** {}\n**Amount of Robux:** {}",
-            price_type, amount as i64
-        ))
-        .field("Gamepass Price", format!("{} R$", gamepass_price), true)
-        .field("Amount in GBP", format!("£{:.2}", gbp_amount), true)
+        .description(description)
         .field(
-            "Amount in USD",
-            format!("${:.2}", gbp_amount * GBP_TO_USD_RATE),
+            match method {
+                pricing::DeliveryMethod::Gamepass => "Gamepass Price",
+                _ => "Robux Sent",
+            },
+            format!("{} R$", robux_spent),
             true,
         )
         .color(0x0096FF)
         .clone();
+    for rate in &display_rates {
+        embed.field(
+            format!("Amount in {}", rate.quote),
+            currency_lines(&rate.quote, gbp_amount * rate.value, tax.as_ref()),
+            true,
+        );
+    }
+    if let Some(pricing) = &role_pricing {
+        if let Some(gbp_to_currency) = &role_rate {
+            embed.field(
+                format!("Amount in {}", gbp_to_currency.quote),
+                currency_lines(
+                    &gbp_to_currency.quote,
+                    gbp_amount * gbp_to_currency.value,
+                    tax.as_ref(),
+                ),
+                true,
+            );
+        }
+        if pricing.discount_percent > 0.0 {
+            embed.field(
+                "Role Pricing",
+                format!(
+                    "<@&{}>: {}% discount applied",
+                    pricing.role_id, pricing.discount_percent
+                ),
+                false,
+            );
+        }
+    }
+    embed.field(
+        "Delivery Methods",
+        pricing::DeliveryMethod::ALL
+            .iter()
+            .map(|&option| {
+                format!(
+                    "{}**{}**: £{:.2} for {} R$ ({})",
+                    if option == method { "▸ " } else { "" },
+                    option.label(),
+                    with_payment_fee(card.gbp_price_via(amount, price_type, option) * discount),
+                    card.robux_spent(amount, price_type, option),
+                    option.terms()
+                )
+            })
+            .collect::<Vec<_>>()
+            .join("\n"),
+        false,
+    );
+    if let (Some(seller), Some(guild_id)) = (&seller, command.guild_id) {
+        let in_stock = stock::store(ctx)
+            .await?
+            .balance(guild_id.0, &seller.name)
+            .await;
+        embed.field(
+            format!("Seller: {}", seller.name),
+            if in_stock < robux_spent as i64 {
+                format!(
+                    "{} R$ in stock ⚠️ not enough for this order ({} R$ needed)",
+                    in_stock, robux_spent
+                )
+            } else {
+                format!("{} R$ in stock", in_stock)
+            },
+            false,
+        );
+    }
+    let received = card.received(amount, price_type);
+    if let Some(official) = pricing::OfficialPrice::cheapest(received, &official_packages)
+        .filter(|official| official.gbp > gross_gbp)
+    {
+        embed.field(
+            "Savings vs Roblox",
+            format!(
+                "Buying {} R$ from Roblox costs **£{:.2}** ({}, {} R$ in all). This price saves **£{:.2}** ({:.0}%).",
+                amount::group_thousands(received as f64),
+                official.gbp,
+                official.describe(),
+                amount::group_thousands(official.robux as f64),
+                official.gbp - gross_gbp,
+                (official.gbp - gross_gbp) / official.gbp * 100.0
+            ),
+            false,
+        );
+    }
+    if shows_math(command) {
+        let mut steps = card.robux_steps(amount, price_type, method);
+        let base_gbp = robux_spent as f64 * card.gbp_per_robux;
+        steps.push(format!(
+            "Price: {} R$ × £{} = £{:.4}",
+            robux_spent, card.gbp_per_robux, base_gbp
+        ));
+        if discount_percent > 0.0 {
+            steps.push(format!(
+                "Role discount: £{:.4} × (1 − {}%) = £{:.4}",
+                base_gbp, discount_percent, net_gbp
+            ));
+        }
+        if let Some(payment) = &payment {
+            steps.push(format!(
+                "{} fee: £{:.4} × (1 + {}%) + £{:.2} = £{:.4}",
+                payment.name, net_gbp, payment.surcharge_percent, payment.fixed_fee_gbp, gbp_amount
+            ));
+        }
+        if let Some(tax) = &tax {
+            steps.push(format!(
+                "{}: £{:.4} × {}% = £{:.4}",
+                tax.label, gbp_amount, tax.rate_percent, tax_gbp
+            ));
+            steps.push(format!(
+                "Total: £{:.4} + £{:.4} = £{:.4}",
+                gbp_amount, tax_gbp, gross_gbp
+            ));
+        }
+        for rate in display_rates.iter().filter(|rate| rate.quote != "GBP") {
+            steps.push(format!(
+                "In {}: £{:.4} × {}{} = {:.4}",
+                rate.quote,
+                gross_gbp,
+                rate.value,
+                if rate.margin_percent != 0.0 {
+                    format!(" (with a {:+.2}% FX margin)", rate.margin_percent)
+                } else {
+                    String::new()
+                },
+                gross_gbp * rate.value
+            ));
+        }
+        steps.push("Amounts are only rounded to 2 decimal places when shown.".to_string());
+        add_math_fields(&mut embed, &steps);
+    }
+    add_large_amount_warning(&mut embed, large_amount_threshold, amount);
+    add_rate_notes(&mut embed, &gbp_to_usd);
+    let stamp = gbp_to_usd
+        .snapshot()
+        .stamp(utc_offset(ctx, command.guild_id, command.user.id.0).await);
+    match order {
+        Ok(order) => {
+            embed.footer(|footer| footer.text(format!("Quote #{} • {}", order.id, stamp)));
+        }
+        Err(error) => {
+            log::error!("Error recording order: {}", error);
+            embed.footer(|footer| footer.text(stamp));
+        }
+    }
 
-    send_embed_response(ctx, command, embed).await
+    send_calculation_response(ctx, command, embed).await
 }
 
-async fn handle_convert_command(
+/// Lists what an amount of Robux costs through each enabled payment method,
+/// cheapest first.
+async fn handle_compare_command(
     ctx: &Context,
     command: &ApplicationCommandInteraction,
 ) -> Result<(), String> {
-    let options = &command.data.options;
+    let option = |name: &str| {
+        command
+            .data
+            .options
+            .iter()
+            .find(|option| option.name == name)
+            .and_then(|option| option.value.as_ref())
+            .and_then(|value| value.as_str())
+    };
+    let amount = amount::parse_robux(option("amount").ok_or("Missing amount")?)?;
+    let price_type = match option("type") {
+        Some(price_type) => {
+            pricing::PriceType::parse(price_type).ok_or("Invalid type. Use 'b/t' or 'a/t'.")?
+        }
+        None => pricing::PriceType::BeforeTax,
+    };
 
-    if options.len() < 2 {
-        return Err("Insufficient command options".to_string());
+    let guild_settings = guild_settings(ctx, command).await?;
+    let mut methods: Vec<settings::PaymentMethod> = guild_settings
+        .payment_methods
+        .iter()
+        .filter(|method| method.enabled)
+        .cloned()
+        .collect();
+    if methods.is_empty() {
+        return Err(
+            "No payment methods are set up yet. Staff can add them with /paymentmethod set"
+                .to_string(),
+        );
     }
-
-    let currency = options[0]
-        .value
-        .as_ref()
-        .ok_or("Missing currency")?
-        .as_str()
-        .ok_or("Invalid currency")?;
-    let amount = options[1]
-        .value
+    let roles: Vec<u64> = command
+        .member
         .as_ref()
-        .ok_or("Missing amount")?
-        .as_f64()
-        .ok_or("Invalid amount")?;
+        .map(|member| member.roles.iter().map(|role| role.0).collect())
+        .unwrap_or_default();
+    let discount_percent = guild_settings
+        .role_pricing_for(&roles)
+        .map_or(0.0, |pricing| pricing.discount_percent);
+    let net_gbp =
+        guild_settings.rate_card().gbp_price(amount, price_type) * (1.0 - discount_percent / 100.0);
+    let tax = guild_settings.tax;
+    let gross = |method: &settings::PaymentMethod| {
+        let total = method.total(net_gbp);
+        total + tax.as_ref().map_or(0.0, |tax| tax.on(total))
+    };
+    methods.sort_by(|a, b| gross(a).total_cmp(&gross(b)));
 
-    let (from_currency, to_currency, converted_amount) = match currency {
-        "GBP" => ("GBP", "USD", amount * GBP_TO_USD_RATE),
-        "USD" => ("USD", "GBP", amount / GBP_TO_USD_RATE),
-        _ => return Err("Invalid currency. Use 'GBP' or 'USD'.".to_string()),
+    // Methods that don't take GBP are also priced in the first currency they do.
+    let mut quotes: Vec<String> = Vec::new();
+    for method in &methods {
+        if let Some(currency) = method.currencies.first().filter(|_| !method.accepts("GBP")) {
+            if !quotes.contains(currency) {
+                quotes.push(currency.clone());
+            }
+        }
+    }
+    let rates = if quotes.is_empty() {
+        Vec::new()
+    } else {
+        guild_rates(ctx, command, "GBP", &quotes).await?
     };
 
-    let embed = CreateEmbed::default()
-        .title("Currency Conversion")
-        .field(
-            format!("Amount in {}", from_currency),
-            format!("{:.2}", amount),
-            true,
-        )
-        .field(
-            format!("Amount in {}", to_currency),
-            format!("{:.2}", converted_amount),
-            true,
-        )
+    let lines: Vec<String> = methods
+        .iter()
+        .enumerate()
+        .map(|(index, method)| {
+            let converted = method
+                .currencies
+                .first()
+                .filter(|_| !method.accepts("GBP"))
+                .and_then(|currency| rates.iter().find(|rate| &rate.quote == currency))
+                .map(|rate| {
+                    let currency = ratecard::Currency {
+                        code: rate.quote.clone(),
+                        gbp_rate: rate.value,
+                    };
+                    format!(" ≈ {}", currency.format(gross(method) * rate.value))
+                })
+                .unwrap_or_default();
+            format!(
+                "{}**{}**: £{:.2}{} ({})",
+                if index == 0 { "▸ " } else { "" },
+                method.name,
+                gross(method),
+                converted,
+                method.describe()
+            )
+        })
+        .collect();
+
+    let mut description = format!(
+        "**Amount of Robux:** {} {}\n**Price before payment fees:** £{:.2}",
+        amount as i64,
+        price_type.label(),
+        net_gbp
+    );
+    if discount_percent > 0.0 {
+        description.push_str(&format!(" ({}% role discount)", discount_percent));
+    }
+    let mut embed = CreateEmbed::default()
+        .title("Payment Methods Compared")
+        .description(description)
+        .field("Total Cost", lines.join("\n"), false)
         .color(0x0096FF)
         .clone();
+    embed.footer(|footer| {
+        footer.text(match &tax {
+            Some(tax) => format!("Cheapest first • Totals include {}", tax.describe()),
+            None => "Cheapest first".to_string(),
+        })
+    });
 
-    send_embed_response(ctx, command, embed).await
+    send_calculation_response(ctx, command, embed).await
 }
 
-async fn handle_robux_command(
+/// Checks a member against Roblox's DevEx requirements and estimates the
+/// payout. Earned Robux can't be looked up, so the member gives them; the
+/// account's standing is checked on Roblox when there's an account to check.
+async fn handle_devex_command(
     ctx: &Context,
     command: &ApplicationCommandInteraction,
 ) -> Result<(), String> {
-    let options = &command.data.options;
-
-    if options.len() < 2 {
-        return Err("Insufficient command options".to_string());
+    let subcommand = command.data.options.first().ok_or("Missing subcommand")?;
+    if subcommand.name != "check" {
+        return Err(format!("Unknown DevEx command: {}", subcommand.name));
     }
+    let option = |name: &str| {
+        subcommand
+            .options
+            .iter()
+            .find(|option| option.name == name)
+            .and_then(|option| option.value.as_ref())
+    };
+    let earned = amount::parse(
+        option("earned")
+            .and_then(|earned| earned.as_str())
+            .ok_or("Missing earned Robux")?,
+    )?;
+    if !earned.is_finite() || earned < 0.0 || earned.fract() != 0.0 || earned > MAX_DEVEX_ROBUX {
+        return Err(format!(
+            "Earned Robux must be a whole number up to {}",
+            amount::group_thousands(MAX_DEVEX_ROBUX)
+        ));
+    }
+    let earned = earned as u64;
+    let answer = |name: &str| option(name).and_then(|answer| answer.as_bool());
+    let manual = |name: &'static str, answer: Option<bool>, hint: &str| devex::Requirement {
+        name,
+        status: devex::Status::from_answer(answer),
+        detail: match answer {
+            Some(true) => "Yes".to_string(),
+            Some(false) => "No".to_string(),
+            None => format!("Not answered; add {}:True if it applies", hint),
+        },
+    };
 
-    let currency = options[0]
-        .value
-        .as_ref()
-        .ok_or("Missing currency")?
-        .as_str()
-        .ok_or("Invalid currency")?;
-    let amount = options[1]
-        .value
-        .as_ref()
-        .ok_or("Missing amount")?
-        .as_f64()
-        .ok_or("Invalid amount")?;
-
-    let (gbp_amount, usd_amount) = match currency {
-        "GBP" => (amount, amount * GBP_TO_USD_RATE),
-        "USD" => (amount / GBP_TO_USD_RATE, amount),
-        _ => return Err("Invalid currency. Use 'GBP' or 'USD'.".to_string()),
+    let account = named_or_linked_account(
+        ctx,
+        command,
+        option("roblox").and_then(|roblox| roblox.as_str()),
+    )
+    .await?;
+    let mut standing = manual(
+        "Account in good standing",
+        answer("good_standing"),
+        "good_standing",
+    );
+    let mut description = String::new();
+    if let Some(account) = &account {
+        description.push_str(&format!(
+            "**Account:** [{}]({})\n",
+            account.name,
+            roblox::profile_url(account.id)
+        ));
+        match roblox::account_status(account.id).await {
+            Ok(status) => {
+                if let Ok(created) = chrono::DateTime::parse_from_rfc3339(&status.created) {
+                    description.push_str(&format!("**Created:** <t:{}:D>\n", created.timestamp()));
+                }
+                if status.is_banned {
+                    standing.status = devex::Status::NotMet;
+                    standing.detail = format!("{} is banned on Roblox", account.name);
+                } else if standing.status == devex::Status::Unknown {
+                    standing.status = devex::Status::Met;
+                    standing.detail = format!("{} isn't banned on Roblox", account.name);
+                }
+            }
+            Err(error) => log::warn!("Error checking Roblox account {}: {}", account.id, error),
+        }
+    }
+    let requirements = [
+        devex::Requirement::earned(earned),
+        manual("13 or older", answer("over_13"), "over_13"),
+        manual(
+            "Verified email address",
+            answer("verified_email"),
+            "verified_email",
+        ),
+        standing,
+    ];
+    let (verdict, color) = match devex::eligible(&requirements) {
+        Some(true) => ("Eligible to cash out", 0x0096FF),
+        Some(false) => ("Not eligible yet", 0xFFA500),
+        None => ("Eligible if the unanswered requirements are met", 0xFFA500),
     };
+    description.push_str(&format!("**Verdict:** {}", verdict));
 
-    let robux_amount = (gbp_amount / ROBUX_TO_GBP_RATE) as i64;
+    let usd_per_robux = devex::usd_per_robux();
+    let usd_to_gbp = match rates::service(ctx).await?.get("USD", "GBP").await {
+        Ok(rate) => Some(rate.value),
+        Err(error) => {
+            log::warn!("Error fetching USD/GBP for a DevEx estimate: {}", error);
+            None
+        }
+    };
+    let payout = |robux: u64| {
+        let usd = robux as f64 * usd_per_robux;
+        format!(
+            "{} R$ × ${} = **${:.2}**{}",
+            amount::group_thousands(robux as f64),
+            usd_per_robux,
+            usd,
+            usd_to_gbp
+                .map(|rate| format!(" (≈ £{:.2})", usd * rate))
+                .unwrap_or_default()
+        )
+    };
+    let mut estimate = payout(earned);
+    if earned < devex::MIN_ROBUX {
+        estimate.push_str(&format!("\nAt the minimum: {}", payout(devex::MIN_ROBUX)));
+    }
 
     let embed = CreateEmbed::default()
-        .title("Robux Calculation")
-        .description(format!(
-            "{:.2} {} affords {} R$ (£{:.2} / ${:.2})",
-            amount, currency, robux_amount, gbp_amount, usd_amount
-        ))
-        .color(0x0096FF)
+        .title("DevEx Eligibility")
+        .description(description)
+        .field(
+            "Requirements",
+            requirements
+                .iter()
+                .map(|requirement| requirement.line())
+                .collect::<Vec<_>>()
+                .join("\n"),
+            false,
+        )
+        .field("Payout Estimate", estimate, false)
+        .footer(|footer| {
+            footer.text("Roblox makes the final decision and can change its rate or requirements")
+        })
+        .color(color)
         .clone();
 
-    send_embed_response(ctx, command, embed).await
+    send_embed(ctx, command, embed, true).await
 }
 
-async fn handle_help_command(
+/// The Roblox account called `username`, or else the invoker's linked one.
+async fn named_or_linked_account(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    username: Option<&str>,
+) -> Result<Option<roblox::User>, String> {
+    Ok(match username {
+        Some(username) => Some(roblox::user(username.trim()).await?),
+        None => links::store(ctx)
+            .await?
+            .get(command.user.id.0)
+            .await
+            .map(|link| roblox::User {
+                id: link.roblox_id,
+                name: link.roblox_username,
+            }),
+    })
+}
+
+/// Totals the RAP of a Roblox account's limiteds and prices it at the server's
+/// b/t and a/t rates, listing the most valuable items. Values come from
+/// Rolimons where it has one, and RAP otherwise.
+async fn handle_rap_command(
     ctx: &Context,
     command: &ApplicationCommandInteraction,
 ) -> Result<(), String> {
-    let embed = CreateEmbed::default()
-        .title("Available Commands")
-        .description(
-            "Here are the available commands and their usage:\n\
-        /price: Calculate the price in GBP and USD for a given amount of Robux\n\
-        /convert: Convert between GBP and USD\n\
-        /robux: Convert GBP or USD to the amount of Robux",
+    let option = |name: &str| {
+        command
+            .data
+            .options
+            .iter()
+            .find(|option| option.name == name)
+            .and_then(|option| option.value.as_ref())
+    };
+    let account = named_or_linked_account(
+        ctx,
+        command,
+        option("username").and_then(|username| username.as_str()),
+    )
+    .await?
+    .ok_or("Give a Roblox username, or link your account with /link")?;
+    let top = option("top")
+        .and_then(|top| top.as_u64())
+        .unwrap_or(DEFAULT_RAP_TOP_ITEMS as u64) as usize;
+
+    let collectibles = roblox::collectibles(account.id).await?;
+    if collectibles.is_empty() {
+        return Err(format!("{} doesn't own any limiteds", account.name));
+    }
+    let mut asset_ids: Vec<u64> = collectibles.iter().map(|item| item.asset_id).collect();
+    asset_ids.sort_unstable();
+    asset_ids.dedup();
+    let tracked = match rolimons::lookup(&asset_ids).await {
+        Ok(tracked) => tracked,
+        Err(error) => {
+            log::warn!("Error fetching Rolimons values: {}", error);
+            HashMap::new()
+        }
+    };
+
+    // (name, copies, RAP, value) per item.
+    let mut holdings: Vec<(String, u64, u64, u64)> = Vec::new();
+    for asset_id in &asset_ids {
+        let copies: Vec<&roblox::Collectible> = collectibles
+            .iter()
+            .filter(|item| item.asset_id == *asset_id)
+            .collect();
+        let item = tracked.get(asset_id);
+        let rap = copies[0]
+            .recent_average_price
+            .or(item.map(|item| item.rap))
+            .unwrap_or(0);
+        let value = item.and_then(|item| item.value).unwrap_or(rap);
+        holdings.push((copies[0].name.clone(), copies.len() as u64, rap, value));
+    }
+    holdings.sort_by(|a, b| (b.1 * b.3).cmp(&(a.1 * a.3)));
+    let total_rap: u64 = holdings.iter().map(|(_, count, rap, _)| count * rap).sum();
+    let total_value: u64 = holdings
+        .iter()
+        .map(|(_, count, _, value)| count * value)
+        .sum();
+
+    let card = guild_settings(ctx, command).await?.rate_card();
+    let gbp_to_usd = guild_rate(ctx, command, "GBP", "USD").await?;
+    let cash = |robux: u64, price_type: pricing::PriceType| {
+        let gbp = robux as f64 * card.gbp_rate(price_type);
+        format!("£{:.2} • ${:.2}", gbp, gbp * gbp_to_usd.value)
+    };
+
+    let mut description = format!(
+        "**Limiteds:** {}{}\n**Total RAP:** {} R$\n**Total Value:** {} R$",
+        collectibles.len(),
+        if collectibles.len() >= roblox::MAX_COLLECTIBLES {
+            " (only the first are counted)"
+        } else {
+            ""
+        },
+        amount::group_thousands(total_rap as f64),
+        amount::group_thousands(total_value as f64)
+    );
+    if tracked.is_empty() {
+        description.push_str("\nRolimons is unavailable, so values are RAP.");
+    }
+    let mut embed = CreateEmbed::default()
+        .title(format!("{}'s Limiteds", account.name))
+        .url(roblox::profile_url(account.id))
+        .description(description)
+        .field(
+            "RAP in Cash (b/t)",
+            cash(total_rap, pricing::PriceType::BeforeTax),
+            true,
+        )
+        .field(
+            "RAP in Cash (a/t)",
+            cash(total_rap, pricing::PriceType::AfterTax),
+            true,
         )
         .color(0x0096FF)
         .clone();
+    let lines: Vec<String> = holdings
+        .iter()
+        .take(top)
+        .map(|(name, count, rap, value)| {
+            format!(
+                "**{}**{}: {} R$ value, {} R$ RAP",
+                name,
+                if *count > 1 {
+                    format!(" ×{}", count)
+                } else {
+                    String::new()
+                },
+                amount::group_thousands((count * value) as f64),
+                amount::group_thousands((count * rap) as f64)
+            )
+        })
+        .collect();
+    add_numbered_fields(&mut embed, "Most Valuable", &lines);
+    embed.footer(|footer| {
+        footer.text(format!(
+            "RAP from Roblox, values from Rolimons • £{:.2} per 1k R$ b/t",
+            card.gbp_per_robux * 1000.0
+        ))
+    });
+    add_rate_notes(&mut embed, &gbp_to_usd);
 
-    send_embed_response(ctx, command, embed).await
+    send_calculation_response(ctx, command, embed).await
 }
 
-async fn send_embed_response(
+/// Looks a limited item up on Rolimons and prices its RAP and value at the
+/// server's rate.
+async fn handle_limited_command(
     ctx: &Context,
     command: &ApplicationCommandInteraction,
-    embed: CreateEmbed,
 ) -> Result<(), String> {
-    command
-        .create_interaction_response(&ctx.http, |response| {
-            response
-                .kind(InteractionResponseType::ChannelMessageWithSource)
-                .interaction_response_data(|message| message.add_embed(embed))
+    let query = command
+        .data
+        .options
+        .first()
+        .and_then(|option| option.value.as_ref())
+        .and_then(|item| item.as_str())
+        .ok_or("Missing item")?;
+    let item = rolimons::find(query).await?;
+
+    let card = guild_settings(ctx, command).await?.rate_card();
+    let gbp_to_usd = guild_rate(ctx, command, "GBP", "USD").await?;
+    let priced = |robux: u64| {
+        let gbp = robux as f64 * card.gbp_per_robux;
+        format!(
+            "{} R$\n£{:.2} • ${:.2}",
+            amount::group_thousands(robux as f64),
+            gbp,
+            gbp * gbp_to_usd.value
+        )
+    };
+
+    let mut description = if item.acronym.is_empty() {
+        String::new()
+    } else {
+        format!("**Acronym:** {}\n", item.acronym)
+    };
+    description.push_str(&format!(
+        "**Demand:** {}\n**Trend:** {}",
+        item.demand.unwrap_or("Unknown"),
+        item.trend.unwrap_or("Unknown")
+    ));
+    let flags: Vec<&str> = [
+        (item.projected, "⚠️ Projected: the RAP is being pushed up"),
+        (item.hyped, "Hyped"),
+        (item.rare, "Rare"),
+    ]
+    .into_iter()
+    .filter(|(set, _)| *set)
+    .map(|(_, label)| label)
+    .collect();
+    if !flags.is_empty() {
+        description.push_str(&format!("\n{}", flags.join("\n")));
+    }
+
+    let mut embed = CreateEmbed::default()
+        .title(&item.name)
+        .url(item.url())
+        .description(description)
+        .field("RAP", priced(item.rap), true)
+        .field(
+            "Value",
+            item.value
+                .map_or_else(|| "Not valued".to_string(), |value| priced(value)),
+            true,
+        )
+        .footer(|footer| {
+            footer.text(format!(
+                "Data from Rolimons • Priced at £{:.2} per 1k R$",
+                card.gbp_per_robux * 1000.0
+            ))
         })
-        .await
-        .map_err(|e| format!("Error sending response: {:?}", e))
+        .color(if item.projected { 0xFFA500 } else { 0x0096FF })
+        .clone();
+    add_rate_notes(&mut embed, &gbp_to_usd);
+
+    send_calculation_response(ctx, command, embed).await
 }
 
-async fn respond_with_error(
+/// Prices two sides of a trade by Rolimons value and RAP, so either party or a
+/// middleman can see which way it leans.
+async fn handle_tradevalue_command(
     ctx: &Context,
     command: &ApplicationCommandInteraction,
-    error_message: &str,
-) {
-    if let Err(why) = command
-        .create_interaction_response(&ctx.http, |response| {
-            response
-                .kind(InteractionResponseType::ChannelMessageWithSource)
-                .interaction_response_data(|message| message.content(error_message))
-        })
-        .await
-    {
-        eprintln!("Cannot respond to slash command: {}", why);
-    }
-}
+) -> Result<(), String> {
+    let option = |name: &str| {
+        command
+            .data
+            .options
+            .iter()
+            .find(|option| option.name == name)
+            .and_then(|option| option.value.as_ref())
+            .and_then(|value| value.as_str())
+    };
+    let offer = trade::parse(option("offer").ok_or("Missing offer")?).await?;
+    let request = trade::parse(option("request").ok_or("Missing request")?).await?;
+
+    let card = guild_settings(ctx, command).await?.rate_card();
+    let gbp_to_usd = guild_rate(ctx, command, "GBP", "USD").await?;
+    let cash = |robux: u64| {
+        let gbp = robux as f64 * card.gbp_per_robux;
+        format!("£{:.2} • ${:.2}", gbp, gbp * gbp_to_usd.value)
+    };
+    let side = |bundle: &trade::Bundle| {
+        format!(
+            "{}\n**Value:** {} R$ ({})\n**RAP:** {} R$",
+            bundle.lines().join("\n"),
+            amount::group_thousands(bundle.value() as f64),
+            cash(bundle.value()),
+            amount::group_thousands(bundle.rap() as f64)
+        )
+    };
+
+    let (offer_value, request_value) = (offer.value(), request.value());
+    let difference = offer_value.abs_diff(request_value);
+    let verdict = if difference == 0 {
+        "The two sides are even in value.".to_string()
+    } else {
+        format!(
+            "The {} is worth {} R$ more ({}), {:.1}% of the {}.",
+            if offer_value > request_value {
+                "offer"
+            } else {
+                "request"
+            },
+            amount::group_thousands(difference as f64),
+            cash(difference),
+            difference as f64 / offer_value.min(request_value).max(1) as f64 * 100.0,
+            if offer_value > request_value {
+                "request"
+            } else {
+                "offer"
+            }
+        )
+    };
+    let mut description = verdict;
+    let rap_difference = offer.rap().abs_diff(request.rap());
+    if rap_difference > 0 {
+        description.push_str(&format!(
+            "\nBy RAP, the {} is {} R$ ahead.",
+            if offer.rap() > request.rap() {
+                "offer"
+            } else {
+                "request"
+            },
+            amount::group_thousands(rap_difference as f64)
+        ));
+    }
+    let projected = offer.has_projected() || request.has_projected();
+    if projected {
+        description.push_str("\n⚠️ Projected items have inflated RAP; check them before trading.");
+    }
+
+    let mut footer = format!(
+        "Data from Rolimons • Unvalued items count at RAP • Priced at £{:.2} per 1k R$",
+        card.gbp_per_robux * 1000.0
+    );
+    if offer.robux > 0 || request.robux > 0 {
+        footer.push_str(&format!(
+            " • Robux count as sent; Roblox keeps {:.0}%",
+            ROBUX_MARKUP_RATE * 100.0
+        ));
+    }
+    let mut embed = CreateEmbed::default()
+        .title("Trade Value")
+        .description(description)
+        .field("Offer", side(&offer), true)
+        .field("Request", side(&request), true)
+        .footer(|f| f.text(footer))
+        .color(if projected { 0xFFA500 } else { 0x0096FF })
+        .clone();
+    add_rate_notes(&mut embed, &gbp_to_usd);
+
+    send_calculation_response(ctx, command, embed).await
+}
+
+async fn handle_calc_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let expression = command
+        .data
+        .options
+        .first()
+        .and_then(|option| option.value.as_ref())
+        .ok_or("Missing expression")?
+        .as_str()
+        .ok_or("Invalid expression")?;
+
+    let items = calc::parse(expression)?;
+    let guild_settings = guild_settings(ctx, command).await?;
+    let (lines, total_gbp) = calc::evaluate(&items, &guild_settings.rate_card());
+    if total_gbp < 0.0 {
+        return Err("The expression comes to a negative total".to_string());
+    }
+
+    let gbp_to_usd = guild_rate(ctx, command, "GBP", "USD").await?;
+    let large_amount_threshold = guild_settings.large_amount_threshold();
+    let tax = guild_settings.tax;
+    let itemized = lines
+        .iter()
+        .map(|line| {
+            format!(
+                "`{}` → {}£{:.2}",
+                line.label,
+                if line.gbp < 0.0 { "-" } else { "" },
+                line.gbp.abs()
+            )
+        })
+        .collect::<Vec<_>>()
+        .join("\n");
+
+    let mut embed = CreateEmbed::default()
+        .title("Calculation")
+        .description(format!("**Expression:** `{}`\n\n{}", expression, itemized))
+        .field(
+            "Total in GBP",
+            tax_lines('£', total_gbp, tax.as_ref()),
+            true,
+        )
+        .field(
+            "Total in USD",
+            tax_lines('$', total_gbp * gbp_to_usd.value, tax.as_ref()),
+            true,
+        )
+        .color(0x0096FF)
+        .clone();
+    // Subtracted terms are still amounts someone typed, so they count too.
+    let robux: f64 = items
+        .iter()
+        .map(|item| match item.term {
+            calc::Term::Robux { robux, .. } => robux,
+            calc::Term::Percent(_) => 0.0,
+        })
+        .sum();
+    add_large_amount_warning(&mut embed, large_amount_threshold, robux);
+    add_rate_notes(&mut embed, &gbp_to_usd);
+    record_calculation(
+        ctx,
+        command,
+        &mut embed,
+        expression.to_string(),
+        format!("£{:.2}", total_gbp),
+        &[&gbp_to_usd],
+    )
+    .await;
+
+    send_calculation_response(ctx, command, embed).await
+}
+
+async fn handle_convert_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let options = &command.data.options;
+
+    if options.len() < 2 {
+        return Err("Insufficient command options".to_string());
+    }
+
+    let currency = options[0]
+        .value
+        .as_ref()
+        .ok_or("Missing currency")?
+        .as_str()
+        .ok_or("Invalid currency")?;
+    let amount = amount::parse_money(
+        options[1]
+            .value
+            .as_ref()
+            .ok_or("Missing amount")?
+            .as_str()
+            .ok_or("Invalid amount")?,
+    )?;
+
+    let gbp_to_usd = guild_rate(ctx, command, "GBP", "USD").await?;
+    let (from_currency, to_currency, converted_amount) =
+        match rates::parse_currency(currency)?.as_str() {
+            "GBP" => ("GBP", "USD", amount * gbp_to_usd.value),
+            "USD" => ("USD", "GBP", amount / gbp_to_usd.value),
+            _ => return Err("Invalid currency. Use 'GBP' or 'USD'.".to_string()),
+        };
+
+    let mut embed = CreateEmbed::default()
+        .title("Currency Conversion")
+        .field(
+            format!("Amount in {}", from_currency),
+            format!("{:.2}", amount),
+            true,
+        )
+        .field(
+            format!("Amount in {}", to_currency),
+            format!("{:.2}", converted_amount),
+            true,
+        )
+        .color(0x0096FF)
+        .clone();
+    add_rate_notes(&mut embed, &gbp_to_usd);
+    record_calculation(
+        ctx,
+        command,
+        &mut embed,
+        format!("{:.2} {}", amount, from_currency),
+        format!("{:.2} {}", converted_amount, to_currency),
+        &[&gbp_to_usd],
+    )
+    .await;
+
+    send_calculation_response(ctx, command, embed).await
+}
+
+async fn handle_robux_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let options = &command.data.options;
+
+    if options.len() < 2 {
+        return Err("Insufficient command options".to_string());
+    }
+
+    let currency = options[0]
+        .value
+        .as_ref()
+        .ok_or("Missing currency")?
+        .as_str()
+        .ok_or("Invalid currency")?;
+    let amount = amount::parse_money(
+        options[1]
+            .value
+            .as_ref()
+            .ok_or("Missing amount")?
+            .as_str()
+            .ok_or("Invalid amount")?,
+    )?;
+
+    let currency = rates::parse_currency(currency)?;
+    let gbp_to_usd = guild_rate(ctx, command, "GBP", "USD").await?;
+    // Any other currency is converted through GBP, which prices are set in.
+    let gbp_to_currency = match currency.as_str() {
+        "GBP" | "USD" => None,
+        _ => Some(guild_rate(ctx, command, "GBP", &currency).await?),
+    };
+    let gbp_amount = match (&gbp_to_currency, currency.as_str()) {
+        (Some(rate), _) => amount / rate.value,
+        (None, "USD") => amount / gbp_to_usd.value,
+        (None, _) => amount,
+    };
+    let usd_amount = gbp_amount * gbp_to_usd.value;
+
+    let gbp_per_robux = guild_settings(ctx, command).await?.gbp_per_robux();
+    let robux_amount = (gbp_amount / gbp_per_robux) as i64;
+
+    let mut embed = CreateEmbed::default()
+        .title("Robux Calculation")
+        .description(format!(
+            "{} affords {} R$ (£{:.2} / ${:.2})",
+            currency::format(&currency, amount),
+            robux_amount,
+            gbp_amount,
+            usd_amount
+        ))
+        .color(0x0096FF)
+        .clone();
+    let rate = gbp_to_currency.as_ref().unwrap_or(&gbp_to_usd);
+    add_rate_notes(&mut embed, rate);
+    record_calculation(
+        ctx,
+        command,
+        &mut embed,
+        currency::format(&currency, amount),
+        format!("{} R$", robux_amount),
+        &[rate],
+    )
+    .await;
+
+    send_calculation_response(ctx, command, embed).await
+}
+
+/// Converts between a gamepass price and the Robux its seller receives: b/t
+/// takes the gamepass price, a/t the amount to receive.
+async fn handle_tax_conversion_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    price_type: pricing::PriceType,
+) -> Result<(), String> {
+    let robux = amount::parse_robux(
+        command
+            .data
+            .options
+            .first()
+            .and_then(|option| option.value.as_ref())
+            .ok_or("Missing amount")?
+            .as_str()
+            .ok_or("Invalid amount")?,
+    )?;
+
+    let card = guild_settings(ctx, command).await?.rate_card();
+    let gamepass_price = card.gamepass_price(robux, price_type);
+    let fee = card.marketplace_fee(gamepass_price);
+    let received = gamepass_price - fee;
+    let (title, description) = match price_type {
+        pricing::PriceType::BeforeTax => (
+            "Before Tax",
+            format!(
+                "A gamepass priced at **{} R$** pays out **{} R$**.",
+                amount::group_thousands(gamepass_price as f64),
+                amount::group_thousands(received as f64)
+            ),
+        ),
+        pricing::PriceType::AfterTax => (
+            "After Tax",
+            format!(
+                "To receive **{} R$**, set the gamepass price to **{} R$**.",
+                amount::group_thousands(received as f64),
+                amount::group_thousands(gamepass_price as f64)
+            ),
+        ),
+    };
+
+    let mut embed = CreateEmbed::default()
+        .title(title)
+        .description(description)
+        .field(
+            "Gamepass Price",
+            format!("{} R$", amount::group_thousands(gamepass_price as f64)),
+            true,
+        )
+        .field(
+            format!("Roblox Fee ({})", card.markup_percent()),
+            format!("{} R$", amount::group_thousands(fee as f64)),
+            true,
+        )
+        .field(
+            "Received",
+            format!("{} R$", amount::group_thousands(received as f64)),
+            true,
+        )
+        .color(0x0096FF)
+        .clone();
+    if shows_math(command) {
+        let steps = card.robux_steps(robux, price_type, pricing::DeliveryMethod::Gamepass);
+        // The last step, Robux spent, only matters when pricing an order.
+        add_math_fields(&mut embed, &steps[..steps.len() - 1]);
+    }
+
+    send_calculation_response(ctx, command, embed).await
+}
+
+/// Whether the command asked for its calculation to be shown step by step.
+fn shows_math(command: &ApplicationCommandInteraction) -> bool {
+    command
+        .data
+        .options
+        .iter()
+        .find(|option| option.name == "show_math")
+        .and_then(|option| option.value.as_ref())
+        .and_then(|value| value.as_bool())
+        .unwrap_or(false)
+}
+
+/// Adds numbered calculation `steps` to `embed`.
+fn add_math_fields(embed: &mut CreateEmbed, steps: &[String]) {
+    add_numbered_fields(embed, "How It's Calculated", steps);
+}
+
+/// Adds `lines` to `embed` as a numbered list under `name`, split across as
+/// many fields as Discord's 1,024-character limit needs.
+fn add_numbered_fields(embed: &mut CreateEmbed, name: &str, lines: &[String]) {
+    let lines: Vec<String> = lines
+        .iter()
+        .enumerate()
+        .map(|(index, line)| format!("{}. {}", index + 1, line))
+        .collect();
+    add_split_fields(embed, name, &format!("{} (continued)", name), &lines);
+}
+
+/// Adds `lines` to `embed` under `name`, starting a field named `continued`
+/// whenever the next line would pass Discord's 1,024-character field limit.
+fn add_split_fields(embed: &mut CreateEmbed, name: &str, continued: &str, lines: &[String]) {
+    let mut chunks: Vec<String> = Vec::new();
+    let mut chunk = String::new();
+    for line in lines {
+        let line = format!("{}\n", line);
+        if !chunk.is_empty() && chunk.len() + line.len() > 1024 {
+            chunks.push(std::mem::take(&mut chunk));
+        }
+        chunk.push_str(&line);
+    }
+    chunks.push(chunk);
+    for (index, chunk) in chunks.iter().enumerate() {
+        embed.field(
+            if index == 0 { name } else { continued },
+            chunk.trim_end(),
+            false,
+        );
+    }
+}
+
+async fn handle_rate_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let pair = command
+        .data
+        .options
+        .first()
+        .and_then(|option| option.value.as_ref())
+        .ok_or("Missing currency pair")?
+        .as_str()
+        .ok_or("Invalid currency pair")?;
+
+    let (base, quote) = rates::parse_pair(pair)?;
+    let service = rates::service(ctx).await?;
+    let rate = guild_rate(ctx, command, &base, &quote).await?;
+
+    let mut embed = CreateEmbed::default()
+        .title("Exchange Rate")
+        .description(format!(
+            "**1 {}** = **{} {}**",
+            rate.base,
+            rate.mid_market(),
+            rate.quote
+        ))
+        .field("Provider", &rate.provider, true)
+        .field(
+            "Fetched",
+            rate.fetched_at_markup('R')
+                .unwrap_or_else(|| "Unknown".to_string()),
+            true,
+        )
+        .field("Source", rate.source.label(), true)
+        .field("Circuit Breaker", service.breaker_state().label(), true)
+        .color(0x0096FF)
+        .clone();
+    add_rate_notes(&mut embed, &rate);
+
+    send_embed_response(ctx, command, embed).await
+}
+
+async fn handle_metrics_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    ensure_owner(command)?;
+
+    let since = store::now().saturating_sub(metrics::WINDOW_SECS);
+    let stats = metrics::metrics(ctx).await?.stats(since).await;
+    let breakers = metrics::breakers(ctx).await?;
+    let lines = stats
+        .iter()
+        .map(|stats| {
+            format!(
+                "**/{}** • {} calls • {:.0}% errors\nP50 {} • P95 {} • P99 {}",
+                stats.command,
+                stats.calls,
+                stats.error_rate(),
+                metrics::format_millis(stats.p50_ms),
+                metrics::format_millis(stats.p95_ms),
+                metrics::format_millis(stats.p99_ms)
+            )
+        })
+        .collect::<Vec<_>>();
+
+    let embed = CreateEmbed::default()
+        .title("Command Metrics")
+        .description(if lines.is_empty() {
+            "No commands have been handled in the last hour.".to_string()
+        } else {
+            lines.join("\n")
+        })
+        .field(
+            "Circuit Breakers",
+            breakers
+                .iter()
+                .map(|(api, state)| format!("{}: {}", api, state.label()))
+                .collect::<Vec<_>>()
+                .join("\n"),
+            false,
+        )
+        .footer(|footer| footer.text("Last hour, since the bot started at the earliest"))
+        .color(0x0096FF)
+        .clone();
+    send_embed(ctx, command, embed, true).await
+}
+
+async fn handle_ping_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let gateway_latency = match ctx.data.read().await.get::<ShardManagerKey>().cloned() {
+        Some(shard_manager) => shard_manager
+            .lock()
+            .await
+            .runners
+            .lock()
+            .await
+            .get(&ShardId(ctx.shard_id))
+            .and_then(|runner| runner.latency),
+        None => None,
+    };
+    let started = Instant::now();
+    let rest_latency = ctx
+        .http
+        .get_current_user()
+        .await
+        .map(|_| started.elapsed())
+        .ok();
+    let service = rates::service(ctx).await?;
+
+    let millis = |latency: Option<Duration>| {
+        latency.map_or_else(
+            || "Unknown".to_string(),
+            |latency| metrics::format_millis(latency.as_millis() as u64),
+        )
+    };
+    let embed = CreateEmbed::default()
+        .title("Pong!")
+        .field("Gateway Latency", millis(gateway_latency), true)
+        .field("REST Latency", millis(rest_latency), true)
+        .field("Shard", ctx.shard_id.to_string(), true)
+        .field("Uptime", format_uptime(debug::uptime()), true)
+        .field(
+            "Memory",
+            debug::resident_memory_kb().map_or_else(
+                || "Unknown".to_string(),
+                |kb| format!("{:.1} MB", kb as f64 / 1024.0),
+            ),
+            true,
+        )
+        .field(
+            "Rate Cache Hits",
+            service.cache_hit_rate().map_or_else(
+                || "No lookups yet".to_string(),
+                |rate| format!("{:.0}%", rate),
+            ),
+            true,
+        )
+        .field(
+            "Last Rate Fetch",
+            service
+                .last_fetch()
+                .map_or_else(|| "Never".to_string(), |at| format!("<t:{}:R>", at)),
+            true,
+        )
+        .field("Rates Breaker", service.breaker_state().label(), true)
+        .field("Roblox Breaker", roblox::breaker_state().label(), true)
+        .color(0x0096FF)
+        .clone();
+    send_embed(ctx, command, embed, true).await
+}
+
+async fn handle_about_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let repository = env!("CARGO_PKG_REPOSITORY");
+    let mut embed = CreateEmbed::default()
+        .title("About")
+        .description("Calculates Robux prices and taxes, and helps run a Robux shop on Discord.")
+        .field("Version", env!("CARGO_PKG_VERSION"), true)
+        .field("Commit", format!("`{}`", env!("BUILD_COMMIT")), true)
+        .field("Built", format!("<t:{}:f>", env!("BUILD_TIMESTAMP")), true)
+        .field(
+            "Libraries",
+            match env!("BUILD_LIBRARIES") {
+                "" => "Unknown",
+                libraries => libraries,
+            },
+            false,
+        )
+        .field(
+            "Links",
+            format!(
+                "[Source code]({0}) • [Report an issue]({0}/issues) • [Releases]({0}/releases)",
+                repository
+            ),
+            false,
+        )
+        .color(0x0096FF)
+        .clone();
+    if let Some(release) = updates::available() {
+        embed
+            .field(
+                "Update Available",
+                format!(
+                    "[{}]({}) is out; this build is out of date.",
+                    release.name.as_deref().unwrap_or(&release.tag_name),
+                    release.html_url
+                ),
+                false,
+            )
+            .color(0xFFA500);
+    }
+    send_embed(ctx, command, embed, true).await
+}
+
+/// `uptime` to the minute, e.g. `2d 5h 13m`.
+fn format_uptime(uptime: Duration) -> String {
+    let minutes = uptime.as_secs() / 60;
+    let (days, hours, minutes) = (minutes / 1440, minutes / 60 % 24, minutes % 60);
+    if days > 0 {
+        format!("{}d {}h {}m", days, hours, minutes)
+    } else if hours > 0 {
+        format!("{}h {}m", hours, minutes)
+    } else {
+        format!("{}m", minutes)
+    }
+}
+
+async fn handle_loglevel_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    ensure_owner(command)?;
+
+    let level = command
+        .data
+        .options
+        .first()
+        .and_then(|option| option.value.as_ref())
+        .and_then(|level| level.as_str())
+        .ok_or("Please choose a log level.")?
+        .parse::<log::LevelFilter>()
+        .map_err(|_| "Unknown log level.".to_string())?;
+    let previous = log::max_level();
+    log::set_max_level(level);
+    log::warn!(
+        "Log level changed from {} to {} by {}",
+        previous,
+        level,
+        command.user.name
+    );
+
+    let embed = CreateEmbed::default()
+        .title("Log Level Changed")
+        .description(format!(
+            "Logging at **{}** until the next restart (was **{}**). `LOG_LEVEL` sets the level the bot starts with.",
+            level.as_str().to_lowercase(),
+            previous.as_str().to_lowercase()
+        ))
+        .color(0x0096FF)
+        .clone();
+    send_embed(ctx, command, embed, true).await
+}
+
+async fn handle_quota_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    ensure_owner(command)?;
+
+    let service = rates::service(ctx).await?;
+    let statuses = service.keys().statuses().await;
+    if statuses.is_empty() {
+        return Err("No exchange API keys are configured".to_string());
+    }
+
+    let mut embed = CreateEmbed::default()
+        .title("Exchange API Quota")
+        .color(0x0096FF)
+        .clone();
+    for status in statuses {
+        let remaining = status
+            .remaining
+            .map(|remaining| remaining.to_string())
+            .unwrap_or_else(|| "Unknown".to_string());
+        let state = if status.active {
+            "Active"
+        } else if status.exhausted {
+            "Exhausted"
+        } else {
+            "Standby"
+        };
+
+        embed.field(
+            status.label,
+            format!(
+                "Calls this month: {}\nRemaining: {}\nStatus: {}",
+                status.usage.calls, remaining, state
+            ),
+            true,
+        );
+    }
+
+    send_embed_response(ctx, command, embed).await
+}
+
+async fn handle_reload_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    ensure_owner(command)?;
+
+    let settings = settings::store(ctx).await?;
+    settings.reload().await?;
+    let tenant = tenant::tenant(ctx).await?;
+    let rate_service = Arc::new(rate_service(&tenant, &*settings.read().await).await?);
+    let provider = rate_service.provider_name().to_string();
+    warm_rate_cache(rate_service.clone(), settings.read().await.currency_pairs());
+    ctx.data
+        .write()
+        .await
+        .insert::<rates::RatesKey>(rate_service);
+    register_commands(ctx, true)
+        .await
+        .map_err(|e| format!("Error registering commands: {}", e))?;
+
+    let embed = CreateEmbed::default()
+        .title("Reloaded")
+        .description(format!(
+            "Re-read `data/settings.json`, switched to the {} rate provider and re-registered {} slash commands.",
+            provider,
+            commands::COMMANDS.len()
+        ))
+        .color(0x0096FF)
+        .clone();
+
+    send_embed(ctx, command, embed, true).await
+}
+
+async fn handle_shutdown_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    ensure_owner(command)?;
+
+    let shard_manager = ctx
+        .data
+        .read()
+        .await
+        .get::<ShardManagerKey>()
+        .cloned()
+        .ok_or("Shard manager unavailable")?;
+    respond_ephemeral(ctx, command, "Shutting down.").await?;
+
+    log::info!("Shutdown requested by {}", command.user.name);
+    shard_manager.lock().await.shutdown_all().await;
+    Ok(())
+}
+
+async fn handle_maintenance_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    ensure_owner(command)?;
+
+    let subcommand = command.data.options.first().ok_or("Missing subcommand")?;
+    let enabled = subcommand.name == "on";
+    let message = subcommand
+        .options
+        .iter()
+        .find(|option| option.name == "message")
+        .and_then(|option| option.value.as_ref())
+        .and_then(|message| message.as_str())
+        .map(|message| message.trim().to_string())
+        .filter(|message| !message.is_empty());
+
+    let notice = settings::store(ctx)
+        .await?
+        .update(|settings| {
+            settings.maintenance.enabled = enabled;
+            if enabled {
+                settings.maintenance.message = message;
+            }
+            settings.maintenance.notice()
+        })
+        .await?;
+
+    let embed = CreateEmbed::default()
+        .title("Maintenance Mode")
+        .description(if enabled {
+            format!(
+                "Maintenance mode is on. Everyone else will see:\n> {}",
+                notice
+            )
+        } else {
+            "Maintenance mode is off.".to_string()
+        })
+        .color(0x0096FF)
+        .clone();
+
+    send_embed(ctx, command, embed, true).await
+}
+
+async fn handle_setmarkup_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let markup = command
+        .data
+        .options
+        .first()
+        .and_then(|option| option.value.as_ref())
+        .ok_or("Missing markup")?
+        .as_f64()
+        .ok_or("Invalid markup")?;
+
+    if !(0.0..=MAX_MARKUP_PERCENT).contains(&markup) {
+        return Err(format!(
+            "The markup must be between 0% and {}%",
+            MAX_MARKUP_PERCENT
+        ));
+    }
+
+    let (old_card, new_card) = settings::store(ctx)
+        .await?
+        .update(|settings| {
+            let guild = settings.guilds.entry(guild_id.0).or_default();
+            let old_card = guild.rate_card();
+            guild.markup_percent = Some(markup);
+            (old_card, guild.rate_card())
+        })
+        .await?;
+    record_change(
+        ctx,
+        command,
+        guild_id,
+        "Markup",
+        old_card.markup_percent(),
+        new_card.markup_percent(),
+    )
+    .await;
+    announce_rate_change(ctx, command, guild_id, "markup", old_card, new_card).await;
+
+    let embed = CreateEmbed::default()
+        .title("Markup Updated")
+        .description(format!(
+            "a/t prices in this server now use a **{}%** markup (default {}%).",
+            markup,
+            ROBUX_MARKUP_RATE * 100.0
+        ))
+        .color(0x0096FF)
+        .clone();
+
+    send_embed_response(ctx, command, embed).await
+}
+
+async fn handle_setrate_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let gbp_per_1k = command
+        .data
+        .options
+        .first()
+        .and_then(|option| option.value.as_ref())
+        .ok_or("Missing rate")?
+        .as_f64()
+        .ok_or("Invalid rate")?;
+
+    if gbp_per_1k <= 0.0 {
+        return Err("The rate must be more than £0".to_string());
+    }
+
+    let (old_card, new_card) = settings::store(ctx)
+        .await?
+        .update(|settings| {
+            let guild = settings.guilds.entry(guild_id.0).or_default();
+            let old_card = guild.rate_card();
+            guild.gbp_per_1k = Some(gbp_per_1k);
+            (old_card, guild.rate_card())
+        })
+        .await?;
+    record_change(
+        ctx,
+        command,
+        guild_id,
+        "Rate",
+        format!("£{:.2} per 1k R$", old_card.gbp_per_robux * 1000.0),
+        format!("£{:.2} per 1k R$", gbp_per_1k),
+    )
+    .await;
+    announce_rate_change(ctx, command, guild_id, "rate", old_card, new_card).await;
+
+    let embed = CreateEmbed::default()
+        .title("Rate Updated")
+        .description(format!(
+            "Prices in this server now start at **£{:.2}** per 1k R$ b/t (default £{:.2}).",
+            gbp_per_1k,
+            ROBUX_TO_GBP_RATE * 1000.0
+        ))
+        .color(0x0096FF)
+        .clone();
+
+    send_embed_response(ctx, command, embed).await
+}
+
+/// Posts old and new prices for the rate card's sample amounts to the guild's
+/// announcement channel, if it has one. Failures are logged rather than failing
+/// the command that changed the rate.
+async fn announce_rate_change(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    guild_id: GuildId,
+    setting: &str,
+    old_card: pricing::RateCard,
+    new_card: pricing::RateCard,
+) {
+    let announcements = match guild_settings(ctx, command).await {
+        Ok(guild_settings) => match guild_settings.announcements {
+            Some(announcements) => announcements,
+            None => return,
+        },
+        Err(error) => {
+            log::error!("Error announcing rate change: {}", error);
+            return;
+        }
+    };
+
+    let embed = CreateEmbed::default()
+        .title("Rates Updated")
+        .description(format!(
+            "<@{}> changed the {}. New prices from now on:",
+            command.user.id, setting
+        ))
+        .field(
+            "Before Tax (b/t)",
+            ratecard::change_lines(&old_card, &new_card, pricing::PriceType::BeforeTax).join("\n"),
+            false,
+        )
+        .field(
+            "After Tax (a/t)",
+            ratecard::change_lines(&old_card, &new_card, pricing::PriceType::AfterTax).join("\n"),
+            false,
+        )
+        .color(0x0096FF)
+        .clone();
+    let ping = announcements
+        .role_id
+        .filter(|_| announcements.ping)
+        .map(|role_id| format!("<@&{}>", role_id));
+
+    let channel_id = ChannelId(announcements.channel_id);
+    let result = outbound::send(&ctx.http, || {
+        channel_id.send_message(&ctx.http, |message| {
+            if let Some(ping) = &ping {
+                message.content(ping);
+            }
+            message.set_embed(embed.clone())
+        })
+    })
+    .await;
+    if let Err(error) = result {
+        log::error!(
+            "Error announcing rate change in guild {}: {:?}",
+            guild_id,
+            error
+        );
+    }
+}
+
+async fn handle_fxmargin_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let margin = command
+        .data
+        .options
+        .first()
+        .and_then(|option| option.value.as_ref())
+        .ok_or("Missing margin")?
+        .as_f64()
+        .ok_or("Invalid margin")?;
+
+    if !(0.0..=MAX_FX_MARGIN_PERCENT).contains(&margin) {
+        return Err(format!(
+            "The FX margin must be between 0% and {}%",
+            MAX_FX_MARGIN_PERCENT
+        ));
+    }
+
+    let old_margin = settings::store(ctx)
+        .await?
+        .update(|settings| {
+            let guild = settings.guilds.entry(guild_id.0).or_default();
+            std::mem::replace(&mut guild.fx_margin_percent, margin)
+        })
+        .await?;
+    record_change(
+        ctx,
+        command,
+        guild_id,
+        "FX Margin",
+        format!("{:.2}%", old_margin),
+        format!("{:.2}%", margin),
+    )
+    .await;
+
+    let embed = CreateEmbed::default()
+        .title("FX Margin Updated")
+        .description(format!(
+            "Conversions in this server now add **{:.2}%** on top of the mid-market rate.",
+            margin
+        ))
+        .color(0x0096FF)
+        .clone();
+
+    send_embed_response(ctx, command, embed).await
+}
+
+async fn handle_ephemeral_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let enabled = command
+        .data
+        .options
+        .first()
+        .and_then(|option| option.value.as_ref())
+        .ok_or("Missing setting")?
+        .as_bool()
+        .ok_or("Invalid setting")?;
+
+    settings::store(ctx)
+        .await?
+        .update(|settings| {
+            settings
+                .guilds
+                .entry(guild_id.0)
+                .or_default()
+                .ephemeral_results = enabled
+        })
+        .await?;
+
+    let embed = CreateEmbed::default()
+        .title("Result Visibility Updated")
+        .description(if enabled {
+            "Calculation results in this server are now only visible to the person who asked."
+        } else {
+            "Calculation results in this server are now visible to everyone in the channel."
+        })
+        .color(0x0096FF)
+        .clone();
+
+    send_embed_response(ctx, command, embed).await
+}
+
+async fn handle_tax_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let rate = command
+        .data
+        .options
+        .iter()
+        .find(|option| option.name == "rate")
+        .and_then(|option| option.value.as_ref())
+        .ok_or("Missing tax rate")?
+        .as_f64()
+        .ok_or("Invalid tax rate")?;
+    let label = command
+        .data
+        .options
+        .iter()
+        .find(|option| option.name == "label")
+        .and_then(|option| option.value.as_ref())
+        .and_then(|value| value.as_str())
+        .unwrap_or("VAT")
+        .to_string();
+
+    if !(0.0..=100.0).contains(&rate) {
+        return Err("The tax rate must be between 0% and 100%".to_string());
+    }
+
+    let tax = (rate > 0.0).then(|| settings::TaxSettings {
+        rate_percent: rate,
+        label,
+    });
+    let description = match &tax {
+        Some(tax) => format!(
+            "Price embeds in this server now show net, {} ({}%) and gross amounts.",
+            tax.label, tax.rate_percent
+        ),
+        None => "Tax lines are now disabled in this server.".to_string(),
+    };
+
+    let new_tax = describe_tax(tax.as_ref());
+    let old_tax = settings::store(ctx)
+        .await?
+        .update(|settings| {
+            let guild = settings.guilds.entry(guild_id.0).or_default();
+            std::mem::replace(&mut guild.tax, tax)
+        })
+        .await?;
+    record_change(
+        ctx,
+        command,
+        guild_id,
+        "Tax",
+        describe_tax(old_tax.as_ref()),
+        new_tax,
+    )
+    .await;
+
+    let embed = CreateEmbed::default()
+        .title("Tax Updated")
+        .description(description)
+        .color(0x0096FF)
+        .clone();
+
+    send_embed_response(ctx, command, embed).await
+}
+
+async fn handle_role_pricing_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let subcommand = command.data.options.first().ok_or("Missing subcommand")?;
+    let option = |name: &str| {
+        subcommand
+            .options
+            .iter()
+            .find(|option| option.name == name)
+            .and_then(|option| option.value.as_ref())
+    };
+    let role_id = option("role")
+        .and_then(|role| role.as_str())
+        .and_then(|role| role.parse::<u64>().ok());
+    let settings = settings::store(ctx).await?;
+
+    let description = match subcommand.name.as_str() {
+        "set" => {
+            let role_id = role_id.ok_or("Missing role")?;
+            let discount_percent = option("discount")
+                .and_then(|discount| discount.as_f64())
+                .unwrap_or(0.0);
+            let currency = option("currency")
+                .and_then(|currency| currency.as_str())
+                .map(rates::parse_currency)
+                .transpose()?;
+
+            if !(0.0..100.0).contains(&discount_percent) {
+                return Err("The discount must be between 0% and 100%".to_string());
+            }
+            if let Some(currency) = &currency {
+                rates::service(ctx).await?.get("GBP", currency).await?;
+            }
+            if discount_percent == 0.0 && currency.is_none() {
+                return Err("Set a discount, a currency or both".to_string());
+            }
+
+            let pricing = settings::RolePricing {
+                role_id,
+                discount_percent,
+                currency,
+            };
+            let new_pricing = pricing.describe();
+            let old_pricing = settings
+                .update(|settings| {
+                    let role_pricing =
+                        &mut settings.guilds.entry(guild_id.0).or_default().role_pricing;
+                    let old_pricing = take_role_pricing(role_pricing, role_id);
+                    role_pricing.push(pricing);
+                    old_pricing
+                })
+                .await?;
+            record_change(
+                ctx,
+                command,
+                guild_id,
+                &format!("Role Pricing <@&{}>", role_id),
+                old_pricing,
+                new_pricing,
+            )
+            .await;
+            format!("Updated pricing for <@&{}>.", role_id)
+        }
+        "remove" => {
+            let role_id = role_id.ok_or("Missing role")?;
+            let old_pricing = settings
+                .update(|settings| {
+                    let role_pricing =
+                        &mut settings.guilds.entry(guild_id.0).or_default().role_pricing;
+                    take_role_pricing(role_pricing, role_id)
+                })
+                .await?;
+            record_change(
+                ctx,
+                command,
+                guild_id,
+                &format!("Role Pricing <@&{}>", role_id),
+                old_pricing,
+                "None".to_string(),
+            )
+            .await;
+            format!("Removed pricing for <@&{}>.", role_id)
+        }
+        _ => {
+            let role_pricing = settings.read().await.guild(Some(guild_id)).role_pricing;
+            if role_pricing.is_empty() {
+                "No role pricing is configured.".to_string()
+            } else {
+                role_pricing
+                    .iter()
+                    .map(|pricing| format!("<@&{}>: {}", pricing.role_id, pricing.describe()))
+                    .collect::<Vec<_>>()
+                    .join("\n")
+            }
+        }
+    };
+
+    let embed = CreateEmbed::default()
+        .title("Role Pricing")
+        .description(description)
+        .color(0x0096FF)
+        .clone();
+
+    send_embed_response(ctx, command, embed).await
+}
+
+async fn handle_spend_tiers_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let subcommand = command.data.options.first().ok_or("Missing subcommand")?;
+    let option = |name: &str| {
+        subcommand
+            .options
+            .iter()
+            .find(|option| option.name == name)
+            .and_then(|option| option.value.as_ref())
+    };
+    let role_id = option("role")
+        .and_then(|role| role.as_str())
+        .and_then(|role| role.parse::<u64>().ok());
+    let settings = settings::store(ctx).await?;
+
+    let description = match subcommand.name.as_str() {
+        "set" => {
+            let role_id = role_id.ok_or("Missing role")?;
+            let threshold_gbp = amount::parse_money(
+                option("spend")
+                    .and_then(|spend| spend.as_str())
+                    .ok_or("Missing spend")?,
+            )?;
+            settings
+                .update(|settings| {
+                    let spend_tiers =
+                        &mut settings.guilds.entry(guild_id.0).or_default().spend_tiers;
+                    spend_tiers.retain(|tier| tier.role_id != role_id);
+                    spend_tiers.push(settings::SpendTier {
+                        role_id,
+                        threshold_gbp,
+                    });
+                })
+                .await?;
+            format!(
+                "Buyers who have spent £{:.2} get <@&{}>.",
+                threshold_gbp, role_id
+            )
+        }
+        "remove" => {
+            let role_id = role_id.ok_or("Missing role")?;
+            let removed = settings
+                .update(|settings| {
+                    let spend_tiers =
+                        &mut settings.guilds.entry(guild_id.0).or_default().spend_tiers;
+                    let count = spend_tiers.len();
+                    spend_tiers.retain(|tier| tier.role_id != role_id);
+                    spend_tiers.len() != count
+                })
+                .await?;
+            if !removed {
+                return Err(format!("<@&{}> isn't a spend tier", role_id));
+            }
+            format!(
+                "Removed the tier for <@&{}>. Members keep the role until you remove it.",
+                role_id
+            )
+        }
+        "channel" => {
+            let channel_id = option("channel")
+                .and_then(|channel| channel.as_str())
+                .and_then(|channel| channel.parse::<u64>().ok());
+            let enabled = option("enabled").and_then(|enabled| enabled.as_bool());
+            let channel_id = settings
+                .update(|settings| {
+                    let guild = settings.guilds.entry(guild_id.0).or_default();
+                    if enabled == Some(false) {
+                        guild.spend_tier_channel_id = None;
+                    } else if channel_id.is_some() {
+                        guild.spend_tier_channel_id = channel_id;
+                    }
+                    guild.spend_tier_channel_id
+                })
+                .await?;
+            match channel_id {
+                Some(channel_id) => format!("Tier upgrades are announced in <#{}>.", channel_id),
+                None => "Tier upgrades aren't announced.".to_string(),
+            }
+        }
+        _ => {
+            let mut spend_tiers = settings.read().await.guild(Some(guild_id)).spend_tiers;
+            spend_tiers.sort_by(|a, b| a.threshold_gbp.total_cmp(&b.threshold_gbp));
+            if spend_tiers.is_empty() {
+                "No spend tiers are configured.".to_string()
+            } else {
+                spend_tiers
+                    .iter()
+                    .map(|tier| format!("£{:.2}: <@&{}>", tier.threshold_gbp, tier.role_id))
+                    .collect::<Vec<_>>()
+                    .join("\n")
+            }
+        }
+    };
+
+    let embed = CreateEmbed::default()
+        .title("Spend Tiers")
+        .description(description)
+        .color(0x0096FF)
+        .clone();
+
+    send_embed_response(ctx, command, embed).await
+}
+
+/// Parses a list of Roblox packs like `400=4.99, 800=9.99`, as Robux and
+/// their GBP price.
+fn parse_official_packages(list: &str) -> Result<Vec<pricing::RobuxPackage>, String> {
+    let mut packages: Vec<pricing::RobuxPackage> = Vec::new();
+    for entry in list
+        .split(',')
+        .map(str::trim)
+        .filter(|entry| !entry.is_empty())
+    {
+        let (robux, gbp) = entry.split_once('=').ok_or_else(|| {
+            format!(
+                "Write each pack as R$=price, e.g. 400=4.99, not '{}'",
+                entry
+            )
+        })?;
+        let robux = amount::parse_robux(robux)?.round() as u64;
+        if robux == 0 {
+            return Err("Packs must have at least 1 R$".to_string());
+        }
+        let gbp = amount::parse_money(gbp)?;
+        if packages.iter().any(|package| package.robux == robux) {
+            return Err(format!("The {} R$ pack is listed twice", robux));
+        }
+        packages.push(pricing::RobuxPackage { robux, gbp });
+    }
+    if packages.is_empty() {
+        return Err("List at least one pack, e.g. 400=4.99".to_string());
+    }
+    if packages.len() > MAX_OFFICIAL_PACKAGES {
+        return Err(format!("List at most {} packs", MAX_OFFICIAL_PACKAGES));
+    }
+    Ok(packages)
+}
+
+/// Parses a list of currency codes like `GBP, USD, EUR`, checking the
+/// exchange rate API can price each of them.
+async fn parse_display_currencies(ctx: &Context, list: &str) -> Result<Vec<String>, String> {
+    let mut currencies: Vec<String> = Vec::new();
+    for code in list.split(|c: char| c == ',' || c.is_whitespace()) {
+        if code.trim().is_empty() {
+            continue;
+        }
+        let code = rates::parse_currency(code)?;
+        if !currencies.contains(&code) {
+            currencies.push(code);
+        }
+    }
+    if currencies.is_empty() {
+        return Err("Give at least one currency code, e.g. GBP, USD, EUR".to_string());
+    }
+    if currencies.len() > settings::MAX_DISPLAY_CURRENCIES {
+        return Err(format!(
+            "Choose at most {} currencies",
+            settings::MAX_DISPLAY_CURRENCIES
+        ));
+    }
+
+    let priced = rates::service(ctx)
+        .await?
+        .get_many("GBP", &currencies)
+        .await;
+    if let Some(missing) = currencies
+        .iter()
+        .find(|code| !priced.iter().any(|rate| &rate.quote == *code))
+    {
+        return Err(format!("No exchange rate is available for {}", missing));
+    }
+    Ok(currencies)
+}
+
+async fn handle_serverconfig_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let subcommand = command.data.options.first().ok_or("Missing subcommand")?;
+    let option = |name: &str| {
+        subcommand
+            .options
+            .iter()
+            .find(|option| option.name == name)
+            .and_then(|option| option.value.as_ref())
+    };
+    let settings = settings::store(ctx).await?;
+
+    if subcommand.name == "claims" {
+        let hours = option("hours").and_then(|hours| hours.as_u64());
+        if hours.is_some() {
+            settings
+                .update(|settings| {
+                    settings
+                        .guilds
+                        .entry(guild_id.0)
+                        .or_default()
+                        .claim_timeout_hours = hours
+                })
+                .await?;
+        }
+        let timeout_hours = settings
+            .read()
+            .await
+            .guild(Some(guild_id))
+            .claim_timeout_secs()
+            / 3600;
+
+        let embed = CreateEmbed::default()
+            .title("Order Claims")
+            .description(format!(
+                "Claimed orders go back in the queue after **{} hours** without activity.",
+                timeout_hours
+            ))
+            .color(0x0096FF)
+            .clone();
+        return send_embed(ctx, command, embed, true).await;
+    }
+
+    if subcommand.name == "identity" {
+        let provider = option("provider").and_then(|provider| provider.as_str());
+        let api_key = option("key").and_then(|key| key.as_str());
+        let identity = match (provider, api_key) {
+            (Some("none"), _) => None,
+            (Some(provider), Some(api_key)) => Some(settings::IdentitySettings {
+                provider: identity::Provider::parse(provider)
+                    .ok_or("Invalid provider. Use 'bloxlink' or 'rover'.")?,
+                api_key: api_key.trim().to_string(),
+            }),
+            (Some(_), None) => return Err("Give the provider's API key with key:".to_string()),
+            (None, _) => settings.read().await.guild(Some(guild_id)).identity,
+        };
+        if provider.is_some() {
+            settings
+                .update(|settings| {
+                    settings.guilds.entry(guild_id.0).or_default().identity = identity.clone()
+                })
+                .await?;
+        }
+
+        let embed = CreateEmbed::default()
+            .title("Roblox Identity")
+            .description(match identity {
+                Some(identity) => format!(
+                    "Members who haven't used `/link` are looked up through {}.",
+                    identity.provider.label()
+                ),
+                None => "Only accounts linked with `/link` are used.".to_string(),
+            })
+            .color(0x0096FF)
+            .clone();
+        return send_embed(ctx, command, embed, true).await;
+    }
+
+    if subcommand.name == "cooldowns" {
+        let seconds = option("seconds").and_then(|seconds| seconds.as_u64());
+        let role_id = option("role")
+            .and_then(|role| role.as_str())
+            .and_then(|role| role.parse::<u64>().ok());
+        let bypass = option("bypass").and_then(|bypass| bypass.as_bool());
+        let cooldowns = settings
+            .update(|settings| {
+                let cooldowns = &mut settings.guilds.entry(guild_id.0).or_default().cooldowns;
+                if seconds.is_some() {
+                    cooldowns.seconds = seconds;
+                }
+                if let Some(role_id) = role_id {
+                    cooldowns.bypass_role_ids.retain(|&id| id != role_id);
+                    if bypass != Some(false) {
+                        cooldowns.bypass_role_ids.push(role_id);
+                    }
+                }
+                cooldowns.clone()
+            })
+            .await?;
+
+        let embed = CreateEmbed::default()
+            .title("Command Cooldowns")
+            .description(match cooldowns.seconds.filter(|&seconds| seconds > 0) {
+                Some(seconds) => format!(
+                    "Members have to wait **{} seconds** between uses of the same command.",
+                    seconds
+                ),
+                None => "Members can use commands as often as they like.".to_string(),
+            })
+            .field(
+                "Exempt Roles",
+                if cooldowns.bypass_role_ids.is_empty() {
+                    "None".to_string()
+                } else {
+                    cooldowns
+                        .bypass_role_ids
+                        .iter()
+                        .map(|role_id| format!("<@&{}>", role_id))
+                        .collect::<Vec<_>>()
+                        .join(", ")
+                },
+                false,
+            )
+            .color(0x0096FF)
+            .clone();
+        return send_embed(ctx, command, embed, true).await;
+    }
+
+    if subcommand.name == "customer" {
+        let role_id = option("role")
+            .and_then(|role| role.as_str())
+            .and_then(|role| role.parse::<u64>().ok());
+        let enabled = option("enabled").and_then(|enabled| enabled.as_bool());
+        let customer_role_id = settings
+            .update(|settings| {
+                let guild = settings.guilds.entry(guild_id.0).or_default();
+                if enabled == Some(false) {
+                    guild.customer_role_id = None;
+                } else if role_id.is_some() {
+                    guild.customer_role_id = role_id;
+                }
+                guild.customer_role_id
+            })
+            .await?;
+
+        let embed = CreateEmbed::default()
+            .title("Customer Role")
+            .description(match customer_role_id {
+                Some(role_id) => format!(
+                    "Buyers get <@&{}> when their first order is completed.",
+                    role_id
+                ),
+                None => "Buyers don't get a role for completing orders.".to_string(),
+            })
+            .color(0x0096FF)
+            .clone();
+        return send_embed(ctx, command, embed, true).await;
+    }
+
+    if subcommand.name == "receipts" {
+        let vouch = option("vouch").and_then(|vouch| vouch.as_str());
+        let pdf = option("pdf").and_then(|pdf| pdf.as_bool());
+        let receipts = settings
+            .update(|settings| {
+                let receipts = &mut settings.guilds.entry(guild_id.0).or_default().receipts;
+                if let Some(vouch) = vouch {
+                    receipts.vouch = match vouch.trim() {
+                        "" | "default" => None,
+                        vouch => Some(vouch.to_string()),
+                    };
+                }
+                if let Some(pdf) = pdf {
+                    receipts.pdf = pdf;
+                }
+                receipts.clone()
+            })
+            .await?;
+
+        let embed = CreateEmbed::default()
+            .title("Receipts")
+            .description(format!(
+                "Buyers are sent a receipt when their order is completed{}.",
+                if receipts.pdf {
+                    ", with a PDF invoice"
+                } else {
+                    ""
+                }
+            ))
+            .field(
+                "Vouch Reminder",
+                receipts.vouch_reminder(Some(command.user.id.0)),
+                false,
+            )
+            .color(0x0096FF)
+            .clone();
+        return send_embed(ctx, command, embed, true).await;
+    }
+
+    if subcommand.name == "currencies" {
+        let currencies = match option("list").and_then(|list| list.as_str()) {
+            Some(list) if list.trim().eq_ignore_ascii_case("default") => Some(Vec::new()),
+            Some(list) => Some(parse_display_currencies(ctx, list).await?),
+            None => None,
+        };
+        let currencies = settings
+            .update(|settings| {
+                let guild = settings.guilds.entry(guild_id.0).or_default();
+                if let Some(currencies) = currencies {
+                    guild.display_currencies = currencies;
+                }
+                guild.display_currencies()
+            })
+            .await?;
+
+        let embed = CreateEmbed::default()
+            .title("Display Currencies")
+            .description(format!(
+                "`/price` shows amounts in {}.",
+                currencies.join(", ")
+            ))
+            .color(0x0096FF)
+            .clone();
+        return send_embed(ctx, command, embed, true).await;
+    }
+
+    if subcommand.name == "language" {
+        let language = match option("code").and_then(|code| code.as_str()) {
+            Some(code) if code.trim().eq_ignore_ascii_case("default") => Some(None),
+            Some(code) => match i18n::supported(code) {
+                Some(locale) => Some(Some(locale.to_string())),
+                None => {
+                    return Err(format!(
+                        "There's no translation for '{}'. Choose from {}.",
+                        code.trim(),
+                        i18n::locales().collect::<Vec<_>>().join(", ")
+                    ))
+                }
+            },
+            None => None,
+        };
+        let language = settings
+            .update(|settings| {
+                let guild = settings.guilds.entry(guild_id.0).or_default();
+                if let Some(language) = language {
+                    guild.language = language;
+                }
+                guild.language.clone()
+            })
+            .await?;
+
+        let embed = CreateEmbed::default()
+            .title("Language")
+            .description(format!(
+                "Members whose Discord language has a translation see responses in it. Everyone else sees **{}**.",
+                language.as_deref().unwrap_or(i18n::DEFAULT_LOCALE)
+            ))
+            .field(
+                "Translations",
+                i18n::locales().collect::<Vec<_>>().join(", "),
+                false,
+            )
+            .color(0x0096FF)
+            .clone();
+        return send_embed(ctx, command, embed, true).await;
+    }
+
+    if subcommand.name == "timezone" {
+        let offset = match option("offset").and_then(|offset| offset.as_str()) {
+            Some(offset) if offset.trim().eq_ignore_ascii_case("default") => Some(None),
+            Some(offset) => Some(Some(timezone::parse(offset)?)),
+            None => None,
+        };
+        let offset = settings
+            .update(|settings| {
+                let guild = settings.guilds.entry(guild_id.0).or_default();
+                if let Some(offset) = offset {
+                    guild.utc_offset_minutes = offset;
+                }
+                guild.utc_offset_minutes.unwrap_or(0)
+            })
+            .await?;
+
+        let embed = CreateEmbed::default()
+            .title("Timezone")
+            .description(format!(
+                "Times Discord can't show in each member's local time, such as embed footers and PDF receipts, are written in **{}** unless the member set their own with `/timezone`.",
+                timezone::label(offset)
+            ))
+            .color(0x0096FF)
+            .clone();
+        return send_embed(ctx, command, embed, true).await;
+    }
+
+    if subcommand.name == "payments" {
+        let channel_id = option("channel")
+            .and_then(|channel| channel.as_str())
+            .and_then(|channel| channel.parse::<u64>().ok());
+        let enabled = option("enabled").and_then(|enabled| enabled.as_bool());
+        let payments_channel_id = settings
+            .update(|settings| {
+                let guild = settings.guilds.entry(guild_id.0).or_default();
+                if enabled == Some(false) {
+                    guild.payments_channel_id = None;
+                } else if channel_id.is_some() {
+                    guild.payments_channel_id = channel_id;
+                }
+                guild.payments_channel_id
+            })
+            .await?;
+
+        let embed = CreateEmbed::default()
+            .title("Payment Alerts")
+            .description(match payments_channel_id {
+                Some(channel_id) => format!(
+                    "Payments received through PayPal or Stripe are reported in <#{}>.",
+                    channel_id
+                ),
+                None => "Payments are only reported to the staff member who claimed the order."
+                    .to_string(),
+            })
+            .color(0x0096FF)
+            .clone();
+        return send_embed(ctx, command, embed, true).await;
+    }
+
+    if subcommand.name == "limits" {
+        let large_amount = option("large_amount").and_then(|robux| robux.as_u64());
+        if large_amount.is_some() {
+            settings
+                .update(|settings| {
+                    settings
+                        .guilds
+                        .entry(guild_id.0)
+                        .or_default()
+                        .large_amount_robux = large_amount
+                })
+                .await?;
+        }
+        let threshold = settings
+            .read()
+            .await
+            .guild(Some(guild_id))
+            .large_amount_threshold();
+
+        let embed = CreateEmbed::default()
+            .title("Amount Limits")
+            .description(match threshold {
+                Some(robux) => format!(
+                    "Quotes, calculations and orders over **{} R$** are flagged for a second look. Nothing over {} R$ is accepted.",
+                    amount::group_thousands(robux as f64),
+                    amount::group_thousands(amount::MAX_ROBUX)
+                ),
+                None => format!(
+                    "Large amounts aren't flagged. Nothing over {} R$ is accepted.",
+                    amount::group_thousands(amount::MAX_ROBUX)
+                ),
+            })
+            .color(0x0096FF)
+            .clone();
+        return send_embed(ctx, command, embed, true).await;
+    }
+
+    if subcommand.name == "packages" {
+        let packages = match option("list").and_then(|list| list.as_str()) {
+            Some(list) if list.trim().eq_ignore_ascii_case("default") => Some(None),
+            Some(list) if list.trim().eq_ignore_ascii_case("off") => Some(Some(Vec::new())),
+            Some(list) => Some(Some(parse_official_packages(list)?)),
+            None => None,
+        };
+        let packages = settings
+            .update(|settings| {
+                let guild = settings.guilds.entry(guild_id.0).or_default();
+                if let Some(packages) = packages {
+                    guild.official_packages = packages;
+                }
+                guild.official_packages()
+            })
+            .await?;
+
+        let embed = CreateEmbed::default()
+            .title("Roblox Packs")
+            .description(if packages.is_empty() {
+                "`/price` doesn't show savings against Roblox's own prices.".to_string()
+            } else {
+                format!(
+                    "`/price` shows how much buyers save against the cheapest mix of these packs:\n{}",
+                    packages
+                        .iter()
+                        .map(|package| format!(
+                            "{} R$ for £{:.2}",
+                            amount::group_thousands(package.robux as f64),
+                            package.gbp
+                        ))
+                        .collect::<Vec<_>>()
+                        .join("\n")
+                )
+            })
+            .color(0x0096FF)
+            .clone();
+        return send_embed(ctx, command, embed, true).await;
+    }
+
+    if subcommand.name == "risk" {
+        let account_days = option("account_days").and_then(|days| days.as_u64());
+        let first_order = match option("first_order").and_then(|gbp| gbp.as_str()) {
+            Some(gbp) if gbp.trim() == "0" => Some(0.0),
+            Some(gbp) => Some(amount::parse_money(gbp)?),
+            None => None,
+        };
+        let risk = settings
+            .update(|settings| {
+                let risk = &mut settings.guilds.entry(guild_id.0).or_default().risk;
+                if account_days.is_some() {
+                    risk.new_account_days = account_days;
+                }
+                if first_order.is_some() {
+                    risk.first_order_limit_gbp = first_order;
+                }
+                risk.clone()
+            })
+            .await?;
+
+        let embed = CreateEmbed::default()
+            .title("Chargeback Risk")
+            .description(format!(
+                "Orders are marked high-risk for {}. An admin has to confirm them with `/order confirm` before they are delivered.",
+                risk.describe()
+            ))
+            .color(0x0096FF)
+            .clone();
+        return send_embed(ctx, command, embed, true).await;
+    }
+
+    if subcommand.name == "sla" {
+        let hours = option("hours").and_then(|hours| hours.as_u64());
+        let channel_id = option("channel")
+            .and_then(|channel| channel.as_str())
+            .and_then(|channel| channel.parse::<u64>().ok());
+        let enabled = option("enabled").and_then(|enabled| enabled.as_bool());
+
+        let current = settings.read().await.guild(Some(guild_id)).sla;
+        let sla = if enabled == Some(false) {
+            None
+        } else if hours.is_none() && channel_id.is_none() {
+            current.clone()
+        } else {
+            Some(settings::SlaSettings {
+                delivery_hours: hours
+                    .or(current.as_ref().map(|current| current.delivery_hours))
+                    .ok_or("Choose how many hours after payment orders must be delivered in")?,
+                channel_id: channel_id.or(current.as_ref().and_then(|current| current.channel_id)),
+            })
+        };
+        settings
+            .update(|settings| settings.guilds.entry(guild_id.0).or_default().sla = sla.clone())
+            .await?;
+
+        let embed = CreateEmbed::default()
+            .title("Delivery Deadline")
+            .description(match sla {
+                Some(sla) => format!("Paid orders must be delivered within {}.", sla.describe()),
+                None => "Paid orders have no delivery deadline.".to_string(),
+            })
+            .color(0x0096FF)
+            .clone();
+        return send_embed(ctx, command, embed, true).await;
+    }
+
+    if subcommand.name == "announcements" {
+        let channel_id = option("channel")
+            .and_then(|channel| channel.as_str())
+            .and_then(|channel| channel.parse::<u64>().ok());
+        let role_id = option("role")
+            .and_then(|role| role.as_str())
+            .and_then(|role| role.parse::<u64>().ok());
+        let ping = option("ping").and_then(|ping| ping.as_bool());
+        let enabled = option("enabled").and_then(|enabled| enabled.as_bool());
+
+        let current = settings.read().await.guild(Some(guild_id)).announcements;
+        let announcements = if enabled == Some(false) {
+            None
+        } else if channel_id.is_none() && role_id.is_none() && ping.is_none() {
+            current.clone()
+        } else {
+            let channel_id = channel_id
+                .or(current.as_ref().map(|current| current.channel_id))
+                .ok_or("Choose a channel to announce rate changes in")?;
+            let role_id = role_id.or(current.as_ref().and_then(|current| current.role_id));
+            let ping = ping
+                .or(current.as_ref().map(|current| current.ping))
+                .unwrap_or(false);
+            if ping && role_id.is_none() {
+                return Err("Choose a role to mention in announcements".to_string());
+            }
+            Some(settings::AnnouncementSettings {
+                channel_id,
+                role_id,
+                ping,
+            })
+        };
+        settings
+            .update(|settings| {
+                settings.guilds.entry(guild_id.0).or_default().announcements = announcements.clone()
+            })
+            .await?;
+
+        let embed = CreateEmbed::default()
+            .title("Rate Announcements")
+            .description(match announcements {
+                Some(announcements) => format!(
+                    "Rate changes are announced in {}.",
+                    announcements.describe()
+                ),
+                None => "Rate changes are not announced.".to_string(),
+            })
+            .color(0x0096FF)
+            .clone();
+        return send_embed_response(ctx, command, embed).await;
+    }
+
+    let description = match option("command").and_then(|command| command.as_str()) {
+        Some(name) => {
+            let spec = commands::find(name).ok_or_else(|| format!("Unknown command '{}'", name))?;
+            if !spec.can_disable() {
+                return Err(format!("`/{}` cannot be disabled", spec.name));
+            }
+            let enabled = option("enabled")
+                .and_then(|enabled| enabled.as_bool())
+                .ok_or("Set enabled to True or False")?;
+
+            settings
+                .update(|settings| {
+                    let disabled_commands = &mut settings
+                        .guilds
+                        .entry(guild_id.0)
+                        .or_default()
+                        .disabled_commands;
+                    if enabled {
+                        disabled_commands.remove(spec.name);
+                    } else {
+                        disabled_commands.insert(spec.name.to_string());
+                    }
+                })
+                .await?;
+            format!(
+                "`/{}` is now {} in this server.",
+                spec.name,
+                if enabled { "enabled" } else { "disabled" }
+            )
+        }
+        None => {
+            let mut disabled_commands: Vec<_> = settings
+                .read()
+                .await
+                .guild(Some(guild_id))
+                .disabled_commands
+                .into_iter()
+                .collect();
+            disabled_commands.sort();
+            if disabled_commands.is_empty() {
+                "Every command is enabled in this server.".to_string()
+            } else {
+                format!(
+                    "Disabled in this server: {}",
+                    disabled_commands
+                        .iter()
+                        .map(|name| format!("`/{}`", name))
+                        .collect::<Vec<_>>()
+                        .join(", ")
+                )
+            }
+        }
+    };
+
+    let embed = CreateEmbed::default()
+        .title("Server Commands")
+        .description(description)
+        .color(0x0096FF)
+        .clone();
+
+    send_embed_response(ctx, command, embed).await
+}
+
+/// Removes the pricing for `role_id`, describing what it was for the audit log.
+fn take_role_pricing(role_pricing: &mut Vec<settings::RolePricing>, role_id: u64) -> String {
+    let old_pricing = role_pricing
+        .iter()
+        .find(|pricing| pricing.role_id == role_id)
+        .map_or_else(|| "None".to_string(), |pricing| pricing.describe());
+    role_pricing.retain(|pricing| pricing.role_id != role_id);
+    old_pricing
+}
+
+fn describe_tax(tax: Option<&settings::TaxSettings>) -> String {
+    tax.map_or_else(|| "Off".to_string(), |tax| tax.describe())
+}
+
+/// Records a pricing change made by the invoking user in the audit log. The
+/// change has already been saved, so a failure is logged rather than returned.
+async fn record_change(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    guild_id: GuildId,
+    setting: &str,
+    old_value: String,
+    new_value: String,
+) {
+    let change = audit::Change {
+        guild_id: guild_id.0,
+        setting: setting.to_string(),
+        actor_id: command.user.id.0,
+        actor_name: command.user.name.clone(),
+        old_value,
+        new_value,
+        changed_at: 0,
+    };
+    let result = match audit::store(ctx).await {
+        Ok(store) => store.record(change).await,
+        Err(error) => Err(error),
+    };
+    if let Err(error) = result {
+        log::error!("Error recording pricing change: {}", error);
+    }
+}
+
+async fn handle_order_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let subcommand = command.data.options.first().ok_or("Missing subcommand")?;
+    let option = |name: &str| subcommand.options.iter().find(|option| option.name == name);
+    match subcommand.name.as_str() {
+        "create" => {}
+        "verify" => return verify_order(ctx, command, guild_id, subcommand).await,
+        "status" => return set_order_status(ctx, command, guild_id, subcommand).await,
+        "claim" | "unclaim" => return claim_order(ctx, command, guild_id, subcommand).await,
+        "refund" => return refund_order(ctx, command, guild_id, subcommand).await,
+        "confirm" => return confirm_order_risk(ctx, command, guild_id, subcommand).await,
+        "paylink" => return send_payment_link(ctx, command, guild_id, subcommand).await,
+        "confirmpayment" => {
+            return confirm_manual_payment(ctx, command, guild_id, subcommand).await
+        }
+        "queue" => return show_order_queue(ctx, command, guild_id).await,
+        other => return Err(format!("Unknown order action: {}", other)),
+    }
+
+    let (buyer, member) = match option("buyer").and_then(|buyer| buyer.resolved.as_ref()) {
+        Some(application_command::CommandDataOptionValue::User(user, member)) => {
+            (user.clone(), member.clone())
+        }
+        _ => return Err("Missing buyer".to_string()),
+    };
+    let amount = amount::parse_robux(
+        option("amount")
+            .and_then(|amount| amount.value.as_ref())
+            .and_then(|amount| amount.as_str())
+            .ok_or("Missing amount")?,
+    )?;
+    let price_type = match option("type")
+        .and_then(|price_type| price_type.value.as_ref())
+        .and_then(|price_type| price_type.as_str())
+    {
+        Some(price_type) => {
+            pricing::PriceType::parse(price_type).ok_or("Invalid type. Use 'b/t' or 'a/t'.")?
+        }
+        None => pricing::PriceType::AfterTax,
+    };
+    let gamepass_id = match option("gamepass")
+        .and_then(|gamepass| gamepass.value.as_ref())
+        .and_then(|gamepass| gamepass.as_str())
+    {
+        Some(gamepass) => Some(
+            roblox::parse_gamepass_id(gamepass)
+                .ok_or("Give the gamepass as its link or numeric ID")?,
+        ),
+        None => None,
+    };
+
+    let guild_settings = guild_settings(ctx, command).await?;
+    let roles: Vec<u64> = member
+        .map(|member| member.roles.iter().map(|role| role.0).collect())
+        .unwrap_or_default();
+    let discount_percent = guild_settings
+        .role_pricing_for(&roles)
+        .map_or(0.0, |pricing| pricing.discount_percent);
+    let seller = match option("seller")
+        .and_then(|seller| seller.value.as_ref())
+        .and_then(|seller| seller.as_str())
+    {
+        Some(name) => Some(
+            guild_settings
+                .seller(name)
+                .cloned()
+                .ok_or_else(|| format!("Unknown seller '{}'. See /seller list.", name))?,
+        ),
+        None => None,
+    };
+    let card = match &seller {
+        Some(seller) => guild_settings.seller_rate_card(seller),
+        None => guild_settings.rate_card(),
+    };
+
+    let gamepass_price = card.gamepass_price(amount, price_type);
+    let gbp_amount = card.gbp_price(amount, price_type) * (1.0 - discount_percent / 100.0);
+    let gbp_to_usd = guild_rate(ctx, command, "GBP", "USD").await?;
+    let large_amount_threshold = guild_settings.large_amount_threshold();
+    let tax = guild_settings.tax;
+    let tax_gbp = tax.as_ref().map_or(0.0, |tax| tax.on(gbp_amount));
+    let gross_gbp = gbp_amount + tax_gbp;
+    let (listed_price, gamepass_check) = check_gamepass(gamepass_id, gamepass_price).await;
+    let buyer_roblox = roblox_username(ctx, Some(guild_id), buyer.id.0).await;
+    let risks = assess_risk(ctx, guild_id, buyer.id.0, gross_gbp, &guild_settings.risk).await?;
+
+    let orders = orders::store(ctx).await?;
+    let duplicates = orders
+        .duplicates(
+            guild_id.0,
+            buyer.id.0,
+            amount as u64,
+            store::now().saturating_sub(DUPLICATE_WINDOW_SECS),
+        )
+        .await;
+    let mut order = orders
+        .record(orders::Order {
+            id: 0,
+            guild_id: Some(guild_id.0),
+            buyer_id: buyer.id.0,
+            buyer_name: buyer.name.clone(),
+            buyer_roblox: buyer_roblox.clone(),
+            robux: amount as u64,
+            gamepass_price,
+            after_tax: price_type.is_after_tax(),
+            fee_robux: card.marketplace_fee(gamepass_price),
+            robux_to_gbp_rate: card.gbp_rate(price_type),
+            gbp_to_usd_rate: gbp_to_usd.value,
+            fx_margin_percent: gbp_to_usd.margin_percent,
+            discount_percent,
+            tax_gbp,
+            total_gbp: gross_gbp,
+            total_usd: gross_gbp * gbp_to_usd.value,
+            status: orders::OrderStatus::PendingPayment,
+            seller: seller.as_ref().map(|seller| seller.name.clone()),
+            method: pricing::DeliveryMethod::Gamepass,
+            payment_method: None,
+            gamepass_id,
+            listed_price,
+            created_at: 0,
+            history: Vec::new(),
+            delivery_proof: None,
+            refunds: Vec::new(),
+            feedback: None,
+            dispute_id: None,
+            dispute_decision: None,
+            escrow: None,
+            sla_reminded_at: None,
+            sla_escalated_at: None,
+            risk: None,
+            payment_proofs: Vec::new(),
+            payment: None,
+            payment_link: None,
+            rate_snapshots: vec![gbp_to_usd.snapshot()],
+            claimed_by: None,
+            claimed_at: None,
+            reminder: None,
+        })
+        .await?;
+    if !risks.is_empty() {
+        order = orders
+            .update(order.id, |order| order.add_risk(risks))
+            .await?;
+    }
+
+    let stamp = gbp_to_usd
+        .snapshot()
+        .stamp(utc_offset(ctx, Some(guild_id), command.user.id.0).await);
+    let mut embed = CreateEmbed::default()
+        .title(format!("Order #{}", order.id))
+        .description(format!(
+            "<@{}> is buying **{} R$** {}{}.",
+            buyer.id,
+            amount as u64,
+            price_type.label(),
+            seller
+                .as_ref()
+                .map(|seller| format!(" from {}", seller.name))
+                .unwrap_or_default()
+        ))
+        .field("Gamepass Price", format!("{} R$", gamepass_price), true)
+        .field(
+            "Total in GBP",
+            tax_lines('£', gbp_amount, tax.as_ref()),
+            true,
+        )
+        .field(
+            "Total in USD",
+            tax_lines('$', gbp_amount * gbp_to_usd.value, tax.as_ref()),
+            true,
+        )
+        .field("Gamepass Check", gamepass_check, false)
+        .footer(|footer| footer.text(format!("{} • {}", order.status.label(), stamp)))
+        .color(
+            if order.price_mismatch()
+                || order.awaiting_risk_confirmation()
+                || !duplicates.is_empty()
+            {
+                0xFFA500
+            } else {
+                0x0096FF
+            },
+        )
+        .clone();
+    if let Some(username) = &buyer_roblox {
+        embed.field("Buyer's Roblox Account", username, false);
+    }
+    add_large_amount_warning(&mut embed, large_amount_threshold, amount);
+    if !duplicates.is_empty() {
+        embed.field(
+            "Possible Duplicate",
+            format!(
+                "<@{}> already has an open order for {} R$ from the last {} minutes: {}. \
+                 Check it isn't the same order before delivering, and cancel whichever is extra.",
+                buyer.id,
+                amount as u64,
+                DUPLICATE_WINDOW_SECS / 60,
+                duplicates
+                    .iter()
+                    .map(|duplicate| format!(
+                        "#{} ({}, <t:{}:R>)",
+                        duplicate.id,
+                        duplicate.status.label(),
+                        duplicate.created_at
+                    ))
+                    .collect::<Vec<_>>()
+                    .join(", ")
+            ),
+            false,
+        );
+    }
+    let tenant = tenant::tenant(ctx).await?;
+    embed.field(
+        "Payment Reference",
+        format!(
+            "`{}`: the buyer puts this on bank transfers and Cash App payments. PayPal buttons send `{}:{}` as `custom`.",
+            order.payment_reference(),
+            tenant.label(),
+            order.payment_reference()
+        ),
+        false,
+    );
+    if let Some(risk) = &order.risk {
+        embed.field("High Risk", risk_summary(&order, risk), false);
+    }
+    if discount_percent > 0.0 {
+        embed.field(
+            "Role Pricing",
+            format!("{}% discount applied", discount_percent),
+            false,
+        );
+    }
+    if listed_price != Some(gamepass_price) {
+        let guide = match send_gamepass_guide(ctx, &buyer, &order).await {
+            Ok(()) => format!("Setup steps were sent to <@{}> by DM.", buyer.id),
+            Err(error) => {
+                log::error!("Error sending gamepass guide: {}", error);
+                format!(
+                    "Couldn't DM <@{}> the setup steps. Their DMs may be closed.",
+                    buyer.id
+                )
+            }
+        };
+        embed.field("Gamepass Setup", guide, false);
+    }
+    add_rate_notes(&mut embed, &gbp_to_usd);
+    let components = payment_proof_button(&order);
+
+    outbound::send(&ctx.http, || {
+        command.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::ChannelMessageWithSource)
+                .interaction_response_data(|message| {
+                    message
+                        .add_embed(embed.clone())
+                        .set_components(components.clone())
+                })
+        })
+    })
+    .await
+    .map_err(|e| format!("Error sending response: {:?}", e))
+}
+
+/// DMs the buyer step-by-step instructions for listing the gamepass `order`
+/// needs, with a button to hand its link over once it's up.
+async fn send_gamepass_guide(
+    ctx: &Context,
+    buyer: &User,
+    order: &orders::Order,
+) -> Result<(), String> {
+    let embed = CreateEmbed::default()
+        .title(format!("Set Up Your Gamepass for Order #{}", order.id))
+        .description(format!(
+            "To receive **{} R$**, list a gamepass priced at exactly **{} R$**:\n\n\
+             **1.** Open the [Creator Dashboard]({}) and pick any of your experiences. \
+             A new blank one works fine.\n\
+             **2.** Go to **Monetization → Passes** and create a pass. Any name and image will do.\n\
+             **3.** On the pass's **Sales** tab, turn on **Item for Sale** and set the price to \
+             **{} R$**. Leave regional pricing off so everyone sees the same price.\n\
+             **4.** Copy the pass's link from its store page, then press **I've set it up** and paste it.\n\n\
+             Stuck? Roblox's [gamepass guide]({}) has screenshots of each step.",
+            order.robux,
+            order.gamepass_price,
+            GAMEPASS_CREATE_URL,
+            order.gamepass_price,
+            GAMEPASS_GUIDE_URL
+        ))
+        .field(
+            "Why this price?",
+            format!(
+                "Roblox keeps {} R$ of each sale as its marketplace fee, so a {} R$ gamepass pays out {} R$.",
+                order.fee_robux,
+                order.gamepass_price,
+                order.gamepass_price - order.fee_robux
+            ),
+            false,
+        )
+        .color(0x0096FF)
+        .clone();
+    let mut components = CreateComponents::default();
+    components.create_action_row(|row| {
+        row.create_button(|button| {
+            button
+                .custom_id(components::custom_id("gamepass", &[&order.id]))
+                .label("I've set it up")
+                .style(ButtonStyle::Success)
+        })
+        .create_button(|button| {
+            button
+                .url(GAMEPASS_CREATE_URL)
+                .label("Creator Dashboard")
+                .style(ButtonStyle::Link)
+        })
+    });
+
+    let channel = buyer
+        .create_dm_channel(&ctx.http)
+        .await
+        .map_err(|e| format!("Error opening DM: {:?}", e))?;
+    outbound::send(&ctx.http, || {
+        channel.id.send_message(&ctx.http, |message| {
+            message
+                .set_embed(embed.clone())
+                .set_components(components.clone())
+        })
+    })
+    .await
+    .map(|_| ())
+    .map_err(|e| format!("Error sending gamepass guide: {:?}", e))
+}
+
+/// The order a gamepass setup button or form is for, from its custom ID
+/// `gamepass:<order id>`, as long as `user_id` is its buyer and it's still
+/// open.
+async fn gamepass_setup_order(
+    ctx: &Context,
+    custom_id: &components::ComponentId,
+    user_id: UserId,
+) -> Result<orders::Order, String> {
+    let id: u64 = custom_id.arg(0)?;
+    let order = orders::store(ctx)
+        .await?
+        .get(id)
+        .await
+        .filter(|order| order.buyer_id == user_id.0)
+        .ok_or_else(|| format!("Order #{} doesn't exist", id))?;
+    if order.status.is_closed() {
+        return Err(format!(
+            "Order #{} is already {}",
+            id,
+            order.status.label().to_lowercase()
+        ));
+    }
+    Ok(order)
+}
+
+/// Handles the "I've set it up" button from the gamepass guide by asking for
+/// the gamepass link.
+async fn handle_gamepass_setup(
+    ctx: &Context,
+    component: &MessageComponentInteraction,
+    custom_id: &components::ComponentId,
+) -> Result<(), String> {
+    let order = gamepass_setup_order(ctx, custom_id, component.user.id).await?;
+
+    outbound::send(&ctx.http, || {
+        component.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::Modal)
+                .interaction_response_data(|modal| {
+                    modal
+                        .custom_id(components::custom_id("gamepass", &[&order.id]))
+                        .title(format!("Gamepass for Order #{}", order.id))
+                        .components(|components| {
+                            components.create_action_row(|row| {
+                                row.create_input_text(|input| {
+                                    input
+                                        .custom_id("gamepass")
+                                        .label("Gamepass link or ID")
+                                        .placeholder("https://www.roblox.com/game-pass/123456")
+                                        .style(InputTextStyle::Short)
+                                        .required(true)
+                                })
+                            })
+                        })
+                })
+        })
+    })
+    .await
+    .map_err(|e| format!("Error opening gamepass form: {:?}", e))
+}
+
+/// Attaches the gamepass submitted from the guide to its order, checking it is
+/// listed at the right price.
+async fn handle_gamepass_submit(
+    ctx: &Context,
+    modal: &ModalSubmitInteraction,
+    custom_id: &components::ComponentId,
+) -> Result<(), String> {
+    let order = gamepass_setup_order(ctx, custom_id, modal.user.id).await?;
+    let input = modal
+        .data
+        .components
+        .iter()
+        .flat_map(|row| &row.components)
+        .find_map(|component| match component {
+            ActionRowComponent::InputText(input) if input.custom_id == "gamepass" => {
+                Some(input.value.as_str())
+            }
+            _ => None,
+        })
+        .ok_or("Missing gamepass")?;
+    let gamepass_id =
+        roblox::parse_gamepass_id(input).ok_or("Give the gamepass as its link or numeric ID")?;
+
+    let (listed_price, gamepass_check) =
+        check_gamepass(Some(gamepass_id), order.gamepass_price).await;
+    let order = orders::store(ctx)
+        .await?
+        .update(order.id, |order| {
+            order.gamepass_id = Some(gamepass_id);
+            order.listed_price = listed_price;
+        })
+        .await?;
+
+    let ready = listed_price == Some(order.gamepass_price);
+    let embed = CreateEmbed::default()
+        .title(format!("Gamepass Added to Order #{}", order.id))
+        .description(if ready {
+            format!(
+                "{}\n\nYou're all set. Staff will buy the gamepass once payment is confirmed.",
+                gamepass_check
+            )
+        } else {
+            format!(
+                "{}\n\nFix the listing, then press **I've set it up** again.",
+                gamepass_check
+            )
+        })
+        .color(if ready { 0x0096FF } else { 0xFFA500 })
+        .clone();
+
+    outbound::send(&ctx.http, || {
+        modal.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::ChannelMessageWithSource)
+                .interaction_response_data(|message| message.add_embed(embed.clone()))
+        })
+    })
+    .await
+    .map_err(|e| format!("Error sending response: {:?}", e))
+}
+
+/// Confirms delivery by checking on Roblox that the seller's account owns the
+/// buyer's gamepass, then marks the order delivered with the proof.
+async fn verify_order(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    guild_id: GuildId,
+    subcommand: &application_command::CommandDataOption,
+) -> Result<(), String> {
+    let option = |name: &str| {
+        subcommand
+            .options
+            .iter()
+            .find(|option| option.name == name)
+            .and_then(|option| option.value.as_ref())
+    };
+    let id = option("id")
+        .and_then(|id| id.as_u64())
+        .ok_or("Missing order ID")?;
+
+    let orders = orders::store(ctx).await?;
+    let order = orders
+        .get(id)
+        .await
+        .filter(|order| order.guild_id == Some(guild_id.0))
+        .ok_or_else(|| format!("Order #{} doesn't exist", id))?;
+    if !order.status.can_become(orders::OrderStatus::Delivered) {
+        return Err(format!(
+            "Order #{} is {}, so it can't be marked delivered",
+            id,
+            order.status.label()
+        ));
+    }
+    if order.awaiting_risk_confirmation() {
+        return Err(format!(
+            "Order #{} is high-risk and has to be confirmed with /order confirm before it is delivered",
+            id
+        ));
+    }
+    let gamepass_id = order
+        .gamepass_id
+        .ok_or("This order has no gamepass to check")?;
+    let username = match option("roblox").and_then(|roblox| roblox.as_str()) {
+        Some(username) => username.trim().to_string(),
+        None => {
+            let guild_settings = guild_settings(ctx, command).await?;
+            order
+                .seller
+                .as_ref()
+                .and_then(|seller| guild_settings.seller(seller))
+                .and_then(|seller| seller.roblox_username.clone())
+                .ok_or("Give the Roblox account that bought the gamepass with roblox:")?
+        }
+    };
+
+    let account = roblox::user(&username).await?;
+    if !roblox::owns_gamepass(account.id, gamepass_id).await? {
+        return Err(format!(
+            "{} doesn't own gamepass {} yet, so the order isn't delivered",
+            account.name, gamepass_id
+        ));
+    }
+
+    let proof = format!(
+        "Roblox account {} ({}) owns gamepass {}",
+        account.name, account.id, gamepass_id
+    );
+    let (order, from) = orders
+        .transition(
+            id,
+            orders::OrderStatus::Delivered,
+            Some(command.user.id.0),
+            |order| order.delivery_proof = Some(proof.clone()),
+        )
+        .await?;
+    on_order_transition(ctx, &order, from).await;
+
+    let embed = CreateEmbed::default()
+        .title(format!("Order #{} Delivered", order.id))
+        .description(format!(
+            "**{}** bought <@{}>'s [gamepass]({}), so the {} R$ have been delivered.",
+            account.name,
+            order.buyer_id,
+            roblox::gamepass_url(gamepass_id),
+            order.robux
+        ))
+        .field("Proof", proof, false)
+        .field(
+            "Delivered",
+            format!(
+                "<t:{}:f>",
+                order
+                    .entered_at(orders::OrderStatus::Delivered)
+                    .unwrap_or_default()
+            ),
+            false,
+        )
+        .color(0x0096FF)
+        .clone();
+
+    send_embed_response(ctx, command, embed).await
+}
+
+/// Moves an order to the given status, or shows its timeline when no status is
+/// given.
+async fn set_order_status(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    guild_id: GuildId,
+    subcommand: &application_command::CommandDataOption,
+) -> Result<(), String> {
+    let option = |name: &str| {
+        subcommand
+            .options
+            .iter()
+            .find(|option| option.name == name)
+            .and_then(|option| option.value.as_ref())
+    };
+    let id = option("id")
+        .and_then(|id| id.as_u64())
+        .ok_or("Missing order ID")?;
+
+    let orders = orders::store(ctx).await?;
+    let order = orders
+        .get(id)
+        .await
+        .filter(|order| order.guild_id == Some(guild_id.0))
+        .ok_or_else(|| format!("Order #{} doesn't exist", id))?;
+    let order = match option("status").and_then(|status| status.as_str()) {
+        Some(status) => {
+            let status = orders::OrderStatus::parse(status).ok_or("Unknown status")?;
+            if status == orders::OrderStatus::Refunded {
+                return Err(format!("Use /order refund id:{} to record the refund", id));
+            }
+            let (order, from) = orders
+                .transition(id, status, Some(command.user.id.0), |_| {})
+                .await?;
+            on_order_transition(ctx, &order, from).await;
+            order
+        }
+        None => order,
+    };
+
+    let timeline = order
+        .history
+        .iter()
+        .map(|change| {
+            format!(
+                "**{}** <t:{}:f>{}",
+                change.status.label(),
+                change.at,
+                change
+                    .by
+                    .map(|by| format!(" by <@{}>", by))
+                    .unwrap_or_default()
+            )
+        })
+        .collect::<Vec<_>>()
+        .join("\n");
+    let next = order
+        .status
+        .next()
+        .iter()
+        .map(|status| status.label())
+        .collect::<Vec<_>>()
+        .join(", ");
+    let mut embed = CreateEmbed::default()
+        .title(format!("Order #{}: {}", order.id, order.status.label()))
+        .description(format!(
+            "<@{}>'s order for **{} R$**.",
+            order.buyer_id, order.robux
+        ))
+        .field(
+            "Timeline",
+            if timeline.is_empty() {
+                format!("Created <t:{}:f>", order.created_at)
+            } else {
+                timeline
+            },
+            false,
+        )
+        .field(
+            "Next",
+            if next.is_empty() {
+                "Nothing, the order is closed".to_string()
+            } else {
+                next
+            },
+            false,
+        )
+        .color(0x0096FF)
+        .clone();
+    if let Some(risk) = &order.risk {
+        embed.field("High Risk", risk_summary(&order, risk), false);
+    }
+    if let Some(method) = &order.payment_method {
+        embed.field("Payment Method", method, true);
+    }
+    if let Some(payment) = &order.payment {
+        embed.field("Payment", payment.describe(), false);
+    }
+    if !order.rate_snapshots.is_empty() {
+        embed.field(
+            "Rates Used",
+            order
+                .rate_snapshots
+                .iter()
+                .map(|snapshot| snapshot.describe())
+                .collect::<Vec<_>>()
+                .join("\n"),
+            false,
+        );
+    }
+    if order.status == orders::OrderStatus::PendingPayment {
+        embed.field("Payment Reference", order.payment_reference(), true);
+    }
+    if let (Some(link), orders::OrderStatus::PendingPayment) = (&order.payment_link, order.status) {
+        embed.field(
+            "Payment Link",
+            format!("{} (<t:{}:R>)", link.url, link.created_at),
+            false,
+        );
+    }
+    if !order.payment_proofs.is_empty() {
+        embed.field("Payment Proof", payment_proof_list(&order), false);
+    }
+    if let Some(dispute_id) = order.dispute_id {
+        embed.field(
+            "Dispute",
+            format!(
+                "#{}: {}",
+                dispute_id,
+                order
+                    .dispute_decision
+                    .map_or("Open", |decision| decision.label())
+            ),
+            false,
+        );
+    }
+
+    send_embed(ctx, command, embed, true).await
+}
+
+/// Why `buyer_id`'s order of `total_gbp` looks like a chargeback risk, if it
+/// does: they were flagged, their Discord account is new, it is a large first
+/// purchase or they have had disputes before.
+async fn assess_risk(
+    ctx: &Context,
+    guild_id: GuildId,
+    buyer_id: u64,
+    total_gbp: f64,
+    settings: &settings::RiskSettings,
+) -> Result<Vec<String>, String> {
+    let mut reasons = Vec::new();
+
+    if let Some(flag) = risk::store(ctx).await?.get(guild_id.0, buyer_id).await {
+        reasons.push(format!(
+            "Flagged by <@{}>: {}",
+            flag.flagged_by, flag.reason
+        ));
+    }
+    let account_age_days = risk::account_age_days(buyer_id);
+    if account_age_days < settings.new_account_days() {
+        reasons.push(format!(
+            "Discord account is only {} days old",
+            account_age_days
+        ));
+    }
+    let first_order_limit_gbp = settings.first_order_limit_gbp();
+    if first_order_limit_gbp > 0.0 && total_gbp > first_order_limit_gbp {
+        let has_completed = orders::store(ctx)
+            .await?
+            .for_buyer(buyer_id)
+            .await
+            .iter()
+            .any(|order| {
+                order.guild_id == Some(guild_id.0) && order.status == orders::OrderStatus::Completed
+            });
+        if !has_completed {
+            reasons.push(format!(
+                "First purchase, over £{:.2}",
+                first_order_limit_gbp
+            ));
+        }
+    }
+    let disputes = disputes::store(ctx)
+        .await?
+        .count_as_buyer(guild_id.0, buyer_id)
+        .await;
+    if disputes > 0 {
+        reasons.push(format!(
+            "Has had {} dispute{} before",
+            disputes,
+            if disputes == 1 { "" } else { "s" }
+        ));
+    }
+
+    Ok(reasons)
+}
+
+/// An order's risk reasons and whether it has been confirmed for delivery.
+fn risk_summary(order: &orders::Order, risk: &orders::RiskReview) -> String {
+    let reasons = risk
+        .reasons
+        .iter()
+        .map(|reason| format!("• {}", reason))
+        .collect::<Vec<_>>()
+        .join("\n");
+    match (risk.confirmed_by, risk.confirmed_at) {
+        (Some(by), Some(at)) => format!("{}\nConfirmed by <@{}> <t:{}:R>.", reasons, by, at),
+        _ => format!(
+            "{}\nAn admin has to confirm it with `/order confirm id:{}` before it is delivered.",
+            reasons, order.id
+        ),
+    }
+}
+
+/// Clears a high-risk order for delivery. Only admins can, so the staff
+/// delivering it can't wave it through themselves.
+async fn confirm_order_risk(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    guild_id: GuildId,
+    subcommand: &application_command::CommandDataOption,
+) -> Result<(), String> {
+    let is_admin = command
+        .member
+        .as_ref()
+        .and_then(|member| member.permissions)
+        .map_or(false, |permissions| {
+            permissions.contains(Permissions::ADMINISTRATOR)
+        });
+    if !is_admin {
+        return Err("Only administrators can confirm high-risk orders".to_string());
+    }
+    let id = subcommand
+        .options
+        .iter()
+        .find(|option| option.name == "id")
+        .and_then(|option| option.value.as_ref())
+        .and_then(|id| id.as_u64())
+        .ok_or("Missing order ID")?;
+
+    let orders = orders::store(ctx).await?;
+    let order = orders
+        .get(id)
+        .await
+        .filter(|order| order.guild_id == Some(guild_id.0))
+        .ok_or_else(|| format!("Order #{} doesn't exist", id))?;
+    if !order.awaiting_risk_confirmation() {
+        return Err(format!("Order #{} isn't waiting for confirmation", id));
+    }
+    let confirmed_at = store::now();
+    let order = orders
+        .update(id, |order| {
+            if let Some(risk) = order.risk.as_mut() {
+                risk.confirmed_by = Some(command.user.id.0);
+                risk.confirmed_at = Some(confirmed_at);
+            }
+        })
+        .await?;
+
+    let embed = CreateEmbed::default()
+        .title(format!("Order #{} Confirmed", order.id))
+        .description(format!(
+            "<@{}>'s order for **{} R$** can be delivered.",
+            order.buyer_id, order.robux
+        ))
+        .field(
+            "High Risk",
+            order
+                .risk
+                .as_ref()
+                .map(|risk| risk_summary(&order, risk))
+                .unwrap_or_default(),
+            false,
+        )
+        .color(0x0096FF)
+        .clone();
+    send_embed_response(ctx, command, embed).await
+}
+
+/// Records a manual payment (bank transfer, Cash App) the buyer made with the
+/// order's payment reference, and marks the order paid. The reference has to
+/// be the order's own, so a payment can't be put against the wrong order.
+async fn confirm_manual_payment(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    guild_id: GuildId,
+    subcommand: &application_command::CommandDataOption,
+) -> Result<(), String> {
+    let option = |name: &str| {
+        subcommand
+            .options
+            .iter()
+            .find(|option| option.name == name)
+            .and_then(|option| option.value.as_ref())
+    };
+    let id = option("id")
+        .and_then(|id| id.as_u64())
+        .ok_or("Missing order ID")?;
+    let reference = option("reference")
+        .and_then(|reference| reference.as_str())
+        .ok_or("Missing payment reference")?;
+    let method = option("method")
+        .and_then(|method| method.as_str())
+        .unwrap_or("Bank transfer");
+
+    let reference_id = orders::parse_payment_reference(reference)?;
+    if reference_id != id {
+        return Err(format!(
+            "`{}` is the reference for order #{}, not #{}",
+            reference.trim(),
+            reference_id,
+            id
+        ));
+    }
+    let orders = orders::store(ctx).await?;
+    let order = orders
+        .get(id)
+        .await
+        .filter(|order| order.guild_id == Some(guild_id.0))
+        .ok_or_else(|| format!("Order #{} doesn't exist", id))?;
+    if order.status != orders::OrderStatus::PendingPayment {
+        return Err(format!(
+            "Order #{} is {}, not waiting for payment",
+            id,
+            order.status.label()
+        ));
+    }
+
+    let payment = orders::Payment {
+        provider: method.to_string(),
+        reference: order.payment_reference(),
+        amount: order.total_gbp,
+        currency: "GBP".to_string(),
+        recorded_at: store::now(),
+        recorded_by: Some(command.user.id.0),
+    };
+    let (order, from) = orders
+        .transition(
+            id,
+            orders::OrderStatus::Paid,
+            Some(command.user.id.0),
+            |order| order.payment = Some(payment.clone()),
+        )
+        .await?;
+    on_order_transition(ctx, &order, from).await;
+
+    let embed = CreateEmbed::default()
+        .title(format!("Order #{} Paid", order.id))
+        .description(format!(
+            "Recorded {} for <@{}>'s order for **{} R$**.",
+            payment.describe(),
+            order.buyer_id,
+            order.robux
+        ))
+        .color(0x0096FF)
+        .clone();
+    send_embed_response(ctx, command, embed).await
+}
+
+/// Creates a Stripe checkout page for an order's total and DMs it to the
+/// buyer. Paying through it marks the order paid via the Stripe webhook.
+async fn send_payment_link(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    guild_id: GuildId,
+    subcommand: &application_command::CommandDataOption,
+) -> Result<(), String> {
+    let id = subcommand
+        .options
+        .iter()
+        .find(|option| option.name == "id")
+        .and_then(|option| option.value.as_ref())
+        .and_then(|id| id.as_u64())
+        .ok_or("Missing order ID")?;
+
+    let orders = orders::store(ctx).await?;
+    let order = orders
+        .get(id)
+        .await
+        .filter(|order| order.guild_id == Some(guild_id.0))
+        .ok_or_else(|| format!("Order #{} doesn't exist", id))?;
+    if order.status != orders::OrderStatus::PendingPayment {
+        return Err(format!(
+            "Order #{} is {}, not waiting for payment",
+            id,
+            order.status.label()
+        ));
+    }
+    let tenant = tenant::tenant(ctx).await?;
+    let checkout = stripe::create_checkout(&tenant, &order).await?;
+    let created_at = store::now();
+    let order = orders
+        .update(id, |order| {
+            order.payment_link = Some(orders::PaymentLink {
+                provider: "Stripe".to_string(),
+                id: checkout.id.clone(),
+                url: checkout.url.clone(),
+                created_at,
+            })
+        })
+        .await?;
+
+    let buyer_embed = CreateEmbed::default()
+        .title(format!("Pay for Order #{}", order.id))
+        .description(format!(
+            "Pay **£{:.2}** for **{} R$** by card. Your order is marked paid as soon as the payment goes through.",
+            order.total_gbp, order.robux
+        ))
+        .color(0x0096FF)
+        .clone();
+    let mut components = payment_proof_button(&order);
+    components.create_action_row(|row| {
+        row.create_button(|button| {
+            button
+                .label(format!("Pay £{:.2}", order.total_gbp))
+                .style(ButtonStyle::Link)
+                .url(&checkout.url)
+        })
+    });
+    dm_buyer_with_components(ctx, &order, buyer_embed, components).await;
+
+    let embed = CreateEmbed::default()
+        .title(format!("Payment Link for Order #{}", order.id))
+        .description(format!(
+            "Sent <@{}> a checkout link for **£{:.2}**.",
+            order.buyer_id, order.total_gbp
+        ))
+        .field("Link", &checkout.url, false)
+        .color(0x0096FF)
+        .clone();
+    send_embed_response(ctx, command, embed).await
+}
+
+/// Assigns an order to the invoker, or releases it back to the queue.
+async fn claim_order(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    guild_id: GuildId,
+    subcommand: &application_command::CommandDataOption,
+) -> Result<(), String> {
+    let id = subcommand
+        .options
+        .iter()
+        .find(|option| option.name == "id")
+        .and_then(|option| option.value.as_ref())
+        .and_then(|id| id.as_u64())
+        .ok_or("Missing order ID")?;
+
+    let orders = orders::store(ctx).await?;
+    orders
+        .get(id)
+        .await
+        .filter(|order| order.guild_id == Some(guild_id.0))
+        .ok_or_else(|| format!("Order #{} doesn't exist", id))?;
+    let description = if subcommand.name == "claim" {
+        let order = orders.claim(id, command.user.id.0).await?;
+        let timeout_hours = guild_settings(ctx, command).await?.claim_timeout_secs() / 3600;
+        format!(
+            "<@{}> is handling <@{}>'s order for **{} R$**. It goes back in the queue after {} hours without activity.",
+            command.user.id, order.buyer_id, order.robux, timeout_hours
+        )
+    } else {
+        let order = orders.unclaim(id).await?;
+        format!(
+            "<@{}>'s order for **{} R$** is back in the queue.",
+            order.buyer_id, order.robux
+        )
+    };
+
+    let embed = CreateEmbed::default()
+        .title(format!(
+            "Order #{} {}",
+            id,
+            if subcommand.name == "claim" {
+                "Claimed"
+            } else {
+                "Unclaimed"
+            }
+        ))
+        .description(description)
+        .color(0x0096FF)
+        .clone();
+    send_embed(ctx, command, embed, false).await
+}
+
+/// Lists the guild's open orders nobody has claimed, and how many each staff
+/// member is handling.
+async fn show_order_queue(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    guild_id: GuildId,
+) -> Result<(), String> {
+    let open = orders::store(ctx).await?.open_in_guild(guild_id.0).await;
+    let (embed, components) = queue_page(&open, command.user.id.0, 0);
+    send_page(ctx, command, embed, components, true).await
+}
+
+/// Handles the order queue pagination controls, whose custom IDs are
+/// `queue:<user id>:<page>:<control>`.
+async fn handle_queue_page(
+    ctx: &Context,
+    component: &MessageComponentInteraction,
+    custom_id: &components::ComponentId,
+) -> Result<(), String> {
+    let guild_id = component
+        .guild_id
+        .ok_or("This button can only be used in a server")?;
+    let user_id: u64 = custom_id.arg(0)?;
+    let page = pagination::requested(component, custom_id, 1)?;
+    if user_id != component.user.id.0 {
+        return Err("Run /order queue to browse the queue yourself".to_string());
+    }
+
+    let open = orders::store(ctx).await?.open_in_guild(guild_id.0).await;
+    let (embed, components) = queue_page(&open, user_id, page);
+    update_page(ctx, component, embed, components).await
+}
+
+/// Renders one page of the unassigned orders among `open`, oldest first, with
+/// every staff member's workload.
+fn queue_page(
+    open: &[orders::Order],
+    user_id: u64,
+    page: usize,
+) -> (CreateEmbed, CreateComponents) {
+    let unassigned: Vec<String> = open
+        .iter()
+        .filter(|order| order.claimed_by.is_none())
+        .map(|order| {
+            format!(
+                "**#{}** <@{}> • {} R$ • {} since <t:{}:R>{}",
+                order.id,
+                order.buyer_id,
+                order.robux,
+                order.status.label(),
+                order.last_activity(),
+                if order.awaiting_risk_confirmation() {
+                    " • high-risk"
+                } else if order.status == orders::OrderStatus::PendingPayment
+                    && !order.payment_proofs.is_empty()
+                {
+                    " • proof sent"
+                } else {
+                    ""
+                }
+            )
+        })
+        .collect();
+    let mut workload: Vec<(u64, usize)> = Vec::new();
+    for staff_id in open.iter().filter_map(|order| order.claimed_by) {
+        match workload.iter_mut().find(|(id, _)| *id == staff_id) {
+            Some((_, count)) => *count += 1,
+            None => workload.push((staff_id, 1)),
+        }
+    }
+    workload.sort_by(|a, b| b.1.cmp(&a.1));
+
+    let mut embed = CreateEmbed::default()
+        .title("Order Queue")
+        .description(format!(
+            "{} open orders, {} unassigned.",
+            open.len(),
+            unassigned.len()
+        ))
+        .color(0x0096FF)
+        .clone();
+    let page = pagination::Page::of(unassigned.len(), QUEUE_PAGE_SIZE, page);
+    if !unassigned.is_empty() {
+        embed.field(
+            "Unassigned",
+            page.items(unassigned.iter(), QUEUE_PAGE_SIZE)
+                .into_iter()
+                .cloned()
+                .collect::<Vec<_>>()
+                .join("\n"),
+            false,
+        );
+    }
+    if !workload.is_empty() {
+        embed.field(
+            "Workload",
+            workload
+                .iter()
+                .map(|(staff_id, count)| format!("<@{}>: {} open", staff_id, count))
+                .collect::<Vec<_>>()
+                .join("\n"),
+            false,
+        );
+    }
+    if page.count > 1 {
+        embed.footer(|footer| footer.text(page.label(i18n::DEFAULT_LOCALE)));
+    }
+    let components = pagination::controls("queue", &[&user_id], &page, i18n::DEFAULT_LOCALE);
+
+    (embed, components)
+}
+
+/// Records funds a middleman holds for an order, their release, or what is
+/// currently held.
+async fn handle_escrow_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let subcommand = command.data.options.first().ok_or("Missing subcommand")?;
+    let option = |name: &str| {
+        subcommand
+            .options
+            .iter()
+            .find(|option| option.name == name)
+            .and_then(|option| option.value.as_ref())
+    };
+    let orders = orders::store(ctx).await?;
+
+    let id = match option("id").and_then(|id| id.as_u64()) {
+        Some(id) => id,
+        None if subcommand.name == "status" => {
+            let held = orders.in_escrow(guild_id.0).await;
+            let lines: Vec<String> = held
+                .iter()
+                .filter_map(|order| {
+                    let escrow = order.escrow.as_ref()?;
+                    Some(format!(
+                        "**#{}** {} held by <@{}> since <t:{}:R>",
+                        order.id,
+                        ratecard::Currency {
+                            code: escrow.currency.clone(),
+                            gbp_rate: 1.0,
+                        }
+                        .format(escrow.amount),
+                        escrow.holder_id,
+                        escrow.held_at
+                    ))
+                })
+                .collect();
+            let embed = CreateEmbed::default()
+                .title("Funds in Escrow")
+                .description(if lines.is_empty() {
+                    "No funds are held for this server's orders.".to_string()
+                } else {
+                    lines.join("\n")
+                })
+                .color(0x0096FF)
+                .clone();
+            return send_embed(ctx, command, embed, true).await;
+        }
+        None => return Err("Missing order ID".to_string()),
+    };
+    let order = orders
+        .get(id)
+        .await
+        .filter(|order| order.guild_id == Some(guild_id.0))
+        .ok_or_else(|| format!("Order #{} doesn't exist", id))?;
+
+    let order = match subcommand.name.as_str() {
+        "hold" => {
+            if order.escrow.as_ref().map_or(false, orders::Escrow::is_held) {
+                return Err(format!(
+                    "Funds are already held for order #{}. Release them first.",
+                    id
+                ));
+            }
+            let holder_id = option("holder")
+                .and_then(|holder| holder.as_str())
+                .and_then(|holder| holder.parse::<u64>().ok())
+                .ok_or("Missing holder")?;
+            let currency = option("currency")
+                .and_then(|currency| currency.as_str())
+                .unwrap_or("GBP")
+                .to_string();
+            let amount = match option("amount").and_then(|amount| amount.as_str()) {
+                Some(amount) => amount::parse_money(amount)?,
+                None if currency == "USD" => order.total_usd,
+                None => order.total_gbp,
+            };
+            let conditions = option("conditions")
+                .and_then(|conditions| conditions.as_str())
+                .unwrap_or("Delivery is confirmed")
+                .trim()
+                .to_string();
+            let escrow = orders::Escrow {
+                amount,
+                currency,
+                holder_id,
+                conditions,
+                held_at: store::now(),
+                recorded_by: command.user.id.0,
+                released_at: None,
+                released_by: None,
+                release_note: None,
+            };
+            orders
+                .update(id, |order| order.escrow = Some(escrow.clone()))
+                .await?
+        }
+        "release" => {
+            if !order.escrow.as_ref().map_or(false, orders::Escrow::is_held) {
+                return Err(format!("No funds are held for order #{}", id));
+            }
+            let note = option("note")
+                .and_then(|note| note.as_str())
+                .map(|note| note.trim().to_string());
+            let released_by = command.user.id.0;
+            orders
+                .update(id, |order| {
+                    if let Some(escrow) = &mut order.escrow {
+                        escrow.released_at = Some(store::now());
+                        escrow.released_by = Some(released_by);
+                        escrow.release_note = note.clone();
+                    }
+                })
+                .await?
+        }
+        "status" => order,
+        other => return Err(format!("Unknown escrow action: {}", other)),
+    };
+
+    let escrow = order
+        .escrow
+        .as_ref()
+        .ok_or_else(|| format!("Order #{} has never had funds in escrow", id))?;
+    let amount = ratecard::Currency {
+        code: escrow.currency.clone(),
+        gbp_rate: 1.0,
+    }
+    .format(escrow.amount);
+    let mut embed = CreateEmbed::default()
+        .title(format!(
+            "Order #{} Escrow: {}",
+            order.id,
+            if escrow.is_held() { "Held" } else { "Released" }
+        ))
+        .description(format!(
+            "**{}** for <@{}>'s order of {} R$.",
+            amount, order.buyer_id, order.robux
+        ))
+        .field("Holder", format!("<@{}>", escrow.holder_id), true)
+        .field(
+            "Held",
+            format!("<t:{}:f> by <@{}>", escrow.held_at, escrow.recorded_by),
+            true,
+        )
+        .field("Release Conditions", &escrow.conditions, false)
+        .color(0x0096FF)
+        .clone();
+    if let (Some(released_at), Some(released_by)) = (escrow.released_at, escrow.released_by) {
+        embed.field(
+            "Released",
+            format!(
+                "<t:{}:f> by <@{}>{}",
+                released_at,
+                released_by,
+                escrow
+                    .release_note
+                    .as_ref()
+                    .map(|note| format!(": {}", note))
+                    .unwrap_or_default()
+            ),
+            false,
+        );
+    }
+
+    send_embed(ctx, command, embed, subcommand.name == "status").await
+}
+
+/// Opens a dispute over an order in a private thread, collects evidence from
+/// both sides, and records an admin's decision.
+async fn handle_dispute_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let subcommand = command.data.options.first().ok_or("Missing subcommand")?;
+    let option = |name: &str| subcommand.options.iter().find(|option| option.name == name);
+    let value = |name: &str| option(name).and_then(|option| option.value.as_ref());
+    let is_staff = command
+        .member
+        .as_ref()
+        .and_then(|member| member.permissions)
+        .map_or(false, |permissions| {
+            permissions.contains(Permissions::MANAGE_GUILD)
+        });
+    let disputes = disputes::store(ctx).await?;
+
+    match subcommand.name.as_str() {
+        "open" => {
+            let order_id = value("order")
+                .and_then(|id| id.as_u64())
+                .ok_or("Missing order ID")?;
+            let reason = value("reason")
+                .and_then(|reason| reason.as_str())
+                .ok_or("Missing reason")?
+                .trim()
+                .to_string();
+            let orders = orders::store(ctx).await?;
+            let order = orders
+                .get(order_id)
+                .await
+                .filter(|order| order.guild_id == Some(guild_id.0))
+                .filter(|order| is_staff || order.buyer_id == command.user.id.0)
+                .ok_or_else(|| format!("Order #{} doesn't exist", order_id))?;
+            if order.status == orders::OrderStatus::Quoted {
+                return Err("Quotes can't be disputed".to_string());
+            }
+
+            let thread = command
+                .channel_id
+                .create_private_thread(&ctx.http, |thread| {
+                    thread
+                        .name(format!("Dispute: Order #{}", order.id))
+                        .kind(ChannelType::PrivateThread)
+                        .invitable(false)
+                })
+                .await
+                .map_err(|e| format!("Error creating dispute thread: {:?}", e))?;
+            let dispute = disputes
+                .open_dispute(disputes::Dispute {
+                    id: 0,
+                    guild_id: guild_id.0,
+                    order_id: order.id,
+                    thread_id: thread.id.0,
+                    opened_by: command.user.id.0,
+                    buyer_id: order.buyer_id,
+                    seller_id: order.claimed_by,
+                    reason: reason.clone(),
+                    opened_at: 0,
+                    evidence: Vec::new(),
+                    resolution: None,
+                })
+                .await?;
+            orders
+                .update(order.id, |order| {
+                    order.dispute_id = Some(dispute.id);
+                    order.dispute_decision = None;
+                })
+                .await?;
+
+            let parties: HashSet<u64> = [
+                Some(dispute.buyer_id),
+                dispute.seller_id,
+                Some(dispute.opened_by),
+            ]
+            .into_iter()
+            .flatten()
+            .collect();
+            for user_id in &parties {
+                if let Err(error) = thread
+                    .id
+                    .add_thread_member(&ctx.http, UserId(*user_id))
+                    .await
+                {
+                    log::error!(
+                        "Error adding {} to dispute #{}: {:?}",
+                        user_id,
+                        dispute.id,
+                        error
+                    );
+                }
+            }
+            let embed = CreateEmbed::default()
+                .title(format!("Dispute #{}: Order #{}", dispute.id, order.id))
+                .description(format!(
+                    "<@{}> opened a dispute over <@{}>'s order for {} R$.\n\n> {}\n\n\
+                     Both sides should add screenshots and other evidence here with `/dispute evidence`. \
+                     An admin will review it and decide with `/dispute resolve`.",
+                    dispute.opened_by, order.buyer_id, order.robux, reason
+                ))
+                .field("Status", order.status.label(), true)
+                .field("Total", format!("£{:.2}", order.net_gbp()), true)
+                .field(
+                    "Handled By",
+                    dispute
+                        .seller_id
+                        .map(|seller_id| format!("<@{}>", seller_id))
+                        .unwrap_or_else(|| "Unclaimed".to_string()),
+                    true,
+                )
+                .color(0xFFA500)
+                .clone();
+            outbound::send(&ctx.http, || {
+                thread
+                    .id
+                    .send_message(&ctx.http, |message| message.set_embed(embed.clone()))
+            })
+            .await
+            .map_err(|e| format!("Error posting in dispute thread: {:?}", e))?;
+
+            respond_ephemeral(
+                ctx,
+                command,
+                &format!("Dispute #{} opened in <#{}>.", dispute.id, thread.id),
+            )
+            .await
+        }
+        "evidence" => {
+            let dispute = disputes
+                .in_thread(command.channel_id.0)
+                .await
+                .ok_or("Use this in a dispute's thread")?;
+            if !is_staff && !dispute.is_party(command.user.id.0) {
+                return Err("Only the people in this dispute can add evidence".to_string());
+            }
+            let attachment = match option("file").and_then(|file| file.resolved.as_ref()) {
+                Some(application_command::CommandDataOptionValue::Attachment(attachment)) => {
+                    Some(attachment.clone())
+                }
+                _ => None,
+            };
+            let note = value("note")
+                .and_then(|note| note.as_str())
+                .map(|note| note.trim().to_string());
+            if attachment.is_none() && note.is_none() {
+                return Err("Attach a file or write a note".to_string());
+            }
+            let dispute = disputes
+                .add_evidence(
+                    dispute.id,
+                    disputes::Evidence {
+                        user_id: command.user.id.0,
+                        url: attachment.as_ref().map(|attachment| attachment.url.clone()),
+                        note: note.clone(),
+                        submitted_at: store::now(),
+                    },
+                )
+                .await?;
+
+            let mut embed = CreateEmbed::default()
+                .title(format!(
+                    "Evidence #{} for Dispute #{}",
+                    dispute.evidence.len(),
+                    dispute.id
+                ))
+                .description(format!(
+                    "From <@{}>{}",
+                    command.user.id,
+                    note.map(|note| format!(":\n> {}", note))
+                        .unwrap_or_default()
+                ))
+                .color(0x0096FF)
+                .clone();
+            if let Some(attachment) = &attachment {
+                embed.field(
+                    "File",
+                    format!("[{}]({})", attachment.filename, attachment.url),
+                    false,
+                );
+                if attachment
+                    .content_type
+                    .as_deref()
+                    .map_or(false, |content_type| content_type.starts_with("image/"))
+                {
+                    embed.image(&attachment.url);
+                }
+            }
+            send_embed(ctx, command, embed, false).await
+        }
+        "resolve" => {
+            if !is_staff {
+                return Err("Only server managers can resolve disputes".to_string());
+            }
+            let dispute = disputes
+                .in_thread(command.channel_id.0)
+                .await
+                .ok_or("Use this in a dispute's thread")?;
+            let decision = value("decision")
+                .and_then(|decision| decision.as_str())
+                .and_then(disputes::Decision::parse)
+                .ok_or("Choose who the dispute is decided in favour of")?;
+            let note = value("note")
+                .and_then(|note| note.as_str())
+                .unwrap_or_default()
+                .trim()
+                .to_string();
+            let dispute = disputes
+                .resolve(
+                    dispute.id,
+                    disputes::Resolution {
+                        decision,
+                        note: note.clone(),
+                        resolved_by: command.user.id.0,
+                        resolved_at: store::now(),
+                    },
+                )
+                .await?;
+            orders::store(ctx)
+                .await?
+                .update(dispute.order_id, |order| {
+                    order.dispute_decision = Some(decision)
+                })
+                .await?;
+
+            let mut embed = CreateEmbed::default()
+                .title(format!("Dispute #{} Resolved", dispute.id))
+                .description(format!(
+                    "<@{}> decided the dispute over order #{}: **{}**.",
+                    command.user.id,
+                    dispute.order_id,
+                    decision.label()
+                ))
+                .field("Evidence Reviewed", dispute.evidence.len(), true)
+                .color(0x0096FF)
+                .clone();
+            if !note.is_empty() {
+                embed.field("Reasoning", &note, false);
+            }
+            if decision != disputes::Decision::Seller {
+                embed.field(
+                    "Next Step",
+                    format!(
+                        "Record any money returned with `/order refund id:{}`.",
+                        dispute.order_id
+                    ),
+                    false,
+                );
+            }
+            send_embed(ctx, command, embed, false).await
+        }
+        "reputation" => {
+            let user_id = value("user")
+                .and_then(|user| user.as_str())
+                .and_then(|user| user.parse::<u64>().ok())
+                .unwrap_or(command.user.id.0);
+            let reputation = disputes.reputation(guild_id.0, user_id).await;
+            let embed = CreateEmbed::default()
+                .title("Dispute Record")
+                .description(format!("<@{}>'s disputes in this server.", user_id))
+                .field("Won", reputation.won, true)
+                .field("Lost", reputation.lost, true)
+                .field("Split", reputation.split, true)
+                .field("Open", reputation.open, true)
+                .color(0x0096FF)
+                .clone();
+            send_embed(ctx, command, embed, true).await
+        }
+        other => Err(format!("Unknown dispute action: {}", other)),
+    }
+}
+
+async fn handle_giveaway_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let subcommand = command.data.options.first().ok_or("Missing subcommand")?;
+    let option = |name: &str| {
+        subcommand
+            .options
+            .iter()
+            .find(|option| option.name == name)
+            .and_then(|option| option.value.as_ref())
+    };
+    let giveaways = giveaways::store(ctx).await?;
+
+    if subcommand.name == "start" {
+        let robux = amount::parse_robux(
+            option("robux")
+                .and_then(|robux| robux.as_str())
+                .ok_or("Missing robux")?,
+        )? as u64;
+        let duration_secs = giveaways::parse_duration(
+            option("duration")
+                .and_then(|duration| duration.as_str())
+                .ok_or("Missing duration")?,
+        )?;
+        let required_role_id = option("role")
+            .and_then(|role| role.as_str())
+            .and_then(|role| role.parse::<u64>().ok());
+
+        let started_at = store::now();
+        let giveaway = giveaways
+            .create(giveaways::Giveaway {
+                id: 0,
+                guild_id: guild_id.0,
+                channel_id: command.channel_id.0,
+                message_id: None,
+                host_id: command.user.id.0,
+                robux,
+                required_role_id,
+                started_at,
+                ends_at: started_at + duration_secs,
+                entrants: Vec::new(),
+                winners: Vec::new(),
+                ended: false,
+                order_id: None,
+            })
+            .await?;
+        let embed = giveaway_embed(&giveaway);
+        let components = giveaway_components(&giveaway);
+        let message = outbound::send(&ctx.http, || {
+            command.channel_id.send_message(&ctx.http, |message| {
+                message
+                    .set_embed(embed.clone())
+                    .set_components(components.clone())
+            })
+        })
+        .await
+        .map_err(|e| format!("Error posting giveaway: {:?}", e))?;
+        giveaways
+            .update(giveaway.id, |giveaway| {
+                giveaway.message_id = Some(message.id.0)
+            })
+            .await?;
+        return respond_ephemeral(
+            ctx,
+            command,
+            &format!(
+                "Giveaway #{} is running until <t:{}:f>. End it early with `/giveaway end id:{}`.",
+                giveaway.id, giveaway.ends_at, giveaway.id
+            ),
+        )
+        .await;
+    }
+
+    let id = option("id")
+        .and_then(|id| id.as_u64())
+        .ok_or("Missing giveaway ID")?;
+    let giveaway = giveaways
+        .get(id)
+        .await
+        .filter(|giveaway| giveaway.guild_id == guild_id.0)
+        .ok_or_else(|| format!("Giveaway #{} doesn't exist", id))?;
+
+    match subcommand.name.as_str() {
+        "end" => {
+            if giveaway.ended {
+                return Err(format!(
+                    "Giveaway #{} has already ended. Use `/giveaway reroll` to draw someone else.",
+                    id
+                ));
+            }
+        }
+        "reroll" => {
+            if !giveaway.ended {
+                return Err(format!("Giveaway #{} is still running", id));
+            }
+            let order = match giveaway.order_id {
+                Some(order_id) => orders::store(ctx).await?.get(order_id).await,
+                None => None,
+            };
+            if let Some(order) = order {
+                if !matches!(
+                    order.status,
+                    orders::OrderStatus::PendingPayment | orders::OrderStatus::Paid
+                ) {
+                    return Err(format!(
+                        "The prize (order #{}) is already {}, so the winner can't be re-rolled",
+                        order.id,
+                        order.status.label().to_lowercase()
+                    ));
+                }
+            }
+        }
+        other => return Err(format!("Unknown giveaway action: {}", other)),
+    }
+
+    let giveaway = draw_giveaway(ctx, id).await?;
+    let winner_id = giveaway.winner().unwrap_or_default();
+    respond_ephemeral(
+        ctx,
+        command,
+        &format!("<@{}> won giveaway #{}.", winner_id, giveaway.id),
+    )
+    .await
+}
+
+/// Enters the member who pressed a giveaway's button, if they meet its
+/// requirements.
+async fn handle_giveaway_entry(
+    ctx: &Context,
+    component: &MessageComponentInteraction,
+    custom_id: &components::ComponentId,
+) -> Result<(), String> {
+    let id: u64 = custom_id.arg(0)?;
+    let giveaways = giveaways::store(ctx).await?;
+    let giveaway = giveaways
+        .get(id)
+        .await
+        .ok_or("This giveaway no longer exists")?;
+    if let Some(role_id) = giveaway.required_role_id {
+        let has_role = component.member.as_ref().map_or(false, |member| {
+            member.roles.iter().any(|role| role.0 == role_id)
+        });
+        if !has_role {
+            return Err(format!("You need <@&{}> to enter this giveaway.", role_id));
+        }
+    }
+
+    let (giveaway, entered) = giveaways.enter(id, component.user.id.0).await?;
+    if entered {
+        update_giveaway_message(ctx, &giveaway).await;
+    }
+
+    outbound::send(&ctx.http, || {
+        component.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::ChannelMessageWithSource)
+                .interaction_response_data(|message| {
+                    message
+                        .content(if entered {
+                            format!("You're in! The winner is drawn <t:{}:R>.", giveaway.ends_at)
+                        } else {
+                            "You've already entered this giveaway.".to_string()
+                        })
+                        .ephemeral(true)
+                })
+        })
+    })
+    .await
+    .map_err(|e| format!("Error sending response: {:?}", e))
+}
+
+/// Ends a giveaway whose time is up, for the giveaway sweeper.
+async fn finish_giveaway(ctx: &Context, id: u64) {
+    if let Err(error) = draw_giveaway(ctx, id).await {
+        log::error!("Error drawing giveaway #{}: {}", id, error);
+    }
+}
+
+/// Ends giveaway `id` if it is still running and draws a winner, or draws a
+/// new one if it has ended. The prize goes to the winner as a zero-priced
+/// order, which a re-roll hands over to the new winner. The result is
+/// announced in the giveaway's channel.
+async fn draw_giveaway(ctx: &Context, id: u64) -> Result<giveaways::Giveaway, String> {
+    let giveaways = giveaways::store(ctx).await?;
+    let drawn = giveaways.draw(id).await;
+    let giveaway = match drawn {
+        Ok(giveaway) => giveaway,
+        Err(error) => {
+            if let Some(giveaway) = giveaways.get(id).await {
+                update_giveaway_message(ctx, &giveaway).await;
+            }
+            return Err(error);
+        }
+    };
+    let winner_id = giveaway.winner().ok_or("No winner was drawn")?;
+    let winner = UserId(winner_id)
+        .to_user(ctx)
+        .await
+        .map_err(|e| format!("Error looking up the winner: {:?}", e))?;
+    let buyer_roblox = roblox_username(ctx, Some(GuildId(giveaway.guild_id)), winner_id).await;
+
+    let orders = orders::store(ctx).await?;
+    let order = match giveaway.order_id {
+        Some(order_id) => {
+            orders
+                .update(order_id, |order| {
+                    order.buyer_id = winner_id;
+                    order.buyer_name = winner.name.clone();
+                    order.buyer_roblox = buyer_roblox.clone();
+                    order.gamepass_id = None;
+                    order.listed_price = None;
+                })
+                .await?
+        }
+        None => {
+            let card = settings::store(ctx)
+                .await?
+                .read()
+                .await
+                .guild(Some(GuildId(giveaway.guild_id)))
+                .rate_card();
+            let gamepass_price =
+                card.gamepass_price(giveaway.robux as f64, pricing::PriceType::AfterTax);
+            orders
+                .record(orders::Order {
+                    id: 0,
+                    guild_id: Some(giveaway.guild_id),
+                    buyer_id: winner_id,
+                    buyer_name: winner.name.clone(),
+                    buyer_roblox,
+                    robux: giveaway.robux,
+                    gamepass_price,
+                    after_tax: true,
+                    fee_robux: card.marketplace_fee(gamepass_price),
+                    robux_to_gbp_rate: 0.0,
+                    gbp_to_usd_rate: 0.0,
+                    fx_margin_percent: 0.0,
+                    discount_percent: 0.0,
+                    tax_gbp: 0.0,
+                    total_gbp: 0.0,
+                    total_usd: 0.0,
+                    status: orders::OrderStatus::Paid,
+                    seller: None,
+                    method: pricing::DeliveryMethod::Gamepass,
+                    payment_method: None,
+                    gamepass_id: None,
+                    listed_price: None,
+                    created_at: 0,
+                    history: Vec::new(),
+                    delivery_proof: None,
+                    refunds: Vec::new(),
+                    feedback: None,
+                    dispute_id: None,
+                    dispute_decision: None,
+                    escrow: None,
+                    sla_reminded_at: None,
+                    sla_escalated_at: None,
+                    risk: None,
+                    payment_proofs: Vec::new(),
+                    payment: None,
+                    payment_link: None,
+                    rate_snapshots: Vec::new(),
+                    claimed_by: None,
+                    claimed_at: None,
+                    reminder: None,
+                })
+                .await?
+        }
+    };
+    let giveaway = giveaways
+        .update(id, |giveaway| giveaway.order_id = Some(order.id))
+        .await?;
+    update_giveaway_message(ctx, &giveaway).await;
+
+    let announcement = if giveaway.winners.len() > 1 {
+        format!(
+            "Re-rolled! <@{}> wins **{} R$** instead. Check your DMs to claim it.",
+            winner_id, giveaway.robux
+        )
+    } else {
+        format!(
+            "Congratulations <@{}>, you won **{} R$**! Check your DMs to claim it.",
+            winner_id, giveaway.robux
+        )
+    };
+    if let Err(error) = outbound::send(&ctx.http, || {
+        ChannelId(giveaway.channel_id)
+            .send_message(&ctx.http, |message| message.content(&announcement))
+    })
+    .await
+    {
+        log::error!("Error announcing giveaway #{}: {:?}", giveaway.id, error);
+    }
+    if let Err(error) = send_gamepass_guide(ctx, &winner, &order).await {
+        log::error!("Error sending gamepass guide: {}", error);
+    }
+
+    Ok(giveaway)
+}
+
+/// Refreshes a giveaway's message with its entrant count, or its result once
+/// it has ended.
+async fn update_giveaway_message(ctx: &Context, giveaway: &giveaways::Giveaway) {
+    let message_id = match giveaway.message_id {
+        Some(message_id) => message_id,
+        None => return,
+    };
+    let embed = giveaway_embed(giveaway);
+    let components = giveaway_components(giveaway);
+    if let Err(error) = outbound::send(&ctx.http, || {
+        ChannelId(giveaway.channel_id).edit_message(&ctx.http, message_id, |message| {
+            message
+                .set_embed(embed.clone())
+                .set_components(components.clone())
+        })
+    })
+    .await
+    {
+        log::error!("Error updating giveaway #{}: {:?}", giveaway.id, error);
+    }
+}
+
+fn giveaway_embed(giveaway: &giveaways::Giveaway) -> CreateEmbed {
+    let mut description = if !giveaway.ended {
+        format!(
+            "Press **Enter** to take part. The winner is drawn <t:{}:R>.",
+            giveaway.ends_at
+        )
+    } else {
+        match giveaway.winner() {
+            Some(winner_id) => format!("Won by <@{}>.", winner_id),
+            None => "Ended without a winner.".to_string(),
+        }
+    };
+    if let Some(role_id) = giveaway.required_role_id {
+        description.push_str(&format!("\nOnly members with <@&{}> can enter.", role_id));
+    }
+
+    CreateEmbed::default()
+        .title(format!("{} R$ Giveaway", giveaway.robux))
+        .description(description)
+        .field("Entrants", giveaway.entrants.len(), true)
+        .field("Hosted By", format!("<@{}>", giveaway.host_id), true)
+        .footer(|footer| footer.text(format!("Giveaway #{}", giveaway.id)))
+        .color(0x0096FF)
+        .clone()
+}
+
+fn giveaway_components(giveaway: &giveaways::Giveaway) -> CreateComponents {
+    let mut components = CreateComponents::default();
+    components.create_action_row(|row| {
+        row.create_button(|button| {
+            button
+                .custom_id(components::custom_id("giveaway", &[&giveaway.id]))
+                .label("Enter")
+                .style(ButtonStyle::Success)
+                .disabled(giveaway.ended)
+        })
+    });
+    components
+}
+
+async fn handle_flag_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let subcommand = command.data.options.first().ok_or("Missing subcommand")?;
+    let option = |name: &str| {
+        subcommand
+            .options
+            .iter()
+            .find(|option| option.name == name)
+            .and_then(|option| option.value.as_ref())
+    };
+    let user_id = option("user")
+        .and_then(|user| user.as_str())
+        .and_then(|user| user.parse::<u64>().ok());
+    let flags = risk::store(ctx).await?;
+
+    let description = match subcommand.name.as_str() {
+        "add" => {
+            let user_id = user_id.ok_or("Missing user")?;
+            let reason = option("reason")
+                .and_then(|reason| reason.as_str())
+                .map(|reason| reason.trim().to_string())
+                .filter(|reason| !reason.is_empty())
+                .ok_or("Missing reason")?;
+            flags
+                .flag(risk::UserFlag {
+                    guild_id: guild_id.0,
+                    user_id,
+                    reason: reason.clone(),
+                    flagged_by: command.user.id.0,
+                    flagged_at: store::now(),
+                })
+                .await?;
+
+            // Orders already placed are held back too, unless delivery has
+            // started.
+            let orders = orders::store(ctx).await?;
+            let mut held = Vec::new();
+            for order in orders.for_buyer(user_id).await {
+                if order.guild_id != Some(guild_id.0)
+                    || !matches!(
+                        order.status,
+                        orders::OrderStatus::PendingPayment | orders::OrderStatus::Paid
+                    )
+                {
+                    continue;
+                }
+                let flag_reason = format!("Flagged by <@{}>: {}", command.user.id, reason);
+                orders
+                    .update(order.id, |order| order.add_risk(vec![flag_reason]))
+                    .await?;
+                held.push(format!("#{}", order.id));
+            }
+
+            let mut description = format!(
+                "Flagged <@{}>: {}\nTheir orders are high-risk until an admin confirms them.",
+                user_id, reason
+            );
+            if !held.is_empty() {
+                description.push_str(&format!("\nHeld back: {}", held.join(", ")));
+            }
+            description
+        }
+        "remove" => {
+            let user_id = user_id.ok_or("Missing user")?;
+            flags
+                .unflag(guild_id.0, user_id)
+                .await?
+                .ok_or_else(|| format!("<@{}> isn't flagged", user_id))?;
+            format!(
+                "Removed <@{}>'s flag. Orders already marked high-risk still need confirming.",
+                user_id
+            )
+        }
+        _ => {
+            let flags = flags.in_guild(guild_id.0).await;
+            if flags.is_empty() {
+                "Nobody is flagged.".to_string()
+            } else {
+                flags
+                    .iter()
+                    .map(|flag| {
+                        format!(
+                            "<@{}>: {} (by <@{}> <t:{}:R>)",
+                            flag.user_id, flag.reason, flag.flagged_by, flag.flagged_at
+                        )
+                    })
+                    .collect::<Vec<_>>()
+                    .join("\n")
+            }
+        }
+    };
+
+    let embed = CreateEmbed::default()
+        .title("Risk Flags")
+        .description(description)
+        .color(0x0096FF)
+        .clone();
+    send_embed(ctx, command, embed, true).await
+}
+
+/// Runs after every order status change: tells the buyer by DM where their
+/// order is now.
+async fn on_order_transition(ctx: &Context, order: &orders::Order, from: orders::OrderStatus) {
+    let message = match order.status {
+        orders::OrderStatus::PendingPayment => "is waiting for your payment.",
+        orders::OrderStatus::Paid => "has been paid. Thanks! Delivery comes next.",
+        orders::OrderStatus::Delivering => "is being delivered now.",
+        orders::OrderStatus::Delivered => {
+            "has been delivered. Your Robux will show as pending on Roblox for a few days."
+        }
+        orders::OrderStatus::Completed => {
+            grant_customer_role(ctx, order).await;
+            update_spend_tier(ctx, order).await;
+            send_receipt(ctx, order).await;
+            return;
+        }
+        orders::OrderStatus::Cancelled => "has been cancelled.",
+        orders::OrderStatus::Refunded => {
+            dm_buyer(ctx, order, refund_receipt(order)).await;
+            return;
+        }
+        orders::OrderStatus::Quoted => return,
+    };
+    let mut embed = CreateEmbed::default()
+        .title(format!("Order #{} {}", order.id, order.status.label()))
+        .description(format!("Your order for **{} R$** {}", order.robux, message))
+        .footer(|footer| footer.text(format!("Previously {}", from.label())))
+        .color(0x0096FF)
+        .clone();
+    if order.status == orders::OrderStatus::PendingPayment {
+        embed.field(
+            "Payment Reference",
+            format!(
+                "Put `{}` in the reference or note when paying by bank transfer or Cash App.",
+                order.payment_reference()
+            ),
+            false,
+        );
+    }
+    dm_buyer_with_components(ctx, order, embed, payment_proof_button(order)).await;
+}
+
+/// Gives the buyer of a completed order the guild's customer role, if it has
+/// one. Adding a role the member already has does nothing, so this is safe on
+/// every completion and restores the role if it was removed.
+async fn grant_customer_role(ctx: &Context, order: &orders::Order) {
+    let guild_id = match order.guild_id {
+        Some(guild_id) => guild_id,
+        None => return,
+    };
+    let role_id = match settings::store(ctx).await {
+        Ok(settings) => {
+            settings
+                .read()
+                .await
+                .guild(Some(GuildId(guild_id)))
+                .customer_role_id
+        }
+        Err(_) => None,
+    };
+    let role_id = match role_id {
+        Some(role_id) => role_id,
+        None => return,
+    };
+
+    let reason = format!("Completed order #{}", order.id);
+    if let Err(error) = outbound::send(&ctx.http, || {
+        ctx.http
+            .add_member_role(guild_id, order.buyer_id, role_id, Some(&reason))
+    })
+    .await
+    {
+        log::error!(
+            "Error giving the customer role to {}: {:?}",
+            order.buyer_id,
+            error
+        );
+    }
+}
+
+/// Moves the buyer of a completed order up to the spend tier their new total
+/// reaches, taking away the lower tiers' roles and announcing the upgrade in
+/// the guild's tier channel. Nothing changes unless the order crossed a
+/// threshold.
+async fn update_spend_tier(ctx: &Context, order: &orders::Order) {
+    let guild_id = match order.guild_id {
+        Some(guild_id) => guild_id,
+        None => return,
+    };
+    let guild = match settings::store(ctx).await {
+        Ok(settings) => settings.read().await.guild(Some(GuildId(guild_id))),
+        Err(_) => return,
+    };
+    if guild.spend_tiers.is_empty() {
+        return;
+    }
+    let spend_gbp = match orders::store(ctx).await {
+        Ok(orders) => orders.spend(guild_id, order.buyer_id).await,
+        Err(_) => return,
+    };
+
+    let tier = match guild.spend_tier_for(spend_gbp) {
+        Some(tier) => tier,
+        None => return,
+    };
+    let previous = guild.spend_tier_for(spend_gbp - order.net_gbp());
+    if previous.map(|previous| previous.role_id) == Some(tier.role_id) {
+        return;
+    }
+
+    let reason = format!("Spent £{:.2} in total", spend_gbp);
+    if let Err(error) = outbound::send(&ctx.http, || {
+        ctx.http
+            .add_member_role(guild_id, order.buyer_id, tier.role_id, Some(&reason))
+    })
+    .await
+    {
+        log::error!(
+            "Error giving spend tier <@&{}> to {}: {:?}",
+            tier.role_id,
+            order.buyer_id,
+            error
+        );
+        return;
+    }
+    for lower in guild
+        .spend_tiers
+        .iter()
+        .filter(|lower| lower.threshold_gbp < tier.threshold_gbp && lower.role_id != tier.role_id)
+    {
+        if let Err(error) = outbound::send(&ctx.http, || {
+            ctx.http
+                .remove_member_role(guild_id, order.buyer_id, lower.role_id, Some(&reason))
+        })
+        .await
+        {
+            log::error!(
+                "Error removing spend tier <@&{}> from {}: {:?}",
+                lower.role_id,
+                order.buyer_id,
+                error
+            );
+        }
+    }
+
+    let channel_id = match guild.spend_tier_channel_id {
+        Some(channel_id) => ChannelId(channel_id),
+        None => return,
+    };
+    let embed = CreateEmbed::default()
+        .title("Tier Upgrade")
+        .description(format!(
+            "<@{}> reached <@&{}> with £{:.2} spent. Thank you!",
+            order.buyer_id, tier.role_id, spend_gbp
+        ))
+        .color(0x0096FF)
+        .clone();
+    if let Err(error) = outbound::send(&ctx.http, || {
+        channel_id.send_message(&ctx.http, |message| message.set_embed(embed.clone()))
+    })
+    .await
+    {
+        log::error!("Error announcing a tier upgrade: {:?}", error);
+    }
+}
+
+/// DMs the buyer of a completed order its receipt, with a PDF invoice if the
+/// guild has them turned on.
+async fn send_receipt(ctx: &Context, order: &orders::Order) {
+    let guild_id = order.guild_id.map(GuildId);
+    let (receipts, utc_offset) = match settings::store(ctx).await {
+        Ok(settings) => {
+            let settings = settings.read().await;
+            (
+                settings.guild(guild_id).receipts,
+                settings.utc_offset(guild_id, order.buyer_id),
+            )
+        }
+        Err(_) => (settings::ReceiptSettings::default(), 0),
+    };
+
+    let mut embed = CreateEmbed::default()
+        .title(format!("Receipt: Order #{}", order.id))
+        .description(format!(
+            "Thanks for your purchase! Your order for **{} R$** is complete.",
+            order.robux
+        ))
+        .field(
+            "Robux",
+            format!(
+                "{} R$ {}",
+                order.robux,
+                if order.after_tax { "a/t" } else { "b/t" }
+            ),
+            true,
+        )
+        .field("Delivery", order.method.label(), true)
+        .field(
+            "Total",
+            format!("£{:.2} (${:.2})", order.net_gbp(), order.net_usd()),
+            true,
+        )
+        .color(0x0096FF)
+        .clone();
+    if order.tax_gbp > 0.0 {
+        embed.field("Tax Included", format!("£{:.2}", order.tax_gbp), true);
+    }
+    if order.refunded_gbp() > 0.0 {
+        embed.field("Refunded", format!("£{:.2}", order.refunded_gbp()), true);
+    }
+    if let Some(staff_id) = order.claimed_by {
+        embed.field("Served By", format!("<@{}>", staff_id), true);
+    }
+    let dates = [
+        ("Ordered", Some(order.created_at)),
+        ("Paid", order.entered_at(orders::OrderStatus::Paid)),
+        (
+            "Delivered",
+            order.entered_at(orders::OrderStatus::Delivered),
+        ),
+        (
+            "Completed",
+            order.entered_at(orders::OrderStatus::Completed),
+        ),
+    ]
+    .iter()
+    .filter_map(|(label, at)| at.map(|at| format!("{}: <t:{}:f>", label, at)))
+    .collect::<Vec<_>>()
+    .join("\n");
+    embed.field("Timeline", dates, false);
+    embed.field("Vouch", receipts.vouch_reminder(order.claimed_by), false);
+    embed.footer(|footer| footer.text("How did we do? Rate your order below."));
+
+    let invoice = if receipts.pdf {
+        let shop = match guild_id {
+            Some(guild_id) => guild_id
+                .to_partial_guild(ctx)
+                .await
+                .map(|guild| guild.name)
+                .ok(),
+            None => None,
+        };
+        Some(receipt_pdf(
+            order,
+            shop.as_deref().unwrap_or("Robux Order"),
+            utc_offset,
+        ))
+    } else {
+        None
+    };
+
+    let mut components = CreateComponents::default();
+    components.create_action_row(|row| {
+        for stars in 1..=5 {
+            row.create_button(|button| {
+                button
+                    .custom_id(components::custom_id("feedback", &[&order.id, &stars]))
+                    .label("★".repeat(stars))
+                    .style(ButtonStyle::Secondary)
+            });
+        }
+        row
+    });
+
+    let result = match UserId(order.buyer_id).create_dm_channel(&ctx.http).await {
+        Ok(channel) => outbound::send(&ctx.http, || {
+            channel.id.send_message(&ctx.http, |message| {
+                message
+                    .set_embed(embed.clone())
+                    .set_components(components.clone());
+                if let Some(invoice) = &invoice {
+                    message.add_file(AttachmentType::Bytes {
+                        data: Cow::Owned(invoice.clone()),
+                        filename: format!("receipt-{}.pdf", order.id),
+                    });
+                }
+                message
+            })
+        })
+        .await
+        .map(|_| ()),
+        Err(error) => Err(error),
+    };
+    if let Err(error) = result {
+        log::error!("Error sending receipt for order #{}: {:?}", order.id, error);
+    }
+}
+
+/// The completed order and star rating a feedback button or form is for,
+/// from its custom ID `feedback:<order id>:<stars>`, as long as `user_id` is
+/// its buyer.
+async fn feedback_target(
+    ctx: &Context,
+    custom_id: &components::ComponentId,
+    user_id: UserId,
+) -> Result<(orders::Order, u8), String> {
+    let id: u64 = custom_id.arg(0)?;
+    let stars: u8 = Some(custom_id.arg(1)?)
+        .filter(|stars| (1..=5).contains(stars))
+        .ok_or("Invalid feedback button")?;
+    let order = orders::store(ctx)
+        .await?
+        .get(id)
+        .await
+        .filter(|order| order.buyer_id == user_id.0)
+        .ok_or_else(|| format!("Order #{} doesn't exist", id))?;
+    if order.status != orders::OrderStatus::Completed {
+        return Err(format!("Order #{} isn't completed", id));
+    }
+    Ok((order, stars))
+}
+
+/// Handles a star button on a receipt by asking for an optional comment.
+async fn handle_feedback_rating(
+    ctx: &Context,
+    component: &MessageComponentInteraction,
+    custom_id: &components::ComponentId,
+) -> Result<(), String> {
+    let (order, stars) = feedback_target(ctx, custom_id, component.user.id).await?;
+
+    outbound::send(&ctx.http, || {
+        component.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::Modal)
+                .interaction_response_data(|modal| {
+                    modal
+                        .custom_id(components::custom_id("feedback", &[&order.id, &stars]))
+                        .title(format!(
+                            "Rate Order #{}: {}",
+                            order.id,
+                            "★".repeat(stars as usize)
+                        ))
+                        .components(|components| {
+                            components.create_action_row(|row| {
+                                row.create_input_text(|input| {
+                                    input
+                                        .custom_id("comment")
+                                        .label("Anything you'd like to add? (optional)")
+                                        .style(InputTextStyle::Paragraph)
+                                        .max_length(FEEDBACK_COMMENT_LENGTH)
+                                        .required(false)
+                                })
+                            })
+                        })
+                })
+        })
+    })
+    .await
+    .map_err(|e| format!("Error opening feedback form: {:?}", e))
+}
+
+/// Stores a buyer's rating and comment from the feedback form.
+async fn handle_feedback_submit(
+    ctx: &Context,
+    modal: &ModalSubmitInteraction,
+    custom_id: &components::ComponentId,
+) -> Result<(), String> {
+    let (order, stars) = feedback_target(ctx, custom_id, modal.user.id).await?;
+    let comment = modal
+        .data
+        .components
+        .iter()
+        .flat_map(|row| &row.components)
+        .find_map(|component| match component {
+            ActionRowComponent::InputText(input) if input.custom_id == "comment" => {
+                Some(input.value.trim().to_string())
+            }
+            _ => None,
+        })
+        .filter(|comment| !comment.is_empty());
+    let feedback = orders::Feedback {
+        stars,
+        comment,
+        given_at: store::now(),
+    };
+    orders::store(ctx)
+        .await?
+        .update(order.id, |order| order.feedback = Some(feedback.clone()))
+        .await?;
+
+    outbound::send(&ctx.http, || {
+        modal.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::ChannelMessageWithSource)
+                .interaction_response_data(|message| {
+                    message.content(format!(
+                        "Thanks for rating order #{} {}! You can change your rating with the buttons above.",
+                        order.id,
+                        "★".repeat(stars as usize)
+                    ))
+                })
+        })
+    })
+    .await
+    .map_err(|e| format!("Error sending response: {:?}", e))
+}
+
+/// A one-page PDF invoice for `order`, headed with the shop's name and dated
+/// in the buyer's timezone.
+fn receipt_pdf(order: &orders::Order, shop: &str, utc_offset: i32) -> Vec<u8> {
+    const LEFT: f32 = 56.0;
+    const RIGHT: f32 = pdf::PAGE_WIDTH - 56.0;
+    const LINE: f32 = 22.0;
+
+    let date = |at: u64| timezone::format(at, utc_offset, "%-d %B %Y");
+    let completed_at = order
+        .entered_at(orders::OrderStatus::Completed)
+        .unwrap_or(order.created_at);
+
+    let mut page = pdf::Page::new();
+    let mut y = pdf::PAGE_HEIGHT - 72.0;
+    page.text(LEFT, y, 22.0, true, shop);
+    page.text_right(RIGHT, y, 22.0, false, "Receipt");
+    y -= LINE * 1.5;
+    page.text(LEFT, y, 11.0, false, &format!("Order #{}", order.id));
+    page.text_right(RIGHT, y, 11.0, false, &date(completed_at));
+    y -= LINE * 0.8;
+    page.text(
+        LEFT,
+        y,
+        11.0,
+        false,
+        &format!(
+            "Billed to {}{}",
+            order.buyer_name,
+            order
+                .buyer_roblox
+                .as_ref()
+                .map(|roblox| format!(" (Roblox: {})", roblox))
+                .unwrap_or_default()
+        ),
+    );
+    y -= LINE;
+    page.rule(LEFT, RIGHT, y);
+
+    let net = order.total_gbp - order.tax_gbp;
+    let mut rows = vec![(
+        format!(
+            "{} R$ {} via {}",
+            order.robux,
+            if order.after_tax { "a/t" } else { "b/t" },
+            order.method.label()
+        ),
+        net,
+    )];
+    if order.tax_gbp > 0.0 {
+        rows.push(("Tax".to_string(), order.tax_gbp));
+    }
+    for refund in &order.refunds {
+        rows.push((format!("Refund on {}", date(refund.at)), -refund.amount_gbp));
+    }
+    for (label, amount) in &rows {
+        y -= LINE;
+        page.text(LEFT, y, 12.0, false, label);
+        page.text_right(
+            RIGHT,
+            y,
+            12.0,
+            false,
+            &format!(
+                "{}£{:.2}",
+                if *amount < 0.0 { "-" } else { "" },
+                amount.abs()
+            ),
+        );
+    }
+    y -= LINE * 0.6;
+    page.rule(LEFT, RIGHT, y);
+    y -= LINE;
+    page.text(LEFT, y, 13.0, true, "Total paid");
+    page.text_right(RIGHT, y, 13.0, true, &format!("£{:.2}", order.net_gbp()));
+    y -= LINE * 0.8;
+    page.text_right(
+        RIGHT,
+        y,
+        10.0,
+        false,
+        &format!(
+            "${:.2} at {:.4} USD per GBP",
+            order.net_usd(),
+            order.gbp_to_usd_rate
+        ),
+    );
+
+    y -= LINE * 2.0;
+    if order.gamepass_price > 0 {
+        page.text(
+            LEFT,
+            y,
+            10.0,
+            false,
+            &format!(
+                "Delivered through a gamepass listed at {} R$, of which Roblox kept {} R$.",
+                order.gamepass_price, order.fee_robux
+            ),
+        );
+        y -= LINE * 0.8;
+    }
+    page.text(
+        LEFT,
+        y,
+        10.0,
+        false,
+        &format!("Ordered {}.", date(order.created_at)),
+    );
+    page.pdf()
+}
+
+/// Applies a payment reported by a provider's webhook. When it names an
+/// order awaiting payment and pays its exact total, the order is marked paid
+/// with the payment recorded; otherwise it is left alone. Either way staff
+/// are told, so nobody has to ask the buyer whether they really sent it.
+async fn settle_payment(
+    ctx: &Context,
+    payment: orders::Payment,
+    order_id: Option<u64>,
+    note: &str,
+) {
+    let orders = match orders::store(ctx).await {
+        Ok(orders) => orders,
+        Err(error) => {
+            log::error!("Error settling payment {}: {}", payment.reference, error);
+            return;
+        }
+    };
+    let order = match order_id {
+        Some(order_id) => orders.get(order_id).await,
+        None => None,
+    };
+    let order = match order {
+        Some(order) => order,
+        None => {
+            let embed = CreateEmbed::default()
+                .title("Unmatched Payment")
+                .description(format!(
+                    "{} arrived without a matching order number. Find the order and record it by hand.",
+                    payment.describe()
+                ))
+                .field(
+                    "Buyer's Note",
+                    if note.is_empty() { "None" } else { note },
+                    false,
+                )
+                .color(0xFFA500)
+                .clone();
+            alert_payment_staff(ctx, None, embed).await;
+            return;
+        }
+    };
+    // Providers resend notifications they think were missed.
+    if order
+        .payment
+        .as_ref()
+        .map_or(false, |paid| paid.reference == payment.reference)
+    {
+        return;
+    }
+
+    let problem = if order.status != orders::OrderStatus::PendingPayment {
+        Some(format!(
+            "the order is already {}",
+            order.status.label().to_lowercase()
+        ))
+    } else {
+        order.payment_mismatch(&payment)
+    };
+    let embed = match problem {
+        Some(problem) => CreateEmbed::default()
+            .title(format!("Payment for Order #{} Needs Checking", order.id))
+            .description(format!(
+                "{} names <@{}>'s order for **{} R$**, but {}. The order was left as it is.",
+                payment.describe(),
+                order.buyer_id,
+                order.robux,
+                problem
+            ))
+            .color(0xFFA500)
+            .clone(),
+        None => {
+            let result = orders
+                .transition(order.id, orders::OrderStatus::Paid, None, |order| {
+                    order.payment = Some(payment.clone())
+                })
+                .await;
+            match result {
+                Ok((paid, from)) => {
+                    on_order_transition(ctx, &paid, from).await;
+                    CreateEmbed::default()
+                        .title(format!("Order #{} Paid", paid.id))
+                        .description(format!(
+                            "{} paid <@{}>'s order for **{} R$**, so it was marked paid automatically.",
+                            payment.describe(),
+                            paid.buyer_id,
+                            paid.robux
+                        ))
+                        .color(0x0096FF)
+                        .clone()
+                }
+                Err(error) => CreateEmbed::default()
+                    .title(format!("Payment for Order #{} Needs Checking", order.id))
+                    .description(format!(
+                        "{} paid <@{}>'s order, but it couldn't be marked paid: {}",
+                        payment.describe(),
+                        order.buyer_id,
+                        error
+                    ))
+                    .color(0xFFA500)
+                    .clone(),
+            }
+        }
+    };
+    alert_payment_staff(ctx, Some(&order), embed).await;
+}
+
+/// Reports a payment to the order's guild payments channel and to the staff
+/// member who claimed it, or to the bot owner when neither is known.
+async fn alert_payment_staff(ctx: &Context, order: Option<&orders::Order>, embed: CreateEmbed) {
+    let mut recipients = Vec::new();
+    if let Some(order) = order {
+        let channel_id = match settings::store(ctx).await {
+            Ok(settings) => {
+                settings
+                    .read()
+                    .await
+                    .guild(order.guild_id.map(GuildId))
+                    .payments_channel_id
+            }
+            Err(_) => None,
+        };
+        recipients.extend(channel_id.map(ChannelId));
+        if let Some(staff_id) = order.claimed_by {
+            match UserId(staff_id).create_dm_channel(&ctx.http).await {
+                Ok(channel) => recipients.push(channel.id),
+                Err(error) => log::error!("Error opening DM with {}: {:?}", staff_id, error),
+            }
+        }
+    }
+    if recipients.is_empty() {
+        if let Some(owner_id) = owner_id() {
+            match UserId(owner_id).create_dm_channel(&ctx.http).await {
+                Ok(channel) => recipients.push(channel.id),
+                Err(error) => log::error!("Error opening DM with the owner: {:?}", error),
+            }
+        }
+    }
+
+    for channel_id in recipients {
+        if let Err(error) = outbound::send(&ctx.http, || {
+            channel_id.send_message(&ctx.http, |message| message.set_embed(embed.clone()))
+        })
+        .await
+        {
+            log::error!("Error reporting a payment: {:?}", error);
+        }
+    }
+}
+
+/// The "Upload payment proof" button for an order awaiting payment, or no
+/// components once it no longer needs proof.
+fn payment_proof_button(order: &orders::Order) -> CreateComponents {
+    let mut components = CreateComponents::default();
+    if order.status == orders::OrderStatus::PendingPayment {
+        components.create_action_row(|row| {
+            row.create_button(|button| {
+                button
+                    .custom_id(components::custom_id("proof", &[&order.id]))
+                    .label("Upload payment proof")
+                    .style(ButtonStyle::Primary)
+            })
+        });
+    }
+    components
+}
+
+/// Handles the "Upload payment proof" button, whose custom ID is
+/// `proof:<order id>`: the buyer is asked to send the screenshot in DMs,
+/// where `handle_payment_proof` picks it up.
+async fn handle_proof_request(
+    ctx: &Context,
+    component: &MessageComponentInteraction,
+    custom_id: &components::ComponentId,
+) -> Result<(), String> {
+    let id: u64 = custom_id.arg(0)?;
+    let order = orders::store(ctx)
+        .await?
+        .get(id)
+        .await
+        .ok_or_else(|| format!("Order #{} doesn't exist", id))?;
+    if order.buyer_id != component.user.id.0 {
+        return Err("Only the buyer can send payment proof for this order".to_string());
+    }
+    if order.status != orders::OrderStatus::PendingPayment {
+        return Err(format!(
+            "Order #{} is {}, so it doesn't need payment proof",
+            id,
+            order.status.label().to_lowercase()
+        ));
+    }
+
+    proofs::requests(ctx)
+        .await?
+        .expect(order.buyer_id, order.id, store::now())
+        .await;
+    let instructions = format!(
+        "Send the screenshot of your payment for order #{} ({} R$, £{:.2}) here as an image within {} minutes.",
+        order.id,
+        order.robux,
+        order.total_gbp,
+        proofs::UPLOAD_WINDOW_SECS / 60
+    );
+    let reply = if component.guild_id.is_some() {
+        let channel = component
+            .user
+            .create_dm_channel(&ctx.http)
+            .await
+            .map_err(|e| format!("Error opening DM: {:?}", e))?;
+        outbound::send(&ctx.http, || channel.id.say(&ctx.http, &instructions))
+            .await
+            .map_err(|_| {
+                "Couldn't DM you. Allow DMs from this server and press the button again."
+            })?;
+        "Check your DMs: send the screenshot there.".to_string()
+    } else {
+        instructions
+    };
+
+    outbound::send(&ctx.http, || {
+        component.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::ChannelMessageWithSource)
+                .interaction_response_data(|message| message.content(&reply).ephemeral(true))
+        })
+    })
+    .await
+    .map_err(|e| format!("Error sending response: {:?}", e))
+}
+
+/// Stores a screenshot a buyer sent in DMs after pressing "Upload payment
+/// proof" against their order, copying it to object storage when configured,
+/// and tells the staff member handling the order. DMs from buyers who haven't
+/// pressed the button are ignored.
+async fn handle_payment_proof(ctx: &Context, msg: &Message) -> Result<(), String> {
+    let requests = proofs::requests(ctx).await?;
+    let request = match requests.get(msg.author.id.0, store::now()).await {
+        Some(request) => request,
+        None => return Ok(()),
+    };
+    let attachment = msg
+        .attachments
+        .iter()
+        .find(|attachment| proofs::is_image(attachment))
+        .ok_or("Attach your payment screenshot as an image, e.g. a PNG or JPG.")?;
+
+    let stored_url = match proofs::store_copy(request.order_id, attachment).await {
+        Ok(stored_url) => stored_url,
+        Err(error) => {
+            log::error!(
+                "Error storing payment proof for order #{}: {}",
+                request.order_id,
+                error
+            );
+            None
+        }
+    };
+    let proof = orders::PaymentProof {
+        filename: attachment.filename.clone(),
+        url: attachment.url.clone(),
+        stored_url,
+        uploaded_at: store::now(),
+    };
+    let order = orders::store(ctx)
+        .await?
+        .update(request.order_id, |order| {
+            order.payment_proofs.push(proof.clone())
+        })
+        .await?;
+    requests.remove(msg.author.id.0).await;
+
+    if let Some(staff_id) = order.claimed_by {
+        let embed = CreateEmbed::default()
+            .title(format!("Payment Proof for Order #{}", order.id))
+            .description(format!(
+                "<@{}> sent proof of paying **£{:.2}** for **{} R$**. Check it against your records, \
+                 then mark the order paid with `/order status id:{} status:paid`.",
+                order.buyer_id, order.total_gbp, order.robux, order.id
+            ))
+            .field("Screenshots", payment_proof_list(&order), false)
+            .image(&proof.url)
+            .color(0x0096FF)
+            .clone();
+        let result = match UserId(staff_id).create_dm_channel(&ctx.http).await {
+            Ok(channel) => outbound::send(&ctx.http, || {
+                channel
+                    .id
+                    .send_message(&ctx.http, |message| message.set_embed(embed.clone()))
+            })
+            .await
+            .map(|_| ()),
+            Err(error) => Err(error),
+        };
+        if let Err(error) = result {
+            log::error!(
+                "Error sending payment proof for order #{} to {}: {:?}",
+                order.id,
+                staff_id,
+                error
+            );
+        }
+    }
+
+    let reply = format!(
+        "Thanks! Your payment proof for order #{} has been sent to staff for review.",
+        order.id
+    );
+    outbound::send(&ctx.http, || msg.channel_id.say(&ctx.http, &reply))
+        .await
+        .map(|_| ())
+        .map_err(|e| format!("Error sending reply: {:?}", e))
+}
+
+/// Links to an order's payment screenshots, with their stored copies.
+fn payment_proof_list(order: &orders::Order) -> String {
+    order
+        .payment_proofs
+        .iter()
+        .map(|proof| {
+            format!(
+                "[{}]({}) <t:{}:R>{}",
+                proof.filename,
+                proof.url,
+                proof.uploaded_at,
+                proof
+                    .stored_url
+                    .as_ref()
+                    .map(|stored_url| format!(" ([stored copy]({}))", stored_url))
+                    .unwrap_or_default()
+            )
+        })
+        .collect::<Vec<_>>()
+        .join("\n")
+}
+
+/// Sends `embed` to `order`'s buyer, logging rather than failing if their DMs
+/// are closed.
+async fn dm_buyer(ctx: &Context, order: &orders::Order, embed: CreateEmbed) {
+    dm_buyer_with_components(ctx, order, embed, CreateComponents::default()).await;
+}
+
+async fn dm_buyer_with_components(
+    ctx: &Context,
+    order: &orders::Order,
+    embed: CreateEmbed,
+    components: CreateComponents,
+) {
+    let result = match UserId(order.buyer_id).create_dm_channel(&ctx.http).await {
+        Ok(channel) => outbound::send(&ctx.http, || {
+            channel.id.send_message(&ctx.http, |message| {
+                message
+                    .set_embed(embed.clone())
+                    .set_components(components.clone())
+            })
+        })
+        .await
+        .map(|_| ()),
+        Err(error) => Err(error),
+    };
+    if let Err(error) = result {
+        log::error!("Error messaging buyer of order #{}: {:?}", order.id, error);
+    }
+}
+
+/// Records a full or partial refund of an order and sends the buyer a receipt.
+async fn refund_order(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    guild_id: GuildId,
+    subcommand: &application_command::CommandDataOption,
+) -> Result<(), String> {
+    let option = |name: &str| {
+        subcommand
+            .options
+            .iter()
+            .find(|option| option.name == name)
+            .and_then(|option| option.value.as_ref())
+    };
+    let id = option("id")
+        .and_then(|id| id.as_u64())
+        .ok_or("Missing order ID")?;
+    let amount = match option("amount").and_then(|amount| amount.as_str()) {
+        Some(amount) => Some(amount::parse_money(amount)?),
+        None => None,
+    };
+    let reason = option("reason")
+        .and_then(|reason| reason.as_str())
+        .map(|reason| reason.trim().to_string());
+
+    let orders = orders::store(ctx).await?;
+    orders
+        .get(id)
+        .await
+        .filter(|order| order.guild_id == Some(guild_id.0))
+        .ok_or_else(|| format!("Order #{} doesn't exist", id))?;
+    let (order, from) = orders.refund(id, amount, reason, command.user.id.0).await?;
+    match from {
+        Some(from) => on_order_transition(ctx, &order, from).await,
+        None => dm_buyer(ctx, &order, refund_receipt(&order)).await,
+    }
+
+    send_embed(ctx, command, refund_receipt(&order), false).await
+}
+
+/// A receipt for `order`'s latest refund.
+fn refund_receipt(order: &orders::Order) -> CreateEmbed {
+    let refund = order.refunds.last();
+    let mut embed = CreateEmbed::default()
+        .title(format!(
+            "Order #{} {}",
+            order.id,
+            if order.status == orders::OrderStatus::Refunded {
+                "Refunded"
+            } else {
+                "Partially Refunded"
+            }
+        ))
+        .description(format!(
+            "**£{:.2}** of <@{}>'s order for {} R$ has been refunded.",
+            refund.map_or(0.0, |refund| refund.amount_gbp),
+            order.buyer_id,
+            order.robux
+        ))
+        .field("Order Total", format!("£{:.2}", order.total_gbp), true)
+        .field(
+            "Refunded So Far",
+            format!("£{:.2}", order.refunded_gbp()),
+            true,
+        )
+        .field("Remaining", format!("£{:.2}", order.net_gbp()), true)
+        .color(0x0096FF)
+        .clone();
+    if let Some(refund) = refund {
+        if let Some(reason) = &refund.reason {
+            embed.field("Reason", reason, false);
+        }
+        embed.field("Refunded On", format!("<t:{}:f>", refund.at), false);
+    }
+    embed
+}
+
+/// Looks the order's gamepass up on Roblox and compares its price with
+/// `expected`, returning the listed price and a line for staff that flags any
+/// mismatch before payment is taken.
+async fn check_gamepass(gamepass_id: Option<u64>, expected: u64) -> (Option<u64>, String) {
+    let id = match gamepass_id {
+        Some(id) => id,
+        None => {
+            return (
+                None,
+                format!(
+                    "No gamepass yet. The buyer needs to list one at **{} R$**.",
+                    expected
+                ),
+            )
+        }
+    };
+
+    match roblox::gamepass(id).await {
+        Ok(gamepass) => {
+            let link = format!(
+                "[{}]({}) by {}",
+                gamepass.name,
+                roblox::gamepass_url(id),
+                gamepass.creator.name
+            );
+            match gamepass.price_in_robux.filter(|_| gamepass.is_for_sale) {
+                Some(price) if price == expected => {
+                    (Some(price), format!("✅ {} is listed at {} R$.", link, price))
+                }
+                Some(price) => (
+                    Some(price),
+                    format!(
+                        "⚠️ {} is listed at {} R$ but the order needs **{} R$**. \
+                         Have the buyer change it before taking payment.",
+                        link, price, expected
+                    ),
+                ),
+                None => (
+                    None,
+                    format!(
+                        "⚠️ {} is off sale. It must be on sale at **{} R$**.",
+                        link, expected
+                    ),
+                ),
+            }
+        }
+        Err(error) => (
+            None,
+            format!(
+                "⚠️ Couldn't check the gamepass ({}). Make sure it is listed at **{} R$** before taking payment.",
+                error, expected
+            ),
+        ),
+    }
+}
+
+/// The Roblox username of `discord_id`: their `/link`ed account, or failing that
+/// the one the guild's verification bot has for them.
+async fn roblox_username(
+    ctx: &Context,
+    guild_id: Option<GuildId>,
+    discord_id: u64,
+) -> Option<String> {
+    match links::store(ctx).await {
+        Ok(links) => {
+            if let Some(link) = links.get(discord_id).await {
+                return Some(link.roblox_username);
+            }
+        }
+        Err(error) => log::error!("Error reading account links: {}", error),
+    }
+
+    let guild_id = guild_id?;
+    let identity = settings::store(ctx)
+        .await
+        .ok()?
+        .read()
+        .await
+        .guild(Some(guild_id))
+        .identity?;
+    let result = match identity
+        .provider
+        .roblox_id(&identity.api_key, guild_id.0, discord_id)
+        .await
+    {
+        Ok(Some(roblox_id)) => roblox::user_by_id(roblox_id).await.map(Some),
+        Ok(None) => Ok(None),
+        Err(error) => Err(error),
+    };
+    result
+        .unwrap_or_else(|error| {
+            log::error!(
+                "Error resolving Roblox account of {}: {}",
+                discord_id,
+                error
+            );
+            None
+        })
+        .map(|user| user.name)
+}
+
+/// Starts linking the invoker's Discord account to a Roblox account: they put a
+/// code in their Roblox profile, then press Verify.
+async fn handle_link_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let username = command
+        .data
+        .options
+        .first()
+        .and_then(|option| option.value.as_ref())
+        .and_then(|username| username.as_str())
+        .ok_or("Missing username")?;
+
+    let account = roblox::user(username.trim()).await?;
+    let pending = links::store(ctx)
+        .await?
+        .start(command.user.id.0, &account)
+        .await?;
+
+    let embed = CreateEmbed::default()
+        .title("Link Your Roblox Account")
+        .description(format!(
+            "Add this code anywhere in the About section of [{}'s profile]({}), then press **Verify**. \
+             You can remove it again once you're linked.\n\n```{}```",
+            account.name,
+            roblox::profile_url(account.id),
+            pending.code
+        ))
+        .color(0x0096FF)
+        .clone();
+    let mut components = CreateComponents::default();
+    components.create_action_row(|row| {
+        row.create_button(|button| {
+            button
+                .custom_id(components::custom_id("link", &[&command.user.id]))
+                .label("Verify")
+                .style(ButtonStyle::Primary)
+        })
+    });
+
+    send_page(ctx, command, embed, components, true).await
+}
+
+/// Sets or shows the invoking user's timezone.
+async fn handle_timezone_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let offset = match command
+        .data
+        .options
+        .first()
+        .and_then(|option| option.value.as_ref())
+        .and_then(|offset| offset.as_str())
+    {
+        Some(offset) if offset.trim().eq_ignore_ascii_case("default") => Some(None),
+        Some(offset) => Some(Some(timezone::parse(offset)?)),
+        None => None,
+    };
+    let user_id = command.user.id.0;
+    let settings = settings::store(ctx).await?;
+    if let Some(offset) = offset {
+        settings
+            .update(|settings| {
+                settings
+                    .users
+                    .entry(user_id)
+                    .or_default()
+                    .utc_offset_minutes = offset
+            })
+            .await?;
+    }
+    let (own, offset) = {
+        let settings = settings.read().await;
+        (
+            settings
+                .users
+                .get(&user_id)
+                .and_then(|user| user.utc_offset_minutes)
+                .is_some(),
+            settings.utc_offset(command.guild_id, user_id),
+        )
+    };
+
+    let embed = CreateEmbed::default()
+        .title("Your Timezone")
+        .description(format!(
+            "Footers and receipts show times for you in **{}**{}. Times in messages always show in your device's local time.",
+            timezone::label(offset),
+            if own { "" } else { ", the server's default" }
+        ))
+        .color(0x0096FF)
+        .clone();
+    send_embed(ctx, command, embed, true).await
+}
+
+/// Handles the Verify button from `/link`, whose custom ID is `link:<user id>`,
+/// by looking for the code in the Roblox profile.
+async fn handle_link_verify(
+    ctx: &Context,
+    component: &MessageComponentInteraction,
+    custom_id: &components::ComponentId,
+) -> Result<(), String> {
+    let user_id: u64 = custom_id.arg(0)?;
+    if user_id != component.user.id.0 {
+        return Err("Run /link to link your own account".to_string());
+    }
+
+    let links = links::store(ctx).await?;
+    let pending = links.pending(user_id).await.ok_or("Run /link first")?;
+    let description = roblox::description(pending.roblox_id).await?;
+    if !description.contains(&pending.code) {
+        return Err(format!(
+            "The code isn't in {}'s About section yet. Roblox can take a minute to update profiles, so try again shortly.",
+            pending.roblox_username
+        ));
+    }
+    let link = links.confirm(user_id).await?;
+
+    let embed = CreateEmbed::default()
+        .title("Roblox Account Linked")
+        .description(format!(
+            "<@{}> is now linked to [{}]({}). Orders will use this account automatically.",
+            user_id,
+            link.roblox_username,
+            roblox::profile_url(link.roblox_id)
+        ))
+        .color(0x0096FF)
+        .clone();
+
+    outbound::send(&ctx.http, || {
+        component.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::UpdateMessage)
+                .interaction_response_data(|message| {
+                    message
+                        .set_embed(embed.clone())
+                        .set_components(CreateComponents::default())
+                })
+        })
+    })
+    .await
+    .map_err(|e| format!("Error updating link: {:?}", e))
+}
+
+async fn handle_seller_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let subcommand = command.data.options.first().ok_or("Missing subcommand")?;
+    let option = |name: &str| {
+        subcommand
+            .options
+            .iter()
+            .find(|option| option.name == name)
+            .and_then(|option| option.value.as_ref())
+    };
+    let name = option("name")
+        .and_then(|name| name.as_str())
+        .map(|name| name.trim().to_string());
+    let settings = settings::store(ctx).await?;
+    let stock = stock::store(ctx).await?;
+
+    let description = match subcommand.name.as_str() {
+        "set" => {
+            let name = name.filter(|name| !name.is_empty()).ok_or("Missing name")?;
+            let gbp_per_1k = option("rate")
+                .and_then(|rate| rate.as_f64())
+                .ok_or("Missing rate")?;
+            let markup_percent = option("markup").and_then(|markup| markup.as_f64());
+            let roblox_username = option("roblox")
+                .and_then(|roblox| roblox.as_str())
+                .map(|roblox| roblox.trim().to_string());
+
+            if gbp_per_1k <= 0.0 {
+                return Err("The rate must be more than £0".to_string());
+            }
+            if let Some(markup) = markup_percent {
+                if !(0.0..=MAX_MARKUP_PERCENT).contains(&markup) {
+                    return Err(format!(
+                        "The markup must be between 0% and {}%",
+                        MAX_MARKUP_PERCENT
+                    ));
+                }
+            }
+
+            let profile = settings::SellerProfile {
+                name: name.clone(),
+                gbp_per_1k,
+                markup_percent,
+                roblox_username,
+            };
+            let new_profile = profile.describe();
+            let old_profile = settings
+                .update(|settings| {
+                    let sellers = &mut settings.guilds.entry(guild_id.0).or_default().sellers;
+                    let old_profile = take_seller(sellers, &name);
+                    sellers.push(profile);
+                    old_profile
+                })
+                .await?;
+            record_change(
+                ctx,
+                command,
+                guild_id,
+                &format!("Seller {}", name),
+                old_profile,
+                new_profile.clone(),
+            )
+            .await;
+            format!("**{}** now sells at {}.", name, new_profile)
+        }
+        "remove" => {
+            let name = name.ok_or("Missing name")?;
+            let old_profile = settings
+                .update(|settings| {
+                    take_seller(
+                        &mut settings.guilds.entry(guild_id.0).or_default().sellers,
+                        &name,
+                    )
+                })
+                .await?;
+            if old_profile == "None" {
+                return Err(format!("Unknown seller '{}'", name));
+            }
+            record_change(
+                ctx,
+                command,
+                guild_id,
+                &format!("Seller {}", name),
+                old_profile,
+                "None".to_string(),
+            )
+            .await;
+            format!("Removed seller **{}**. Their stock ledger is kept.", name)
+        }
+        "stock" => {
+            let name = name.ok_or("Missing name")?;
+            let seller = settings
+                .read()
+                .await
+                .guild(Some(guild_id))
+                .seller(&name)
+                .cloned()
+                .ok_or_else(|| format!("Unknown seller '{}'", name))?;
+            let robux = option("amount")
+                .and_then(|amount| amount.as_i64())
+                .filter(|&amount| amount != 0)
+                .ok_or("The amount must be a non-zero number of Robux")?;
+            let note = option("note")
+                .and_then(|note| note.as_str())
+                .unwrap_or_default()
+                .to_string();
+
+            let balance = stock
+                .record(stock::StockEntry {
+                    guild_id: guild_id.0,
+                    seller: seller.name.clone(),
+                    robux,
+                    note,
+                    actor_id: command.user.id.0,
+                    created_at: 0,
+                })
+                .await?;
+            format!(
+                "{} {} R$ for **{}**. They now have {} R$ in stock.",
+                if robux > 0 { "Added" } else { "Removed" },
+                robux.abs(),
+                seller.name,
+                balance
+            )
+        }
+        _ => {
+            let sellers = settings.read().await.guild(Some(guild_id)).sellers;
+            if sellers.is_empty() {
+                "No sellers are configured. Prices use the server's default rate card.".to_string()
+            } else {
+                let mut lines = Vec::new();
+                for seller in &sellers {
+                    lines.push(format!(
+                        "**{}**: {} • {} R$ in stock",
+                        seller.name,
+                        seller.describe(),
+                        stock.balance(guild_id.0, &seller.name).await
+                    ));
+                }
+                lines.join("\n")
+            }
+        }
+    };
+
+    let embed = CreateEmbed::default()
+        .title("Sellers")
+        .description(description)
+        .color(0x0096FF)
+        .clone();
+
+    send_embed_response(ctx, command, embed).await
+}
+
+/// Removes the seller called `name`, describing their profile for the audit log.
+fn take_seller(sellers: &mut Vec<settings::SellerProfile>, name: &str) -> String {
+    let matches = |seller: &settings::SellerProfile| seller.name.eq_ignore_ascii_case(name);
+    let old_profile = sellers
+        .iter()
+        .find(|seller| matches(seller))
+        .map_or_else(|| "None".to_string(), |seller| seller.describe());
+    sellers.retain(|seller| !matches(seller));
+    old_profile
+}
+
+async fn handle_payment_method_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let subcommand = command.data.options.first().ok_or("Missing subcommand")?;
+    let option = |name: &str| {
+        subcommand
+            .options
+            .iter()
+            .find(|option| option.name == name)
+            .and_then(|option| option.value.as_ref())
+    };
+    let name = option("name")
+        .and_then(|name| name.as_str())
+        .map(|name| name.trim().to_string());
+    let settings = settings::store(ctx).await?;
+
+    let description = match subcommand.name.as_str() {
+        "set" => {
+            let name = name.filter(|name| !name.is_empty()).ok_or("Missing name")?;
+            let surcharge_percent = option("surcharge")
+                .and_then(|surcharge| surcharge.as_f64())
+                .unwrap_or(0.0);
+            let fixed_fee_gbp = option("fee").and_then(|fee| fee.as_f64()).unwrap_or(0.0);
+            let currencies = match option("currencies").and_then(|currencies| currencies.as_str()) {
+                Some(list) => parse_display_currencies(ctx, list).await?,
+                None => Vec::new(),
+            };
+            let enabled = option("enabled")
+                .and_then(|enabled| enabled.as_bool())
+                .unwrap_or(true);
+
+            if !(0.0..=MAX_PAYMENT_SURCHARGE_PERCENT).contains(&surcharge_percent) {
+                return Err(format!(
+                    "The surcharge must be between 0% and {}%",
+                    MAX_PAYMENT_SURCHARGE_PERCENT
+                ));
+            }
+            if !(0.0..=MAX_PAYMENT_FEE_GBP).contains(&fixed_fee_gbp) {
+                return Err(format!(
+                    "The fee must be between £0 and £{}",
+                    MAX_PAYMENT_FEE_GBP
+                ));
+            }
+
+            let method = settings::PaymentMethod {
+                name: name.clone(),
+                surcharge_percent,
+                fixed_fee_gbp,
+                currencies,
+                enabled,
+            };
+            let new_method = method.describe();
+            let old_method = settings
+                .update(|settings| {
+                    let methods = &mut settings
+                        .guilds
+                        .entry(guild_id.0)
+                        .or_default()
+                        .payment_methods;
+                    let old_method = take_payment_method(methods, &name);
+                    methods.push(method);
+                    old_method
+                })
+                .await?;
+            record_change(
+                ctx,
+                command,
+                guild_id,
+                &format!("Payment Method {}", name),
+                old_method,
+                new_method.clone(),
+            )
+            .await;
+            format!("**{}**: {}.", name, new_method)
+        }
+        "remove" => {
+            let name = name.ok_or("Missing name")?;
+            let old_method = settings
+                .update(|settings| {
+                    take_payment_method(
+                        &mut settings
+                            .guilds
+                            .entry(guild_id.0)
+                            .or_default()
+                            .payment_methods,
+                        &name,
+                    )
+                })
+                .await?;
+            if old_method == "None" {
+                return Err(format!("Unknown payment method '{}'", name));
+            }
+            record_change(
+                ctx,
+                command,
+                guild_id,
+                &format!("Payment Method {}", name),
+                old_method,
+                "None".to_string(),
+            )
+            .await;
+            format!("Removed payment method **{}**.", name)
+        }
+        _ => {
+            let methods = settings.read().await.guild(Some(guild_id)).payment_methods;
+            if methods.is_empty() {
+                "No payment methods are configured. Prices don't include any payment fees."
+                    .to_string()
+            } else {
+                methods
+                    .iter()
+                    .map(|method| format!("**{}**: {}", method.name, method.describe()))
+                    .collect::<Vec<_>>()
+                    .join("\n")
+            }
+        }
+    };
+
+    let embed = CreateEmbed::default()
+        .title("Payment Methods")
+        .description(description)
+        .color(0x0096FF)
+        .clone();
+
+    send_embed_response(ctx, command, embed).await
+}
+
+/// Removes the payment method called `name`, describing it for the audit log.
+fn take_payment_method(methods: &mut Vec<settings::PaymentMethod>, name: &str) -> String {
+    let matches = |method: &settings::PaymentMethod| method.name.eq_ignore_ascii_case(name);
+    let old_method = methods
+        .iter()
+        .find(|method| matches(method))
+        .map_or_else(|| "None".to_string(), |method| method.describe());
+    methods.retain(|method| !matches(method));
+    old_method
+}
+
+async fn handle_ratecard_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let subcommand = command.data.options.first().ok_or("Missing subcommand")?;
+    match subcommand.name.as_str() {
+        "view" => {
+            let seller = subcommand
+                .options
+                .iter()
+                .find(|option| option.name == "seller")
+                .and_then(|option| option.value.as_ref())
+                .and_then(|value| value.as_str());
+            return send_rate_card(ctx, command, seller).await;
+        }
+        "image" => {
+            let seller = subcommand
+                .options
+                .iter()
+                .find(|option| option.name == "seller")
+                .and_then(|option| option.value.as_ref())
+                .and_then(|value| value.as_str());
+            return send_rate_card_image(ctx, command, guild_id, seller).await;
+        }
+        "history" => {}
+        "export" => {
+            let format = subcommand
+                .options
+                .iter()
+                .find(|option| option.name == "format")
+                .and_then(|option| option.value.as_ref())
+                .and_then(|value| value.as_str())
+                .unwrap_or("json");
+            return send_rate_card_export(ctx, command, guild_id, format).await;
+        }
+        "import" => return import_rate_card(ctx, command, guild_id, subcommand).await,
+        other => return Err(format!("Unknown rate card view: {}", other)),
+    }
+
+    let changes = audit::store(ctx).await?.for_guild(guild_id.0).await;
+    let (embed, components) = audit_page(&changes, command.user.id.0, 0);
+
+    send_page(ctx, command, embed, components, true).await
+}
+
+/// Sends the guild's pricing configuration as a JSON or CSV file, for backups
+/// or to set up another server the same way. Manage Server only.
+async fn send_rate_card_export(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    guild_id: GuildId,
+    format: &str,
+) -> Result<(), String> {
+    if !can_manage_guild(command) {
+        return Err("Exporting the rate card requires the Manage Server permission".to_string());
+    }
+
+    let guild_settings = guild_settings(ctx, command).await?;
+    let export = ratecard::PricingExport::new(&guild_settings);
+    let data = match format {
+        "json" => export.to_json()?,
+        "csv" => export.to_csv(),
+        other => return Err(format!("Unknown export format: {}", other)),
+    };
+
+    outbound::send(&ctx.http, || {
+        command.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::ChannelMessageWithSource)
+                .interaction_response_data(|message| {
+                    message
+                        .content(format!(
+                            "Exported {} seller(s) and {} role tier(s).",
+                            export.sellers.len(),
+                            export.role_pricing.len()
+                        ))
+                        .add_file(AttachmentType::Bytes {
+                            data: Cow::Owned(data.as_bytes().to_vec()),
+                            filename: format!("ratecard-{}.{}", guild_id.0, format),
+                        })
+                        .ephemeral(true)
+                })
+        })
+    })
+    .await
+    .map_err(|e| format!("Error sending response: {:?}", e))
+}
+
+fn can_manage_guild(command: &ApplicationCommandInteraction) -> bool {
+    command
+        .member
+        .as_ref()
+        .and_then(|member| member.permissions)
+        .map_or(false, |permissions| {
+            permissions.contains(Permissions::MANAGE_GUILD)
+        })
+}
+
+/// Previews, or with `apply` saves, the pricing in a file made by
+/// `/ratecard export`, replacing this server's rates, markup, FX margin, tax,
+/// display currencies, role tiers, sellers and payment methods. Tiers for
+/// roles this server doesn't have are left out, so a setup can be cloned from
+/// another server.
+async fn import_rate_card(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    guild_id: GuildId,
+    subcommand: &application_command::CommandDataOption,
+) -> Result<(), String> {
+    if !can_manage_guild(command) {
+        return Err("Importing a rate card requires the Manage Server permission".to_string());
+    }
+    let option = |name: &str| subcommand.options.iter().find(|option| option.name == name);
+    let attachment = match option("file").and_then(|file| file.resolved.as_ref()) {
+        Some(application_command::CommandDataOptionValue::Attachment(attachment)) => attachment,
+        _ => return Err("Attach a file made by /ratecard export".to_string()),
+    };
+    let apply = option("apply")
+        .and_then(|apply| apply.value.as_ref())
+        .and_then(|apply| apply.as_bool())
+        .unwrap_or(false);
+    if attachment.size > ratecard::MAX_IMPORT_BYTES {
+        return Err(format!(
+            "{} is too large to be a rate card export",
+            attachment.filename
+        ));
+    }
+
+    let data = attachment
+        .download()
+        .await
+        .map_err(|e| format!("Error downloading {}: {:?}", attachment.filename, e))?;
+    let mut export = ratecard::PricingExport::parse(&data)?;
+    let rates = rates::service(ctx).await?;
+    let priced = rates.get_many("GBP", &export.display_currencies).await;
+    if let Some(missing) = export
+        .display_currencies
+        .iter()
+        .find(|code| !priced.iter().any(|rate| &rate.quote == *code))
+    {
+        return Err(format!("No exchange rate is available for {}", missing));
+    }
+    for currency in export
+        .role_pricing
+        .iter()
+        .filter_map(|tier| tier.currency.as_ref())
+    {
+        rates.get("GBP", currency).await?;
+    }
+
+    let mut notes = Vec::new();
+    if !export.role_pricing.is_empty() {
+        let roles = guild_id
+            .roles(&ctx.http)
+            .await
+            .map_err(|e| format!("Error fetching roles: {:?}", e))?;
+        let count = export.role_pricing.len();
+        export
+            .role_pricing
+            .retain(|tier| roles.contains_key(&RoleId(tier.role_id)));
+        if export.role_pricing.len() < count {
+            notes.push(format!(
+                "{} role tier(s) were left out because their roles aren't in this server.",
+                count - export.role_pricing.len()
+            ));
+        }
+    }
+
+    let settings = settings::store(ctx).await?;
+    let changes = export.changes(&settings.read().await.guild(Some(guild_id)));
+    let mut lines: Vec<String> = changes
+        .iter()
+        .map(|(setting, old_value, new_value)| {
+            format!("**{}**: {} → {}", setting, old_value, new_value)
+        })
+        .collect();
+    if lines.len() > IMPORT_PREVIEW_LINES {
+        let hidden = lines.len() - IMPORT_PREVIEW_LINES;
+        lines.truncate(IMPORT_PREVIEW_LINES);
+        lines.push(format!("…and {} more change(s).", hidden));
+    }
+    if lines.is_empty() {
+        lines.push("Nothing would change.".to_string());
+    }
+    lines.extend(notes);
+
+    if !apply || changes.is_empty() {
+        let mut embed = CreateEmbed::default()
+            .title("Rate Card Import Preview")
+            .description(lines.join("\n"))
+            .color(0xFFA500)
+            .clone();
+        if !changes.is_empty() {
+            embed.footer(|footer| footer.text("Run again with apply:True to save these changes"));
+        }
+        return send_embed(ctx, command, embed, true).await;
+    }
+
+    let (old_card, new_card) = settings
+        .update(|settings| {
+            let guild = settings.guilds.entry(guild_id.0).or_default();
+            let old_card = guild.rate_card();
+            export.apply(guild);
+            (old_card, guild.rate_card())
+        })
+        .await?;
+    for (setting, old_value, new_value) in changes {
+        record_change(ctx, command, guild_id, &setting, old_value, new_value).await;
+    }
+    if old_card.gbp_per_robux != new_card.gbp_per_robux || old_card.markup != new_card.markup {
+        announce_rate_change(ctx, command, guild_id, "pricing", old_card, new_card).await;
+    }
+
+    let embed = CreateEmbed::default()
+        .title("Rate Card Imported")
+        .description(lines.join("\n"))
+        .color(0x0096FF)
+        .clone();
+    send_embed(ctx, command, embed, true).await
+}
+
+/// Builds the guild's pricing table, or `seller`'s, priced in GBP, USD and every
+/// currency a role is priced in. Also returns the GBP to USD rate for its notes.
+async fn rate_card_table(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    title: &str,
+    seller: Option<&str>,
+) -> Result<(ratecard::Table, rates::Rate), String> {
+    let guild_settings = guild_settings(ctx, command).await?;
+    let (title, card) = match seller {
+        Some(name) => {
+            let seller = guild_settings
+                .seller(name)
+                .ok_or_else(|| format!("Unknown seller '{}'", name))?;
+            (
+                format!("{}: {}", title, seller.name),
+                guild_settings.seller_rate_card(seller),
+            )
+        }
+        None => (title.to_string(), guild_settings.rate_card()),
+    };
+
+    let mut currencies = vec![ratecard::Currency {
+        code: "GBP".to_string(),
+        gbp_rate: 1.0,
+    }];
+    let usd_rate = guild_rate(ctx, command, "GBP", "USD").await?;
+    currencies.push(ratecard::Currency {
+        code: "USD".to_string(),
+        gbp_rate: usd_rate.value,
+    });
+    for code in guild_settings
+        .role_pricing
+        .iter()
+        .filter_map(|pricing| pricing.currency.as_ref())
+    {
+        let code = code.to_uppercase();
+        if currencies.iter().any(|currency| currency.code == code) {
+            continue;
+        }
+        let rate = guild_rate(ctx, command, "GBP", &code).await?;
+        currencies.push(ratecard::Currency {
+            code,
+            gbp_rate: rate.value,
+        });
+    }
+
+    let table = ratecard::Table {
+        title,
+        card,
+        currencies,
+        tiers: guild_settings
+            .role_pricing
+            .iter()
+            .filter(|pricing| pricing.discount_percent > 0.0)
+            .map(|pricing| ratecard::Tier {
+                role_id: pricing.role_id,
+                label: format!("<@&{}>", pricing.role_id),
+                discount_percent: pricing.discount_percent,
+            })
+            .collect(),
+        tax: guild_settings.tax.clone(),
+        payment_methods: guild_settings
+            .payment_methods
+            .iter()
+            .filter(|method| method.enabled)
+            .cloned()
+            .collect(),
+    };
+    Ok((table, usd_rate))
+}
+
+async fn send_rate_card(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    seller: Option<&str>,
+) -> Result<(), String> {
+    let (table, usd_rate) = rate_card_table(ctx, command, "Rate Card", seller).await?;
+    let mut embed = table.embed();
+    add_rate_notes(&mut embed, &usd_rate);
+    send_embed_response(ctx, command, embed).await
+}
+
+/// Sends the rate card as a PNG titled with the server's name, with tiers named
+/// after their roles since mentions don't render in images.
+async fn send_rate_card_image(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    guild_id: GuildId,
+    seller: Option<&str>,
+) -> Result<(), String> {
+    let guild = guild_id
+        .to_partial_guild(ctx)
+        .await
+        .map_err(|e| format!("Error fetching server: {:?}", e))?;
+    let (mut table, usd_rate) = rate_card_table(ctx, command, &guild.name, seller).await?;
+    if !table.tiers.is_empty() {
+        let roles = guild_id
+            .roles(&ctx.http)
+            .await
+            .map_err(|e| format!("Error fetching roles: {:?}", e))?;
+        for tier in &mut table.tiers {
+            if let Some(role) = roles.get(&RoleId(tier.role_id)) {
+                tier.label = role.name.clone();
+            }
+        }
+    }
+
+    let mut notes = Vec::new();
+    if usd_rate.margin_percent != 0.0 {
+        notes.push(format!(
+            "Includes a {:+.2}% FX margin",
+            usd_rate.margin_percent
+        ));
+    }
+    if usd_rate.stale_warning().is_some() {
+        notes.push("Exchange rates may be out of date".to_string());
+    }
+    let png = table.png(&notes);
+
+    outbound::send(&ctx.http, || {
+        command.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::ChannelMessageWithSource)
+                .interaction_response_data(|message| {
+                    message.add_file(AttachmentType::Bytes {
+                        data: Cow::Owned(png.clone()),
+                        filename: "ratecard.png".to_string(),
+                    })
+                })
+        })
+    })
+    .await
+    .map_err(|e| format!("Error sending response: {:?}", e))
+}
+
+/// Handles the rate change history pagination controls, whose custom IDs are
+/// `audit:<user id>:<page>:<control>`.
+async fn handle_audit_page(
+    ctx: &Context,
+    component: &MessageComponentInteraction,
+    custom_id: &components::ComponentId,
+) -> Result<(), String> {
+    let guild_id = component
+        .guild_id
+        .ok_or("This button can only be used in a server")?;
+    let user_id: u64 = custom_id.arg(0)?;
+    let page = pagination::requested(component, custom_id, 1)?;
+
+    if user_id != component.user.id.0 {
+        return Err("Run /ratecard history to browse the changes yourself".to_string());
+    }
+
+    let changes = audit::store(ctx).await?.for_guild(guild_id.0).await;
+    let (embed, components) = audit_page(&changes, user_id, page);
+
+    update_page(ctx, component, embed, components).await
+}
+
+/// Renders one page of a guild's pricing changes, newest first, with
+/// pagination controls.
+fn audit_page(
+    changes: &[audit::Change],
+    user_id: u64,
+    page: usize,
+) -> (CreateEmbed, CreateComponents) {
+    let page = pagination::Page::of(changes.len(), AUDIT_PAGE_SIZE, page);
+
+    let lines: Vec<_> = page
+        .items(changes.iter().rev(), AUDIT_PAGE_SIZE)
+        .into_iter()
+        .map(|change| {
+            format!(
+                "<t:{}:f> • **{}**: {} → {} by <@{}>",
+                change.changed_at,
+                change.setting,
+                change.old_value,
+                change.new_value,
+                change.actor_id
+            )
+        })
+        .collect();
+
+    let embed = CreateEmbed::default()
+        .title("Rate Change History")
+        .description(if lines.is_empty() {
+            "No pricing changes have been recorded yet.".to_string()
+        } else {
+            lines.join("\n")
+        })
+        .footer(|footer| footer.text(page.label(i18n::DEFAULT_LOCALE)))
+        .color(0x0096FF)
+        .clone();
+    let components = pagination::controls("audit", &[&user_id], &page, i18n::DEFAULT_LOCALE);
+
+    (embed, components)
+}
+
+async fn handle_export_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    ensure_owner(command)?;
+
+    let subcommand = command.data.options.first().ok_or("Missing subcommand")?;
+    if subcommand.name != "orders" {
+        return Err(format!("Unknown export: {}", subcommand.name));
+    }
+
+    let period = match subcommand
+        .options
+        .first()
+        .and_then(|option| option.value.as_ref())
+    {
+        Some(period) => period::Period::parse(period.as_str().ok_or("Invalid period")?)?,
+        None => period::Period::all(),
+    };
+    let orders = orders::store(ctx).await?.in_period(&period).await;
+    let csv = orders::to_csv(&orders);
+
+    outbound::send(&ctx.http, || {
+        command.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::ChannelMessageWithSource)
+                .interaction_response_data(|message| {
+                    message
+                        .content(format!(
+                            "Exported {} orders for period `{}`.",
+                            orders.len(),
+                            period.label
+                        ))
+                        .add_file(AttachmentType::Bytes {
+                            data: Cow::Owned(csv.as_bytes().to_vec()),
+                            filename: format!("orders-{}.csv", period.label.replace("..", "_")),
+                        })
+                        .ephemeral(true)
+                })
+        })
+    })
+    .await
+    .map_err(|e| format!("Error sending response: {:?}", e))
+}
+
+/// Sends an encrypted snapshot of the bot's settings and records, or restores
+/// one over them.
+async fn handle_backup_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    ensure_owner(command)?;
+
+    let tenant = tenant::tenant(ctx).await?;
+    let subcommand = command.data.options.first().ok_or("Missing subcommand")?;
+    match subcommand.name.as_str() {
+        "create" => {}
+        "restore" => return restore_backup(ctx, command, &tenant, subcommand).await,
+        other => return Err(format!("Unknown backup action: {}", other)),
+    }
+
+    let archive = backup::Archive::create(&tenant.name, &tenant.partition)?;
+    let sealed = archive.seal()?;
+    log::info!(
+        "Backup of {} documents created by {}",
+        archive.file_count(),
+        command.user.name
+    );
+
+    outbound::send(&ctx.http, || {
+        command.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::ChannelMessageWithSource)
+                .interaction_response_data(|message| {
+                    message
+                        .content(format!(
+                            "Backed up {} documents. It can only be restored with the same `BACKUP_PASSPHRASE`.",
+                            archive.file_count()
+                        ))
+                        .add_file(AttachmentType::Bytes {
+                            data: Cow::Owned(sealed.clone()),
+                            filename: format!(
+                                "backup-{}-{}.rcbackup",
+                                tenant.label(),
+                                archive.created_at
+                            ),
+                        })
+                        .ephemeral(true)
+                })
+        })
+    })
+    .await
+    .map_err(|e| format!("Error sending response: {:?}", e))
+}
+
+/// Restores a backup made by `/backup create` for this bot. Documents changed
+/// since the backup was made are only replaced with `overwrite`, and the
+/// current ones are saved first so a restore can be undone.
+async fn restore_backup(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    tenant: &tenant::Tenant,
+    subcommand: &application_command::CommandDataOption,
+) -> Result<(), String> {
+    let option = |name: &str| subcommand.options.iter().find(|option| option.name == name);
+    let attachment = match option("file").and_then(|file| file.resolved.as_ref()) {
+        Some(application_command::CommandDataOptionValue::Attachment(attachment)) => attachment,
+        _ => return Err("Attach a file made by /backup create".to_string()),
+    };
+    let overwrite = option("overwrite")
+        .and_then(|overwrite| overwrite.value.as_ref())
+        .and_then(|overwrite| overwrite.as_bool())
+        .unwrap_or(false);
+    if attachment.size > backup::MAX_ARCHIVE_BYTES {
+        return Err(format!(
+            "{} is too large to be a backup",
+            attachment.filename
+        ));
+    }
+
+    let sealed = attachment
+        .download()
+        .await
+        .map_err(|e| format!("Error downloading {}: {:?}", attachment.filename, e))?;
+    let archive = backup::Archive::open(&sealed)?;
+    if archive.tenant != tenant.name {
+        return Err("That backup belongs to another bot".to_string());
+    }
+    let newer = archive.newer_files(&tenant.partition);
+    if !newer.is_empty() && !overwrite {
+        return Err(format!(
+            "{} hold records this backup from <t:{}:f> lacks, and restoring it would lose them. Run again with overwrite:True to restore it anyway.",
+            newer.join(", "),
+            archive.created_at
+        ));
+    }
+
+    let safety_copy = archive.restore(&tenant.name, &tenant.partition)?;
+    reopen_stores(ctx, tenant).await?;
+    log::warn!(
+        "Backup from {} restored by {}; previous data saved to {}",
+        archive.created_at,
+        command.user.name,
+        safety_copy
+    );
+
+    let embed = CreateEmbed::default()
+        .title("Backup Restored")
+        .description(format!(
+            "Restored {} documents from the backup made on <t:{}:f>. The data it replaced was saved to `{}`.",
+            archive.file_count(),
+            archive.created_at,
+            safety_copy
+        ))
+        .color(0xFFA500)
+        .clone();
+
+    send_embed(ctx, command, embed, true).await
+}
+
+/// Re-reads every document a backup covers, after a restore has replaced them
+/// on disk. All are opened before any is swapped in, so a bad document leaves
+/// the running stores untouched.
+async fn reopen_stores(ctx: &Context, tenant: &tenant::Tenant) -> Result<(), String> {
+    let partition = &tenant.partition;
+    let orders = orders::OrderStore::open(partition, &tenant.cache)?;
+    let calculations = calculations::CalculationStore::open(partition)?;
+    let audit = audit::AuditStore::open(partition)?;
+    let stock = stock::StockStore::open(partition, &tenant.cache)?;
+    let links = links::LinkStore::open(partition)?;
+    let disputes = disputes::DisputeStore::open(partition)?;
+    let flags = risk::FlagStore::open(partition)?;
+    let giveaways = giveaways::GiveawayStore::open(partition)?;
+    settings::store(ctx).await?.reload().await?;
+
+    let mut data = ctx.data.write().await;
+    data.insert::<orders::OrdersKey>(Arc::new(orders));
+    data.insert::<calculations::CalculationsKey>(Arc::new(calculations));
+    data.insert::<audit::AuditKey>(Arc::new(audit));
+    data.insert::<stock::StockKey>(Arc::new(stock));
+    data.insert::<links::LinksKey>(Arc::new(links));
+    data.insert::<disputes::DisputesKey>(Arc::new(disputes));
+    data.insert::<risk::FlagsKey>(Arc::new(flags));
+    data.insert::<giveaways::GiveawaysKey>(Arc::new(giveaways));
+    Ok(())
+}
+
+/// Shows buyers' ratings of orders created in `period`.
+async fn send_service_stats(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    period: &period::Period,
+    orders: &[orders::Order],
+) -> Result<(), String> {
+    let summary = orders::summarize_feedback(orders);
+
+    let mut embed = CreateEmbed::default()
+        .title("Service Statistics")
+        .description(format!("Period: `{}`", period.label))
+        .field("Ratings", summary.ratings, true)
+        .field(
+            "Average",
+            if summary.ratings == 0 {
+                "No ratings yet".to_string()
+            } else {
+                format!("{:.2} ★", summary.average_stars)
+            },
+            true,
+        )
+        .field(
+            "Breakdown",
+            (1..=5)
+                .rev()
+                .map(|stars| format!("{}: {}", "★".repeat(stars), summary.distribution[stars - 1]))
+                .collect::<Vec<_>>()
+                .join("\n"),
+            false,
+        )
+        .color(0x0096FF)
+        .clone();
+    if !summary.by_staff.is_empty() {
+        embed.field(
+            "By Staff",
+            summary
+                .by_staff
+                .iter()
+                .map(|(staff_id, count, average)| {
+                    format!("<@{}>: {:.2} ★ from {} ratings", staff_id, average, count)
+                })
+                .collect::<Vec<_>>()
+                .join("\n"),
+            false,
+        );
+    }
+    if !summary.recent.is_empty() {
+        embed.field(
+            "Recent Feedback",
+            summary
+                .recent
+                .iter()
+                .take(RECENT_FEEDBACK_SIZE)
+                .filter_map(|order| {
+                    let feedback = order.feedback.as_ref()?;
+                    Some(format!(
+                        "{} **#{}** <@{}>: {}",
+                        "★".repeat(feedback.stars as usize),
+                        order.id,
+                        order.buyer_id,
+                        feedback
+                            .comment
+                            .as_deref()
+                            .unwrap_or_default()
+                            .chars()
+                            .take(FEEDBACK_PREVIEW_LENGTH)
+                            .collect::<String>()
+                    ))
+                })
+                .collect::<Vec<_>>()
+                .join("\n"),
+            false,
+        );
+    }
+
+    send_embed_response(ctx, command, embed).await
+}
+
+async fn handle_stats_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let subcommand = command.data.options.first().ok_or("Missing subcommand")?;
+    let period = match subcommand
+        .options
+        .first()
+        .and_then(|option| option.value.as_ref())
+    {
+        Some(period) => period::Period::parse(period.as_str().ok_or("Invalid period")?)?,
+        None => period::Period::parse("30d")?,
+    };
+    let orders: Vec<_> = orders::store(ctx)
+        .await?
+        .in_period(&period)
+        .await
+        .into_iter()
+        .filter(|order| order.guild_id == Some(guild_id.0))
+        .collect();
+    match subcommand.name.as_str() {
+        "sales" => {}
+        "service" => return send_service_stats(ctx, command, &period, &orders).await,
+        other => return Err(format!("Unknown statistics: {}", other)),
+    }
+    let utc_offset = settings::store(ctx)
+        .await?
+        .read()
+        .await
+        .guild(Some(guild_id))
+        .utc_offset_minutes
+        .unwrap_or(0);
+    // Quotes and cancelled orders aren't sales.
+    let paid: Vec<_> = orders
+        .into_iter()
+        .filter(|order| order.status.is_paid())
+        .collect();
+    let summary = orders::summarize(&paid, utc_offset);
+
+    let busiest_days = summary
+        .busiest_days
+        .iter()
+        .take(3)
+        .map(|(day, count)| format!("{}: {} orders", day, count))
+        .collect::<Vec<_>>();
+
+    let embed = CreateEmbed::default()
+        .title("Sales Statistics")
+        .description(format!("Period: `{}`", period.label))
+        .field("Orders", summary.order_count, true)
+        .field("Robux Sold", format!("{} R$", summary.robux), true)
+        .field(
+            "Average Order",
+            format!("{:.0} R$", summary.average_robux()),
+            true,
+        )
+        .field("Gross (GBP)", format!("£{:.2}", summary.gross_gbp), true)
+        .field("Gross (USD)", format!("${:.2}", summary.gross_usd), true)
+        .field(
+            "Marketplace Fees",
+            format!("{} R$", summary.fee_robux),
+            true,
+        )
+        .field(
+            "Refunds (GBP)",
+            format!("£{:.2}", summary.refunded_gbp),
+            true,
+        )
+        .field("Net (GBP)", format!("£{:.2}", summary.net_gbp()), true)
+        .field("Net (USD)", format!("${:.2}", summary.net_usd()), true)
+        .field(
+            "Busiest Days",
+            if busiest_days.is_empty() {
+                "No orders yet".to_string()
+            } else {
+                busiest_days.join("\n")
+            },
+            false,
+        )
+        .color(0x0096FF)
+        .clone();
+
+    send_embed_response(ctx, command, embed).await
+}
+
+async fn handle_leaderboard_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let subcommand = command.data.options.first().ok_or("Missing subcommand")?;
+    let settings = settings::store(ctx).await?;
+
+    if subcommand.name == "opt-out" || subcommand.name == "opt-in" {
+        let opt_out = subcommand.name == "opt-out";
+        settings
+            .update(|settings| {
+                let opt_outs = &mut settings
+                    .guilds
+                    .entry(guild_id.0)
+                    .or_default()
+                    .leaderboard_opt_outs;
+                if opt_out {
+                    opt_outs.insert(command.user.id.0);
+                } else {
+                    opt_outs.remove(&command.user.id.0);
+                }
+            })
+            .await?;
+
+        let message = if opt_out {
+            "You will no longer appear on this server's leaderboard."
+        } else {
+            "You will appear on this server's leaderboard again."
+        };
+        return respond_ephemeral(ctx, command, message).await;
+    }
+
+    let by_spend = subcommand
+        .options
+        .iter()
+        .find(|option| option.name == "by")
+        .and_then(|option| option.value.as_ref())
+        .and_then(|value| value.as_str())
+        == Some("spend");
+    let period = match subcommand
+        .options
+        .iter()
+        .find(|option| option.name == "period")
+        .and_then(|option| option.value.as_ref())
+    {
+        Some(period) => period::Period::parse(period.as_str().ok_or("Invalid period")?)?,
+        None => period::Period::all(),
+    };
+
+    let buyers = leaderboard(ctx, guild_id, &period, by_spend).await?;
+    let (embed, components) = leaderboard_page(&buyers, by_spend, &period, command.user.id.0, 0);
+    send_page(ctx, command, embed, components, false).await
+}
+
+/// Handles the leaderboard pagination controls, whose custom IDs are
+/// `leaderboard:<user id>:<spend|robux>:<period>:<page>:<control>`.
+async fn handle_leaderboard_page(
+    ctx: &Context,
+    component: &MessageComponentInteraction,
+    custom_id: &components::ComponentId,
+) -> Result<(), String> {
+    let guild_id = component
+        .guild_id
+        .ok_or("This button can only be used in a server")?;
+    let user_id: u64 = custom_id.arg(0)?;
+    let by_spend = custom_id.arg::<String>(1)? == "spend";
+    let period = period::Period::parse(&custom_id.arg::<String>(2)?)?;
+    let page = pagination::requested(component, custom_id, 3)?;
+    if user_id != component.user.id.0 {
+        return Err("Run /leaderboard view to browse the leaderboard yourself".to_string());
+    }
+
+    let buyers = leaderboard(ctx, guild_id, &period, by_spend).await?;
+    let (embed, components) = leaderboard_page(&buyers, by_spend, &period, user_id, page);
+    update_page(ctx, component, embed, components).await
+}
 
-async fn register_commands(ctx: &Context) -> Result<(), Box<dyn std::error::Error>> {
-    let guild_id = GuildId(env::var("GUILD_ID")?.parse()?);
+/// The guild's buyers in `period`, best first, leaving out those who opted out.
+async fn leaderboard(
+    ctx: &Context,
+    guild_id: GuildId,
+    period: &period::Period,
+    by_spend: bool,
+) -> Result<Vec<orders::BuyerTotal>, String> {
+    let opt_outs = settings::store(ctx)
+        .await?
+        .read()
+        .await
+        .guild(Some(guild_id))
+        .leaderboard_opt_outs;
+    let orders: Vec<_> = orders::store(ctx)
+        .await?
+        .in_period(period)
+        .await
+        .into_iter()
+        .filter(|order| order.guild_id == Some(guild_id.0) && !opt_outs.contains(&order.buyer_id))
+        .collect();
+    Ok(orders::top_buyers(&orders, by_spend))
+}
 
-    let commands = guild_id
-        .set_application_commands(&ctx.http, |commands| {
-            commands
-                .create_application_command(|command: &mut CreateApplicationCommand| {
-                    command
-                        .name("help")
-                        .description("Display the available commands and their usage")
-                })
-                .create_application_command(|command: &mut CreateApplicationCommand| {
-                    command
-                        .name("price")
-                        .description(
-                            "Calculate the price in GBP and USD for a given amount of Robux",
+/// Renders one page of the leaderboard, with pagination controls for
+/// `user_id`.
+fn leaderboard_page(
+    buyers: &[orders::BuyerTotal],
+    by_spend: bool,
+    period: &period::Period,
+    user_id: u64,
+    page: usize,
+) -> (CreateEmbed, CreateComponents) {
+    let page = pagination::Page::of(buyers.len(), LEADERBOARD_PAGE_SIZE, page);
+    let first_rank = page.index * LEADERBOARD_PAGE_SIZE;
+    let lines: Vec<_> = page
+        .items(buyers.iter(), LEADERBOARD_PAGE_SIZE)
+        .into_iter()
+        .enumerate()
+        .map(|(rank, buyer)| {
+            let total = if by_spend {
+                format!("£{:.2}", buyer.spend_gbp)
+            } else {
+                format!("{} R$", buyer.robux)
+            };
+            format!(
+                "**{}.** <@{}> — {} ({} orders)",
+                first_rank + rank + 1,
+                buyer.buyer_id,
+                total,
+                buyer.order_count
+            )
+        })
+        .collect();
+
+    let embed = CreateEmbed::default()
+        .title(if by_spend {
+            "Top Buyers by Spend"
+        } else {
+            "Top Buyers by Robux"
+        })
+        .description(if lines.is_empty() {
+            "No orders yet".to_string()
+        } else {
+            lines.join("\n")
+        })
+        .footer(|footer| {
+            footer.text(format!(
+                "{} • Period: {} • Use /leaderboard opt-out to hide yourself",
+                page.label(i18n::DEFAULT_LOCALE),
+                period.label
+            ))
+        })
+        .color(0x0096FF)
+        .clone();
+    let components = pagination::controls(
+        "leaderboard",
+        &[
+            &user_id,
+            &if by_spend { "spend" } else { "robux" },
+            &period.label,
+        ],
+        &page,
+        i18n::DEFAULT_LOCALE,
+    );
+
+    (embed, components)
+}
+
+/// Sets a reminder on one of the buyer's open quotes. Staff can set one on any
+/// quote in the server.
+async fn handle_remindme_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let guild_id = command
+        .guild_id
+        .ok_or("This command can only be used in a server")?;
+    let option = |name: &str| {
+        command
+            .data
+            .options
+            .iter()
+            .find(|option| option.name == name)
+            .and_then(|option| option.value.as_ref())
+    };
+    let quote_id = option("quote")
+        .and_then(|quote| quote.as_u64())
+        .ok_or("Missing quote")?;
+    let delay_secs = giveaways::parse_duration(
+        option("in")
+            .and_then(|delay| delay.as_str())
+            .ok_or("Missing time")?,
+    )?;
+    if delay_secs > MAX_REMINDER_SECS {
+        return Err("Reminders can be set at most 7 days ahead".to_string());
+    }
+
+    let orders = orders::store(ctx).await?;
+    let user_id = command.user.id.0;
+    let quote = orders
+        .get(quote_id)
+        .await
+        .filter(|order| order.guild_id == Some(guild_id.0))
+        .filter(|order| {
+            order.buyer_id == user_id
+                || order.claimed_by == Some(user_id)
+                || can_manage_guild(command)
+        })
+        .ok_or_else(|| format!("Quote #{} doesn't exist", quote_id))?;
+    if quote.status != orders::OrderStatus::Quoted {
+        return Err(format!(
+            "#{} is no longer a quote; it is {}",
+            quote.id,
+            quote.status.label()
+        ));
+    }
+
+    let at = store::now() + delay_secs;
+    orders
+        .update(quote.id, |order| {
+            order.reminder = Some(orders::Reminder {
+                at,
+                channel_id: command.channel_id.0,
+                set_by: user_id,
+            })
+        })
+        .await?;
+
+    respond_ephemeral(
+        ctx,
+        command,
+        &format!(
+            "I'll remind you about quote #{} here <t:{}:R>{}.",
+            quote.id,
+            at,
+            if quote.buyer_id == user_id {
+                ""
+            } else {
+                ", pinging the buyer too"
+            }
+        ),
+    )
+    .await
+}
+
+async fn handle_history_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let orders = orders::store(ctx).await?.for_buyer(command.user.id.0).await;
+    let (embed, components) = history_page(&orders, command.user.id.0, 0);
+
+    send_page(ctx, command, embed, components, true).await
+}
+
+/// Handles the history pagination controls, whose custom IDs are
+/// `history:<user id>:<page>:<control>`.
+async fn handle_history_page(
+    ctx: &Context,
+    component: &MessageComponentInteraction,
+    custom_id: &components::ComponentId,
+) -> Result<(), String> {
+    let user_id: u64 = custom_id.arg(0)?;
+    let page = pagination::requested(component, custom_id, 1)?;
+
+    if user_id != component.user.id.0 {
+        return Err("History buttons can only be used by their owner".to_string());
+    }
+
+    let orders = orders::store(ctx).await?.for_buyer(user_id).await;
+    let (embed, components) = history_page(&orders, user_id, page);
+
+    update_page(ctx, component, embed, components).await
+}
+
+/// Renders one page of a buyer's orders, newest first, with pagination
+/// controls.
+fn history_page(
+    orders: &[orders::Order],
+    user_id: u64,
+    page: usize,
+) -> (CreateEmbed, CreateComponents) {
+    let page = pagination::Page::of(orders.len(), HISTORY_PAGE_SIZE, page);
+
+    let lines: Vec<_> = page
+        .items(orders.iter().rev(), HISTORY_PAGE_SIZE)
+        .into_iter()
+        .map(|order| {
+            format!(
+                "**#{}** • <t:{}:d> • {}\n{} R$ ({} R$ gamepass) • £{:.2} / ${:.2} at {:.4} GBP/USD",
+                order.id,
+                order.created_at,
+                order.status.label(),
+                order.robux,
+                order.gamepass_price,
+                order.total_gbp,
+                order.total_usd,
+                order.gbp_to_usd_rate
+            )
+        })
+        .collect();
+
+    let embed = CreateEmbed::default()
+        .title("Your Order History")
+        .description(if lines.is_empty() {
+            "You have no orders or quotes yet.".to_string()
+        } else {
+            lines.join("\n\n")
+        })
+        .footer(|footer| footer.text(page.label(i18n::DEFAULT_LOCALE)))
+        .color(0x0096FF)
+        .clone();
+    let components = pagination::controls("history", &[&user_id], &page, i18n::DEFAULT_LOCALE);
+
+    (embed, components)
+}
+
+/// The locale to answer a member using `user_locale` in, per `i18n::resolve`.
+async fn response_locale(
+    ctx: &Context,
+    guild_id: Option<GuildId>,
+    user_locale: &str,
+) -> &'static str {
+    let language = match settings::store(ctx).await {
+        Ok(settings) => settings.read().await.guild(guild_id).language,
+        Err(_) => None,
+    };
+    i18n::resolve(user_locale, language.as_deref())
+}
+
+/// Minutes east of UTC to write times in for `user_id`, for text where Discord
+/// timestamps don't render.
+async fn utc_offset(ctx: &Context, guild_id: Option<GuildId>, user_id: u64) -> i32 {
+    match settings::store(ctx).await {
+        Ok(settings) => settings.read().await.utc_offset(guild_id, user_id),
+        Err(_) => 0,
+    }
+}
+
+async fn handle_explain_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let topic = command
+        .data
+        .options
+        .first()
+        .and_then(|option| option.value.as_ref())
+        .and_then(|topic| topic.as_str())
+        .ok_or("Missing topic")?;
+    let locale = response_locale(ctx, command.guild_id, &command.locale).await;
+    let embed = explain::embed(topic, locale).ok_or_else(|| format!("Unknown topic: {}", topic))?;
+
+    send_embed(ctx, command, embed, false).await
+}
+
+async fn handle_help_command(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<(), String> {
+    let specs = visible_commands(ctx, command.guild_id).await?;
+    let locale = response_locale(ctx, command.guild_id, &command.locale).await;
+    let (embed, components) = help_page(&specs, command.user.id.0, 0, locale);
+
+    send_page(ctx, command, embed, components, true).await
+}
+
+/// Handles the help pagination controls, whose custom IDs are
+/// `help:<user id>:<page>:<control>`.
+async fn handle_help_page(
+    ctx: &Context,
+    component: &MessageComponentInteraction,
+    custom_id: &components::ComponentId,
+) -> Result<(), String> {
+    let user_id: u64 = custom_id.arg(0)?;
+    let page = pagination::requested(component, custom_id, 1)?;
+
+    if user_id != component.user.id.0 {
+        return Err("Run /help to browse the commands yourself".to_string());
+    }
+
+    let specs = visible_commands(ctx, component.guild_id).await?;
+    let locale = response_locale(ctx, component.guild_id, &component.locale).await;
+    let (embed, components) = help_page(&specs, user_id, page, locale);
+
+    update_page(ctx, component, embed, components).await
+}
+
+/// The registered commands, minus those disabled in `guild_id`.
+async fn visible_commands(
+    ctx: &Context,
+    guild_id: Option<GuildId>,
+) -> Result<Vec<&'static commands::CommandSpec>, String> {
+    let disabled_commands = settings::store(ctx)
+        .await?
+        .read()
+        .await
+        .guild(guild_id)
+        .disabled_commands;
+    Ok(commands::COMMANDS
+        .iter()
+        .filter(|spec| !disabled_commands.contains(spec.name))
+        .collect())
+}
+
+/// Renders a help page for `specs` in the user's `locale`: page 0 lists every
+/// command and each following page describes one command in detail.
+fn help_page(
+    specs: &[&commands::CommandSpec],
+    user_id: u64,
+    page: usize,
+    locale: &str,
+) -> (CreateEmbed, CreateComponents) {
+    let page = pagination::Page::of(specs.len() + 1, 1, page);
+
+    let mut embed = CreateEmbed::default();
+    match page.index.checked_sub(1).map(|index| specs[index]) {
+        None => {
+            embed.title(i18n::t(locale, "help.title", &[])).description(
+                specs
+                    .iter()
+                    .map(|spec| {
+                        format!(
+                            "`/{}` — {}",
+                            spec.localized_name(locale),
+                            spec.localized_description(locale)
                         )
-                        .create_option(|option| {
-                            option
-                                .name("type")
-                                .description("Conversion type (b/t or a/t)")
-                                .kind(CommandOptionType::String)
-                                .required(true)
-                                .add_string_choice("b/t", "b/t")
-                                .add_string_choice("a/t", "a/t")
-                        })
-                        .create_option(|option| {
-                            option
-                                .name("amount")
-                                .description("Amount of Robux")
-                                .kind(CommandOptionType::Integer)
-                                .required(true)
-                        })
+                    })
+                    .collect::<Vec<_>>()
+                    .join("\n"),
+            );
+        }
+        Some(spec) => {
+            embed
+                .title(format!("/{}", spec.localized_name(locale)))
+                .description(spec.localized_description(locale));
+
+            let options = describe_options(spec, &spec.key(), spec.options, locale);
+            if !options.is_empty() {
+                let lines: Vec<String> = options.lines().map(str::to_string).collect();
+                add_split_fields(
+                    &mut embed,
+                    &i18n::t(locale, "help.options", &[]),
+                    &i18n::t(locale, "help.options_continued", &[]),
+                    &lines,
+                );
+            }
+            if !spec.examples.is_empty() {
+                embed.field(
+                    i18n::t(locale, "help.examples", &[]),
+                    spec.examples
+                        .iter()
+                        .map(|example| format!("`{}`", example))
+                        .collect::<Vec<_>>()
+                        .join("\n"),
+                    false,
+                );
+            }
+            embed.field(
+                i18n::t(locale, "help.permissions", &[]),
+                if spec.guild_only {
+                    i18n::t(
+                        locale,
+                        "help.servers_only",
+                        &[("access", spec.access.label(locale))],
+                    )
+                } else {
+                    spec.access.label(locale).to_string()
+                },
+                false,
+            );
+        }
+    }
+    let footer = page.label(locale);
+    embed.footer(|f| f.text(footer)).color(0x0096FF);
+    let components = pagination::controls("help", &[&user_id], &page, locale);
+
+    (embed, components)
+}
+
+/// Lists a command's options, expanding subcommands into their own options.
+/// `key` is the catalog key prefix the options are nested under.
+fn describe_options(
+    spec: &commands::CommandSpec,
+    key: &str,
+    options: &[commands::OptionSpec],
+    locale: &str,
+) -> String {
+    options
+        .iter()
+        .map(|option| {
+            let name = option.localized_name(key, locale);
+            let description = option.localized_description(key, locale);
+            if option.kind == CommandOptionType::SubCommand {
+                let sub_options = describe_options(spec, &option.key(key), option.options, locale);
+                format!(
+                    "**/{} {}** — {}{}",
+                    spec.localized_name(locale),
+                    name,
+                    description,
+                    if sub_options.is_empty() {
+                        String::new()
+                    } else {
+                        format!("\n{}", sub_options)
+                    }
+                )
+            } else {
+                let choices = option
+                    .choices
+                    .iter()
+                    .map(|(name, _)| *name)
+                    .collect::<Vec<_>>();
+                format!(
+                    "`{}`{} — {}{}",
+                    name,
+                    if option.required {
+                        String::new()
+                    } else {
+                        format!(" ({})", i18n::t(locale, "help.optional", &[]))
+                    },
+                    description,
+                    if choices.is_empty() {
+                        String::new()
+                    } else {
+                        format!(" [{}]", choices.join(", "))
+                    }
+                )
+            }
+        })
+        .collect::<Vec<_>>()
+        .join("\n")
+}
+
+/// The bot owner's user ID, configured in `OWNER_ID`.
+fn owner_id() -> Option<u64> {
+    env::var("OWNER_ID").ok().and_then(|id| id.parse().ok())
+}
+
+/// Restricts a command to the bot owner.
+fn ensure_owner(command: &ApplicationCommandInteraction) -> Result<(), String> {
+    if owner_id() != Some(command.user.id.0) {
+        return Err("This command is restricted to the bot owner.".to_string());
+    }
+    Ok(())
+}
+
+/// Formats a net amount, breaking it into net, tax and gross lines when the
+/// guild charges tax.
+fn tax_lines(symbol: char, net: f64, tax: Option<&settings::TaxSettings>) -> String {
+    tax_lines_with(|amount| format!("{}{:.2}", symbol, amount), net, tax)
+}
+
+/// Like `tax_lines`, written the way `currency` is: its symbol where it has
+/// one and its usual number of decimals.
+fn currency_lines(currency: &str, net: f64, tax: Option<&settings::TaxSettings>) -> String {
+    tax_lines_with(|amount| currency::format(currency, amount), net, tax)
+}
+
+fn tax_lines_with(
+    format_amount: impl Fn(f64) -> String,
+    net: f64,
+    tax: Option<&settings::TaxSettings>,
+) -> String {
+    match tax {
+        Some(tax) => format!(
+            "Net: {}\n{} ({}%): {}\n**Gross: {}**",
+            format_amount(net),
+            tax.label,
+            tax.rate_percent,
+            format_amount(tax.on(net)),
+            format_amount(net + tax.on(net)),
+        ),
+        None => format_amount(net),
+    }
+}
+
+async fn guild_settings(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+) -> Result<settings::GuildSettings, String> {
+    Ok(settings::store(ctx)
+        .await?
+        .read()
+        .await
+        .guild(command.guild_id))
+}
+
+/// Fetches a rate with the invoking guild's FX margin applied.
+async fn guild_rate(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    base: &str,
+    quote: &str,
+) -> Result<rates::Rate, String> {
+    let margin = guild_settings(ctx, command).await?.fx_margin_percent;
+    let rate = rates::service(ctx).await?.get(base, quote).await?;
+
+    Ok(rate.with_margin(margin))
+}
+
+/// Records a conversion with the rates it used, and stamps the embed's footer
+/// with the record's number and the first rate so it can be found and
+/// reproduced in a dispute.
+async fn record_calculation(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    embed: &mut CreateEmbed,
+    input: String,
+    result: String,
+    rates: &[&rates::Rate],
+) {
+    let snapshots: Vec<_> = rates.iter().map(|rate| rate.snapshot()).collect();
+    let utc_offset = utc_offset(ctx, command.guild_id, command.user.id.0).await;
+    let stamp = snapshots
+        .first()
+        .map(|snapshot| snapshot.stamp(utc_offset))
+        .unwrap_or_default();
+    let calculation = calculations::Calculation {
+        id: 0,
+        guild_id: command.guild_id.map(|guild_id| guild_id.0),
+        user_id: command.user.id.0,
+        command: command.data.name.clone(),
+        input,
+        result,
+        rates: snapshots,
+        at: 0,
+    };
+    let recorded = match calculations::store(ctx).await {
+        Ok(calculations) => calculations.record(calculation).await,
+        Err(error) => Err(error),
+    };
+    match recorded {
+        Ok(calculation) => {
+            embed.footer(|footer| {
+                footer.text(format!("Calculation #{} • {}", calculation.id, stamp))
+            });
+        }
+        Err(error) => {
+            log::error!("Error recording calculation: {}", error);
+            embed.footer(|footer| footer.text(stamp));
+        }
+    }
+}
+
+/// Fetches `base` against each of `quotes` in one batch, with the invoking
+/// guild's FX margin applied to every conversion.
+async fn guild_rates(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    base: &str,
+    quotes: &[String],
+) -> Result<Vec<rates::Rate>, String> {
+    let margin = guild_settings(ctx, command).await?.fx_margin_percent;
+    let rates = rates::service(ctx).await?.get_many(base, quotes).await;
+
+    Ok(rates
+        .into_iter()
+        .map(|rate| match rate.base == rate.quote {
+            true => rate,
+            false => rate.with_margin(margin),
+        })
+        .collect())
+}
+
+/// Flags `robux` on `embed` when it is past the guild's large-amount `threshold`,
+/// so a stray extra zero gets noticed before anyone pays.
+fn add_large_amount_warning(embed: &mut CreateEmbed, threshold: Option<u64>, robux: f64) {
+    let threshold = match threshold {
+        Some(threshold) => threshold,
+        None => return,
+    };
+    if robux <= threshold as f64 {
+        return;
+    }
+    embed
+        .field(
+            "Large Amount",
+            format!(
+                "⚠️ {} R$ is over this server's {} R$ limit for routine orders. Double-check the amount.",
+                amount::group_thousands(robux),
+                amount::group_thousands(threshold as f64)
+            ),
+            false,
+        )
+        .color(0xFFA500);
+}
+
+/// Discloses any FX margin in the rate and flags embeds built from a rate that
+/// did not come from the live exchange API.
+fn add_rate_notes(embed: &mut CreateEmbed, rate: &rates::Rate) {
+    if rate.margin_percent != 0.0 {
+        embed.field(
+            "FX Margin",
+            format!(
+                "Includes a {:+.2}% margin on the mid-market rate (1 {} = {:.4} {})",
+                rate.margin_percent,
+                rate.base,
+                rate.mid_market(),
+                rate.quote
+            ),
+            false,
+        );
+    }
+    if let Some(warning) = rate.stale_warning() {
+        embed.field("Warning", warning, false);
+    }
+}
+
+async fn send_embed_response(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    embed: CreateEmbed,
+) -> Result<(), String> {
+    send_embed(ctx, command, embed, false).await
+}
+
+/// Sends a calculation result, privately if the guild has chosen ephemeral results.
+async fn send_calculation_response(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    embed: CreateEmbed,
+) -> Result<(), String> {
+    let ephemeral = guild_settings(ctx, command).await?.ephemeral_results;
+    send_embed(ctx, command, embed, ephemeral).await
+}
+
+/// Sends the first page of a paginated list with its controls.
+async fn send_page(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    embed: CreateEmbed,
+    components: CreateComponents,
+    ephemeral: bool,
+) -> Result<(), String> {
+    outbound::send(&ctx.http, || {
+        command.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::ChannelMessageWithSource)
+                .interaction_response_data(|message| {
+                    message
+                        .add_embed(embed.clone())
+                        .set_components(components.clone())
+                        .ephemeral(ephemeral)
                 })
-                .create_application_command(|command: &mut CreateApplicationCommand| {
-                    command
-                        .name("convert")
-                        .description("Convert between GBP and USD")
-                        .create_option(|option| {
-                            option
-                                .name("currency")
-                                .description("Currency to convert from (GBP or USD)")
-                                .kind(CommandOptionType::String)
-                                .required(true)
-                                .add_string_choice("GBP", "GBP")
-                                .add_string_choice("USD", "USD")
-                        })
-                        .create_option(|option| {
-                            option
-                                .name("amount")
-                                .description("Amount to convert")
-                                .kind(CommandOptionType::Number)
-                                .required(true)
-                        })
+        })
+    })
+    .await
+    .map_err(|e| format!("Error sending response: {:?}", e))
+}
+
+/// Replaces a paginated message with the page its controls asked for.
+async fn update_page(
+    ctx: &Context,
+    component: &MessageComponentInteraction,
+    embed: CreateEmbed,
+    components: CreateComponents,
+) -> Result<(), String> {
+    outbound::send(&ctx.http, || {
+        component.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::UpdateMessage)
+                .interaction_response_data(|message| {
+                    message
+                        .set_embed(embed.clone())
+                        .set_components(components.clone())
                 })
-                .create_application_command(|command: &mut CreateApplicationCommand| {
-                    command
-                        .name("robux")
-                        .description("Convert GBP or USD to the amount of Robux")
-                        .create_option(|option| {
-                            option
-                                .name("currency")
-                                .description("Currency to convert from (GBP or USD)")
-                                .kind(CommandOptionType::String)
-                                .required(true)
-                                .add_string_choice("GBP", "GBP")
-                                .add_string_choice("USD", "USD")
-                        })
-                        .create_option(|option| {
-                            option
-                                .name("amount")
-                                .description("Amount to convert")
-                                .kind(CommandOptionType::Number)
-                                .required(true)
-                        })
+        })
+    })
+    .await
+    .map_err(|e| format!("Error updating message: {:?}", e))
+}
+
+async fn send_embed(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    embed: CreateEmbed,
+    ephemeral: bool,
+) -> Result<(), String> {
+    let result = outbound::send(&ctx.http, || {
+        command.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::ChannelMessageWithSource)
+                .interaction_response_data(|message| {
+                    message.add_embed(embed.clone()).ephemeral(ephemeral)
                 })
         })
-        .await?;
+    })
+    .await;
+
+    match result {
+        Ok(()) => Ok(()),
+        Err(_) if response_window_passed(command) => {
+            let content = format!(
+                "<@{}>, sorry for the wait. Here is your `/{}` result:",
+                command.user.id, command.data.name
+            );
+            send_late_reply(ctx, command, &content, Some(embed), ephemeral).await
+        }
+        Err(e) => Err(format!("Error sending response: {:?}", e)),
+    }
+}
+
+/// Whether the window for the initial response to `command` has passed, e.g.
+/// because an API call hung while the handler was working.
+fn response_window_passed(command: &ApplicationCommandInteraction) -> bool {
+    let created_at = (command.id.0 >> 22) + DISCORD_EPOCH_MS;
+    let now = SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map(|d| d.as_millis() as u64)
+        .unwrap_or_default();
+    now.saturating_sub(created_at) > INTERACTION_RESPONSE_WINDOW_MS
+}
+
+/// Delivers a reply to an interaction that can no longer be responded to:
+/// public replies are posted in the channel, private ones sent by DM.
+async fn send_late_reply(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    content: &str,
+    embed: Option<CreateEmbed>,
+    private: bool,
+) -> Result<(), String> {
+    let channel_id = if private {
+        command
+            .user
+            .id
+            .create_dm_channel(&ctx.http)
+            .await
+            .map_err(|e| format!("Error opening DM: {:?}", e))?
+            .id
+    } else {
+        command.channel_id
+    };
+
+    outbound::send(&ctx.http, || {
+        channel_id.send_message(&ctx.http, |message| {
+            message.content(content);
+            if let Some(embed) = &embed {
+                message.set_embed(embed.clone());
+            }
+            message
+        })
+    })
+    .await
+    .map(|_| ())
+    .map_err(|e| format!("Error sending late reply: {:?}", e))
+}
+
+async fn respond_ephemeral(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    content: &str,
+) -> Result<(), String> {
+    let result = outbound::send(&ctx.http, || {
+        command.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::ChannelMessageWithSource)
+                .interaction_response_data(|message| message.content(content).ephemeral(true))
+        })
+    })
+    .await;
+
+    match result {
+        Ok(()) => Ok(()),
+        Err(_) if response_window_passed(command) => {
+            send_late_reply(ctx, command, content, None, true).await
+        }
+        Err(e) => Err(format!("Error sending response: {:?}", e)),
+    }
+}
+
+async fn respond_with_error(
+    ctx: &Context,
+    command: &ApplicationCommandInteraction,
+    error_message: &str,
+) {
+    if let Err(why) = outbound::send(&ctx.http, || {
+        command.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::ChannelMessageWithSource)
+                .interaction_response_data(|message| message.content(error_message).ephemeral(true))
+        })
+    })
+    .await
+    {
+        if !response_window_passed(command) {
+            log::error!("Cannot respond to slash command: {}", why);
+        } else if let Err(why) = send_late_reply(ctx, command, error_message, None, true).await {
+            log::error!("Cannot deliver late error reply: {}", why);
+        }
+    }
+}
+
+async fn respond_to_component_with_error(
+    ctx: &Context,
+    component: &MessageComponentInteraction,
+    error_message: &str,
+) {
+    if let Err(why) = outbound::send(&ctx.http, || {
+        component.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::ChannelMessageWithSource)
+                .interaction_response_data(|message| message.content(error_message).ephemeral(true))
+        })
+    })
+    .await
+    {
+        log::error!("Cannot respond to component: {}", why);
+    }
+}
+
+async fn respond_to_modal_with_error(
+    ctx: &Context,
+    modal: &ModalSubmitInteraction,
+    error_message: &str,
+) {
+    if let Err(why) = outbound::send(&ctx.http, || {
+        modal.create_interaction_response(&ctx.http, |response| {
+            response
+                .kind(InteractionResponseType::ChannelMessageWithSource)
+                .interaction_response_data(|message| message.content(error_message).ephemeral(true))
+        })
+    })
+    .await
+    {
+        log::error!("Cannot respond to modal: {}", why);
+    }
+}
 
-    println!("Registered the following slash commands: {:#?}", commands);
+async fn register_commands(ctx: &Context, force: bool) -> Result<(), Box<dyn std::error::Error>> {
+    let tenant = tenant::tenant(ctx).await?;
+    let guild_id = tenant.guild_id.ok_or_else(|| {
+        format!(
+            "No guild is set to register the {} bot's commands in",
+            tenant.label()
+        )
+    })?;
+    registration::sync(ctx, &tenant.partition, guild_id, force).await?;
     Ok(())
 }
@@ -0,0 +1,254 @@
+use crate::{
+    breaker::BreakerState, outbound, owner_id, rates, roblox, settings::SettingsKey, store,
+};
+use serenity::{
+    builder::CreateEmbed,
+    model::id::{ChannelId, UserId},
+    prelude::*,
+};
+use std::{
+    collections::{HashMap, HashSet, VecDeque},
+    sync::Arc,
+    time::Duration,
+};
+
+/// How far back `/metrics` looks.
+pub const WINDOW_SECS: u64 = 3600;
+/// Samples kept per command, so a busy command can't grow without bound.
+const MAX_SAMPLES: usize = 5000;
+/// How often commands are checked against the alert thresholds.
+const CHECK_INTERVAL_SECS: u64 = 60;
+
+/// One handled command.
+struct Sample {
+    at: u64,
+    millis: u64,
+    ok: bool,
+}
+
+/// How a command has been doing over a period.
+pub struct CommandStats {
+    pub command: String,
+    pub calls: usize,
+    pub errors: usize,
+    pub p50_ms: u64,
+    pub p95_ms: u64,
+    pub p99_ms: u64,
+}
+
+impl CommandStats {
+    /// Share of calls that failed, in percent.
+    pub fn error_rate(&self) -> f64 {
+        self.errors as f64 * 100.0 / self.calls.max(1) as f64
+    }
+}
+
+/// Handling times and outcomes of recent commands, by command name. They only
+/// live in memory, so a restart starts the figures afresh.
+#[derive(Default)]
+pub struct Metrics {
+    samples: Mutex<HashMap<String, VecDeque<Sample>>>,
+    /// Commands an alert has been sent for and that haven't recovered yet.
+    alerting: Mutex<HashSet<String>>,
+}
+
+impl Metrics {
+    pub async fn record(&self, command: &str, elapsed: Duration, ok: bool) {
+        let now = store::now();
+        let mut samples = self.samples.lock().await;
+        let samples = samples.entry(command.to_string()).or_default();
+        while samples.len() >= MAX_SAMPLES
+            || samples
+                .front()
+                .is_some_and(|sample| sample.at + WINDOW_SECS < now)
+        {
+            samples.pop_front();
+        }
+        samples.push_back(Sample {
+            at: now,
+            millis: elapsed.as_millis() as u64,
+            ok,
+        });
+    }
+
+    /// Stats for every command handled since `since`, busiest first.
+    pub async fn stats(&self, since: u64) -> Vec<CommandStats> {
+        let samples = self.samples.lock().await;
+        let mut stats: Vec<_> = samples
+            .iter()
+            .filter_map(|(command, samples)| {
+                let recent: Vec<_> = samples.iter().filter(|sample| sample.at >= since).collect();
+                if recent.is_empty() {
+                    return None;
+                }
+                let mut millis: Vec<_> = recent.iter().map(|sample| sample.millis).collect();
+                millis.sort_unstable();
+                Some(CommandStats {
+                    command: command.clone(),
+                    calls: recent.len(),
+                    errors: recent.iter().filter(|sample| !sample.ok).count(),
+                    p50_ms: percentile(&millis, 50.0),
+                    p95_ms: percentile(&millis, 95.0),
+                    p99_ms: percentile(&millis, 99.0),
+                })
+            })
+            .collect();
+        stats.sort_by(|a, b| b.calls.cmp(&a.calls).then(a.command.cmp(&b.command)));
+        stats
+    }
+}
+
+/// The state of the circuit breaker around each upstream API, by API.
+pub async fn breakers(ctx: &Context) -> Result<Vec<(&'static str, BreakerState)>, String> {
+    Ok(vec![
+        ("Exchange Rates", rates::service(ctx).await?.breaker_state()),
+        ("Roblox", roblox::breaker_state()),
+    ])
+}
+
+/// The nearest-rank `percent`th percentile of `sorted`.
+fn percentile(sorted: &[u64], percent: f64) -> u64 {
+    if sorted.is_empty() {
+        return 0;
+    }
+    let rank = (percent / 100.0 * sorted.len() as f64).ceil() as usize;
+    sorted[rank.clamp(1, sorted.len()) - 1]
+}
+
+/// e.g. `850 ms` or `2.4 s`.
+pub fn format_millis(millis: u64) -> String {
+    if millis < 1000 {
+        format!("{} ms", millis)
+    } else {
+        format!("{:.1} s", millis as f64 / 1000.0)
+    }
+}
+
+/// Starts checking commands against the alert thresholds every minute, once
+/// per bot.
+pub fn start(ctx: Context) {
+    tokio::spawn(async move {
+        loop {
+            tokio::time::sleep(Duration::from_secs(CHECK_INTERVAL_SECS)).await;
+            if let Err(error) = check(&ctx).await {
+                log::error!("Error checking command metrics: {}", error);
+            }
+        }
+    });
+}
+
+/// Alerts the owner about commands that have been slow or failing over the
+/// whole alert window, and says when they recover. Each problem is reported
+/// once rather than every minute.
+async fn check(ctx: &Context) -> Result<(), String> {
+    let (settings, metrics) = {
+        let data = ctx.data.read().await;
+        match (data.get::<SettingsKey>(), data.get::<MetricsKey>()) {
+            (Some(settings), Some(metrics)) => (settings.clone(), metrics.clone()),
+            _ => return Ok(()),
+        }
+    };
+    let alerts = settings.read().await.alerts.clone();
+    if !alerts.enabled {
+        return Ok(());
+    }
+
+    let since = store::now().saturating_sub(alerts.window_minutes * 60);
+    let stats = metrics.stats(since).await;
+    let mut alerting = metrics.alerting.lock().await;
+    let mut embeds = Vec::new();
+    for stats in &stats {
+        let slow = stats.p95_ms > alerts.p95_ms;
+        let failing = stats.error_rate() > alerts.error_rate_percent;
+        let unhealthy = stats.calls >= alerts.min_calls && (slow || failing);
+        if unhealthy && alerting.insert(stats.command.clone()) {
+            let mut problems = Vec::new();
+            if slow {
+                problems.push(format!(
+                    "P95 of {} (threshold {})",
+                    format_millis(stats.p95_ms),
+                    format_millis(alerts.p95_ms)
+                ));
+            }
+            if failing {
+                problems.push(format!(
+                    "{:.0}% of calls failing (threshold {:.0}%)",
+                    stats.error_rate(),
+                    alerts.error_rate_percent
+                ));
+            }
+            embeds.push(
+                CreateEmbed::default()
+                    .title(format!("/{} is struggling", stats.command))
+                    .description(format!(
+                        "Over the last {} minutes: {}.",
+                        alerts.window_minutes,
+                        problems.join(", ")
+                    ))
+                    .field("Calls", stats.calls, true)
+                    .field("P50", format_millis(stats.p50_ms), true)
+                    .field("P99", format_millis(stats.p99_ms), true)
+                    .color(0xFF0000)
+                    .clone(),
+            );
+        } else if !unhealthy && alerting.remove(&stats.command) {
+            embeds.push(
+                CreateEmbed::default()
+                    .title(format!("/{} has recovered", stats.command))
+                    .description(format!(
+                        "Over the last {} minutes: P95 of {}, {:.0}% of {} calls failing.",
+                        alerts.window_minutes,
+                        format_millis(stats.p95_ms),
+                        stats.error_rate(),
+                        stats.calls
+                    ))
+                    .color(0x0096FF)
+                    .clone(),
+            );
+        }
+    }
+    // Commands nobody has used since have nothing left to alert about.
+    alerting.retain(|command| stats.iter().any(|stats| &stats.command == command));
+    drop(alerting);
+    if embeds.is_empty() {
+        return Ok(());
+    }
+
+    let channel_id = match (alerts.channel_id, owner_id()) {
+        (Some(channel_id), _) => ChannelId(channel_id),
+        (None, Some(owner_id)) => {
+            UserId(owner_id)
+                .create_dm_channel(&ctx.http)
+                .await
+                .map_err(|e| format!("Error opening DM with the owner: {:?}", e))?
+                .id
+        }
+        (None, None) => {
+            log::warn!("Command alert skipped: set alerts.channel_id or OWNER_ID");
+            return Ok(());
+        }
+    };
+    for embed in embeds {
+        outbound::send(&ctx.http, || {
+            channel_id.send_message(&ctx.http, |message| message.set_embed(embed.clone()))
+        })
+        .await
+        .map_err(|e| format!("Error sending command alert: {:?}", e))?;
+    }
+    Ok(())
+}
+
+pub struct MetricsKey;
+
+impl TypeMapKey for MetricsKey {
+    type Value = Arc<Metrics>;
+}
+
+pub async fn metrics(ctx: &Context) -> Result<Arc<Metrics>, String> {
+    ctx.data
+        .read()
+        .await
+        .get::<MetricsKey>()
+        .cloned()
+        .ok_or_else(|| "Command metrics unavailable".to_string())
+}
@@ -0,0 +1,333 @@
+use crate::{backup::Archive, store, tenant::Tenant, trace};
+use ring::{digest, hmac};
+use std::{env, sync::Arc, sync::OnceLock, time::Duration};
+
+/// How often the bucket is checked for a due backup.
+const CHECK_INTERVAL: Duration = Duration::from_secs(3600);
+const DEFAULT_INTERVAL_HOURS: u64 = 24;
+const DEFAULT_KEEP: usize = 14;
+const DEFAULT_REGION: &str = "us-east-1";
+const DEFAULT_PREFIX: &str = "backups";
+const REQUEST_TIMEOUT: Duration = Duration::from_secs(120);
+
+fn client() -> &'static reqwest::Client {
+    static CLIENT: OnceLock<reqwest::Client> = OnceLock::new();
+    CLIENT.get_or_init(|| {
+        reqwest::Client::builder()
+            .timeout(REQUEST_TIMEOUT)
+            .build()
+            .unwrap_or_default()
+    })
+}
+
+/// An S3-compatible bucket (AWS S3, Cloudflare R2, Backblaze B2, MinIO…)
+/// addressed path-style, with requests signed using AWS Signature Version 4.
+struct Bucket {
+    /// e.g. `https://s3.eu-west-2.amazonaws.com`.
+    endpoint: String,
+    name: String,
+    region: String,
+    access_key_id: String,
+    secret_access_key: String,
+}
+
+/// Where backups go and how many are kept, from `BACKUP_S3_*`.
+struct Config {
+    bucket: Bucket,
+    /// Key prefix, under which each bot's backups get a folder.
+    prefix: String,
+    interval_secs: u64,
+    keep: usize,
+}
+
+impl Config {
+    /// `None` when `BACKUP_S3_ENDPOINT` is unset, turning offsite backups off.
+    fn from_env() -> Result<Option<Self>, String> {
+        let endpoint = match env::var("BACKUP_S3_ENDPOINT") {
+            Ok(endpoint) if !endpoint.trim().is_empty() => endpoint,
+            _ => return Ok(None),
+        };
+        let required = |name: &str| {
+            env::var(name)
+                .ok()
+                .filter(|value| !value.trim().is_empty())
+                .ok_or_else(|| format!("{} must be set for offsite backups", name))
+        };
+        let number = |name: &str, default: u64| match env::var(name) {
+            Ok(value) => value
+                .trim()
+                .parse::<u64>()
+                .ok()
+                .filter(|value| *value > 0)
+                .ok_or_else(|| format!("{} must be a whole number above 0", name)),
+            Err(_) => Ok(default),
+        };
+        Ok(Some(Self {
+            bucket: Bucket {
+                endpoint: endpoint.trim().trim_end_matches('/').to_string(),
+                name: required("BACKUP_S3_BUCKET")?,
+                region: env::var("BACKUP_S3_REGION").unwrap_or_else(|_| DEFAULT_REGION.to_string()),
+                access_key_id: required("BACKUP_S3_ACCESS_KEY_ID")?,
+                secret_access_key: required("BACKUP_S3_SECRET_ACCESS_KEY")?,
+            },
+            prefix: env::var("BACKUP_S3_PREFIX")
+                .map(|prefix| prefix.trim_matches('/').to_string())
+                .unwrap_or_else(|_| DEFAULT_PREFIX.to_string()),
+            interval_secs: number("BACKUP_INTERVAL_HOURS", DEFAULT_INTERVAL_HOURS)? * 3600,
+            keep: number("BACKUP_KEEP", DEFAULT_KEEP as u64)? as usize,
+        }))
+    }
+}
+
+/// Starts uploading an encrypted backup of `tenant`'s data to the bucket in
+/// `BACKUP_S3_ENDPOINT` every `BACKUP_INTERVAL_HOURS`, deleting all but the
+/// newest `BACKUP_KEEP`. The last upload is read back from the bucket, so
+/// restarts don't delay or repeat one. Call it once per bot; instances
+/// sharing its cache take turns.
+pub fn start(tenant: Arc<Tenant>) {
+    let config = match Config::from_env() {
+        Ok(Some(config)) => config,
+        Ok(None) => return,
+        Err(error) => {
+            log::error!("Offsite backups are off: {}", error);
+            return;
+        }
+    };
+    if env::var("BACKUP_PASSPHRASE").map_or(true, |passphrase| passphrase.is_empty()) {
+        log::error!("Offsite backups are off: BACKUP_PASSPHRASE is not set");
+        return;
+    }
+    let folder = match config.prefix.as_str() {
+        "" => tenant.label().to_string(),
+        prefix => format!("{}/{}", prefix, tenant.label()),
+    };
+    log::info!(
+        "Backing up to {}/{}/{} every {} hours",
+        config.bucket.endpoint,
+        config.bucket.name,
+        folder,
+        config.interval_secs / 3600
+    );
+
+    tokio::spawn(async move {
+        loop {
+            if tenant
+                .cache
+                .lease("offsite-backup", CHECK_INTERVAL)
+                .await
+                .is_some()
+            {
+                if let Err(error) = run(&config, &tenant, &folder).await {
+                    log::error!("Error uploading offsite backup: {}", error);
+                }
+            }
+            tokio::time::sleep(CHECK_INTERVAL).await;
+        }
+    });
+}
+
+/// Uploads a backup if the newest in `folder` is older than the interval,
+/// then prunes old ones.
+async fn run(config: &Config, tenant: &Tenant, folder: &str) -> Result<(), String> {
+    let mut keys = config.bucket.list(&format!("{}/", folder)).await?;
+    // Named by timestamp, so sorting by name sorts oldest first.
+    keys.retain(|key| key.ends_with(".rcbackup"));
+    keys.sort();
+    let last_upload = keys.last().and_then(|key| backup_time(key)).unwrap_or(0);
+    if store::now() < last_upload + config.interval_secs {
+        return Ok(());
+    }
+
+    let archive = Archive::create(&tenant.name, &tenant.partition)?;
+    let key = format!("{}/backup-{}.rcbackup", folder, archive.created_at);
+    config.bucket.put(&key, archive.seal()?).await?;
+    log::info!(
+        "Uploaded offsite backup of {} documents to {}",
+        archive.file_count(),
+        key
+    );
+    keys.push(key);
+
+    let excess = keys.len().saturating_sub(config.keep);
+    for key in &keys[..excess] {
+        if let Err(error) = config.bucket.delete(key).await {
+            log::error!("Error deleting old offsite backup {}: {}", key, error);
+        }
+    }
+    Ok(())
+}
+
+/// The timestamp in a key like `backups/default/backup-1700000000.rcbackup`.
+fn backup_time(key: &str) -> Option<u64> {
+    key.rsplit('/')
+        .next()?
+        .strip_prefix("backup-")?
+        .strip_suffix(".rcbackup")?
+        .parse()
+        .ok()
+}
+
+impl Bucket {
+    async fn put(&self, key: &str, body: Vec<u8>) -> Result<(), String> {
+        self.send(reqwest::Method::PUT, key, &[], body).await?;
+        Ok(())
+    }
+
+    async fn delete(&self, key: &str) -> Result<(), String> {
+        self.send(reqwest::Method::DELETE, key, &[], Vec::new())
+            .await?;
+        Ok(())
+    }
+
+    /// Keys starting with `prefix`. Only the first 1,000 are listed, which
+    /// covers any sensible retention.
+    async fn list(&self, prefix: &str) -> Result<Vec<String>, String> {
+        let body = self
+            .send(
+                reqwest::Method::GET,
+                "",
+                &[("list-type", "2"), ("prefix", prefix)],
+                Vec::new(),
+            )
+            .await?;
+        // The reply is small, flat XML; each object's key is in a <Key> tag.
+        Ok(body
+            .split("<Key>")
+            .skip(1)
+            .filter_map(|rest| rest.split_once("</Key>"))
+            .map(|(key, _)| unescape_xml(key))
+            .collect())
+    }
+
+    /// Sends a signed request for `key` (the bucket itself when empty),
+    /// returning the response body.
+    async fn send(
+        &self,
+        method: reqwest::Method,
+        key: &str,
+        query: &[(&str, &str)],
+        body: Vec<u8>,
+    ) -> Result<String, String> {
+        let path = if key.is_empty() {
+            format!("/{}", uri_encode(&self.name, false))
+        } else {
+            format!(
+                "/{}/{}",
+                uri_encode(&self.name, false),
+                uri_encode(key, true)
+            )
+        };
+        let mut query: Vec<String> = query
+            .iter()
+            .map(|(name, value)| {
+                format!("{}={}", uri_encode(name, false), uri_encode(value, false))
+            })
+            .collect();
+        query.sort();
+        let query = query.join("&");
+        let url = format!(
+            "{}{}{}{}",
+            self.endpoint,
+            path,
+            if query.is_empty() { "" } else { "?" },
+            query
+        );
+        let host = reqwest::Url::parse(&url)
+            .ok()
+            .and_then(|url| {
+                let host = url.host_str()?.to_string();
+                Some(match url.port() {
+                    Some(port) => format!("{}:{}", host, port),
+                    None => host,
+                })
+            })
+            .ok_or_else(|| format!("{} is not a valid endpoint", self.endpoint))?;
+
+        let now = chrono::Utc::now();
+        let timestamp = now.format("%Y%m%dT%H%M%SZ").to_string();
+        let date = now.format("%Y%m%d").to_string();
+        let payload_hash = hex(digest::digest(&digest::SHA256, &body).as_ref());
+        let signed_headers = "host;x-amz-content-sha256;x-amz-date";
+        let canonical_request = format!(
+            "{}\n{}\n{}\nhost:{}\nx-amz-content-sha256:{}\nx-amz-date:{}\n\n{}\n{}",
+            method, path, query, host, payload_hash, timestamp, signed_headers, payload_hash
+        );
+        let scope = format!("{}/{}/s3/aws4_request", date, self.region);
+        let string_to_sign = format!(
+            "AWS4-HMAC-SHA256\n{}\n{}\n{}",
+            timestamp,
+            scope,
+            hex(digest::digest(&digest::SHA256, canonical_request.as_bytes()).as_ref())
+        );
+        let signing_key = [date.as_str(), &self.region, "s3", "aws4_request"]
+            .iter()
+            .fold(
+                format!("AWS4{}", self.secret_access_key).into_bytes(),
+                |key, part| sign(&key, part.as_bytes()),
+            );
+        let authorization = format!(
+            "AWS4-HMAC-SHA256 Credential={}/{}, SignedHeaders={}, Signature={}",
+            self.access_key_id,
+            scope,
+            signed_headers,
+            hex(&sign(&signing_key, string_to_sign.as_bytes()))
+        );
+
+        let request = client()
+            .request(method, &url)
+            .header("x-amz-content-sha256", &payload_hash)
+            .header("x-amz-date", &timestamp)
+            .header("Authorization", authorization)
+            .body(body);
+        let response = trace::send("s3", vec![], request)
+            .await
+            .map_err(|e| format!("Error contacting {}: {}", self.endpoint, e))?;
+        let status = response.status();
+        let text = response
+            .text()
+            .await
+            .map_err(|e| format!("Error reading reply from {}: {}", self.endpoint, e))?;
+        if !status.is_success() {
+            let code = text
+                .split_once("<Code>")
+                .and_then(|(_, rest)| rest.split_once("</Code>"))
+                .map_or("", |(code, _)| code);
+            return Err(format!("Bucket returned {} {}", status, code));
+        }
+        Ok(text)
+    }
+}
+
+fn sign(key: &[u8], data: &[u8]) -> Vec<u8> {
+    hmac::sign(&hmac::Key::new(hmac::HMAC_SHA256, key), data)
+        .as_ref()
+        .to_vec()
+}
+
+fn hex(bytes: &[u8]) -> String {
+    bytes.iter().map(|byte| format!("{:02x}", byte)).collect()
+}
+
+/// Percent-encodes everything but unreserved characters, and `/` in keys, as
+/// Signature Version 4 requires.
+fn uri_encode(value: &str, keep_slash: bool) -> String {
+    value
+        .bytes()
+        .map(|byte| match byte {
+            b'A'..=b'Z' | b'a'..=b'z' | b'0'..=b'9' | b'-' | b'_' | b'.' | b'~' => {
+                (byte as char).to_string()
+            }
+            b'/' if keep_slash => "/".to_string(),
+            _ => format!("%{:02X}", byte),
+        })
+        .collect()
+}
+
+fn unescape_xml(value: &str) -> String {
+    value
+        .replace("&lt;", "<")
+        .replace("&gt;", ">")
+        .replace("&quot;", "\"")
+        .replace("&apos;", "'")
+        .replace("&amp;", "&")
+}
@@ -0,0 +1,1352 @@
+use crate::{
+    cache::Cache,
+    disputes::Decision,
+    period::Period,
+    pricing::DeliveryMethod,
+    rates::RateSnapshot,
+    store::{self, JsonStore, Partition},
+};
+use chrono::{DateTime, Datelike, Weekday};
+use serde::{Deserialize, Serialize};
+use serenity::prelude::*;
+use std::{collections::HashMap, sync::Arc};
+
+const ORDERS_FILE: &str = "orders.json";
+/// Half a penny: amounts closer than this are the same.
+const MONEY_EPSILON: f64 = 0.005;
+/// How long a quote the buyer hasn't acted on is kept: past the furthest
+/// reminder a quote can have, 7 days ahead.
+const QUOTE_TTL_SECS: u64 = 14 * 24 * 60 * 60;
+
+/// Where an order is in its lifecycle. Orders only move along the arrows in
+/// [`OrderStatus::next`]:
+///
+/// quoted → pending payment → paid → delivering → delivered → completed, with
+/// cancellation before payment and refunds after it.
+#[derive(Serialize, Deserialize, Clone, Copy, PartialEq, Eq, Debug)]
+#[serde(rename_all = "snake_case")]
+pub enum OrderStatus {
+    Quoted,
+    PendingPayment,
+    Paid,
+    Delivering,
+    Delivered,
+    Completed,
+    Cancelled,
+    Refunded,
+}
+
+impl OrderStatus {
+    pub fn parse(value: &str) -> Option<Self> {
+        match value.to_lowercase().replace([' ', '-'], "_").as_str() {
+            "quoted" => Some(OrderStatus::Quoted),
+            "pending_payment" => Some(OrderStatus::PendingPayment),
+            "paid" => Some(OrderStatus::Paid),
+            "delivering" => Some(OrderStatus::Delivering),
+            "delivered" => Some(OrderStatus::Delivered),
+            "completed" => Some(OrderStatus::Completed),
+            "cancelled" | "canceled" => Some(OrderStatus::Cancelled),
+            "refunded" => Some(OrderStatus::Refunded),
+            _ => None,
+        }
+    }
+
+    pub fn label(self) -> &'static str {
+        match self {
+            OrderStatus::Quoted => "Quoted",
+            OrderStatus::PendingPayment => "Pending Payment",
+            OrderStatus::Paid => "Paid",
+            OrderStatus::Delivering => "Delivering",
+            OrderStatus::Delivered => "Delivered",
+            OrderStatus::Completed => "Completed",
+            OrderStatus::Cancelled => "Cancelled",
+            OrderStatus::Refunded => "Refunded",
+        }
+    }
+
+    /// The statuses an order in this status may move to.
+    pub fn next(self) -> &'static [OrderStatus] {
+        use OrderStatus::*;
+        match self {
+            Quoted => &[PendingPayment, Cancelled],
+            PendingPayment => &[Paid, Cancelled],
+            Paid => &[Delivering, Delivered, Refunded],
+            Delivering => &[Delivered, Refunded],
+            Delivered => &[Completed, Refunded],
+            Completed => &[Refunded],
+            Cancelled | Refunded => &[],
+        }
+    }
+
+    pub fn can_become(self, status: OrderStatus) -> bool {
+        self.next().contains(&status)
+    }
+
+    /// Whether the buyer has paid and the sale stands, so the order counts
+    /// towards sales figures. Quotes, unpaid and cancelled orders don't, nor do
+    /// fully refunded ones.
+    pub fn is_paid(self) -> bool {
+        matches!(
+            self,
+            OrderStatus::Paid
+                | OrderStatus::Delivering
+                | OrderStatus::Delivered
+                | OrderStatus::Completed
+        )
+    }
+
+    /// Whether the Robux have been handed over or the order called off, so the
+    /// buyer has nothing left to do.
+    pub fn is_closed(self) -> bool {
+        matches!(
+            self,
+            OrderStatus::Delivered
+                | OrderStatus::Completed
+                | OrderStatus::Cancelled
+                | OrderStatus::Refunded
+        )
+    }
+}
+
+/// An order entering a status.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct StatusChange {
+    pub status: OrderStatus,
+    /// Unix timestamp of the change.
+    pub at: u64,
+    /// Who made the change, unset for the bot itself.
+    #[serde(default)]
+    pub by: Option<u64>,
+}
+
+/// The buyer's rating of a completed order.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct Feedback {
+    /// 1 to 5.
+    pub stars: u8,
+    #[serde(default)]
+    pub comment: Option<String>,
+    pub given_at: u64,
+}
+
+/// Money given back to the buyer.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct Refund {
+    pub amount_gbp: f64,
+    #[serde(default)]
+    pub reason: Option<String>,
+    /// Unix timestamp of the refund, and who recorded it.
+    pub at: u64,
+    pub by: u64,
+}
+
+/// A payment received for an order, from a provider's notification or
+/// recorded by staff.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct Payment {
+    /// Who handled the money, e.g. `PayPal`.
+    pub provider: String,
+    /// The provider's ID for the payment, e.g. a PayPal transaction ID.
+    pub reference: String,
+    pub amount: f64,
+    pub currency: String,
+    pub recorded_at: u64,
+    /// Staff member who recorded it, when it didn't come in automatically.
+    #[serde(default)]
+    pub recorded_by: Option<u64>,
+}
+
+impl Payment {
+    /// e.g. `35.00 GBP via PayPal (5TY05013RG002845M)`.
+    pub fn describe(&self) -> String {
+        format!(
+            "{:.2} {} via {} ({})",
+            self.amount, self.currency, self.provider, self.reference
+        )
+    }
+}
+
+/// A hosted checkout page the bot created for the buyer to pay through.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct PaymentLink {
+    /// e.g. `Stripe`.
+    pub provider: String,
+    /// The provider's ID for the page, e.g. a Checkout Session ID.
+    pub id: String,
+    pub url: String,
+    pub created_at: u64,
+}
+
+/// A ping about an open quote, so it isn't forgotten before it's paid.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct Reminder {
+    /// Unix timestamp of when to send it.
+    pub at: u64,
+    /// Where the buyer and staff are pinged.
+    pub channel_id: u64,
+    pub set_by: u64,
+}
+
+/// A screenshot the buyer sent as proof they paid.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct PaymentProof {
+    pub filename: String,
+    /// Discord's link to the attachment.
+    pub url: String,
+    /// The copy kept in object storage, when it is configured.
+    #[serde(default)]
+    pub stored_url: Option<String>,
+    pub uploaded_at: u64,
+}
+
+/// Why an order looks like a chargeback risk, and the admin who cleared it
+/// for delivery.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct RiskReview {
+    pub reasons: Vec<String>,
+    pub flagged_at: u64,
+    #[serde(default)]
+    pub confirmed_by: Option<u64>,
+    #[serde(default)]
+    pub confirmed_at: Option<u64>,
+}
+
+/// Payment a middleman holds for an order until its release conditions are
+/// met.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct Escrow {
+    pub amount: f64,
+    /// e.g. `GBP`.
+    pub currency: String,
+    pub holder_id: u64,
+    /// What has to happen before the funds are released, e.g. "buyer confirms
+    /// the Robux arrived".
+    pub conditions: String,
+    /// Unix timestamp of when the hold was recorded, and by whom.
+    pub held_at: u64,
+    pub recorded_by: u64,
+    #[serde(default)]
+    pub released_at: Option<u64>,
+    #[serde(default)]
+    pub released_by: Option<u64>,
+    /// Who the funds went to or why, e.g. "paid out to the seller".
+    #[serde(default)]
+    pub release_note: Option<String>,
+}
+
+impl Escrow {
+    pub fn is_held(&self) -> bool {
+        self.released_at.is_none()
+    }
+}
+
+/// A price calculation made for a buyer, with the rates it was based on.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct Order {
+    pub id: u64,
+    #[serde(default)]
+    pub guild_id: Option<u64>,
+    pub buyer_id: u64,
+    pub buyer_name: String,
+    /// The buyer's linked Roblox account when the order was made.
+    #[serde(default)]
+    pub buyer_roblox: Option<String>,
+    /// Robux the buyer receives.
+    pub robux: u64,
+    /// Price the gamepass has to be listed at to deliver `robux`, or 0 when it
+    /// is delivered some other way.
+    pub gamepass_price: u64,
+    pub after_tax: bool,
+    /// Robux withheld by Roblox's marketplace fee.
+    pub fee_robux: u64,
+    pub robux_to_gbp_rate: f64,
+    pub gbp_to_usd_rate: f64,
+    #[serde(default)]
+    pub fx_margin_percent: f64,
+    /// Role-based discount applied before tax.
+    #[serde(default)]
+    pub discount_percent: f64,
+    /// Consumption tax included in `total_gbp`.
+    #[serde(default)]
+    pub tax_gbp: f64,
+    pub total_gbp: f64,
+    pub total_usd: f64,
+    pub status: OrderStatus,
+    /// Seller profile whose rate card priced the order.
+    #[serde(default)]
+    pub seller: Option<String>,
+    #[serde(default)]
+    pub method: DeliveryMethod,
+    /// Payment method whose fee the total includes.
+    #[serde(default)]
+    pub payment_method: Option<String>,
+    /// The buyer's gamepass, once they have given it.
+    #[serde(default)]
+    pub gamepass_id: Option<u64>,
+    /// The gamepass's price on Roblox when it was last checked.
+    #[serde(default)]
+    pub listed_price: Option<u64>,
+    /// Unix timestamp of when the order was created.
+    pub created_at: u64,
+    /// Every status the order has been in, oldest first.
+    #[serde(default)]
+    pub history: Vec<StatusChange>,
+    /// How delivery was confirmed, e.g. the Roblox account found owning the
+    /// gamepass.
+    #[serde(default)]
+    pub delivery_proof: Option<String>,
+    /// Refunds given on the order, oldest first.
+    #[serde(default)]
+    pub refunds: Vec<Refund>,
+    #[serde(default)]
+    pub feedback: Option<Feedback>,
+    /// The order's latest dispute, and how it was decided once it has been.
+    #[serde(default)]
+    pub dispute_id: Option<u64>,
+    #[serde(default)]
+    pub dispute_decision: Option<Decision>,
+    /// Funds held by a middleman for the order, if it goes through one.
+    #[serde(default)]
+    pub escrow: Option<Escrow>,
+    /// Unix timestamp of when staff were reminded the delivery deadline is
+    /// near.
+    #[serde(default)]
+    pub sla_reminded_at: Option<u64>,
+    /// Unix timestamp of when the missed delivery deadline was escalated.
+    #[serde(default)]
+    pub sla_escalated_at: Option<u64>,
+    /// Set when the order is high-risk, e.g. a new account's large first
+    /// purchase.
+    #[serde(default)]
+    pub risk: Option<RiskReview>,
+    /// Screenshots the buyer sent as proof of payment, oldest first.
+    #[serde(default)]
+    pub payment_proofs: Vec<PaymentProof>,
+    /// The payment that paid for the order, once it has been received.
+    #[serde(default)]
+    pub payment: Option<Payment>,
+    /// The latest checkout page sent to the buyer, if any.
+    #[serde(default)]
+    pub payment_link: Option<PaymentLink>,
+    /// The exchange rates the order was priced with, GBP/USD first.
+    #[serde(default)]
+    pub rate_snapshots: Vec<RateSnapshot>,
+    /// Staff member handling the order.
+    #[serde(default)]
+    pub claimed_by: Option<u64>,
+    /// Unix timestamp of when `claimed_by` took the order.
+    #[serde(default)]
+    pub claimed_at: Option<u64>,
+    /// A follow-up set on the quote with `/remindme`, until it is sent.
+    #[serde(default)]
+    pub reminder: Option<Reminder>,
+}
+
+impl Order {
+    /// Unix timestamp of when the order last entered `status`.
+    pub fn entered_at(&self, status: OrderStatus) -> Option<u64> {
+        self.history
+            .iter()
+            .rev()
+            .find(|change| change.status == status)
+            .map(|change| change.at)
+    }
+
+    /// GBP refunded so far.
+    pub fn refunded_gbp(&self) -> f64 {
+        self.refunds.iter().map(|refund| refund.amount_gbp).sum()
+    }
+
+    /// `total_gbp` less refunds.
+    pub fn net_gbp(&self) -> f64 {
+        self.total_gbp - self.refunded_gbp()
+    }
+
+    /// `total_usd` less refunds, at the order's exchange rate.
+    pub fn net_usd(&self) -> f64 {
+        if self.total_gbp > 0.0 {
+            self.total_usd * self.net_gbp() / self.total_gbp
+        } else {
+            self.total_usd
+        }
+    }
+
+    /// Whether staff still have work to do on the order: it has been placed
+    /// and isn't closed.
+    pub fn is_open(&self) -> bool {
+        self.status != OrderStatus::Quoted && !self.status.is_closed()
+    }
+
+    /// Unix timestamp of the last thing done on the order: its claim or its
+    /// latest status change.
+    pub fn last_activity(&self) -> u64 {
+        self.history
+            .iter()
+            .map(|change| change.at)
+            .chain(self.claimed_at)
+            .max()
+            .unwrap_or(self.created_at)
+    }
+
+    /// Whether this is a quote untouched for longer than `QUOTE_TTL_SECS` at
+    /// `now`, with no reminder still to come.
+    fn is_stale_quote(&self, now: u64) -> bool {
+        self.status == OrderStatus::Quoted
+            && now.saturating_sub(self.last_activity()) > QUOTE_TTL_SECS
+            && self
+                .reminder
+                .as_ref()
+                .map_or(true, |reminder| reminder.at <= now)
+    }
+
+    /// Unix timestamp the order has to be delivered by, `delivery_secs` after
+    /// it was paid, while it is waiting for delivery.
+    pub fn delivery_deadline(&self, delivery_secs: u64) -> Option<u64> {
+        match self.status {
+            OrderStatus::Paid | OrderStatus::Delivering => self
+                .entered_at(OrderStatus::Paid)
+                .map(|paid_at| paid_at + delivery_secs),
+            _ => None,
+        }
+    }
+
+    /// Whether the order is high-risk and no admin has confirmed it yet, so
+    /// it mustn't be delivered.
+    pub fn awaiting_risk_confirmation(&self) -> bool {
+        self.risk
+            .as_ref()
+            .map_or(false, |risk| risk.confirmed_by.is_none())
+    }
+
+    /// Why `payment` doesn't pay for the order, if it doesn't: it is in a
+    /// currency the order wasn't priced in, or for a different amount to the
+    /// penny.
+    pub fn payment_mismatch(&self, payment: &Payment) -> Option<String> {
+        let expected = match payment.currency.as_str() {
+            "GBP" => self.total_gbp,
+            "USD" => self.total_usd,
+            other => return Some(format!("paid in {}, not GBP or USD", other)),
+        };
+        if (expected * 100.0).round() != (payment.amount * 100.0).round() {
+            return Some(format!(
+                "paid {:.2} {} but the order is {:.2} {}",
+                payment.amount, payment.currency, expected, payment.currency
+            ));
+        }
+        None
+    }
+
+    /// The reference the buyer should put on a manual payment.
+    pub fn payment_reference(&self) -> String {
+        payment_reference(self.id)
+    }
+
+    /// Marks the order high-risk for `reasons`. New reasons have to be
+    /// confirmed again even if an admin confirmed the earlier ones.
+    pub fn add_risk(&mut self, reasons: Vec<String>) {
+        let risk = self.risk.get_or_insert_with(|| RiskReview {
+            reasons: Vec::new(),
+            flagged_at: store::now(),
+            confirmed_by: None,
+            confirmed_at: None,
+        });
+        for reason in reasons {
+            if !risk.reasons.contains(&reason) {
+                risk.reasons.push(reason);
+                risk.confirmed_by = None;
+                risk.confirmed_at = None;
+            }
+        }
+    }
+
+    /// Whether the gamepass was last seen listed at a price other than the one
+    /// the order needs.
+    pub fn price_mismatch(&self) -> bool {
+        self.listed_price
+            .map_or(false, |listed_price| listed_price != self.gamepass_price)
+    }
+}
+
+#[derive(Serialize, Deserialize, Default)]
+struct OrderBook {
+    next_id: u64,
+    orders: Vec<Order>,
+}
+
+/// Every order and quote, persisted in the data directory. Instances sharing a
+/// cache take turns changing orders, so IDs are never handed out twice and a
+/// status change can't be made from a status another instance already left.
+pub struct OrderStore {
+    book: JsonStore<OrderBook>,
+}
+
+impl OrderStore {
+    pub fn open(partition: &Partition, cache: &Cache) -> Result<Self, String> {
+        Ok(Self {
+            book: JsonStore::open(partition, ORDERS_FILE)?.shared(cache),
+        })
+    }
+
+    /// Assigns `order` the next ID and creation time, then persists it. Every
+    /// `/price` records a quote, so stale quotes are dropped here to keep the
+    /// book from growing without bound.
+    pub async fn record(&self, order: Order) -> Result<Order, String> {
+        self.book
+            .update(|book| {
+                let created_at = store::now();
+                book.orders
+                    .retain(|order| !order.is_stale_quote(created_at));
+                book.next_id += 1;
+                let order = Order {
+                    id: book.next_id,
+                    created_at,
+                    history: vec![StatusChange {
+                        status: order.status,
+                        at: created_at,
+                        by: None,
+                    }],
+                    ..order
+                };
+                book.orders.push(order.clone());
+                order
+            })
+            .await
+    }
+
+    /// The order with `id`, if any.
+    pub async fn get(&self, id: u64) -> Option<Order> {
+        self.book
+            .read()
+            .await
+            .orders
+            .iter()
+            .find(|order| order.id == id)
+            .cloned()
+    }
+
+    /// Applies `change` to the order with `id` and persists it, returning the
+    /// updated order.
+    pub async fn update(&self, id: u64, change: impl FnOnce(&mut Order)) -> Result<Order, String> {
+        self.book
+            .update(|book| {
+                let order = book.orders.iter_mut().find(|order| order.id == id)?;
+                change(order);
+                Some(order.clone())
+            })
+            .await?
+            .ok_or_else(|| format!("Order #{} doesn't exist", id))
+    }
+
+    /// Moves the order with `id` to `status` on behalf of `by`, if its current
+    /// status allows it, applying `change` as part of the same update. Returns
+    /// the updated order and the status it left.
+    pub async fn transition(
+        &self,
+        id: u64,
+        status: OrderStatus,
+        by: Option<u64>,
+        change: impl FnOnce(&mut Order),
+    ) -> Result<(Order, OrderStatus), String> {
+        self.book
+            .update(|book| {
+                let order = book
+                    .orders
+                    .iter_mut()
+                    .find(|order| order.id == id)
+                    .ok_or_else(|| format!("Order #{} doesn't exist", id))?;
+                let from = order.status;
+                if !from.can_become(status) {
+                    return Err(format!(
+                        "Order #{} is {} and can't become {}",
+                        id,
+                        from.label(),
+                        status.label()
+                    ));
+                }
+                if order.awaiting_risk_confirmation()
+                    && matches!(status, OrderStatus::Delivering | OrderStatus::Delivered)
+                {
+                    return Err(format!(
+                        "Order #{} is high-risk and has to be confirmed with /order confirm before it is delivered",
+                        id
+                    ));
+                }
+                change(order);
+                order.status = status;
+                order.history.push(StatusChange {
+                    status,
+                    at: store::now(),
+                    by,
+                });
+                Ok((order.clone(), from))
+            })
+            .await?
+    }
+
+    /// Refunds `amount_gbp` of the order with `id`, or whatever is left of it
+    /// when `None`. Refunding everything that's left moves the order to
+    /// refunded. Returns the updated order and, if its status changed, the
+    /// status it left.
+    pub async fn refund(
+        &self,
+        id: u64,
+        amount_gbp: Option<f64>,
+        reason: Option<String>,
+        by: u64,
+    ) -> Result<(Order, Option<OrderStatus>), String> {
+        self.book
+            .update(|book| {
+                let order = book
+                    .orders
+                    .iter_mut()
+                    .find(|order| order.id == id)
+                    .ok_or_else(|| format!("Order #{} doesn't exist", id))?;
+                if !order.status.can_become(OrderStatus::Refunded) {
+                    return Err(format!(
+                        "Order #{} is {}, so there's nothing to refund",
+                        id,
+                        order.status.label().to_lowercase()
+                    ));
+                }
+                let remaining = order.net_gbp();
+                let amount_gbp = amount_gbp.unwrap_or(remaining);
+                if amount_gbp <= 0.0 {
+                    return Err("Refunds must be more than £0".to_string());
+                }
+                if amount_gbp > remaining + MONEY_EPSILON {
+                    return Err(format!(
+                        "Only £{:.2} of order #{} is left to refund",
+                        remaining, id
+                    ));
+                }
+
+                let at = store::now();
+                order.refunds.push(Refund {
+                    amount_gbp,
+                    reason,
+                    at,
+                    by,
+                });
+                let from = order.status;
+                if order.net_gbp() <= MONEY_EPSILON {
+                    order.status = OrderStatus::Refunded;
+                    order.history.push(StatusChange {
+                        status: OrderStatus::Refunded,
+                        at,
+                        by: Some(by),
+                    });
+                    Ok((order.clone(), Some(from)))
+                } else {
+                    Ok((order.clone(), None))
+                }
+            })
+            .await?
+    }
+
+    /// Assigns the open order with `id` to `staff_id`, unless another staff
+    /// member has it.
+    pub async fn claim(&self, id: u64, staff_id: u64) -> Result<Order, String> {
+        self.book
+            .update(|book| {
+                let order = book
+                    .orders
+                    .iter_mut()
+                    .find(|order| order.id == id)
+                    .ok_or_else(|| format!("Order #{} doesn't exist", id))?;
+                if !order.is_open() {
+                    return Err(format!(
+                        "Order #{} is {}, so there's nothing to claim",
+                        id,
+                        order.status.label().to_lowercase()
+                    ));
+                }
+                match order.claimed_by {
+                    Some(claimed_by) if claimed_by != staff_id => {
+                        return Err(format!(
+                            "<@{}> has already claimed order #{}",
+                            claimed_by, id
+                        ))
+                    }
+                    _ => {}
+                }
+                order.claimed_by = Some(staff_id);
+                order.claimed_at = Some(store::now());
+                Ok(order.clone())
+            })
+            .await?
+    }
+
+    /// Releases the order with `id` back to the queue.
+    pub async fn unclaim(&self, id: u64) -> Result<Order, String> {
+        self.update(id, |order| {
+            order.claimed_by = None;
+            order.claimed_at = None;
+        })
+        .await
+    }
+
+    /// Releases every claimed order that has gone longer than its guild's
+    /// `timeout_secs` without activity, returning them as they were before.
+    pub async fn unclaim_stale(
+        &self,
+        now: u64,
+        timeout_secs: impl Fn(Option<u64>) -> u64,
+    ) -> Result<Vec<Order>, String> {
+        self.book
+            .update(|book| {
+                let mut released = Vec::new();
+                for order in &mut book.orders {
+                    if order.claimed_by.is_some()
+                        && order.is_open()
+                        && now.saturating_sub(order.last_activity()) > timeout_secs(order.guild_id)
+                    {
+                        released.push(order.clone());
+                        order.claimed_by = None;
+                        order.claimed_at = None;
+                    }
+                }
+                released
+            })
+            .await
+    }
+
+    /// Quotes in any guild whose reminder is due at `now`.
+    pub async fn reminders_due(&self, now: u64) -> Vec<Order> {
+        self.book
+            .read()
+            .await
+            .orders
+            .iter()
+            .filter(|order| order.status == OrderStatus::Quoted)
+            .filter(|order| {
+                order
+                    .reminder
+                    .as_ref()
+                    .map_or(false, |reminder| reminder.at <= now)
+            })
+            .cloned()
+            .collect()
+    }
+
+    /// Orders waiting for delivery in any guild, oldest first.
+    pub async fn awaiting_delivery(&self) -> Vec<Order> {
+        self.book
+            .read()
+            .await
+            .orders
+            .iter()
+            .filter(|order| matches!(order.status, OrderStatus::Paid | OrderStatus::Delivering))
+            .cloned()
+            .collect()
+    }
+
+    /// Orders in `guild_id` whose escrowed funds haven't been released yet,
+    /// oldest first.
+    pub async fn in_escrow(&self, guild_id: u64) -> Vec<Order> {
+        self.book
+            .read()
+            .await
+            .orders
+            .iter()
+            .filter(|order| {
+                order.guild_id == Some(guild_id)
+                    && order.escrow.as_ref().map_or(false, Escrow::is_held)
+            })
+            .cloned()
+            .collect()
+    }
+
+    /// Orders in `guild_id` that staff still have work to do on, oldest
+    /// first.
+    pub async fn open_in_guild(&self, guild_id: u64) -> Vec<Order> {
+        self.book
+            .read()
+            .await
+            .orders
+            .iter()
+            .filter(|order| order.guild_id == Some(guild_id) && order.is_open())
+            .cloned()
+            .collect()
+    }
+
+    /// `buyer_id`'s open orders in `guild_id` for exactly `robux`, created at
+    /// or after `since`: likely the same order placed twice.
+    pub async fn duplicates(
+        &self,
+        guild_id: u64,
+        buyer_id: u64,
+        robux: u64,
+        since: u64,
+    ) -> Vec<Order> {
+        self.book
+            .read()
+            .await
+            .orders
+            .iter()
+            .filter(|order| {
+                order.guild_id == Some(guild_id)
+                    && order.buyer_id == buyer_id
+                    && order.robux == robux
+                    && order.created_at >= since
+                    && order.is_open()
+            })
+            .cloned()
+            .collect()
+    }
+
+    /// Orders placed by `buyer_id`, oldest first.
+    pub async fn for_buyer(&self, buyer_id: u64) -> Vec<Order> {
+        self.book
+            .read()
+            .await
+            .orders
+            .iter()
+            .filter(|order| order.buyer_id == buyer_id)
+            .cloned()
+            .collect()
+    }
+
+    /// What `buyer_id` has spent in `guild_id` in total, after refunds,
+    /// counting completed orders only.
+    pub async fn spend(&self, guild_id: u64, buyer_id: u64) -> f64 {
+        self.book
+            .read()
+            .await
+            .orders
+            .iter()
+            .filter(|order| {
+                order.guild_id == Some(guild_id)
+                    && order.buyer_id == buyer_id
+                    && order.status == OrderStatus::Completed
+            })
+            .map(Order::net_gbp)
+            .sum()
+    }
+
+    /// Orders created within `period`, oldest first.
+    pub async fn in_period(&self, period: &Period) -> Vec<Order> {
+        self.book
+            .read()
+            .await
+            .orders
+            .iter()
+            .filter(|order| period.contains(order.created_at))
+            .cloned()
+            .collect()
+    }
+}
+
+/// Characters for payment reference check codes, leaving out ones buyers mix
+/// up when typing them into a banking app (`0`/`O`, `1`/`I`).
+const CHECK_ALPHABET: &[u8] = b"23456789ABCDEFGHJKLMNPQRSTUVWXYZ";
+
+/// The reference a buyer puts on a manual payment (bank transfer, Cash App)
+/// for order `id`, e.g. `RBX-42-YN`. The two check characters catch a
+/// mistyped order number.
+pub fn payment_reference(id: u64) -> String {
+    let hash = id.wrapping_mul(0x9E37_79B9_7F4A_7C15) >> 54;
+    format!(
+        "RBX-{}-{}{}",
+        id,
+        CHECK_ALPHABET[(hash >> 5) as usize & 31] as char,
+        CHECK_ALPHABET[hash as usize & 31] as char
+    )
+}
+
+/// The order number in payment reference `text`, checking its format and
+/// check characters. Case and surrounding spaces don't matter.
+pub fn parse_payment_reference(text: &str) -> Result<u64, String> {
+    let reference = text.trim().to_uppercase();
+    let invalid = || {
+        format!(
+            "`{}` isn't a payment reference. They look like `{}`.",
+            text.trim(),
+            payment_reference(42)
+        )
+    };
+    let id = match reference.split('-').collect::<Vec<_>>().as_slice() {
+        ["RBX", id, check] if check.len() == 2 => id.parse::<u64>().map_err(|_| invalid())?,
+        _ => return Err(invalid()),
+    };
+    if payment_reference(id) != reference {
+        return Err(format!(
+            "`{}` has the wrong check characters. Check it for typos.",
+            text.trim()
+        ));
+    }
+    Ok(id)
+}
+
+/// Finds the order number in free text a buyer wrote with their payment,
+/// e.g. `RBX-42-YN`, `Order #42`, `order 42` or just `42`.
+pub fn parse_order_reference(text: &str) -> Option<u64> {
+    let words: Vec<&str> = text.split_whitespace().collect();
+    if let Some(id) = words.iter().find_map(|word| {
+        parse_payment_reference(word.trim_matches(|c: char| !c.is_alphanumeric() && c != '-')).ok()
+    }) {
+        return Some(id);
+    }
+    if let Some(id) = words
+        .iter()
+        .find_map(|word| word.strip_prefix('#'))
+        .and_then(|id| leading_number(id))
+    {
+        return Some(id);
+    }
+    if let Some(id) = words.windows(2).find_map(|pair| {
+        pair[0]
+            .trim_end_matches(|c: char| !c.is_alphanumeric())
+            .eq_ignore_ascii_case("order")
+            .then(|| leading_number(pair[1].trim_start_matches('#')))
+            .flatten()
+    }) {
+        return Some(id);
+    }
+    match words.as_slice() {
+        [word] => word.parse().ok(),
+        _ => None,
+    }
+}
+
+fn leading_number(text: &str) -> Option<u64> {
+    let digits: String = text.chars().take_while(|c| c.is_ascii_digit()).collect();
+    digits.parse().ok()
+}
+
+/// Totals over a set of orders.
+pub struct SalesSummary {
+    pub order_count: usize,
+    pub robux: u64,
+    pub fee_robux: u64,
+    pub gross_gbp: f64,
+    pub gross_usd: f64,
+    pub refunded_gbp: f64,
+    pub refunded_usd: f64,
+    /// Weekdays with at least one order, busiest first.
+    pub busiest_days: Vec<(Weekday, usize)>,
+}
+
+impl SalesSummary {
+    pub fn average_robux(&self) -> f64 {
+        if self.order_count == 0 {
+            0.0
+        } else {
+            self.robux as f64 / self.order_count as f64
+        }
+    }
+
+    pub fn net_gbp(&self) -> f64 {
+        self.gross_gbp - self.refunded_gbp
+    }
+
+    pub fn net_usd(&self) -> f64 {
+        self.gross_usd - self.refunded_usd
+    }
+}
+
+/// Totals `orders`, counting busiest days in the timezone `utc_offset` minutes
+/// east of UTC.
+pub fn summarize(orders: &[Order], utc_offset: i32) -> SalesSummary {
+    let mut per_day = [0; 7];
+    for order in orders {
+        let local = order.created_at as i64 + utc_offset as i64 * 60;
+        if let Some(created_at) = DateTime::from_timestamp(local, 0) {
+            per_day[created_at.weekday().num_days_from_monday() as usize] += 1;
+        }
+    }
+
+    let mut busiest_days: Vec<_> = (0..7)
+        .filter(|&day| per_day[day] > 0)
+        .map(|day| {
+            (
+                Weekday::try_from(day as u8).unwrap_or(Weekday::Mon),
+                per_day[day],
+            )
+        })
+        .collect();
+    busiest_days.sort_by(|a, b| b.1.cmp(&a.1));
+
+    SalesSummary {
+        order_count: orders.len(),
+        robux: orders
+            .iter()
+            .fold(0, |robux, order| robux.saturating_add(order.robux)),
+        fee_robux: orders
+            .iter()
+            .fold(0, |fee, order| fee.saturating_add(order.fee_robux)),
+        gross_gbp: orders.iter().map(|order| order.total_gbp).sum(),
+        gross_usd: orders.iter().map(|order| order.total_usd).sum(),
+        refunded_gbp: orders.iter().map(|order| order.refunded_gbp()).sum(),
+        refunded_usd: orders
+            .iter()
+            .map(|order| order.total_usd - order.net_usd())
+            .sum(),
+        busiest_days,
+    }
+}
+
+/// Buyers' ratings over a set of orders.
+pub struct ServiceSummary {
+    pub ratings: usize,
+    pub average_stars: f64,
+    /// Ratings given per star count, 1 star first.
+    pub distribution: [usize; 5],
+    /// Each staff member's rating count and average, best first.
+    pub by_staff: Vec<(u64, usize, f64)>,
+    /// Rated orders with a comment, newest first.
+    pub recent: Vec<Order>,
+}
+
+pub fn summarize_feedback(orders: &[Order]) -> ServiceSummary {
+    let mut rated: Vec<&Order> = orders
+        .iter()
+        .filter(|order| order.feedback.is_some())
+        .collect();
+    rated.sort_by_key(|order| std::cmp::Reverse(order.feedback.as_ref().map_or(0, |f| f.given_at)));
+
+    let mut distribution = [0; 5];
+    let mut by_staff: HashMap<u64, (usize, u64)> = HashMap::new();
+    let mut total_stars = 0;
+    for order in &rated {
+        let stars = order.feedback.as_ref().map_or(0, |feedback| feedback.stars);
+        distribution[(stars.clamp(1, 5) - 1) as usize] += 1;
+        total_stars += stars as u64;
+        if let Some(staff_id) = order.claimed_by {
+            let entry = by_staff.entry(staff_id).or_default();
+            entry.0 += 1;
+            entry.1 += stars as u64;
+        }
+    }
+
+    let mut by_staff: Vec<(u64, usize, f64)> = by_staff
+        .into_iter()
+        .map(|(staff_id, (count, stars))| (staff_id, count, stars as f64 / count as f64))
+        .collect();
+    by_staff.sort_by(|a, b| b.2.total_cmp(&a.2).then(b.1.cmp(&a.1)));
+
+    ServiceSummary {
+        ratings: rated.len(),
+        average_stars: if rated.is_empty() {
+            0.0
+        } else {
+            total_stars as f64 / rated.len() as f64
+        },
+        distribution,
+        by_staff,
+        recent: rated
+            .into_iter()
+            .filter(|order| {
+                order
+                    .feedback
+                    .as_ref()
+                    .map_or(false, |feedback| feedback.comment.is_some())
+            })
+            .cloned()
+            .collect(),
+    }
+}
+
+/// One buyer's totals across their orders.
+pub struct BuyerTotal {
+    pub buyer_id: u64,
+    pub order_count: usize,
+    pub robux: u64,
+    pub spend_gbp: f64,
+}
+
+/// Totals per buyer over paid orders, net of partial refunds, sorted by Robux
+/// purchased or by spend, highest first.
+pub fn top_buyers(orders: &[Order], by_spend: bool) -> Vec<BuyerTotal> {
+    let mut totals: HashMap<u64, BuyerTotal> = HashMap::new();
+    for order in orders.iter().filter(|order| order.status.is_paid()) {
+        let total = totals.entry(order.buyer_id).or_insert_with(|| BuyerTotal {
+            buyer_id: order.buyer_id,
+            order_count: 0,
+            robux: 0,
+            spend_gbp: 0.0,
+        });
+        total.order_count += 1;
+        total.robux += order.robux;
+        total.spend_gbp += order.net_gbp();
+    }
+
+    let mut totals: Vec<_> = totals.into_values().collect();
+    if by_spend {
+        totals.sort_by(|a, b| b.spend_gbp.total_cmp(&a.spend_gbp));
+    } else {
+        totals.sort_by(|a, b| b.robux.cmp(&a.robux));
+    }
+    totals
+}
+
+/// Renders orders as CSV with a header row.
+pub fn to_csv(orders: &[Order]) -> String {
+    let mut csv = String::from(
+        "id,created_at,guild_id,buyer_id,buyer_name,robux,gamepass_price,after_tax,\
+         fee_robux,robux_to_gbp_rate,gbp_to_usd_rate,fx_margin_percent,discount_percent,tax_gbp,total_gbp,total_usd,status,seller,method,\
+         gamepass_id,listed_price,buyer_roblox,refunded_gbp\n",
+    );
+
+    for order in orders {
+        let created_at = DateTime::from_timestamp(order.created_at as i64, 0)
+            .map(|created_at| created_at.to_rfc3339())
+            .unwrap_or_default();
+        let fields = [
+            order.id.to_string(),
+            created_at,
+            order.guild_id.map(|id| id.to_string()).unwrap_or_default(),
+            order.buyer_id.to_string(),
+            csv_field(&order.buyer_name),
+            order.robux.to_string(),
+            order.gamepass_price.to_string(),
+            order.after_tax.to_string(),
+            order.fee_robux.to_string(),
+            order.robux_to_gbp_rate.to_string(),
+            order.gbp_to_usd_rate.to_string(),
+            order.fx_margin_percent.to_string(),
+            order.discount_percent.to_string(),
+            format!("{:.2}", order.tax_gbp),
+            format!("{:.2}", order.total_gbp),
+            format!("{:.2}", order.total_usd),
+            order.status.label().to_string(),
+            csv_field(order.seller.as_deref().unwrap_or_default()),
+            order.method.label().to_string(),
+            order
+                .gamepass_id
+                .map(|id| id.to_string())
+                .unwrap_or_default(),
+            order
+                .listed_price
+                .map(|price| price.to_string())
+                .unwrap_or_default(),
+            csv_field(order.buyer_roblox.as_deref().unwrap_or_default()),
+            format!("{:.2}", order.refunded_gbp()),
+        ];
+        csv.push_str(&fields.join(","));
+        csv.push('\n');
+    }
+
+    csv
+}
+
+/// Quotes a field if it contains a delimiter, quote or line break, and neutralises
+/// values a spreadsheet would treat as a formula.
+pub fn csv_field(value: &str) -> String {
+    let value = if value.starts_with(['=', '+', '-', '@']) {
+        format!("'{}", value)
+    } else {
+        value.to_string()
+    };
+
+    if value.contains([',', '"', '\n', '\r']) {
+        format!("\"{}\"", value.replace('"', "\"\""))
+    } else {
+        value
+    }
+}
+
+pub struct OrdersKey;
+
+impl TypeMapKey for OrdersKey {
+    type Value = Arc<OrderStore>;
+}
+
+pub async fn store(ctx: &Context) -> Result<Arc<OrderStore>, String> {
+    ctx.data
+        .read()
+        .await
+        .get::<OrdersKey>()
+        .cloned()
+        .ok_or_else(|| "Order store unavailable".to_string())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use OrderStatus::*;
+
+    const ALL: [OrderStatus; 8] = [
+        Quoted,
+        PendingPayment,
+        Paid,
+        Delivering,
+        Delivered,
+        Completed,
+        Cancelled,
+        Refunded,
+    ];
+
+    fn order(status: OrderStatus, total_gbp: f64) -> Order {
+        serde_json::from_value(serde_json::json!({
+            "id": 0,
+            "buyer_id": 1,
+            "buyer_name": "buyer",
+            "robux": 1_000,
+            "gamepass_price": 1_429,
+            "after_tax": true,
+            "fee_robux": 429,
+            "robux_to_gbp_rate": 0.01,
+            "gbp_to_usd_rate": 1.25,
+            "total_gbp": total_gbp,
+            "total_usd": total_gbp * 1.25,
+            "status": status,
+            "created_at": 0,
+        }))
+        .unwrap()
+    }
+
+    /// A store in a fresh directory of its own, holding `order`.
+    async fn store_with(name: &str, order: Order) -> (OrderStore, u64) {
+        let dir =
+            std::env::temp_dir().join(format!("robux-orders-{}-{}", std::process::id(), name));
+        let _ = std::fs::remove_dir_all(&dir);
+        let partition = Partition::claim("", dir).unwrap();
+        let store = OrderStore::open(&partition, &Cache::from_env().unwrap()).unwrap();
+        let id = store.record(order).await.unwrap().id;
+        (store, id)
+    }
+
+    #[test]
+    fn orders_move_forward_and_close() {
+        assert!(Quoted.can_become(PendingPayment));
+        assert!(Quoted.can_become(Cancelled));
+        assert!(PendingPayment.can_become(Paid));
+        assert!(Paid.can_become(Delivering));
+        assert!(Paid.can_become(Delivered));
+        assert!(Delivered.can_become(Completed));
+        assert!(Completed.can_become(Refunded));
+    }
+
+    #[test]
+    fn orders_cannot_skip_payment_or_reopen() {
+        assert!(!Quoted.can_become(Paid));
+        assert!(!PendingPayment.can_become(Delivered));
+        assert!(!PendingPayment.can_become(Refunded));
+        assert!(!Paid.can_become(Cancelled));
+        assert!(!Delivered.can_become(Delivering));
+        assert!(!Completed.can_become(Paid));
+        for status in ALL {
+            assert!(!status.can_become(status), "{:?} can become itself", status);
+            assert!(!Cancelled.can_become(status));
+            assert!(!Refunded.can_become(status));
+        }
+    }
+
+    #[test]
+    fn every_next_status_is_allowed() {
+        for status in ALL {
+            for next in ALL {
+                assert_eq!(status.can_become(next), status.next().contains(&next));
+            }
+        }
+    }
+
+    #[test]
+    fn only_standing_sales_are_paid() {
+        let paid: Vec<_> = ALL.into_iter().filter(|status| status.is_paid()).collect();
+        assert_eq!(paid, [Paid, Delivering, Delivered, Completed]);
+        assert!(!Refunded.is_paid());
+        assert!(Refunded.is_closed());
+    }
+
+    #[test]
+    fn statuses_parse_from_labels() {
+        for status in ALL {
+            assert_eq!(OrderStatus::parse(status.label()), Some(status));
+        }
+        assert_eq!(OrderStatus::parse("pending-payment"), Some(PendingPayment));
+        assert_eq!(OrderStatus::parse("canceled"), Some(Cancelled));
+        assert_eq!(OrderStatus::parse("lost"), None);
+    }
+
+    #[tokio::test]
+    async fn transition_records_history_and_refuses_skips() {
+        let (store, id) = store_with("transition", order(PendingPayment, 10.0)).await;
+        let (order, from) = store
+            .transition(id, Paid, Some(7), |order| order.delivery_proof = None)
+            .await
+            .unwrap();
+        assert_eq!(from, PendingPayment);
+        assert_eq!(order.status, Paid);
+        let change = order.history.last().unwrap();
+        assert_eq!((change.status, change.by), (Paid, Some(7)));
+
+        let error = store.transition(id, Quoted, None, |_| {}).await;
+        assert!(error.is_err());
+        assert_eq!(store.get(id).await.unwrap().status, Paid);
+        assert!(store.transition(id + 1, Paid, None, |_| {}).await.is_err());
+    }
+
+    #[tokio::test]
+    async fn partial_refunds_keep_the_status_until_nothing_is_left() {
+        let (store, id) = store_with("refund", order(Delivered, 10.0)).await;
+        let (order, from) = store.refund(id, Some(4.0), None, 7).await.unwrap();
+        assert_eq!(from, None);
+        assert_eq!(order.status, Delivered);
+        assert!((order.net_gbp() - 6.0).abs() < MONEY_EPSILON);
+
+        assert!(store.refund(id, Some(6.5), None, 7).await.is_err());
+        assert!(store.refund(id, Some(0.0), None, 7).await.is_err());
+
+        let (order, from) = store.refund(id, None, None, 7).await.unwrap();
+        assert_eq!(from, Some(Delivered));
+        assert_eq!(order.status, Refunded);
+        assert_eq!(order.refunds.len(), 2);
+        assert!(!order.status.is_paid());
+        assert!(store.refund(id, None, None, 7).await.is_err());
+    }
+
+    #[tokio::test]
+    async fn unpaid_orders_cannot_be_refunded() {
+        let (store, id) = store_with("unpaid-refund", order(PendingPayment, 10.0)).await;
+        assert!(store.refund(id, None, None, 7).await.is_err());
+    }
+
+    #[test]
+    fn payment_references_round_trip() {
+        for id in [1, 42, 999, 123_456, u64::MAX] {
+            let reference = payment_reference(id);
+            assert!(reference.starts_with(&format!("RBX-{}-", id)));
+            assert_eq!(parse_payment_reference(&reference), Ok(id));
+            assert_eq!(
+                parse_payment_reference(&format!(" {} ", reference.to_lowercase())),
+                Ok(id)
+            );
+        }
+    }
+
+    #[test]
+    fn corrupted_references_are_rejected() {
+        let reference = payment_reference(42);
+        let check = &reference[reference.len() - 2..];
+        for wrong in CHECK_ALPHABET.iter().map(|c| *c as char) {
+            let mut corrupted = reference.clone();
+            corrupted.pop();
+            corrupted.push(wrong);
+            if corrupted != reference {
+                assert!(parse_payment_reference(&corrupted).is_err());
+            }
+        }
+        // A mistyped order number no longer matches its check characters.
+        assert!(parse_payment_reference(&format!("RBX-43-{}", check)).is_err());
+        for malformed in ["RBX-42", "RBX-42-ABC", "ABC-42-XY", "RBX-x-XY", ""] {
+            assert!(parse_payment_reference(malformed).is_err());
+        }
+    }
+
+    #[test]
+    fn order_references_are_found_in_notes() {
+        let reference = payment_reference(42);
+        assert_eq!(
+            parse_order_reference(&format!("Robux ({})", reference)),
+            Some(42)
+        );
+        assert_eq!(parse_order_reference("for #42, thanks"), Some(42));
+        assert_eq!(parse_order_reference("Order 42"), Some(42));
+        assert_eq!(parse_order_reference("order: #42"), Some(42));
+        assert_eq!(parse_order_reference("42"), Some(42));
+        assert_eq!(parse_order_reference("RBX-43-ZZ"), None);
+        assert_eq!(parse_order_reference("thanks for the robux"), None);
+        assert_eq!(parse_order_reference("2 gamepasses"), None);
+    }
+}
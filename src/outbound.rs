@@ -0,0 +1,99 @@
+use serenity::{
+    http::{error::ErrorResponse, routing::Route, Http, HttpError},
+    Error as SerenityError,
+};
+use std::{
+    future::Future,
+    sync::OnceLock,
+    time::{Duration, SystemTime},
+};
+use tokio::sync::Semaphore;
+
+/// Outbound requests allowed in flight at once; further ones wait their turn.
+const MAX_IN_FLIGHT: usize = 4;
+const MAX_ATTEMPTS: u32 = 4;
+/// Backoff when Discord gave no delay, doubled on each retry.
+const RETRY_BASE_MS: u64 = 500;
+/// Longest wait a send will retry after; a longer one is reported instead.
+const MAX_RETRY_DELAY: Duration = Duration::from_secs(30);
+
+fn queue() -> &'static Semaphore {
+    static QUEUE: OnceLock<Semaphore> = OnceLock::new();
+    QUEUE.get_or_init(|| Semaphore::new(MAX_IN_FLIGHT))
+}
+
+/// Runs an outbound Discord request (an interaction response, follow-up or
+/// channel message), queued behind other sends and retried when Discord
+/// answers 429. Serenity already waits out the buckets announced in rate-limit
+/// headers; this catches the 429s that still get through during bursts, such
+/// as shared and global limits. Each retry waits out the failing route's
+/// bucket when it's known, backing off otherwise, and gives up its place in
+/// the queue while it waits.
+pub async fn send<T, F, Fut>(http: &Http, mut request: F) -> serenity::Result<T>
+where
+    F: FnMut() -> Fut,
+    Fut: Future<Output = serenity::Result<T>>,
+{
+    let mut attempt = 1;
+
+    loop {
+        let result = {
+            let _permit = queue().acquire().await.ok();
+            request().await
+        };
+        match result {
+            Err(error) if attempt < MAX_ATTEMPTS && is_rate_limited(&error) => {
+                let delay = match retry_after(http, &error).await {
+                    Some(delay) if delay > MAX_RETRY_DELAY => return Err(error),
+                    Some(delay) => delay,
+                    None => Duration::from_millis(RETRY_BASE_MS * 2u64.pow(attempt - 1)),
+                };
+                log::warn!("Rate limited by Discord, retrying in {:?}", delay);
+                tokio::time::sleep(delay).await;
+                attempt += 1;
+            }
+            result => return result,
+        }
+    }
+}
+
+/// How long Discord asked us to wait before retrying `error`'s request.
+/// Serenity's errors drop the 429's headers and body, but its rate limiter
+/// records each route's reset from `X-RateLimit-Reset-After`, so the wait is
+/// the reset of the failing route's bucket if that bucket is exhausted. `None`
+/// otherwise, as for shared and global limits, so the send backs off instead.
+async fn retry_after(http: &Http, error: &SerenityError) -> Option<Duration> {
+    let route = route(unsuccessful(error)?)?;
+    let routes = http.ratelimiter.routes();
+    let bucket = routes.read().await.get(&route)?.clone();
+    let bucket = bucket.lock().await;
+    if bucket.remaining() > 0 {
+        return None;
+    }
+    bucket.reset()?.duration_since(SystemTime::now()).ok()
+}
+
+/// The rate-limit route of a failed channel message, e.g.
+/// `/api/v10/channels/123/messages`. Those are the sends that hit per-route
+/// limits; other routes return `None`.
+fn route(response: &ErrorResponse) -> Option<Route> {
+    let segments: Vec<&str> = response.url.path_segments()?.collect();
+    match segments.as_slice() {
+        [.., "channels", id, "messages"] => id.parse().ok().map(Route::ChannelsIdMessages),
+        _ => None,
+    }
+}
+
+fn is_rate_limited(error: &SerenityError) -> bool {
+    unsuccessful(error).map_or(false, |response| response.status_code.as_u16() == 429)
+}
+
+fn unsuccessful(error: &SerenityError) -> Option<&ErrorResponse> {
+    match error {
+        SerenityError::Http(error) => match error.as_ref() {
+            HttpError::UnsuccessfulRequest(response) => Some(response),
+            _ => None,
+        },
+        _ => None,
+    }
+}
@@ -0,0 +1,139 @@
+use crate::{
+    components::{self, ComponentId},
+    i18n,
+};
+use serenity::{
+    builder::CreateComponents,
+    model::application::{
+        component::ButtonStyle, interaction::message_component::MessageComponentInteraction,
+    },
+};
+use std::fmt::Display;
+
+/// Discord's limit on a select menu's options.
+const MAX_JUMP_OPTIONS: usize = 25;
+
+/// One page of a long list, and how many pages there are.
+pub struct Page {
+    pub index: usize,
+    pub count: usize,
+}
+
+impl Page {
+    /// Page `index` of `len` items shown `per_page` at a time, clamped to the
+    /// last page. An empty list still has one page.
+    pub fn of(len: usize, per_page: usize, index: usize) -> Self {
+        let count = len.div_ceil(per_page).max(1);
+        Self {
+            index: index.min(count - 1),
+            count,
+        }
+    }
+
+    /// The items on this page, `per_page` at a time.
+    pub fn items<T>(&self, items: impl Iterator<Item = T>, per_page: usize) -> Vec<T> {
+        items.skip(self.index * per_page).take(per_page).collect()
+    }
+
+    /// "Page 2 of 5" in `locale`.
+    pub fn label(&self, locale: &str) -> String {
+        i18n::t(
+            locale,
+            "pagination.page",
+            &[
+                ("page", &(self.index + 1).to_string()),
+                ("pages", &self.count.to_string()),
+            ],
+        )
+    }
+}
+
+/// First, previous, next and last buttons for `page`, plus a menu to jump
+/// straight to a page once there are more than two. Their custom IDs are
+/// `<kind>:<state…>:<page>:<control>`, where `state` is whatever the handler
+/// needs to rebuild the list; [`requested`] reads back the page asked for.
+/// A single page gets no controls.
+pub fn controls(kind: &str, state: &[&dyn Display], page: &Page, locale: &str) -> CreateComponents {
+    let mut components = CreateComponents::default();
+    if page.count <= 1 {
+        return components;
+    }
+
+    let custom_id = |target: usize, control: &str| {
+        let mut args = state.to_vec();
+        args.push(&target);
+        args.push(&control);
+        components::custom_id(kind, &args)
+    };
+    let last = page.count - 1;
+    let buttons = [
+        ("f", "pagination.first", 0, page.index == 0),
+        (
+            "p",
+            "pagination.previous",
+            page.index.saturating_sub(1),
+            page.index == 0,
+        ),
+        (
+            "n",
+            "pagination.next",
+            (page.index + 1).min(last),
+            page.index == last,
+        ),
+        ("l", "pagination.last", last, page.index == last),
+    ];
+    components.create_action_row(|row| {
+        for (control, label, target, disabled) in buttons {
+            row.create_button(|button| {
+                button
+                    .custom_id(custom_id(target, control))
+                    .label(i18n::t(locale, label, &[]))
+                    .style(ButtonStyle::Secondary)
+                    .disabled(disabled)
+            });
+        }
+        row
+    });
+
+    if page.count > 2 {
+        // Pages around the current one, when there are too many to list.
+        let first = page
+            .index
+            .saturating_sub(MAX_JUMP_OPTIONS / 2)
+            .min(page.count.saturating_sub(MAX_JUMP_OPTIONS));
+        let shown = first..page.count.min(first + MAX_JUMP_OPTIONS);
+        components.create_action_row(|row| {
+            row.create_select_menu(|menu| {
+                menu.custom_id(custom_id(page.index, "j"))
+                    .placeholder(i18n::t(locale, "pagination.jump", &[]))
+                    .options(|options| {
+                        for index in shown {
+                            options.create_option(|option| option.label(index + 1).value(index));
+                        }
+                        options
+                    })
+            })
+        });
+    }
+
+    components
+}
+
+/// The page a control made by [`controls`] asks for, given how many `state`
+/// values its custom ID carries.
+pub fn requested(
+    component: &MessageComponentInteraction,
+    custom_id: &ComponentId,
+    state_len: usize,
+) -> Result<usize, String> {
+    let control: String = custom_id.arg(state_len + 1)?;
+    if control == "j" {
+        return component
+            .data
+            .values
+            .first()
+            .and_then(|value| value.parse().ok())
+            .ok_or_else(|| "Pick a page to jump to".to_string());
+    }
+    custom_id.arg(state_len)
+}
@@ -0,0 +1,309 @@
+use crate::{
+    orders::{self, Payment},
+    store,
+    tenant::{self, Tenant},
+    webhooks::{self, Request, Response},
+};
+use serde::{Deserialize, Serialize};
+use serde_json::{value::RawValue, Value};
+use serenity::prelude::*;
+use std::{env, sync::OnceLock, time::Duration};
+
+const LIVE_IPN_URL: &str = "https://ipnpb.paypal.com/cgi-bin/webscr";
+const SANDBOX_IPN_URL: &str = "https://ipnpb.sandbox.paypal.com/cgi-bin/webscr";
+const LIVE_API_URL: &str = "https://api-m.paypal.com";
+const SANDBOX_API_URL: &str = "https://api-m.sandbox.paypal.com";
+const REQUEST_TIMEOUT: Duration = Duration::from_secs(30);
+
+fn client() -> &'static reqwest::Client {
+    static CLIENT: OnceLock<reqwest::Client> = OnceLock::new();
+    CLIENT.get_or_init(|| {
+        reqwest::Client::builder()
+            .timeout(REQUEST_TIMEOUT)
+            .build()
+            .unwrap_or_default()
+    })
+}
+
+/// Whether `PAYPAL_SANDBOX` points verification at PayPal's sandbox.
+fn sandbox() -> bool {
+    env::var("PAYPAL_SANDBOX").map_or(false, |sandbox| sandbox == "1" || sandbox == "true")
+}
+
+/// Splits the bot's name off a `custom` value tagged as `<bot>:<reference>`,
+/// e.g. `shop-a:RBX-42-XY`, which PayPal buttons can send so a payment can
+/// only settle an order of the bot it was made for. Untagged values, such as
+/// buyers' own notes, come back whole.
+fn split_tag(custom: &str) -> (Option<&str>, &str) {
+    match custom.split_once(':') {
+        Some((label, rest))
+            if !label.is_empty()
+                && label
+                    .chars()
+                    .all(|c| c.is_ascii_lowercase() || c.is_ascii_digit() || c == '-') =>
+        {
+            (Some(label), rest.trim())
+        }
+        _ => (None, custom),
+    }
+}
+
+/// Handles an Instant Payment Notification. PayPal resends it until it gets a
+/// 200, so anything that isn't worth a retry is acknowledged. IPNs are refused
+/// until the bot's `PAYPAL_RECEIVER_EMAIL` is set: PayPal verifies any genuine
+/// payment, including one a buyer sends to their own account, so without it
+/// an order could be settled for free.
+pub async fn handle_ipn(ctx: &Context, request: &Request) -> Response {
+    let tenant = match tenant::tenant(ctx).await {
+        Ok(tenant) => tenant,
+        Err(error) => {
+            log::error!("Error handling PayPal IPN: {}", error);
+            return Response::new(500, "Unavailable");
+        }
+    };
+    let receiver = match tenant.var("PAYPAL_RECEIVER_EMAIL") {
+        Some(receiver) => receiver,
+        None => {
+            log::error!(
+                "Refusing a PayPal IPN because {} isn't set",
+                tenant.var_name("PAYPAL_RECEIVER_EMAIL")
+            );
+            return Response::new(503, "PayPal IPN isn't configured");
+        }
+    };
+    match verify_ipn(&request.body).await {
+        Ok(true) => {}
+        Ok(false) => {
+            log::warn!("Ignoring a PayPal IPN that PayPal didn't verify");
+            return Response::ok();
+        }
+        Err(error) => {
+            log::error!("Error verifying PayPal IPN: {}", error);
+            return Response::new(500, "Verification failed");
+        }
+    }
+
+    let fields = webhooks::parse_form(&request.body);
+    let field = |name: &str| fields.get(name).map(|value| value.trim()).unwrap_or("");
+    if field("payment_status") != "Completed" {
+        return Response::ok();
+    }
+    if !field("receiver_email").eq_ignore_ascii_case(&receiver) {
+        log::warn!(
+            "Ignoring a PayPal IPN for another account: {}",
+            field("receiver_email")
+        );
+        return Response::ok();
+    }
+    let (tag, custom) = split_tag(field("custom"));
+    if tag.map_or(false, |tag| tag != tenant.label()) {
+        log::warn!(
+            "Ignoring PayPal IPN {} for another bot: {}",
+            field("txn_id"),
+            field("custom")
+        );
+        return Response::ok();
+    }
+    let amount = match field("mc_gross").parse::<f64>() {
+        Ok(amount) => amount,
+        Err(_) => {
+            log::warn!("PayPal IPN {} has no amount", field("txn_id"));
+            return Response::ok();
+        }
+    };
+
+    let payment = Payment {
+        provider: "PayPal".to_string(),
+        reference: field("txn_id").to_string(),
+        amount,
+        currency: field("mc_currency").to_uppercase(),
+        recorded_at: store::now(),
+        recorded_by: None,
+    };
+    // Buyers put the order number wherever PayPal lets them.
+    let notes: Vec<&str> = ["invoice", "custom", "item_number", "item_name", "memo"]
+        .iter()
+        .map(|name| match *name {
+            "custom" => custom,
+            name => field(name),
+        })
+        .filter(|note| !note.is_empty())
+        .collect();
+    let order_id = notes
+        .iter()
+        .find_map(|note| orders::parse_order_reference(note));
+    crate::settle_payment(ctx, payment, order_id, &notes.join(" / ")).await;
+    Response::ok()
+}
+
+/// Handles a REST webhook event. Only `PAYMENT.CAPTURE.COMPLETED` marks orders
+/// paid, and only for captures that aren't tagged for another bot.
+pub async fn handle_webhook(ctx: &Context, request: &Request) -> Response {
+    let tenant = match tenant::tenant(ctx).await {
+        Ok(tenant) => tenant,
+        Err(error) => {
+            log::error!("Error handling PayPal webhook: {}", error);
+            return Response::new(500, "Unavailable");
+        }
+    };
+    let event: Value = match serde_json::from_slice(&request.body) {
+        Ok(event) => event,
+        Err(_) => return Response::new(400, "Invalid JSON"),
+    };
+    match verify_webhook(&tenant, request).await {
+        Ok(true) => {}
+        Ok(false) => return Response::new(401, "Invalid signature"),
+        Err(error) => {
+            log::error!("Error verifying PayPal webhook: {}", error);
+            return Response::new(500, "Verification failed");
+        }
+    }
+    if event["event_type"] != "PAYMENT.CAPTURE.COMPLETED" {
+        return Response::ok();
+    }
+
+    let capture = &event["resource"];
+    let amount = match capture["amount"]["value"]
+        .as_str()
+        .and_then(|amount| amount.parse::<f64>().ok())
+    {
+        Some(amount) => amount,
+        None => {
+            log::warn!("PayPal capture {} has no amount", capture["id"]);
+            return Response::ok();
+        }
+    };
+    let text = |value: &Value| value.as_str().unwrap_or("").to_string();
+    let payment = Payment {
+        provider: "PayPal".to_string(),
+        reference: text(&capture["id"]),
+        amount,
+        currency: text(&capture["amount"]["currency_code"]).to_uppercase(),
+        recorded_at: store::now(),
+        recorded_by: None,
+    };
+    let custom_id = text(&capture["custom_id"]);
+    let (tag, custom_id) = split_tag(&custom_id);
+    if tag.map_or(false, |tag| tag != tenant.label()) {
+        log::warn!(
+            "Refusing PayPal capture {} for another bot: {}",
+            capture["id"],
+            capture["custom_id"]
+        );
+        return Response::new(403, "Capture is for another bot");
+    }
+    let notes = [text(&capture["invoice_id"]), custom_id.to_string()];
+    let order_id = notes
+        .iter()
+        .find_map(|note| orders::parse_order_reference(note));
+    crate::settle_payment(ctx, payment, order_id, &notes.join(" / ")).await;
+    Response::ok()
+}
+
+/// Asks PayPal whether it sent IPN `body`, as the IPN protocol requires.
+async fn verify_ipn(body: &[u8]) -> Result<bool, String> {
+    let mut message = b"cmd=_notify-validate&".to_vec();
+    message.extend_from_slice(body);
+    let response = client()
+        .post(if sandbox() {
+            SANDBOX_IPN_URL
+        } else {
+            LIVE_IPN_URL
+        })
+        .header("Content-Type", "application/x-www-form-urlencoded")
+        .header("User-Agent", "RobuxCalculatorBot-IPN")
+        .body(message)
+        .send()
+        .await
+        .map_err(|e| format!("Error contacting PayPal: {}", e))?;
+    if !response.status().is_success() {
+        return Err(format!("PayPal returned {}", response.status()));
+    }
+    let verdict = response
+        .text()
+        .await
+        .map_err(|e| format!("Error reading PayPal's answer: {}", e))?;
+    Ok(verdict.trim() == "VERIFIED")
+}
+
+/// Checks a webhook's signature with PayPal's verification API, using
+/// `tenant`'s app credentials in `PAYPAL_CLIENT_ID`/`PAYPAL_CLIENT_SECRET` and
+/// its webhook's ID in `PAYPAL_WEBHOOK_ID` (each suffixed with the bot's name
+/// for a named bot). A webhook registered for another bot's app fails the
+/// check. The event is passed on exactly as it arrived, since PayPal checks
+/// the signature against those bytes.
+async fn verify_webhook(tenant: &Tenant, request: &Request) -> Result<bool, String> {
+    #[derive(Deserialize)]
+    struct Token {
+        access_token: String,
+    }
+    #[derive(Deserialize)]
+    struct Verification {
+        verification_status: String,
+    }
+    #[derive(Serialize)]
+    struct VerifyRequest<'a> {
+        auth_algo: &'a str,
+        cert_url: &'a str,
+        transmission_id: &'a str,
+        transmission_sig: &'a str,
+        transmission_time: &'a str,
+        webhook_id: String,
+        webhook_event: &'a RawValue,
+    }
+
+    let credential = |name: &str| {
+        tenant
+            .var(name)
+            .ok_or_else(|| format!("{} isn't set", tenant.var_name(name)))
+    };
+    let client_id = credential("PAYPAL_CLIENT_ID")?;
+    let client_secret = credential("PAYPAL_CLIENT_SECRET")?;
+    let webhook_id = credential("PAYPAL_WEBHOOK_ID")?;
+    let api_url = if sandbox() {
+        SANDBOX_API_URL
+    } else {
+        LIVE_API_URL
+    };
+
+    let token: Token = client()
+        .post(format!("{}/v1/oauth2/token", api_url))
+        .basic_auth(client_id, Some(client_secret))
+        .form(&[("grant_type", "client_credentials")])
+        .send()
+        .await
+        .map_err(|e| format!("Error contacting PayPal: {}", e))?
+        .error_for_status()
+        .map_err(|e| format!("Error signing in to PayPal: {}", e))?
+        .json()
+        .await
+        .map_err(|e| format!("Error reading PayPal token: {}", e))?;
+
+    let event: &RawValue = serde_json::from_slice(&request.body)
+        .map_err(|e| format!("Error reading PayPal event: {}", e))?;
+    let header = |name: &str| request.header(name).unwrap_or("");
+    let verification: Verification = client()
+        .post(format!(
+            "{}/v1/notifications/verify-webhook-signature",
+            api_url
+        ))
+        .bearer_auth(token.access_token)
+        .json(&VerifyRequest {
+            auth_algo: header("paypal-auth-algo"),
+            cert_url: header("paypal-cert-url"),
+            transmission_id: header("paypal-transmission-id"),
+            transmission_sig: header("paypal-transmission-sig"),
+            transmission_time: header("paypal-transmission-time"),
+            webhook_id,
+            webhook_event: event,
+        })
+        .send()
+        .await
+        .map_err(|e| format!("Error contacting PayPal: {}", e))?
+        .error_for_status()
+        .map_err(|e| format!("Error verifying with PayPal: {}", e))?
+        .json()
+        .await
+        .map_err(|e| format!("Error reading PayPal's answer: {}", e))?;
+    Ok(verification.verification_status == "SUCCESS")
+}
@@ -0,0 +1,131 @@
+/// A4 in PDF points.
+pub const PAGE_WIDTH: f32 = 595.0;
+pub const PAGE_HEIGHT: f32 = 842.0;
+
+/// A single-page PDF of text and horizontal rules in the standard Helvetica
+/// fonts, written without any PDF dependencies. Enough for receipts and
+/// invoices; not a general-purpose layout engine.
+pub struct Page {
+    content: String,
+}
+
+impl Page {
+    pub fn new() -> Self {
+        Self {
+            content: String::new(),
+        }
+    }
+
+    /// Draws `text` with its baseline at `y` points from the bottom.
+    pub fn text(&mut self, x: f32, y: f32, size: f32, bold: bool, text: &str) {
+        self.content.push_str(&format!(
+            "BT /{} {} Tf {} {} Td ({}) Tj ET\n",
+            if bold { "F2" } else { "F1" },
+            size,
+            x,
+            y,
+            escape(text)
+        ));
+    }
+
+    /// Draws `text` so it ends at `right`.
+    pub fn text_right(&mut self, right: f32, y: f32, size: f32, bold: bool, text: &str) {
+        self.text(right - text_width(text, size), y, size, bold, text);
+    }
+
+    /// Draws a thin grey line from `x1` to `x2` at height `y`.
+    pub fn rule(&mut self, x1: f32, x2: f32, y: f32) {
+        self.content.push_str(&format!(
+            "0.8 G 0.5 w {} {} m {} {} l S 0 G\n",
+            x1, y, x2, y
+        ));
+    }
+
+    /// Encodes the page as a PDF file.
+    pub fn pdf(&self) -> Vec<u8> {
+        let objects = [
+            "<< /Type /Catalog /Pages 2 0 R >>".to_string(),
+            "<< /Type /Pages /Kids [3 0 R] /Count 1 >>".to_string(),
+            format!(
+                "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 {} {}] \
+                 /Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>",
+                PAGE_WIDTH, PAGE_HEIGHT
+            ),
+            "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>"
+                .to_string(),
+            "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>"
+                .to_string(),
+        ];
+        let content = self.content.as_bytes();
+
+        let mut pdf: Vec<u8> = b"%PDF-1.4\n%\xe2\xe3\xcf\xd3\n".to_vec();
+        let mut offsets = Vec::with_capacity(objects.len() + 1);
+        for (index, object) in objects.iter().enumerate() {
+            offsets.push(pdf.len());
+            pdf.extend_from_slice(format!("{} 0 obj\n{}\nendobj\n", index + 1, object).as_bytes());
+        }
+        offsets.push(pdf.len());
+        pdf.extend_from_slice(
+            format!(
+                "{} 0 obj\n<< /Length {} >>\nstream\n",
+                objects.len() + 1,
+                content.len()
+            )
+            .as_bytes(),
+        );
+        pdf.extend_from_slice(content);
+        pdf.extend_from_slice(b"\nendstream\nendobj\n");
+
+        let xref = pdf.len();
+        pdf.extend_from_slice(
+            format!("xref\n0 {}\n0000000000 65535 f \n", offsets.len() + 1).as_bytes(),
+        );
+        for offset in &offsets {
+            pdf.extend_from_slice(format!("{:010} 00000 n \n", offset).as_bytes());
+        }
+        pdf.extend_from_slice(
+            format!(
+                "trailer\n<< /Size {} /Root 1 0 R >>\nstartxref\n{}\n%%EOF\n",
+                offsets.len() + 1,
+                xref
+            )
+            .as_bytes(),
+        );
+        pdf
+    }
+}
+
+/// Approximate width of `text` in points, using Helvetica's average glyph
+/// width. Good enough to right-align numbers.
+pub fn text_width(text: &str, size: f32) -> f32 {
+    text.chars()
+        .map(|c| match c {
+            '0'..='9' | '£' | '$' | '€' => 0.556,
+            '.' | ',' | ' ' => 0.278,
+            'A'..='Z' => 0.667,
+            _ => 0.5,
+        })
+        .sum::<f32>()
+        * size
+}
+
+/// `text` as a PDF string literal body in WinAnsi encoding. Characters the
+/// encoding lacks become `?`.
+fn escape(text: &str) -> String {
+    let mut escaped = String::with_capacity(text.len());
+    for c in text.chars() {
+        match c {
+            '(' | ')' | '\\' => {
+                escaped.push('\\');
+                escaped.push(c);
+            }
+            ' '..='~' => escaped.push(c),
+            '£' => escaped.push_str("\\243"),
+            '€' => escaped.push_str("\\200"),
+            '•' => escaped.push_str("\\225"),
+            '→' => escaped.push_str("->"),
+            _ => escaped.push('?'),
+        }
+    }
+    escaped
+}
@@ -0,0 +1,192 @@
+use chrono::{DateTime, Datelike, Duration, NaiveDate, TimeZone, Utc};
+
+/// Longest `Nd` period accepted; about ten years.
+const MAX_DAYS: i64 = 3650;
+
+/// A UTC date range used to filter order history. `start` is inclusive and
+/// `end` exclusive; a missing bound is unbounded.
+pub struct Period {
+    pub label: String,
+    pub start: Option<DateTime<Utc>>,
+    pub end: Option<DateTime<Utc>>,
+}
+
+impl Period {
+    /// Parses `all`, `today`, a number of days such as `7d`, `this-month`,
+    /// `last-month`, a month (`2024-05`), a day (`2024-05-01`) or an inclusive
+    /// day range (`2024-05-01..2024-05-31`).
+    pub fn parse(input: &str) -> Result<Self, String> {
+        let input = input.trim().to_lowercase();
+        let today = Utc::now().date_naive();
+
+        let (start, end) = match input.as_str() {
+            "all" => return Ok(Self::all()),
+            "today" => (today, today),
+            "this-month" | "month" => return Ok(Self::month(today.year(), today.month())),
+            "last-month" => {
+                let last_month = first_of_month(today.year(), today.month())? - Duration::days(1);
+                return Ok(Self::month(last_month.year(), last_month.month()));
+            }
+            _ => {
+                if let Some(days) = input.strip_suffix('d').and_then(|d| d.parse::<i64>().ok()) {
+                    if !(1..=MAX_DAYS).contains(&days) {
+                        return Err(format!(
+                            "The number of days must be between 1 and {}",
+                            MAX_DAYS
+                        ));
+                    }
+                    let start = Duration::try_days(days - 1)
+                        .and_then(|span| today.checked_sub_signed(span))
+                        .ok_or_else(|| invalid(&input))?;
+                    (start, today)
+                } else if let Some((from, to)) = input.split_once("..") {
+                    (parse_date(from)?, parse_date(to)?)
+                } else if let Ok(day) = parse_date(&input) {
+                    (day, day)
+                } else if let Some((year, month)) = input.split_once('-') {
+                    let year = year.parse().map_err(|_| invalid(&input))?;
+                    let month = month.parse().map_err(|_| invalid(&input))?;
+                    first_of_month(year, month)?;
+                    return Ok(Self::month(year, month));
+                } else {
+                    return Err(invalid(&input));
+                }
+            }
+        };
+
+        if end < start {
+            return Err("The end of the period is before its start".to_string());
+        }
+
+        let end = end.succ_opt().ok_or_else(|| invalid(&input))?;
+        Ok(Self {
+            label: input,
+            start: Some(start_of(start)),
+            end: Some(start_of(end)),
+        })
+    }
+
+    pub fn all() -> Self {
+        Self {
+            label: "all".to_string(),
+            start: None,
+            end: None,
+        }
+    }
+
+    /// The calendar month `month` of `year`.
+    pub fn month(year: i32, month: u32) -> Self {
+        let start = first_of_month(year, month).unwrap_or_default();
+        let end = if month == 12 {
+            first_of_month(year + 1, 1)
+        } else {
+            first_of_month(year, month + 1)
+        }
+        .unwrap_or_default();
+
+        Self {
+            label: format!("{:04}-{:02}", year, month),
+            start: Some(start_of(start)),
+            end: Some(start_of(end)),
+        }
+    }
+
+    /// Whether the Unix `timestamp` falls inside the period.
+    pub fn contains(&self, timestamp: u64) -> bool {
+        let timestamp = timestamp as i64;
+        self.start
+            .map_or(true, |start| timestamp >= start.timestamp())
+            && self.end.map_or(true, |end| timestamp < end.timestamp())
+    }
+}
+
+fn parse_date(input: &str) -> Result<NaiveDate, String> {
+    NaiveDate::parse_from_str(input.trim(), "%Y-%m-%d").map_err(|_| invalid(input))
+}
+
+fn first_of_month(year: i32, month: u32) -> Result<NaiveDate, String> {
+    NaiveDate::from_ymd_opt(year, month, 1)
+        .ok_or_else(|| format!("Invalid month '{}-{}'", year, month))
+}
+
+fn start_of(date: NaiveDate) -> DateTime<Utc> {
+    Utc.from_utc_datetime(&date.and_hms_opt(0, 0, 0).unwrap_or_default())
+}
+
+fn invalid(input: &str) -> String {
+    format!(
+        "Invalid period '{}'. Use all, today, 7d, this-month, last-month, 2024-05 or 2024-05-01..2024-05-31.",
+        input
+    )
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn date(year: i32, month: u32, day: u32) -> Option<DateTime<Utc>> {
+        Some(start_of(NaiveDate::from_ymd_opt(year, month, day).unwrap()))
+    }
+
+    #[test]
+    fn day_ranges_include_their_last_day() {
+        let period = Period::parse("2024-05-01..2024-05-31").unwrap();
+        assert_eq!(period.start, date(2024, 5, 1));
+        assert_eq!(period.end, date(2024, 6, 1));
+        let day = Period::parse(" 2024-02-29 ").unwrap();
+        assert_eq!(day.start, date(2024, 2, 29));
+        assert_eq!(day.end, date(2024, 3, 1));
+    }
+
+    #[test]
+    fn months_end_at_the_next_month() {
+        let period = Period::parse("2024-12").unwrap();
+        assert_eq!(period.label, "2024-12");
+        assert_eq!(period.start, date(2024, 12, 1));
+        assert_eq!(period.end, date(2025, 1, 1));
+    }
+
+    #[test]
+    fn day_counts_end_today() {
+        let today = Utc::now().date_naive();
+        let period = Period::parse("7D").unwrap();
+        assert_eq!(period.start, Some(start_of(today - Duration::days(6))));
+        assert_eq!(period.end, Some(start_of(today + Duration::days(1))));
+        assert!(Period::parse("1d").is_ok());
+        assert!(Period::parse("3650d").is_ok());
+        assert!(Period::parse("0d").is_err());
+        assert!(Period::parse("3651d").is_err());
+    }
+
+    #[test]
+    fn all_contains_everything() {
+        let period = Period::parse("ALL").unwrap();
+        assert!(period.contains(0));
+        assert!(period.contains(u32::MAX as u64));
+    }
+
+    #[test]
+    fn contains_excludes_the_end() {
+        let period = Period::parse("2024-05-01").unwrap();
+        let start = period.start.unwrap().timestamp() as u64;
+        assert!(!period.contains(start - 1));
+        assert!(period.contains(start));
+        assert!(period.contains(start + 86_399));
+        assert!(!period.contains(start + 86_400));
+    }
+
+    #[test]
+    fn malformed_periods_are_rejected() {
+        for input in [
+            "",
+            "yesterday",
+            "2024-13",
+            "2024-02-30",
+            "2024-05-31..2024-05-01",
+            "2024-05-01..",
+            "-5d",
+        ] {
+            assert!(Period::parse(input).is_err(), "{:?} parsed", input);
+        }
+    }
+}
@@ -0,0 +1,77 @@
+use crate::{
+    rates,
+    settings::{PresenceEntry, SettingsKey},
+    ROBUX_TO_GBP_RATE,
+};
+use serenity::{
+    model::{gateway::Activity, id::GuildId},
+    prelude::*,
+};
+use std::{collections::HashSet, sync::Arc, time::Duration};
+
+/// Discord throttles presence updates, so rotating faster than this is pointless.
+const MIN_INTERVAL_SECS: u64 = 15;
+
+/// Guilds the bot is currently a member of, kept up to date from gateway events.
+pub struct GuildsKey;
+
+impl TypeMapKey for GuildsKey {
+    type Value = Arc<RwLock<HashSet<GuildId>>>;
+}
+
+/// Starts the presence rotation task, once per bot.
+pub fn start(ctx: Context) {
+    tokio::spawn(rotate(ctx));
+}
+
+async fn rotate(ctx: Context) {
+    let mut index = 0;
+
+    loop {
+        let settings = ctx.data.read().await.get::<SettingsKey>().cloned();
+        let (enabled, interval_secs, entries) = match settings {
+            Some(settings) => {
+                let settings = settings.read().await;
+                (
+                    settings.presence.enabled,
+                    settings.presence.interval_secs,
+                    settings.presence.entries.clone(),
+                )
+            }
+            None => return,
+        };
+
+        if enabled && !entries.is_empty() {
+            let entry = entries[index % entries.len()];
+            if let Some(status) = describe(&ctx, entry).await {
+                ctx.set_activity(Activity::watching(status)).await;
+            }
+            index += 1;
+        }
+
+        tokio::time::sleep(Duration::from_secs(interval_secs.max(MIN_INTERVAL_SECS))).await;
+    }
+}
+
+/// Renders a presence entry, or `None` when its data is currently unavailable.
+async fn describe(ctx: &Context, entry: PresenceEntry) -> Option<String> {
+    match entry {
+        PresenceEntry::RobuxPrice => Some(format!("1k R$ = £{:.2}", 1000.0 * ROBUX_TO_GBP_RATE)),
+        PresenceEntry::ExchangeRate => {
+            let rate = rates::service(ctx)
+                .await
+                .ok()?
+                .get("GBP", "USD")
+                .await
+                .ok()?;
+            Some(format!("GBP/USD {:.2}", rate.value))
+        }
+        PresenceEntry::ServerCount => {
+            let guilds = match ctx.data.read().await.get::<GuildsKey>() {
+                Some(guilds) => guilds.read().await.len(),
+                None => 0,
+            };
+            Some(format!("Serving {} servers", guilds))
+        }
+    }
+}
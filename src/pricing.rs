@@ -0,0 +1,469 @@
+use crate::{amount, ROBUX_MARKUP_RATE, ROBUX_TO_GBP_RATE};
+use serde::{Deserialize, Serialize};
+
+/// Basis points in a whole, for exact fee arithmetic.
+const BASIS_POINTS: u64 = 10_000;
+
+/// Whether a Robux amount is what the gamepass is listed at (before tax) or what
+/// the buyer should receive once Roblox's marketplace fee is taken (after tax).
+#[derive(Clone, Copy, PartialEq, Eq)]
+pub enum PriceType {
+    BeforeTax,
+    AfterTax,
+}
+
+impl PriceType {
+    pub fn parse(value: &str) -> Option<Self> {
+        match value.to_lowercase().as_str() {
+            "b/t" | "bt" => Some(PriceType::BeforeTax),
+            "a/t" | "at" => Some(PriceType::AfterTax),
+            _ => None,
+        }
+    }
+
+    pub fn label(self) -> &'static str {
+        match self {
+            PriceType::BeforeTax => "b/t",
+            PriceType::AfterTax => "a/t",
+        }
+    }
+
+    pub fn is_after_tax(self) -> bool {
+        self == PriceType::AfterTax
+    }
+}
+
+/// How the Robux reach the buyer.
+#[derive(Serialize, Deserialize, Clone, Copy, PartialEq, Eq, Default)]
+#[serde(rename_all = "snake_case")]
+pub enum DeliveryMethod {
+    /// The buyer lists a gamepass and the seller buys it, so Roblox's fee applies.
+    #[default]
+    Gamepass,
+    /// The seller pays the buyer out of a group's funds, with no fee.
+    GroupPayout,
+    /// The seller buys an item for the buyer, with no fee.
+    Gift,
+}
+
+impl DeliveryMethod {
+    pub const ALL: [DeliveryMethod; 3] = [
+        DeliveryMethod::Gamepass,
+        DeliveryMethod::GroupPayout,
+        DeliveryMethod::Gift,
+    ];
+
+    pub fn parse(value: &str) -> Option<Self> {
+        match value.to_lowercase().as_str() {
+            "gamepass" => Some(DeliveryMethod::Gamepass),
+            "group" | "group payout" => Some(DeliveryMethod::GroupPayout),
+            "gift" => Some(DeliveryMethod::Gift),
+            _ => None,
+        }
+    }
+
+    pub fn label(self) -> &'static str {
+        match self {
+            DeliveryMethod::Gamepass => "Gamepass",
+            DeliveryMethod::GroupPayout => "Group Payout",
+            DeliveryMethod::Gift => "Gift",
+        }
+    }
+
+    /// When the buyer has the Robux and what the method requires.
+    pub fn terms(self) -> &'static str {
+        match self {
+            DeliveryMethod::Gamepass => "Roblox takes its fee; pending for up to 7 days",
+            DeliveryMethod::GroupPayout => {
+                "No fee, instant; the buyer must have been in the group for 14 days"
+            }
+            DeliveryMethod::Gift => "No fee, instant; delivered as an item instead of Robux",
+        }
+    }
+}
+
+/// What a seller charges: GBP per Robux before tax, and the markup (the share of
+/// the gamepass price withheld on a sale, e.g. 0.3) used to gross up a/t amounts.
+#[derive(Clone, Copy)]
+pub struct RateCard {
+    pub gbp_per_robux: f64,
+    pub markup: f64,
+}
+
+impl Default for RateCard {
+    fn default() -> Self {
+        Self {
+            gbp_per_robux: ROBUX_TO_GBP_RATE,
+            markup: ROBUX_MARKUP_RATE,
+        }
+    }
+}
+
+impl RateCard {
+    /// GBP charged per Robux.
+    pub fn gbp_rate(&self, price_type: PriceType) -> f64 {
+        match price_type {
+            PriceType::BeforeTax => self.gbp_per_robux,
+            PriceType::AfterTax => self.gbp_per_robux / (1.0 - self.markup),
+        }
+    }
+
+    /// GBP charged for `robux`. a/t amounts are charged for the whole gamepass
+    /// price, since that is what the seller spends.
+    pub fn gbp_price(&self, robux: f64, price_type: PriceType) -> f64 {
+        match price_type {
+            PriceType::BeforeTax => robux * self.gbp_per_robux,
+            PriceType::AfterTax => {
+                self.gamepass_price(robux, price_type) as f64 * self.gbp_per_robux
+            }
+        }
+    }
+
+    /// Price the gamepass has to be listed at for the buyer to receive `robux`:
+    /// the smallest price whose share after the fee, rounded down as Roblox
+    /// does, covers the amount.
+    pub fn gamepass_price(&self, robux: f64, price_type: PriceType) -> u64 {
+        let robux = robux.max(0.0).ceil() as u64;
+        match price_type {
+            PriceType::BeforeTax => robux,
+            PriceType::AfterTax => {
+                let kept = self.kept_basis_points();
+                if kept == 0 {
+                    return u64::MAX;
+                }
+                // Widened so no amount can overflow; prices past u64 saturate.
+                let price =
+                    (robux as u128 * BASIS_POINTS as u128 + kept as u128 - 1) / kept as u128;
+                price.min(u64::MAX as u128) as u64
+            }
+        }
+    }
+
+    /// Robux the buyer ends up with for an order of `robux`: the amount itself
+    /// a/t, or what is left after the fee b/t.
+    pub fn received(&self, robux: f64, price_type: PriceType) -> u64 {
+        let gamepass_price = self.gamepass_price(robux, price_type);
+        gamepass_price - self.marketplace_fee(gamepass_price)
+    }
+
+    /// Robux the seller spends to deliver an order of `robux` by `method`. Only
+    /// gamepasses lose a fee, so other methods just send what the buyer
+    /// receives.
+    pub fn robux_spent(&self, robux: f64, price_type: PriceType, method: DeliveryMethod) -> u64 {
+        match method {
+            DeliveryMethod::Gamepass => self.gamepass_price(robux, price_type),
+            DeliveryMethod::GroupPayout | DeliveryMethod::Gift => self.received(robux, price_type),
+        }
+    }
+
+    /// GBP charged for an order of `robux` delivered by `method`.
+    pub fn gbp_price_via(&self, robux: f64, price_type: PriceType, method: DeliveryMethod) -> f64 {
+        self.robux_spent(robux, price_type, method) as f64 * self.gbp_per_robux
+    }
+
+    /// How an order of `robux` becomes the Robux spent delivering it by
+    /// `method`, one step per line, with Roblox's rounding spelled out.
+    pub fn robux_steps(
+        &self,
+        robux: f64,
+        price_type: PriceType,
+        method: DeliveryMethod,
+    ) -> Vec<String> {
+        let kept_percent = self.kept_basis_points() as f64 / 100.0;
+        let gamepass_price = self.gamepass_price(robux, price_type);
+        let fee = self.marketplace_fee(gamepass_price);
+        let received = gamepass_price - fee;
+        let mut steps = vec![match price_type {
+            PriceType::BeforeTax => format!(
+                "Gamepass price: {} R$, since b/t amounts are the price",
+                gamepass_price
+            ),
+            PriceType::AfterTax => format!(
+                "Gamepass price: ⌈{} ÷ {}%⌉ = {} R$, the lowest price whose {}% share covers the amount",
+                robux.max(0.0).ceil(),
+                kept_percent,
+                gamepass_price,
+                kept_percent
+            ),
+        }];
+        steps.push(format!(
+            "Roblox fee: {} − ⌊{} × {}%⌋ = {} R$, as Roblox rounds the seller's share down",
+            gamepass_price, gamepass_price, kept_percent, fee
+        ));
+        steps.push(format!(
+            "Received: {} − {} = {} R$",
+            gamepass_price, fee, received
+        ));
+        steps.push(match method {
+            DeliveryMethod::Gamepass => {
+                format!("Robux spent: the gamepass price, {} R$", gamepass_price)
+            }
+            _ => format!(
+                "Robux spent: {} sends what a gamepass would pay out, {} R$",
+                method.label(),
+                received
+            ),
+        });
+        steps
+    }
+
+    /// Robux withheld from a sale at `gamepass_price`. The seller's share is
+    /// rounded down, so the fee is rounded up.
+    pub fn marketplace_fee(&self, gamepass_price: u64) -> u64 {
+        let kept = gamepass_price as u128 * self.kept_basis_points() as u128 / BASIS_POINTS as u128;
+        gamepass_price - kept as u64
+    }
+
+    /// The markup as a percentage, e.g. `30%` or `12.5%`, worked out from whole
+    /// basis points so float noise doesn't show.
+    pub fn markup_percent(&self) -> String {
+        let basis_points = BASIS_POINTS - self.kept_basis_points();
+        format!("{}%", basis_points as f64 / 100.0)
+    }
+
+    /// The seller's share of a sale in basis points, e.g. 7000 for a 30% markup.
+    /// Working in whole basis points keeps the rounding exact.
+    fn kept_basis_points(&self) -> u64 {
+        ((1.0 - self.markup) * BASIS_POINTS as f64)
+            .round()
+            .clamp(0.0, BASIS_POINTS as f64) as u64
+    }
+}
+
+/// A Robux pack sold by Roblox, with its GBP price.
+#[derive(Serialize, Deserialize, Clone, Copy, PartialEq)]
+pub struct RobuxPackage {
+    pub robux: u64,
+    pub gbp: f64,
+}
+
+/// Roblox's standard packs without Premium, which `/price` compares against
+/// unless a guild lists its own.
+pub const OFFICIAL_PACKAGES: &[RobuxPackage] = &[
+    RobuxPackage {
+        robux: 400,
+        gbp: 4.99,
+    },
+    RobuxPackage {
+        robux: 800,
+        gbp: 9.99,
+    },
+    RobuxPackage {
+        robux: 1_700,
+        gbp: 19.99,
+    },
+    RobuxPackage {
+        robux: 4_500,
+        gbp: 49.99,
+    },
+    RobuxPackage {
+        robux: 10_000,
+        gbp: 99.99,
+    },
+    RobuxPackage {
+        robux: 22_500,
+        gbp: 199.99,
+    },
+];
+
+/// The cheapest way to buy some Robux from Roblox.
+pub struct OfficialPrice {
+    pub gbp: f64,
+    /// Robux the packs add up to, at least the amount asked for.
+    pub robux: u64,
+    /// How many of each pack, largest first.
+    pub packages: Vec<(RobuxPackage, u64)>,
+}
+
+impl OfficialPrice {
+    /// The cheapest combination of `packages` giving at least `robux`, or
+    /// `None` if there are no packs to buy.
+    pub fn cheapest(robux: u64, packages: &[RobuxPackage]) -> Option<Self> {
+        let packages: Vec<RobuxPackage> = packages
+            .iter()
+            .copied()
+            .filter(|package| package.robux > 0 && package.gbp > 0.0)
+            .collect();
+        // Counted in the largest step every pack is a multiple of, and in
+        // pence, so the search is small and exact.
+        let unit = packages
+            .iter()
+            .fold(0, |unit, package| gcd(unit, package.robux));
+        if unit == 0 {
+            return None;
+        }
+        let units: Vec<usize> = packages
+            .iter()
+            .map(|package| (package.robux / unit) as usize)
+            .collect();
+        let pence: Vec<u64> = packages
+            .iter()
+            .map(|package| (package.gbp * 100.0).round() as u64)
+            .collect();
+        let target = robux.div_ceil(unit) as usize;
+        let limit = target + units.iter().max().copied().unwrap_or_default();
+
+        // cost[n] is the cheapest way to make exactly n units, and last[n] the
+        // pack bought last to get there.
+        let mut cost = vec![u64::MAX; limit];
+        let mut last = vec![0; limit];
+        cost[0] = 0;
+        for total in 1..limit {
+            for (index, &size) in units.iter().enumerate() {
+                if size <= total && cost[total - size] != u64::MAX {
+                    let candidate = cost[total - size] + pence[index];
+                    if candidate < cost[total] {
+                        cost[total] = candidate;
+                        last[total] = index;
+                    }
+                }
+            }
+        }
+        let mut total = (target..limit).min_by_key(|&total| cost[total])?;
+        if cost[total] == u64::MAX {
+            return None;
+        }
+
+        let gbp = cost[total] as f64 / 100.0;
+        let bought_robux = total as u64 * unit;
+        let mut counts = vec![0; packages.len()];
+        while total > 0 {
+            counts[last[total]] += 1;
+            total -= units[last[total]];
+        }
+        let mut bought: Vec<(RobuxPackage, u64)> = packages
+            .into_iter()
+            .zip(counts)
+            .filter(|(_, count)| *count > 0)
+            .collect();
+        bought.sort_by(|a, b| b.0.robux.cmp(&a.0.robux));
+        Some(Self {
+            gbp,
+            robux: bought_robux,
+            packages: bought,
+        })
+    }
+
+    /// e.g. `2 × 10,000 R$ + 1 × 400 R$`.
+    pub fn describe(&self) -> String {
+        self.packages
+            .iter()
+            .map(|(package, count)| {
+                format!(
+                    "{} × {} R$",
+                    count,
+                    amount::group_thousands(package.robux as f64)
+                )
+            })
+            .collect::<Vec<_>>()
+            .join(" + ")
+    }
+}
+
+fn gcd(a: u64, b: u64) -> u64 {
+    if b == 0 {
+        a
+    } else {
+        gcd(b, a % b)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn card(markup: f64) -> RateCard {
+        RateCard {
+            gbp_per_robux: ROBUX_TO_GBP_RATE,
+            markup,
+        }
+    }
+
+    #[test]
+    fn after_tax_price_rounds_up_to_cover_the_amount() {
+        let card = card(0.3);
+        assert_eq!(card.gamepass_price(1_000.0, PriceType::AfterTax), 1_429);
+        assert_eq!(card.marketplace_fee(1_429), 429);
+        assert_eq!(card.received(1_000.0, PriceType::AfterTax), 1_000);
+        // One Robux less would leave the buyer short.
+        assert_eq!(1_428 - card.marketplace_fee(1_428), 999);
+    }
+
+    #[test]
+    fn exact_multiples_need_no_rounding() {
+        let card = card(0.3);
+        assert_eq!(card.gamepass_price(700.0, PriceType::AfterTax), 1_000);
+        assert_eq!(card.marketplace_fee(1_000), 300);
+        assert_eq!(card.gamepass_price(7_000.0, PriceType::AfterTax), 10_000);
+        assert_eq!(card.marketplace_fee(10_000), 3_000);
+    }
+
+    #[test]
+    fn before_tax_amounts_are_the_price() {
+        let card = card(0.3);
+        assert_eq!(card.gamepass_price(1_000.0, PriceType::BeforeTax), 1_000);
+        assert_eq!(card.received(1_000.0, PriceType::BeforeTax), 700);
+        assert_eq!(card.gamepass_price(999.5, PriceType::BeforeTax), 1_000);
+        assert_eq!(card.gamepass_price(-5.0, PriceType::AfterTax), 0);
+    }
+
+    #[test]
+    fn no_markup_takes_no_fee() {
+        let card = card(0.0);
+        assert_eq!(card.kept_basis_points(), BASIS_POINTS);
+        assert_eq!(card.gamepass_price(1_000.0, PriceType::AfterTax), 1_000);
+        assert_eq!(card.marketplace_fee(1_000), 0);
+    }
+
+    #[test]
+    fn high_markup_grosses_up_tenfold() {
+        let card = card(0.9);
+        assert_eq!(card.kept_basis_points(), 1_000);
+        assert_eq!(card.gamepass_price(100.0, PriceType::AfterTax), 1_000);
+        assert_eq!(card.marketplace_fee(1_000), 900);
+        assert_eq!(card.gamepass_price(101.0, PriceType::AfterTax), 1_010);
+        assert_eq!(card.marketplace_fee(1_010), 909);
+    }
+
+    #[test]
+    fn nothing_kept_saturates_the_price() {
+        let card = card(1.0);
+        assert_eq!(card.kept_basis_points(), 0);
+        assert_eq!(card.gamepass_price(1.0, PriceType::AfterTax), u64::MAX);
+        assert_eq!(card.marketplace_fee(1_000), 1_000);
+        assert_eq!(card.gamepass_price(1_000.0, PriceType::BeforeTax), 1_000);
+    }
+
+    #[test]
+    fn markup_percent_has_no_float_noise() {
+        assert_eq!(card(0.3).markup_percent(), "30%");
+        assert_eq!(card(0.125).markup_percent(), "12.5%");
+        assert_eq!(card(0.0).markup_percent(), "0%");
+    }
+
+    #[test]
+    fn fee_and_received_round_trip() {
+        for markup in [0.0, 0.1, 0.3, 0.333, 0.5, 0.9] {
+            let card = card(markup);
+            for robux in 0..=5_000u64 {
+                let price = card.gamepass_price(robux as f64, PriceType::AfterTax);
+                assert_eq!(
+                    card.received(robux as f64, PriceType::AfterTax),
+                    robux,
+                    "{} R$ at {}",
+                    robux,
+                    markup
+                );
+                if price > 0 {
+                    let cheaper = price - 1;
+                    assert!(
+                        cheaper - card.marketplace_fee(cheaper) < robux,
+                        "{} R$ at {} could be listed for less",
+                        robux,
+                        markup
+                    );
+                }
+            }
+        }
+    }
+}
@@ -0,0 +1,124 @@
+use serenity::{model::channel::Attachment, prelude::*};
+use std::{collections::HashMap, env, sync::Arc, time::Duration};
+
+/// How long a buyer has to send their screenshot after pressing the button.
+pub const UPLOAD_WINDOW_SECS: u64 = 600;
+const UPLOAD_TIMEOUT: Duration = Duration::from_secs(30);
+
+/// An order a buyer has asked to send payment proof for, waiting for their
+/// screenshot in DMs.
+#[derive(Clone, Copy)]
+pub struct ProofRequest {
+    pub order_id: u64,
+    pub expires_at: u64,
+}
+
+/// Buyers' outstanding proof requests by Discord ID. They only live in
+/// memory: after a restart the buyer just presses the button again.
+#[derive(Default)]
+pub struct ProofRequests {
+    requests: Mutex<HashMap<u64, ProofRequest>>,
+}
+
+impl ProofRequests {
+    /// Waits for `buyer_id`'s screenshot for `order_id`, replacing any
+    /// earlier request of theirs.
+    pub async fn expect(&self, buyer_id: u64, order_id: u64, now: u64) {
+        self.requests.lock().await.insert(
+            buyer_id,
+            ProofRequest {
+                order_id,
+                expires_at: now + UPLOAD_WINDOW_SECS,
+            },
+        );
+    }
+
+    /// `buyer_id`'s request, if it hasn't expired at `now`.
+    pub async fn get(&self, buyer_id: u64, now: u64) -> Option<ProofRequest> {
+        let mut requests = self.requests.lock().await;
+        match requests.get(&buyer_id).copied() {
+            Some(request) if request.expires_at > now => Some(request),
+            Some(_) => {
+                requests.remove(&buyer_id);
+                None
+            }
+            None => None,
+        }
+    }
+
+    pub async fn remove(&self, buyer_id: u64) {
+        self.requests.lock().await.remove(&buyer_id);
+    }
+}
+
+/// Whether `attachment` looks like a screenshot.
+pub fn is_image(attachment: &Attachment) -> bool {
+    match &attachment.content_type {
+        Some(content_type) => content_type.starts_with("image/"),
+        None => {
+            let filename = attachment.filename.to_lowercase();
+            [".png", ".jpg", ".jpeg", ".gif", ".webp"]
+                .iter()
+                .any(|extension| filename.ends_with(extension))
+        }
+    }
+}
+
+/// Copies `attachment` to the object storage bucket in `PROOF_STORAGE_URL`,
+/// if one is configured, returning the copy's URL. Discord's attachment links
+/// expire, so the copy is what keeps the proof around for later disputes.
+///
+/// The file is `PUT` to `<PROOF_STORAGE_URL>/order-<id>/<attachment id>-<filename>`,
+/// with `PROOF_STORAGE_TOKEN` as a bearer token when set. That works with
+/// presigned-style buckets and most S3-compatible gateways.
+pub async fn store_copy(order_id: u64, attachment: &Attachment) -> Result<Option<String>, String> {
+    let base_url = match env::var("PROOF_STORAGE_URL") {
+        Ok(base_url) if !base_url.trim().is_empty() => base_url,
+        _ => return Ok(None),
+    };
+    let bytes = attachment
+        .download()
+        .await
+        .map_err(|e| format!("Error downloading {}: {:?}", attachment.filename, e))?;
+    let url = format!(
+        "{}/order-{}/{}-{}",
+        base_url.trim_end_matches('/'),
+        order_id,
+        attachment.id.0,
+        attachment.filename
+    );
+
+    let mut request = reqwest::Client::new()
+        .put(&url)
+        .timeout(UPLOAD_TIMEOUT)
+        .body(bytes);
+    if let Some(content_type) = &attachment.content_type {
+        request = request.header("Content-Type", content_type);
+    }
+    if let Ok(token) = env::var("PROOF_STORAGE_TOKEN") {
+        request = request.bearer_auth(token);
+    }
+    let response = request
+        .send()
+        .await
+        .map_err(|e| format!("Error contacting proof storage: {}", e))?;
+    if !response.status().is_success() {
+        return Err(format!("Proof storage returned {}", response.status()));
+    }
+    Ok(Some(url))
+}
+
+pub struct ProofRequestsKey;
+
+impl TypeMapKey for ProofRequestsKey {
+    type Value = Arc<ProofRequests>;
+}
+
+pub async fn requests(ctx: &Context) -> Result<Arc<ProofRequests>, String> {
+    ctx.data
+        .read()
+        .await
+        .get::<ProofRequestsKey>()
+        .cloned()
+        .ok_or_else(|| "Payment proof uploads unavailable".to_string())
+}
@@ -0,0 +1,154 @@
+use crate::{
+    jsonpath,
+    settings::{RateAuth, RateProviderKind, RateProviderSettings},
+};
+use reqwest::{header::HeaderMap, StatusCode};
+use serde_json::Value;
+use std::collections::HashMap;
+
+/// An exchange rate API described entirely by configuration: where to send the
+/// request, how to authenticate and where the rate table sits in the response.
+pub struct RateProvider {
+    name: String,
+    url: String,
+    auth: RateAuth,
+    rates_path: String,
+    error_path: Option<String>,
+    quota_header: Option<String>,
+    quota_error: Option<String>,
+}
+
+impl RateProvider {
+    /// Builds the provider from settings, filling in defaults for built-in kinds.
+    pub fn from_settings(settings: &RateProviderSettings) -> Result<Self, String> {
+        let (name, url, rates_path, error_path, quota_error) = match settings.kind {
+            RateProviderKind::OpenErApi => (
+                "open.er-api.com",
+                Some("https://open.er-api.com/v6/latest/{base}"),
+                Some("$.rates"),
+                Some("$['error-type']"),
+                None,
+            ),
+            RateProviderKind::ExchangeRateApi => (
+                "exchangerate-api.com",
+                Some("https://v6.exchangerate-api.com/v6/{key}/latest/{base}"),
+                Some("$.conversion_rates"),
+                Some("$['error-type']"),
+                Some("quota-reached"),
+            ),
+            RateProviderKind::Frankfurter => (
+                "frankfurter.app",
+                Some("https://api.frankfurter.app/latest?from={base}"),
+                Some("$.rates"),
+                Some("$.message"),
+                None,
+            ),
+            RateProviderKind::Custom => ("custom", None, None, None, None),
+        };
+
+        Ok(Self {
+            name: settings.name.clone().unwrap_or_else(|| name.to_string()),
+            url: settings
+                .url
+                .clone()
+                .or(url.map(String::from))
+                .ok_or("The custom rate provider requires a url")?,
+            auth: settings.auth.clone(),
+            rates_path: settings
+                .rates_path
+                .clone()
+                .or(rates_path.map(String::from))
+                .ok_or("The custom rate provider requires a rates_path")?,
+            error_path: settings.error_path.clone().or(error_path.map(String::from)),
+            quota_header: settings.quota_header.clone(),
+            quota_error: settings
+                .quota_error
+                .clone()
+                .or(quota_error.map(String::from)),
+        })
+    }
+
+    pub fn name(&self) -> &str {
+        &self.name
+    }
+
+    /// Whether requests need an API key, either in the URL or via `auth`.
+    pub fn needs_key(&self) -> bool {
+        self.url.contains("{key}") || !matches!(self.auth, RateAuth::None)
+    }
+
+    pub fn request(
+        &self,
+        client: &reqwest::Client,
+        base: &str,
+        key: Option<&str>,
+    ) -> reqwest::RequestBuilder {
+        let key = key.unwrap_or_default();
+        let url = self.url.replace("{base}", base).replace("{key}", key);
+        let request = client.get(url);
+
+        match &self.auth {
+            RateAuth::None => request,
+            RateAuth::Header { name } => request.header(name.as_str(), key),
+            RateAuth::Query { name } => request.query(&[(name.as_str(), key)]),
+            RateAuth::Bearer => request.bearer_auth(key),
+        }
+    }
+
+    /// The calls remaining on the key, if the provider reports it in a header.
+    pub fn remaining_quota(&self, headers: &HeaderMap) -> Option<u64> {
+        headers
+            .get(self.quota_header.as_deref()?)?
+            .to_str()
+            .ok()?
+            .trim()
+            .parse()
+            .ok()
+    }
+
+    /// Whether a response means the key used has run out of quota.
+    pub fn is_quota_exhausted(
+        &self,
+        status: StatusCode,
+        rates: &Result<HashMap<String, f64>, String>,
+    ) -> bool {
+        status == StatusCode::TOO_MANY_REQUESTS
+            || matches!((rates, &self.quota_error), (Err(error), Some(quota_error)) if error.contains(quota_error.as_str()))
+    }
+
+    /// Maps a response body to a quote → rate table. Rates given as numeric
+    /// strings are accepted; other non-numeric entries are skipped.
+    pub fn extract_rates(&self, body: &Value) -> Result<HashMap<String, f64>, String> {
+        if let Some(error) = self
+            .error_path
+            .as_deref()
+            .and_then(|path| jsonpath::extract(body, path))
+            .filter(|error| !error.is_null())
+        {
+            let error = error
+                .as_str()
+                .map(String::from)
+                .unwrap_or(error.to_string());
+            return Err(format!("Exchange rate API error: {}", error));
+        }
+
+        let rates = jsonpath::extract(body, &self.rates_path)
+            .and_then(Value::as_object)
+            .ok_or_else(|| {
+                format!(
+                    "Exchange rate response has no object at {}",
+                    self.rates_path
+                )
+            })?;
+
+        Ok(rates
+            .iter()
+            .filter_map(|(quote, rate)| {
+                let rate = rate
+                    .as_f64()
+                    .or_else(|| rate.as_str().and_then(|rate| rate.parse().ok()))?;
+                Some((quote.to_uppercase(), rate))
+            })
+            .collect())
+    }
+}
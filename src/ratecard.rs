@@ -0,0 +1,695 @@
+use crate::{
+    canvas::{self, Canvas},
+    orders::csv_field,
+    pricing::{PriceType, RateCard},
+    rates,
+    settings::{self, GuildSettings, PaymentMethod, RolePricing, SellerProfile, TaxSettings},
+    MAX_FX_MARGIN_PERCENT, MAX_MARKUP_PERCENT, MAX_PAYMENT_FEE_GBP, MAX_PAYMENT_SURCHARGE_PERCENT,
+};
+use serde::{Deserialize, Serialize};
+use serenity::builder::CreateEmbed;
+
+/// Robux amounts priced on the card.
+pub const SAMPLE_AMOUNTS: &[u64] = &[1_000, 5_000, 10_000, 50_000];
+
+const BRAND_COLOR: u32 = 0x0096FF;
+const BACKGROUND_COLOR: u32 = 0xFFFFFF;
+const TEXT_COLOR: u32 = 0x1E1E1E;
+const MUTED_COLOR: u32 = 0x6B7280;
+const HEADING_ROW_COLOR: u32 = 0xDCEBFA;
+const STRIPE_COLOR: u32 = 0xF2F7FD;
+/// Pixels per font pixel for body text and for the title.
+const TEXT_SCALE: u32 = 2;
+const TITLE_SCALE: u32 = 3;
+const PADDING: u32 = 24;
+const CELL_PADDING: u32 = 16;
+const HEADER_HEIGHT: u32 = 72;
+const LOGO_SIZE: u32 = 48;
+const ROW_HEIGHT: u32 = 28;
+const LINE_HEIGHT: u32 = 22;
+
+/// A currency prices are shown in, with the rate from GBP (1 for GBP itself).
+pub struct Currency {
+    pub code: String,
+    pub gbp_rate: f64,
+}
+
+impl Currency {
+    /// Formats `amount` with the currency's symbol where it has a common one.
+    pub fn format(&self, amount: f64) -> String {
+        match self.code.as_str() {
+            "GBP" => format!("£{:.2}", amount),
+            "USD" => format!("${:.2}", amount),
+            "EUR" => format!("€{:.2}", amount),
+            code => format!("{:.2} {}", amount, code),
+        }
+    }
+}
+
+/// A discount tier, e.g. a role's pricing.
+pub struct Tier {
+    pub role_id: u64,
+    /// How the tier is named, a role mention by default.
+    pub label: String,
+    pub discount_percent: f64,
+}
+
+/// Everything a guild's rate card shows.
+pub struct Table {
+    pub title: String,
+    pub card: RateCard,
+    /// GBP first, then every other display currency.
+    pub currencies: Vec<Currency>,
+    pub tiers: Vec<Tier>,
+    pub tax: Option<TaxSettings>,
+    /// The payment methods buyers can choose, with their fees.
+    pub payment_methods: Vec<PaymentMethod>,
+}
+
+impl Table {
+    /// The net price of `robux` in `currency` before any tier discount.
+    pub fn price(&self, robux: u64, price_type: PriceType, currency: &Currency) -> f64 {
+        self.card.gbp_price(robux as f64, price_type) * currency.gbp_rate
+    }
+
+    /// One line per sample amount, with the price in every currency.
+    pub fn lines(&self, price_type: PriceType) -> Vec<String> {
+        SAMPLE_AMOUNTS
+            .iter()
+            .map(|&robux| {
+                let prices = self
+                    .currencies
+                    .iter()
+                    .map(|currency| currency.format(self.price(robux, price_type, currency)))
+                    .collect::<Vec<_>>()
+                    .join(" • ");
+                format!("`{:>6}` {}", amount_label(robux), prices)
+            })
+            .collect()
+    }
+
+    /// The summary line under the title, e.g. the rate per 1k and the markup.
+    pub fn summary(&self) -> String {
+        let mut summary = format!(
+            "**£{:.2}** per 1k R$ b/t • a/t prices cover a **{}** markup",
+            self.card.gbp_per_robux * 1000.0,
+            self.card.markup_percent()
+        );
+        if let Some(tax) = &self.tax {
+            summary.push_str(&format!("\nPrices exclude {}", tax.describe()));
+        }
+        summary
+    }
+
+    pub fn embed(&self) -> CreateEmbed {
+        let mut embed = CreateEmbed::default()
+            .title(&self.title)
+            .description(self.summary())
+            .field(
+                "Before Tax (b/t)",
+                self.lines(PriceType::BeforeTax).join("\n"),
+                false,
+            )
+            .field(
+                "After Tax (a/t)",
+                self.lines(PriceType::AfterTax).join("\n"),
+                false,
+            )
+            .color(0x0096FF)
+            .clone();
+        if !self.tiers.is_empty() {
+            embed.field(
+                "Discount Tiers",
+                self.tiers
+                    .iter()
+                    .map(|tier| format!("{}: {}% off", tier.label, tier.discount_percent))
+                    .collect::<Vec<_>>()
+                    .join("\n"),
+                false,
+            );
+        }
+        if !self.payment_methods.is_empty() {
+            embed.field(
+                "Payment Methods",
+                self.payment_methods
+                    .iter()
+                    .map(|method| format!("**{}**: {}", method.name, method.describe()))
+                    .collect::<Vec<_>>()
+                    .join("\n"),
+                false,
+            );
+        }
+        if self.currencies.len() > 1 {
+            embed.footer(|footer| {
+                footer.text("Prices in other currencies use today's exchange rates")
+            });
+        }
+        embed
+    }
+
+    /// Renders the table as a branded PNG for announcement channels and social
+    /// posts: a header with the logo and title, one table per price type with a
+    /// column per currency, then the summary, tiers, payment methods and `notes`.
+    pub fn png(&self, notes: &[String]) -> Vec<u8> {
+        let headings: Vec<String> = std::iter::once("Amount".to_string())
+            .chain(self.currencies.iter().map(|currency| currency.code.clone()))
+            .collect();
+        let sections: Vec<(&str, Vec<Vec<String>>)> = [
+            ("Before Tax (b/t)", PriceType::BeforeTax),
+            ("After Tax (a/t)", PriceType::AfterTax),
+        ]
+        .into_iter()
+        .map(|(title, price_type)| {
+            let rows = SAMPLE_AMOUNTS
+                .iter()
+                .map(|&robux| {
+                    std::iter::once(amount_label(robux))
+                        .chain(self.currencies.iter().map(|currency| {
+                            currency.format(self.price(robux, price_type, currency))
+                        }))
+                        .collect()
+                })
+                .collect();
+            (title, rows)
+        })
+        .collect();
+
+        let mut footer: Vec<String> = self.summary().lines().map(str::to_string).collect();
+        footer.extend(
+            self.tiers
+                .iter()
+                .map(|tier| format!("{}: {}% off", tier.label, tier.discount_percent)),
+        );
+        footer.extend(
+            self.payment_methods
+                .iter()
+                .map(|method| format!("{}: {}", method.name, method.describe())),
+        );
+        if self.currencies.len() > 1 {
+            footer.push("Other currencies use today's exchange rates".to_string());
+        }
+        footer.extend(notes.iter().cloned());
+        let footer: Vec<String> = footer.iter().map(|line| line.replace("**", "")).collect();
+
+        let column_widths: Vec<u32> = (0..headings.len())
+            .map(|column| {
+                sections
+                    .iter()
+                    .flat_map(|(_, rows)| rows.iter().map(|row| row[column].as_str()))
+                    .chain(std::iter::once(headings[column].as_str()))
+                    .map(|text| canvas::text_width(text, TEXT_SCALE))
+                    .max()
+                    .unwrap_or_default()
+                    + 2 * CELL_PADDING
+            })
+            .collect();
+        let table_width: u32 = column_widths.iter().sum();
+        let width = footer
+            .iter()
+            .map(|line| canvas::text_width(line, TEXT_SCALE))
+            .chain(std::iter::once(
+                LOGO_SIZE + PADDING / 2 + canvas::text_width(&self.title, TITLE_SCALE),
+            ))
+            .chain(std::iter::once(table_width))
+            .max()
+            .unwrap_or_default()
+            + 2 * PADDING;
+        let section_height = ROW_HEIGHT * (SAMPLE_AMOUNTS.len() as u32 + 2) + PADDING;
+        let height = HEADER_HEIGHT
+            + PADDING
+            + section_height * sections.len() as u32
+            + LINE_HEIGHT * footer.len() as u32
+            + PADDING;
+
+        let mut image = Canvas::new(width, height, BACKGROUND_COLOR);
+        let text_offset = (ROW_HEIGHT - canvas::text_height(TEXT_SCALE)) / 2;
+
+        // Header: a logo badge and the title on the brand color.
+        image.fill_rect(0, 0, width, HEADER_HEIGHT, BRAND_COLOR);
+        let logo_top = (HEADER_HEIGHT - LOGO_SIZE) / 2;
+        image.fill_rect(PADDING, logo_top, LOGO_SIZE, LOGO_SIZE, BACKGROUND_COLOR);
+        image.text(
+            PADDING + (LOGO_SIZE - canvas::text_width("R$", TEXT_SCALE)) / 2,
+            logo_top + (LOGO_SIZE - canvas::text_height(TEXT_SCALE)) / 2,
+            "R$",
+            TEXT_SCALE,
+            BRAND_COLOR,
+        );
+        image.text(
+            PADDING + LOGO_SIZE + PADDING / 2,
+            (HEADER_HEIGHT - canvas::text_height(TITLE_SCALE)) / 2,
+            &self.title,
+            TITLE_SCALE,
+            BACKGROUND_COLOR,
+        );
+
+        let mut y = HEADER_HEIGHT + PADDING;
+        for (title, rows) in &sections {
+            image.text(PADDING, y + text_offset, title, TEXT_SCALE, BRAND_COLOR);
+            y += ROW_HEIGHT;
+            image.fill_rect(PADDING, y, table_width, ROW_HEIGHT, HEADING_ROW_COLOR);
+            draw_row(
+                &mut image,
+                y + text_offset,
+                &headings,
+                &column_widths,
+                TEXT_COLOR,
+            );
+            y += ROW_HEIGHT;
+            for (index, row) in rows.iter().enumerate() {
+                if index % 2 == 1 {
+                    image.fill_rect(PADDING, y, table_width, ROW_HEIGHT, STRIPE_COLOR);
+                }
+                draw_row(&mut image, y + text_offset, row, &column_widths, TEXT_COLOR);
+                y += ROW_HEIGHT;
+            }
+            y += PADDING;
+        }
+
+        for line in &footer {
+            image.text(PADDING, y, line, TEXT_SCALE, MUTED_COLOR);
+            y += LINE_HEIGHT;
+        }
+
+        image.png()
+    }
+}
+
+/// One line per sample amount comparing its GBP price under `old` and `new`.
+pub fn change_lines(old: &RateCard, new: &RateCard, price_type: PriceType) -> Vec<String> {
+    SAMPLE_AMOUNTS
+        .iter()
+        .map(|&robux| {
+            format!(
+                "`{:>6}` £{:.2} → **£{:.2}**",
+                amount_label(robux),
+                old.gbp_price(robux as f64, price_type),
+                new.gbp_price(robux as f64, price_type)
+            )
+        })
+        .collect()
+}
+
+/// e.g. `10k R$`.
+fn amount_label(robux: u64) -> String {
+    format!("{}k R$", robux / 1000)
+}
+
+/// Draws one table row: the first cell left-aligned, the prices right-aligned.
+fn draw_row(image: &mut Canvas, y: u32, cells: &[String], widths: &[u32], rgb: u32) {
+    let mut x = PADDING;
+    for (index, (cell, width)) in cells.iter().zip(widths).enumerate() {
+        let left = if index == 0 {
+            x + CELL_PADDING
+        } else {
+            x + width - CELL_PADDING - canvas::text_width(cell, TEXT_SCALE)
+        };
+        image.text(left, y, cell, TEXT_SCALE, rgb);
+        x += width;
+    }
+}
+
+/// Bumped when the export format changes incompatibly.
+const EXPORT_VERSION: u32 = 1;
+/// Largest file `/ratecard import` will read; real exports are a few KB.
+pub const MAX_IMPORT_BYTES: u64 = 256 * 1024;
+const EXPORT_CSV_HEADER: &str = "type,name,role_id,gbp_per_1k,markup_percent,discount_percent,currency,roblox_username,value,surcharge_percent,fixed_fee_gbp,enabled";
+const EXPORT_CSV_COLUMNS: usize = 12;
+
+/// A guild's pricing configuration, as exported by `/ratecard export` for
+/// backups or to copy to another server. Prices and markups are the effective
+/// ones, defaults included, so the file describes the card on its own.
+#[derive(Serialize, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct PricingExport {
+    pub version: u32,
+    pub gbp_per_1k: f64,
+    pub markup_percent: f64,
+    pub fx_margin_percent: f64,
+    pub tax: Option<TaxSettings>,
+    pub display_currencies: Vec<String>,
+    pub role_pricing: Vec<RolePricing>,
+    pub sellers: Vec<SellerProfile>,
+    /// Missing from files exported before payment methods existed.
+    #[serde(default)]
+    pub payment_methods: Vec<PaymentMethod>,
+}
+
+impl PricingExport {
+    pub fn new(settings: &GuildSettings) -> Self {
+        Self {
+            version: EXPORT_VERSION,
+            gbp_per_1k: settings.gbp_per_robux() * 1000.0,
+            markup_percent: settings.markup_rate() * 100.0,
+            fx_margin_percent: settings.fx_margin_percent,
+            tax: settings.tax.clone(),
+            display_currencies: settings.display_currencies(),
+            role_pricing: settings.role_pricing.clone(),
+            sellers: settings.sellers.clone(),
+            payment_methods: settings.payment_methods.clone(),
+        }
+    }
+
+    /// Reads a JSON export, refusing unknown fields so a misspelt setting isn't
+    /// silently dropped, and checks every value is one the matching command
+    /// would accept. Currencies are normalised but not priced; that needs the
+    /// rate service.
+    pub fn parse(data: &[u8]) -> Result<Self, String> {
+        let mut export: Self =
+            serde_json::from_slice(data).map_err(|e| format!("Invalid rate card file: {}", e))?;
+        if export.version != EXPORT_VERSION {
+            return Err(format!(
+                "Rate card files of version {} can't be imported; export it again",
+                export.version
+            ));
+        }
+        if export.gbp_per_1k <= 0.0 {
+            return Err("The rate must be more than £0".to_string());
+        }
+        check_markup(export.markup_percent)?;
+        if !(0.0..=MAX_FX_MARGIN_PERCENT).contains(&export.fx_margin_percent) {
+            return Err(format!(
+                "The FX margin must be between 0% and {}%",
+                MAX_FX_MARGIN_PERCENT
+            ));
+        }
+        if let Some(tax) = &export.tax {
+            if !(0.0..=100.0).contains(&tax.rate_percent) || tax.label.trim().is_empty() {
+                return Err("The tax needs a rate between 0% and 100% and a label".to_string());
+            }
+        }
+
+        let mut currencies: Vec<String> = Vec::new();
+        for code in &export.display_currencies {
+            let code = rates::parse_currency(code)?;
+            if !currencies.contains(&code) {
+                currencies.push(code);
+            }
+        }
+        if currencies.is_empty() || currencies.len() > settings::MAX_DISPLAY_CURRENCIES {
+            return Err(format!(
+                "List between 1 and {} display currencies",
+                settings::MAX_DISPLAY_CURRENCIES
+            ));
+        }
+        export.display_currencies = currencies;
+
+        for (index, tier) in export.role_pricing.iter().enumerate() {
+            if export.role_pricing[..index]
+                .iter()
+                .any(|other| other.role_id == tier.role_id)
+            {
+                return Err(format!("Role {} is priced twice", tier.role_id));
+            }
+        }
+        for tier in &mut export.role_pricing {
+            if !(0.0..100.0).contains(&tier.discount_percent) {
+                return Err("Role discounts must be between 0% and 100%".to_string());
+            }
+            tier.currency = tier
+                .currency
+                .as_deref()
+                .map(rates::parse_currency)
+                .transpose()?;
+        }
+
+        for (index, seller) in export.sellers.iter().enumerate() {
+            let name = seller.name.trim();
+            if name.is_empty() {
+                return Err("Every seller needs a name".to_string());
+            }
+            if export.sellers[..index]
+                .iter()
+                .any(|other| other.name.trim().eq_ignore_ascii_case(name))
+            {
+                return Err(format!("Seller '{}' is listed twice", name));
+            }
+            if seller.gbp_per_1k <= 0.0 {
+                return Err(format!("{}'s rate must be more than £0", name));
+            }
+            if let Some(markup) = seller.markup_percent {
+                check_markup(markup)?;
+            }
+        }
+
+        for index in 0..export.payment_methods.len() {
+            let method = &export.payment_methods[index];
+            let name = method.name.trim();
+            if name.is_empty() {
+                return Err("Every payment method needs a name".to_string());
+            }
+            if export.payment_methods[..index]
+                .iter()
+                .any(|other| other.name.trim().eq_ignore_ascii_case(name))
+            {
+                return Err(format!("Payment method '{}' is listed twice", name));
+            }
+            if !(0.0..=MAX_PAYMENT_SURCHARGE_PERCENT).contains(&method.surcharge_percent) {
+                return Err(format!(
+                    "{}'s surcharge must be between 0% and {}%",
+                    name, MAX_PAYMENT_SURCHARGE_PERCENT
+                ));
+            }
+            if !(0.0..=MAX_PAYMENT_FEE_GBP).contains(&method.fixed_fee_gbp) {
+                return Err(format!(
+                    "{}'s fee must be between £0 and £{}",
+                    name, MAX_PAYMENT_FEE_GBP
+                ));
+            }
+            let mut currencies: Vec<String> = Vec::new();
+            for code in &method.currencies {
+                let code = rates::parse_currency(code)?;
+                if !currencies.contains(&code) {
+                    currencies.push(code);
+                }
+            }
+            export.payment_methods[index].currencies = currencies;
+        }
+        Ok(export)
+    }
+
+    /// What importing this into `current` would change, as the setting, its
+    /// old value and its new value, named as in the rate change history.
+    pub fn changes(&self, current: &GuildSettings) -> Vec<(String, String, String)> {
+        let mut changes = Vec::new();
+        let mut compare = |setting: String, old: String, new: String| {
+            if old != new {
+                changes.push((setting, old, new));
+            }
+        };
+        compare(
+            "Rate".to_string(),
+            format!("£{:.2} per 1k R$", current.gbp_per_robux() * 1000.0),
+            format!("£{:.2} per 1k R$", self.gbp_per_1k),
+        );
+        compare(
+            "Markup".to_string(),
+            format!("{}%", current.markup_rate() * 100.0),
+            format!("{}%", self.markup_percent),
+        );
+        compare(
+            "FX Margin".to_string(),
+            format!("{:.2}%", current.fx_margin_percent),
+            format!("{:.2}%", self.fx_margin_percent),
+        );
+        let describe_tax =
+            |tax: Option<&TaxSettings>| tax.map_or_else(|| "Off".to_string(), |tax| tax.describe());
+        compare(
+            "Tax".to_string(),
+            describe_tax(current.tax.as_ref()),
+            describe_tax(self.tax.as_ref()),
+        );
+        compare(
+            "Display Currencies".to_string(),
+            current.display_currencies().join(", "),
+            self.display_currencies.join(", "),
+        );
+
+        let mut role_ids: Vec<u64> = current
+            .role_pricing
+            .iter()
+            .chain(&self.role_pricing)
+            .map(|tier| tier.role_id)
+            .collect();
+        role_ids.sort_unstable();
+        role_ids.dedup();
+        for role_id in role_ids {
+            let describe = |tiers: &[RolePricing]| {
+                tiers
+                    .iter()
+                    .find(|tier| tier.role_id == role_id)
+                    .map_or_else(|| "None".to_string(), |tier| tier.describe())
+            };
+            compare(
+                format!("Role Pricing <@&{}>", role_id),
+                describe(&current.role_pricing),
+                describe(&self.role_pricing),
+            );
+        }
+
+        let mut names: Vec<String> = current
+            .sellers
+            .iter()
+            .chain(&self.sellers)
+            .map(|seller| seller.name.trim().to_string())
+            .collect();
+        names.sort_unstable_by_key(|name| name.to_lowercase());
+        names.dedup_by(|a, b| a.eq_ignore_ascii_case(b));
+        for name in names {
+            let describe = |sellers: &[SellerProfile]| {
+                sellers
+                    .iter()
+                    .find(|seller| seller.name.trim().eq_ignore_ascii_case(&name))
+                    .map_or_else(|| "None".to_string(), |seller| seller.describe())
+            };
+            compare(
+                format!("Seller {}", name),
+                describe(&current.sellers),
+                describe(&self.sellers),
+            );
+        }
+
+        let mut names: Vec<String> = current
+            .payment_methods
+            .iter()
+            .chain(&self.payment_methods)
+            .map(|method| method.name.trim().to_string())
+            .collect();
+        names.sort_unstable_by_key(|name| name.to_lowercase());
+        names.dedup_by(|a, b| a.eq_ignore_ascii_case(b));
+        for name in names {
+            let describe = |methods: &[PaymentMethod]| {
+                methods
+                    .iter()
+                    .find(|method| method.name.trim().eq_ignore_ascii_case(&name))
+                    .map_or_else(|| "None".to_string(), |method| method.describe())
+            };
+            compare(
+                format!("Payment Method {}", name),
+                describe(&current.payment_methods),
+                describe(&self.payment_methods),
+            );
+        }
+        changes
+    }
+
+    /// Replaces `guild`'s pricing with this configuration.
+    pub fn apply(self, guild: &mut GuildSettings) {
+        guild.gbp_per_1k = Some(self.gbp_per_1k);
+        guild.markup_percent = Some(self.markup_percent);
+        guild.fx_margin_percent = self.fx_margin_percent;
+        guild.tax = self.tax;
+        guild.display_currencies = self.display_currencies;
+        guild.role_pricing = self.role_pricing;
+        guild.sellers = self
+            .sellers
+            .into_iter()
+            .map(|seller| SellerProfile {
+                name: seller.name.trim().to_string(),
+                ..seller
+            })
+            .collect();
+        guild.payment_methods = self
+            .payment_methods
+            .into_iter()
+            .map(|method| PaymentMethod {
+                name: method.name.trim().to_string(),
+                ..method
+            })
+            .collect();
+    }
+
+    pub fn to_json(&self) -> Result<String, String> {
+        serde_json::to_string_pretty(self).map_err(|e| format!("Error serializing pricing: {}", e))
+    }
+
+    /// One row per setting, role tier, seller and payment method, with the
+    /// columns that don't apply to a row left empty.
+    pub fn to_csv(&self) -> String {
+        let mut rows = vec![
+            setting_row("version", &self.version.to_string()),
+            setting_row("gbp_per_1k", &self.gbp_per_1k.to_string()),
+            setting_row("markup_percent", &self.markup_percent.to_string()),
+            setting_row("fx_margin_percent", &self.fx_margin_percent.to_string()),
+            setting_row("display_currencies", &self.display_currencies.join(" ")),
+        ];
+        if let Some(tax) = &self.tax {
+            rows.push(setting_row(
+                "tax_rate_percent",
+                &tax.rate_percent.to_string(),
+            ));
+            rows.push(setting_row("tax_label", &tax.label));
+        }
+        for tier in &self.role_pricing {
+            rows.push(vec![
+                "role".to_string(),
+                String::new(),
+                tier.role_id.to_string(),
+                String::new(),
+                String::new(),
+                tier.discount_percent.to_string(),
+                tier.currency.clone().unwrap_or_default(),
+                String::new(),
+                String::new(),
+                String::new(),
+                String::new(),
+                String::new(),
+            ]);
+        }
+        for seller in &self.sellers {
+            rows.push(vec![
+                "seller".to_string(),
+                seller.name.clone(),
+                String::new(),
+                seller.gbp_per_1k.to_string(),
+                seller
+                    .markup_percent
+                    .map(|markup| markup.to_string())
+                    .unwrap_or_default(),
+                String::new(),
+                String::new(),
+                seller.roblox_username.clone().unwrap_or_default(),
+                String::new(),
+                String::new(),
+                String::new(),
+                String::new(),
+            ]);
+        }
+        for method in &self.payment_methods {
+            let mut row = vec![String::new(); EXPORT_CSV_COLUMNS];
+            row[0] = "payment".to_string();
+            row[1] = method.name.clone();
+            row[6] = method.currencies.join(" ");
+            row[9] = method.surcharge_percent.to_string();
+            row[10] = method.fixed_fee_gbp.to_string();
+            row[11] = method.enabled.to_string();
+            rows.push(row);
+        }
+
+        let mut csv = format!("{}\n", EXPORT_CSV_HEADER);
+        for row in rows {
+            let fields: Vec<String> = row.iter().map(|field| csv_field(field)).collect();
+            csv.push_str(&fields.join(","));
+            csv.push('\n');
+        }
+        csv
+    }
+}
+
+fn check_markup(markup: f64) -> Result<(), String> {
+    if !(0.0..=MAX_MARKUP_PERCENT).contains(&markup) {
+        return Err(format!(
+            "The markup must be between 0% and {}%",
+            MAX_MARKUP_PERCENT
+        ));
+    }
+    Ok(())
+}
+
+fn setting_row(name: &str, value: &str) -> Vec<String> {
+    let mut row = vec![String::new(); EXPORT_CSV_COLUMNS];
+    row[0] = "setting".to_string();
+    row[1] = name.to_string();
+    row[8] = value.to_string();
+    row
+}
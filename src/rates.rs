@@ -0,0 +1,845 @@
+use crate::{
+    api_keys::KeyRing,
+    breaker::{BreakerState, CircuitBreaker},
+    cache::Cache,
+    rate_provider::RateProvider,
+    settings::FallbackRate,
+    singleflight,
+    store::{JsonStore, Partition},
+    timezone, trace,
+};
+use reqwest::{header::HeaderMap, StatusCode};
+use serde::{Deserialize, Serialize};
+use serde_json::Value;
+use serenity::prelude::*;
+use std::{
+    collections::{BTreeMap, HashMap},
+    env,
+    sync::{
+        atomic::{AtomicU64, Ordering},
+        Arc,
+    },
+    time::{Duration, SystemTime, UNIX_EPOCH},
+};
+
+const DEFAULT_CACHE_TTL_SECS: u64 = 600;
+/// How long past the TTL a cached rate is still served while a fresh one is
+/// fetched in the background.
+const DEFAULT_STALE_GRACE_SECS: u64 = 1800;
+const LAST_KNOWN_RATES_FILE: &str = "rates.json";
+const STATIC_PROVIDER_NAME: &str = "static fallback table";
+const BREAKER_FAILURE_THRESHOLD: u32 = 3;
+const BREAKER_COOLDOWN_SECS: u64 = 60;
+
+/// Where a rate was served from.
+#[derive(Clone, Copy, PartialEq, Eq)]
+pub enum RateSource {
+    Live,
+    Cache,
+    LastKnownGood,
+    Static,
+}
+
+impl RateSource {
+    pub fn label(self) -> &'static str {
+        match self {
+            RateSource::Live => "Live",
+            RateSource::Cache => "Cache",
+            RateSource::LastKnownGood => "Last known good",
+            RateSource::Static => "Static fallback",
+        }
+    }
+}
+
+/// An exchange rate along with where and when it was obtained.
+#[derive(Clone)]
+pub struct Rate {
+    pub base: String,
+    pub quote: String,
+    pub value: f64,
+    pub provider: String,
+    pub fetched_at: SystemTime,
+    pub source: RateSource,
+    /// FX margin, in percent, already applied to `value`.
+    pub margin_percent: f64,
+}
+
+impl Rate {
+    /// Applies an FX margin on top of the mid-market rate, so amounts converted
+    /// into the quote currency come out higher.
+    pub fn with_margin(self, margin_percent: f64) -> Self {
+        Self {
+            value: self.value * (1.0 + margin_percent / 100.0),
+            margin_percent,
+            ..self
+        }
+    }
+
+    /// The rate before any FX margin was applied.
+    pub fn mid_market(&self) -> f64 {
+        self.value / (1.0 + self.margin_percent / 100.0)
+    }
+
+    pub fn fetched_at_unix(&self) -> u64 {
+        unix_secs(self.fetched_at)
+    }
+
+    /// Discord timestamp markup for when the rate was fetched, if that is known.
+    pub fn fetched_at_markup(&self, style: char) -> Option<String> {
+        (self.fetched_at != UNIX_EPOCH).then(|| format!("<t:{}:{}>", self.fetched_at_unix(), style))
+    }
+
+    /// A warning for users when the rate did not come from the exchange API within
+    /// the cache TTL.
+    pub fn stale_warning(&self) -> Option<String> {
+        match self.source {
+            RateSource::Live | RateSource::Cache => None,
+            _ => Some(match self.fetched_at_markup('f') {
+                Some(as_of) => format!("Rate may be stale (as of {})", as_of),
+                None => "Rate may be stale (static fallback rate)".to_string(),
+            }),
+        }
+    }
+}
+
+impl Rate {
+    /// What a calculation used this rate as, for the record.
+    pub fn snapshot(&self) -> RateSnapshot {
+        RateSnapshot {
+            base: self.base.clone(),
+            quote: self.quote.clone(),
+            value: self.value,
+            margin_percent: self.margin_percent,
+            provider: self.provider.clone(),
+            source: self.source.label().to_string(),
+            fetched_at: self.fetched_at_unix(),
+        }
+    }
+}
+
+/// The exact rate a calculation or order used, stored with it so the numbers
+/// can be reproduced later, e.g. in a dispute.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct RateSnapshot {
+    pub base: String,
+    pub quote: String,
+    /// The rate applied, FX margin included.
+    pub value: f64,
+    #[serde(default)]
+    pub margin_percent: f64,
+    pub provider: String,
+    /// Where the rate was served from, as in `RateSource::label`.
+    pub source: String,
+    /// Unix timestamp of when the rate was fetched, or 0 for an undated
+    /// static fallback rate.
+    pub fetched_at: u64,
+}
+
+impl RateSnapshot {
+    /// e.g. `GBP/USD 1.27120 via open.er-api.com as of 17 Oct 2026 13:00 UTC+01:00`
+    /// in the viewer's timezone, for embed footers, where Discord timestamps
+    /// don't render. Rates that didn't come from the API within the cache TTL
+    /// say so, so staff can tell when prices are running on degraded data.
+    pub fn stamp(&self, utc_offset_minutes: i32) -> String {
+        let as_of = (self.fetched_at > 0).then(|| {
+            format!(
+                "{} {}",
+                timezone::format(self.fetched_at, utc_offset_minutes, "%-d %b %Y %H:%M"),
+                timezone::label(utc_offset_minutes)
+            )
+        });
+        self.line(as_of)
+    }
+
+    /// As `stamp`, with the fetch time as Discord timestamp markup so each
+    /// viewer sees it in their own time, for embed fields.
+    pub fn describe(&self) -> String {
+        self.line((self.fetched_at > 0).then(|| format!("<t:{}:f>", self.fetched_at)))
+    }
+
+    fn line(&self, as_of: Option<String>) -> String {
+        let as_of = as_of
+            .map(|as_of| format!(" as of {}", as_of))
+            .unwrap_or_default();
+        let degraded = if self.source == RateSource::Live.label()
+            || self.source == RateSource::Cache.label()
+        {
+            String::new()
+        } else {
+            format!(" • {} rate", self.source)
+        };
+        format!(
+            "{}/{} {:.5} via {}{}{}",
+            self.base, self.quote, self.value, self.provider, as_of, degraded
+        )
+    }
+}
+
+/// Every rate quoted against one base currency, as returned by a single request.
+#[derive(Serialize, Deserialize, Clone)]
+struct RateTable {
+    rates: HashMap<String, f64>,
+    fetched_at: SystemTime,
+}
+
+/// The last rate successfully fetched for a pair, persisted across restarts.
+#[derive(Serialize, Deserialize, Clone)]
+struct LastKnownRate {
+    value: f64,
+    provider: String,
+    fetched_at: u64,
+}
+
+/// Fetches exchange rates from the configured API. Each request returns the full
+/// table for a base currency, which is cached for a TTL so that every pair sharing
+/// that base (or quoting it) is served without another request.
+///
+/// Tables are kept in the bot's cache, which instances sharing a Redis cache
+/// fill for each other. They are also persisted as they are fetched and loaded
+/// back into the cache on startup, so a restart within the TTL makes no
+/// requests. Once a table
+/// expires it is still served for a grace period while the fresh one is
+/// fetched in the background.
+///
+/// When the API is unavailable, or the circuit breaker has tripped after repeated
+/// failures, the last known good rate is served instead, and failing that the
+/// operator-configured fallback table.
+pub struct RateService {
+    client: reqwest::Client,
+    provider: RateProvider,
+    keys: KeyRing,
+    ttl: Duration,
+    stale_grace: Duration,
+    cache: Cache,
+    last_known: JsonStore<HashMap<String, LastKnownRate>>,
+    fallback_rates: HashMap<String, FallbackRate>,
+    breaker: CircuitBreaker,
+    inflight: singleflight::Group<Result<RateTable, String>>,
+    /// Lookups served from the cache, fresh or in its grace period.
+    cache_hits: AtomicU64,
+    /// Lookups that had to wait for a request to the API.
+    cache_misses: AtomicU64,
+    /// When a table was last fetched from the API, as a Unix timestamp.
+    last_fetch: AtomicU64,
+}
+
+impl RateService {
+    /// Builds the service around `provider`, reading the cache TTL from
+    /// `RATE_CACHE_TTL_SECS` and the grace period from `RATE_STALE_GRACE_SECS`.
+    /// Last known rates are kept in `partition` and tables in `cache`.
+    pub async fn new(
+        partition: &Partition,
+        cache: Cache,
+        provider: RateProvider,
+        keys: KeyRing,
+        fallback_rates: HashMap<String, FallbackRate>,
+    ) -> Result<Self, String> {
+        if provider.needs_key() && keys.is_empty() {
+            return Err(format!(
+                "The {} rate provider requires RATE_API_KEYS or RATE_API_KEY to be set",
+                provider.name()
+            ));
+        }
+
+        let ttl = env::var("RATE_CACHE_TTL_SECS")
+            .ok()
+            .and_then(|ttl| ttl.parse().ok())
+            .unwrap_or(DEFAULT_CACHE_TTL_SECS);
+        let stale_grace = env::var("RATE_STALE_GRACE_SECS")
+            .ok()
+            .and_then(|grace| grace.parse().ok())
+            .unwrap_or(DEFAULT_STALE_GRACE_SECS);
+        let last_known = JsonStore::open(partition, LAST_KNOWN_RATES_FILE)?;
+        let restored = last_known
+            .try_read()
+            .map(|last_known| restore_tables(&last_known, provider.name()))
+            .unwrap_or_default();
+        let last_fetch = restored
+            .values()
+            .map(|table: &RateTable| unix_secs(table.fetched_at))
+            .max()
+            .unwrap_or_default();
+        let max_age = Duration::from_secs(ttl + stale_grace);
+        for (base, table) in &restored {
+            // A table another instance already cached is at least as fresh.
+            let age = table.fetched_at.elapsed().unwrap_or(Duration::MAX);
+            if let (Some(ttl), Ok(value)) = (max_age.checked_sub(age), serde_json::to_string(table))
+            {
+                cache
+                    .set_new(&table_key(provider.name(), base), &value, ttl)
+                    .await;
+            }
+        }
+
+        Ok(Self {
+            client: reqwest::Client::new(),
+            provider,
+            keys,
+            ttl: Duration::from_secs(ttl),
+            stale_grace: Duration::from_secs(stale_grace),
+            cache,
+            last_known,
+            fallback_rates: fallback_rates
+                .into_iter()
+                .map(|(pair, rate)| (pair.to_uppercase(), rate))
+                .collect(),
+            breaker: CircuitBreaker::new(
+                BREAKER_FAILURE_THRESHOLD,
+                Duration::from_secs(BREAKER_COOLDOWN_SECS),
+            ),
+            inflight: singleflight::Group::new(),
+            cache_hits: AtomicU64::new(0),
+            cache_misses: AtomicU64::new(0),
+            last_fetch: AtomicU64::new(last_fetch),
+        })
+    }
+
+    /// Returns the `base`/`quote` rate, serving it from the cache while it is
+    /// fresh, or while it is in its grace period as it is refreshed.
+    pub async fn get(self: &Arc<Self>, base: &str, quote: &str) -> Result<Rate, String> {
+        let key = (base.to_uppercase(), quote.to_uppercase());
+        if key.0 == key.1 {
+            return Ok(self.rate(&key, 1.0, SystemTime::now(), RateSource::Live));
+        }
+
+        if let Some(rate) = self.cached(&key, self.ttl).await {
+            self.cache_hits.fetch_add(1, Ordering::Relaxed);
+            return Ok(rate);
+        }
+        if let Some(rate) = self.cached(&key, self.ttl + self.stale_grace).await {
+            self.cache_hits.fetch_add(1, Ordering::Relaxed);
+            self.refresh_in_background(&key.0);
+            return Ok(rate);
+        }
+
+        self.cache_misses.fetch_add(1, Ordering::Relaxed);
+        let table = self.inflight.run(&key.0, || self.refresh(&key.0)).await;
+        let value = table.and_then(|table| {
+            table
+                .rates
+                .get(&key.1)
+                .map(|value| (*value, table.fetched_at))
+                .ok_or_else(|| format!("No exchange rate available for {}/{}", key.0, key.1))
+        });
+
+        match value {
+            Ok((value, fetched_at)) => Ok(self.rate(&key, value, fetched_at, RateSource::Live)),
+            Err(error) => {
+                log::warn!("{}; trying fallback rates", error);
+                self.fallback(&key).await.ok_or(error)
+            }
+        }
+    }
+
+    /// Returns `base` against each of `quotes`, in order, with one request for
+    /// the whole table however many quotes miss the cache. Quotes with no rate
+    /// anywhere, even in the fallbacks, are left out.
+    pub async fn get_many(self: &Arc<Self>, base: &str, quotes: &[String]) -> Vec<Rate> {
+        let base = base.to_uppercase();
+        let mut rates = Vec::with_capacity(quotes.len());
+        let mut expiring = false;
+        for quote in quotes {
+            let key = (base.clone(), quote.to_uppercase());
+            let rate = if key.0 == key.1 {
+                Some(self.rate(&key, 1.0, SystemTime::now(), RateSource::Live))
+            } else {
+                match self.cached(&key, self.ttl).await {
+                    Some(rate) => Some(rate),
+                    None => {
+                        let stale = self.cached(&key, self.ttl + self.stale_grace).await;
+                        expiring |= stale.is_some();
+                        stale
+                    }
+                }
+            };
+            rates.push((key, rate));
+        }
+        if expiring {
+            self.refresh_in_background(&base);
+        }
+        let misses = rates.iter().filter(|(_, rate)| rate.is_none()).count() as u64;
+        self.cache_hits
+            .fetch_add(rates.len() as u64 - misses, Ordering::Relaxed);
+        self.cache_misses.fetch_add(misses, Ordering::Relaxed);
+
+        if rates.iter().any(|(_, rate)| rate.is_none()) {
+            let table = self.inflight.run(&base, || self.refresh(&base)).await;
+            if let Err(error) = &table {
+                log::warn!("{}; trying fallback rates", error);
+            }
+            for (key, rate) in rates.iter_mut().filter(|(_, rate)| rate.is_none()) {
+                *rate = match table
+                    .as_ref()
+                    .ok()
+                    .and_then(|table| Some((*table.rates.get(&key.1)?, table.fetched_at)))
+                {
+                    Some((value, fetched_at)) => {
+                        Some(self.rate(key, value, fetched_at, RateSource::Live))
+                    }
+                    None => self.fallback(key).await,
+                };
+            }
+        }
+
+        rates
+            .into_iter()
+            .filter_map(|(key, rate)| {
+                if rate.is_none() {
+                    log::error!("No exchange rate available for {}", pair_name(&key));
+                }
+                rate
+            })
+            .collect()
+    }
+
+    /// Fetches the tables behind `pairs` ahead of the first command that needs
+    /// them, one request per base currency. Returns the pairs that couldn't be
+    /// fetched from the API.
+    pub async fn warm(self: &Arc<Self>, pairs: &[(String, String)]) -> Vec<String> {
+        let mut by_base: BTreeMap<String, Vec<String>> = BTreeMap::new();
+        for (base, quote) in pairs {
+            by_base
+                .entry(base.to_uppercase())
+                .or_default()
+                .push(quote.to_uppercase());
+        }
+
+        let mut missed = Vec::new();
+        for (base, quotes) in by_base {
+            let rates = self.get_many(&base, &quotes).await;
+            for quote in quotes {
+                let live = rates.iter().any(|rate| {
+                    rate.quote == quote
+                        && matches!(rate.source, RateSource::Live | RateSource::Cache)
+                });
+                if !live {
+                    missed.push(format!("{}/{}", base, quote));
+                }
+            }
+        }
+        missed
+    }
+
+    /// Serves a pair from a cached table for either of its currencies fetched
+    /// less than `max_age` ago.
+    async fn cached(&self, key: &(String, String), max_age: Duration) -> Option<Rate> {
+        let fresh = |table: RateTable| {
+            (table.fetched_at.elapsed().unwrap_or(Duration::MAX) < max_age).then_some(table)
+        };
+
+        let direct = self
+            .table(&key.0)
+            .await
+            .and_then(fresh)
+            .and_then(|table| Some((*table.rates.get(&key.1)?, table.fetched_at)));
+        let (value, fetched_at) = match direct {
+            Some(found) => found,
+            None => self
+                .table(&key.1)
+                .await
+                .and_then(fresh)
+                .and_then(|table| Some((1.0 / *table.rates.get(&key.0)?, table.fetched_at)))?,
+        };
+
+        Some(self.rate(key, value, fetched_at, RateSource::Cache))
+    }
+
+    /// The cached table for `base`, however old.
+    async fn table(&self, base: &str) -> Option<RateTable> {
+        let value = self
+            .cache
+            .get(&table_key(self.provider.name(), base))
+            .await?;
+        serde_json::from_str(&value).ok()
+    }
+
+    /// Refreshes `base`'s table without making the caller wait for it.
+    fn refresh_in_background(self: &Arc<Self>, base: &str) {
+        let service = Arc::clone(self);
+        let base = base.to_string();
+        tokio::spawn(async move {
+            if let Err(error) = service.inflight.run(&base, || service.refresh(&base)).await {
+                log::error!("Error refreshing {} rates: {}", base, error);
+            }
+        });
+    }
+
+    /// Fetches the table for `base` and records it in the cache and the last known
+    /// good store.
+    async fn refresh(&self, base: &str) -> Result<RateTable, String> {
+        let table = RateTable {
+            rates: self.fetch(base).await?,
+            fetched_at: SystemTime::now(),
+        };
+
+        match serde_json::to_string(&table) {
+            Ok(value) => {
+                self.cache
+                    .set(
+                        &table_key(self.provider.name(), base),
+                        &value,
+                        self.ttl + self.stale_grace,
+                    )
+                    .await
+            }
+            Err(error) => log::error!("Error caching {} rates: {}", base, error),
+        }
+        self.remember(base, &table).await;
+        self.last_fetch
+            .store(unix_secs(table.fetched_at), Ordering::Relaxed);
+
+        Ok(table)
+    }
+
+    pub fn provider_name(&self) -> &str {
+        self.provider.name()
+    }
+
+    /// The share of lookups served from the cache, as a percentage, or `None`
+    /// before the first lookup.
+    pub fn cache_hit_rate(&self) -> Option<f64> {
+        let hits = self.cache_hits.load(Ordering::Relaxed);
+        let total = hits + self.cache_misses.load(Ordering::Relaxed);
+        (total > 0).then(|| hits as f64 * 100.0 / total as f64)
+    }
+
+    /// When rates were last fetched from the API, as a Unix timestamp,
+    /// including fetches from before a restart.
+    pub fn last_fetch(&self) -> Option<u64> {
+        Some(self.last_fetch.load(Ordering::Relaxed)).filter(|&at| at > 0)
+    }
+
+    pub fn breaker_state(&self) -> BreakerState {
+        self.breaker.state()
+    }
+
+    pub fn keys(&self) -> &KeyRing {
+        &self.keys
+    }
+
+    /// Fetches every rate quoted against `base`, short-circuiting while the breaker
+    /// is open. Only transport failures and server errors count against the
+    /// breaker; a client error (e.g. an unknown currency) means the upstream is
+    /// healthy and the request itself was rejected.
+    ///
+    /// When the API reports that the active key is out of quota, the key is taken
+    /// out of rotation and the request is retried with the next one.
+    async fn fetch(&self, base: &str) -> Result<HashMap<String, f64>, String> {
+        loop {
+            let key =
+                match self.provider.needs_key() {
+                    true => Some(self.keys.active().await.ok_or(
+                        "All exchange API keys have reached their monthly quota".to_string(),
+                    )?),
+                    false => None,
+                };
+
+            if !self.breaker.allow() {
+                return Err(
+                    "Exchange rate API is temporarily disabled after repeated failures".to_string(),
+                );
+            }
+
+            let response = self.request(base, key.as_deref()).await;
+            match response {
+                Ok(_) => self.breaker.record_success(),
+                Err(_) => self.breaker.record_failure(),
+            }
+
+            let (status, headers, body) = response?;
+            let rates = self.provider.extract_rates(&body);
+            if let Some(key) = &key {
+                self.keys
+                    .record_call(key, self.provider.remaining_quota(&headers))
+                    .await;
+
+                if self.provider.is_quota_exhausted(status, &rates) {
+                    self.keys.mark_exhausted(key).await;
+                    continue;
+                }
+            }
+
+            if status.is_client_error() {
+                return Err(rates.err().unwrap_or_else(|| {
+                    format!("Exchange rate API rejected the request ({})", status)
+                }));
+            }
+
+            return rates;
+        }
+    }
+
+    async fn request(
+        &self,
+        base: &str,
+        key: Option<&str>,
+    ) -> Result<(StatusCode, HeaderMap, Value), String> {
+        let response = trace::send(
+            "exchange_rates.fetch",
+            vec![
+                ("rates.provider", self.provider.name().to_string()),
+                ("rates.base", base.to_string()),
+            ],
+            self.provider.request(&self.client, base, key),
+        )
+        .await
+        .map_err(|e| format!("Error fetching exchange rates: {}", e))?;
+
+        let status = response.status();
+        if status.is_server_error() {
+            return Err(format!("Exchange rate API returned {}", status));
+        }
+
+        let headers = response.headers().clone();
+        match response.json().await {
+            Ok(body) => Ok((status, headers, body)),
+            Err(_) if status.is_client_error() => Ok((status, headers, Value::Null)),
+            Err(e) => Err(format!("Error parsing exchange rates: {}", e)),
+        }
+    }
+
+    async fn remember(&self, base: &str, table: &RateTable) {
+        let fetched_at = unix_secs(table.fetched_at);
+
+        let result = self
+            .last_known
+            .update(|last_known| {
+                for (quote, value) in &table.rates {
+                    last_known.insert(
+                        format!("{}/{}", base, quote),
+                        LastKnownRate {
+                            value: *value,
+                            provider: self.provider.name().to_string(),
+                            fetched_at,
+                        },
+                    );
+                }
+            })
+            .await;
+        if let Err(error) = result {
+            log::error!("Error saving last known rates: {}", error);
+        }
+    }
+
+    /// Looks up the last known good rate, then the static fallback table, trying
+    /// each in both directions of the pair.
+    async fn fallback(&self, key: &(String, String)) -> Option<Rate> {
+        let inverse = (key.1.clone(), key.0.clone());
+
+        {
+            let last_known = self.last_known.read().await;
+            let found = last_known
+                .get(&pair_name(key))
+                .map(|rate| (rate.clone(), false))
+                .or_else(|| {
+                    last_known
+                        .get(&pair_name(&inverse))
+                        .map(|rate| (rate.clone(), true))
+                });
+
+            if let Some((rate, inverted)) = found {
+                let value = if inverted {
+                    1.0 / rate.value
+                } else {
+                    rate.value
+                };
+                return Some(Rate {
+                    provider: rate.provider,
+                    ..self.rate(
+                        key,
+                        value,
+                        UNIX_EPOCH + Duration::from_secs(rate.fetched_at),
+                        RateSource::LastKnownGood,
+                    )
+                });
+            }
+        }
+
+        let (rate, inverted) = self
+            .fallback_rates
+            .get(&pair_name(key))
+            .map(|rate| (*rate, false))
+            .or_else(|| {
+                self.fallback_rates
+                    .get(&pair_name(&inverse))
+                    .map(|rate| (*rate, true))
+            })?;
+
+        let value = if inverted {
+            1.0 / rate.value
+        } else {
+            rate.value
+        };
+        Some(Rate {
+            provider: STATIC_PROVIDER_NAME.to_string(),
+            ..self.rate(
+                key,
+                value,
+                UNIX_EPOCH + Duration::from_secs(rate.as_of.unwrap_or_default()),
+                RateSource::Static,
+            )
+        })
+    }
+
+    fn rate(
+        &self,
+        key: &(String, String),
+        value: f64,
+        fetched_at: SystemTime,
+        source: RateSource,
+    ) -> Rate {
+        Rate {
+            base: key.0.clone(),
+            quote: key.1.clone(),
+            value,
+            provider: self.provider.name().to_string(),
+            fetched_at,
+            source,
+            margin_percent: 0.0,
+        }
+    }
+}
+
+fn unix_secs(at: SystemTime) -> u64 {
+    at.duration_since(UNIX_EPOCH)
+        .map(|d| d.as_secs())
+        .unwrap_or_default()
+}
+
+/// Rebuilds the cache from the last known good rates, keeping each base's
+/// most recent table as long as it came from `provider`.
+fn restore_tables(
+    last_known: &HashMap<String, LastKnownRate>,
+    provider: &str,
+) -> HashMap<String, RateTable> {
+    let mut latest: HashMap<String, u64> = HashMap::new();
+    for (pair, rate) in last_known {
+        if let Some((base, _)) = pair.split_once('/') {
+            let fetched_at = latest.entry(base.to_string()).or_default();
+            *fetched_at = (*fetched_at).max(rate.fetched_at);
+        }
+    }
+
+    let mut tables: HashMap<String, RateTable> = HashMap::new();
+    for (pair, rate) in last_known {
+        let (base, quote) = match pair.split_once('/') {
+            Some(pair) => pair,
+            None => continue,
+        };
+        if rate.provider != provider || latest.get(base) != Some(&rate.fetched_at) {
+            continue;
+        }
+        tables
+            .entry(base.to_string())
+            .or_insert_with(|| RateTable {
+                rates: HashMap::new(),
+                fetched_at: UNIX_EPOCH + Duration::from_secs(rate.fetched_at),
+            })
+            .rates
+            .insert(quote.to_string(), rate.value);
+    }
+    tables
+}
+
+/// The cache key for `provider`'s table for `base`. Providers quote slightly
+/// different rates, so their tables are kept apart.
+fn table_key(provider: &str, base: &str) -> String {
+    format!("rates:{}:{}", provider, base)
+}
+
+fn pair_name(key: &(String, String)) -> String {
+    format!("{}/{}", key.0, key.1)
+}
+
+pub struct RatesKey;
+
+impl TypeMapKey for RatesKey {
+    type Value = Arc<RateService>;
+}
+
+pub async fn service(ctx: &Context) -> Result<Arc<RateService>, String> {
+    ctx.data
+        .read()
+        .await
+        .get::<RatesKey>()
+        .cloned()
+        .ok_or_else(|| "Exchange rate service unavailable".to_string())
+}
+
+/// Symbols and names people write instead of ISO codes. `R$` is left out:
+/// here it means Robux, not Brazilian reais.
+const CURRENCY_ALIASES: &[(&str, &str)] = &[
+    ("£", "GBP"),
+    ("pound", "GBP"),
+    ("pounds", "GBP"),
+    ("quid", "GBP"),
+    ("sterling", "GBP"),
+    ("$", "USD"),
+    ("us$", "USD"),
+    ("dollar", "USD"),
+    ("dollars", "USD"),
+    ("buck", "USD"),
+    ("bucks", "USD"),
+    ("€", "EUR"),
+    ("euro", "EUR"),
+    ("euros", "EUR"),
+    ("c$", "CAD"),
+    ("ca$", "CAD"),
+    ("a$", "AUD"),
+    ("au$", "AUD"),
+    ("nz$", "NZD"),
+    ("¥", "JPY"),
+    ("yen", "JPY"),
+    ("₹", "INR"),
+    ("rupee", "INR"),
+    ("rupees", "INR"),
+    ("₱", "PHP"),
+    ("zł", "PLN"),
+];
+
+/// Parses a currency as an ISO code (`EUR`, `cad`), a symbol (`£`, `€`) or a
+/// name (`pounds`, `quid`, `bucks`), returning the ISO code.
+pub fn parse_currency(currency: &str) -> Result<String, String> {
+    let currency = currency.trim();
+    let lowercase = currency.to_lowercase();
+    if let Some((_, code)) = CURRENCY_ALIASES
+        .iter()
+        .find(|(alias, _)| *alias == lowercase)
+    {
+        return Ok(code.to_string());
+    }
+    if currency.len() != 3 || !currency.chars().all(|c| c.is_ascii_alphabetic()) {
+        return Err(format!(
+            "'{}' isn't a currency. Use a code like EUR, a symbol like € or a name like pounds.",
+            currency
+        ));
+    }
+    Ok(currency.to_uppercase())
+}
+
+/// Parses a currency pair such as `GBP/USD`, `gbp-usd`, `GBPUSD`, `£/$` or
+/// `pounds to euros`.
+pub fn parse_pair(pair: &str) -> Result<(String, String), String> {
+    let invalid = || {
+        format!(
+            "Invalid currency pair '{}'. Use a format like GBP/USD.",
+            pair
+        )
+    };
+    let parts: Vec<&str> = pair
+        .split(|c: char| matches!(c, '/' | '-' | ':') || c.is_whitespace())
+        .filter(|part| !part.is_empty() && !part.eq_ignore_ascii_case("to"))
+        .collect();
+
+    match parts.as_slice() {
+        [base, quote] => Ok((
+            parse_currency(base).map_err(|_| invalid())?,
+            parse_currency(quote).map_err(|_| invalid())?,
+        )),
+        [letters] if letters.len() == 6 && letters.chars().all(|c| c.is_ascii_alphabetic()) => {
+            let letters = letters.to_uppercase();
+            Ok((letters[..3].to_string(), letters[3..].to_string()))
+        }
+        _ => Err(invalid()),
+    }
+}
@@ -0,0 +1,191 @@
+use serenity::prelude::*;
+use std::time::Duration;
+use tokio::{
+    io::{AsyncBufReadExt, AsyncReadExt, AsyncWriteExt, BufReader},
+    net::TcpStream,
+};
+
+const DEFAULT_PORT: u16 = 6379;
+const COMMAND_TIMEOUT: Duration = Duration::from_secs(2);
+/// Values the cache stores are small; anything bigger is refused.
+const MAX_BULK_BYTES: usize = 16 * 1024 * 1024;
+
+/// A reply to a Redis command. Error replies are returned as `Err` instead.
+pub enum Reply {
+    Nil,
+    /// A simple string such as `OK`.
+    Status,
+    Integer(i64),
+    Bulk(Vec<u8>),
+}
+
+/// A minimal Redis client speaking RESP2 over a single connection, with just
+/// what the cache needs. Commands are sent one at a time, and the connection is
+/// reopened after a failure.
+pub struct Redis {
+    address: String,
+    username: Option<String>,
+    password: Option<String>,
+    database: u32,
+    connection: Mutex<Option<BufReader<TcpStream>>>,
+}
+
+impl Redis {
+    /// Parses `redis://[[username]:password@]host[:port][/database]`. TLS
+    /// (`rediss://`) isn't supported; use a local tunnel for it.
+    pub fn from_url(url: &str) -> Result<Self, String> {
+        let rest = url
+            .strip_prefix("redis://")
+            .ok_or_else(|| format!("{} is not a redis:// URL", url))?;
+        let (credentials, rest) = match rest.rsplit_once('@') {
+            Some((credentials, rest)) => (Some(credentials), rest),
+            None => (None, rest),
+        };
+        let (host, database) = match rest.split_once('/') {
+            Some((host, "")) => (host, 0),
+            Some((host, database)) => (
+                host,
+                database
+                    .parse()
+                    .map_err(|_| format!("{} is not a Redis database number", database))?,
+            ),
+            None => (rest, 0),
+        };
+        if host.is_empty() {
+            return Err("The Redis URL has no host".to_string());
+        }
+        let address = if host.contains(':') {
+            host.to_string()
+        } else {
+            format!("{}:{}", host, DEFAULT_PORT)
+        };
+        let (username, password) = match credentials.map(|c| c.split_once(':')) {
+            Some(Some((username, password))) => (
+                Some(username.to_string()).filter(|username| !username.is_empty()),
+                Some(password.to_string()),
+            ),
+            Some(None) => (None, credentials.map(String::from)),
+            None => (None, None),
+        };
+
+        Ok(Self {
+            address,
+            username,
+            password,
+            database,
+            connection: Mutex::new(None),
+        })
+    }
+
+    /// Sends `args` as one command and reads its reply.
+    pub async fn command(&self, args: &[&str]) -> Result<Reply, String> {
+        let mut connection = self.connection.lock().await;
+        if connection.is_none() {
+            *connection = Some(
+                tokio::time::timeout(COMMAND_TIMEOUT, self.connect())
+                    .await
+                    .map_err(|_| format!("Timed out connecting to Redis at {}", self.address))??,
+            );
+        }
+        let stream = connection.as_mut().ok_or("Not connected to Redis")?;
+        match tokio::time::timeout(COMMAND_TIMEOUT, round_trip(stream, args)).await {
+            Ok(Ok(reply)) => Ok(reply),
+            Ok(Err(Failure::Reply(error))) => Err(error),
+            Ok(Err(Failure::Connection(error))) => {
+                *connection = None;
+                Err(error)
+            }
+            Err(_) => {
+                // A reply may still arrive, so the connection can't be reused.
+                *connection = None;
+                Err(format!("Redis at {} timed out", self.address))
+            }
+        }
+    }
+
+    async fn connect(&self) -> Result<BufReader<TcpStream>, String> {
+        let stream = TcpStream::connect(&self.address)
+            .await
+            .map_err(|e| format!("Error connecting to Redis at {}: {}", self.address, e))?;
+        let mut stream = BufReader::new(stream);
+        let failed = |failure: Failure| match failure {
+            Failure::Reply(error) | Failure::Connection(error) => error,
+        };
+        if let Some(password) = &self.password {
+            let mut args = vec!["AUTH"];
+            if let Some(username) = &self.username {
+                args.push(username);
+            }
+            args.push(password);
+            round_trip(&mut stream, &args).await.map_err(failed)?;
+        }
+        if self.database != 0 {
+            round_trip(&mut stream, &["SELECT", &self.database.to_string()])
+                .await
+                .map_err(failed)?;
+        }
+        Ok(stream)
+    }
+}
+
+/// Why a command failed: Redis answered with an error, leaving the connection
+/// usable, or the connection itself broke.
+enum Failure {
+    Reply(String),
+    Connection(String),
+}
+
+async fn round_trip(stream: &mut BufReader<TcpStream>, args: &[&str]) -> Result<Reply, Failure> {
+    let mut request = format!("*{}\r\n", args.len());
+    for arg in args {
+        request.push_str(&format!("${}\r\n{}\r\n", arg.len(), arg));
+    }
+    stream
+        .get_mut()
+        .write_all(request.as_bytes())
+        .await
+        .map_err(|e| Failure::Connection(format!("Error writing to Redis: {}", e)))?;
+
+    let broken =
+        |e: std::io::Error| Failure::Connection(format!("Error reading from Redis: {}", e));
+    let mut line = String::new();
+    stream.read_line(&mut line).await.map_err(broken)?;
+    let line = line.trim_end_matches(['\r', '\n']);
+    if line.is_empty() {
+        return Err(Failure::Connection(
+            "Redis closed the connection".to_string(),
+        ));
+    }
+    let (kind, value) = line.split_at(1);
+    match kind {
+        "+" => Ok(Reply::Status),
+        "-" => Err(Failure::Reply(format!("Redis error: {}", value))),
+        ":" => value
+            .parse()
+            .map(Reply::Integer)
+            .map_err(|_| Failure::Connection(format!("Bad integer reply from Redis: {}", value))),
+        "$" => {
+            let length: i64 = value
+                .parse()
+                .map_err(|_| Failure::Connection(format!("Bad reply from Redis: {}", line)))?;
+            if length < 0 {
+                return Ok(Reply::Nil);
+            }
+            let length = length as usize;
+            if length > MAX_BULK_BYTES {
+                return Err(Failure::Connection(format!(
+                    "Redis reply of {} bytes is too large",
+                    length
+                )));
+            }
+            let mut body = vec![0; length + 2];
+            stream.read_exact(&mut body).await.map_err(broken)?;
+            body.truncate(length);
+            Ok(Reply::Bulk(body))
+        }
+        _ => Err(Failure::Connection(format!(
+            "Unexpected reply from Redis: {}",
+            line
+        ))),
+    }
+}
@@ -0,0 +1,136 @@
+use crate::{
+    commands::{self, CommandSpec},
+    store::{JsonStore, Partition},
+};
+use serde::{Deserialize, Serialize};
+use serenity::{builder::CreateApplicationCommand, model::id::GuildId, prelude::*};
+use std::collections::HashMap;
+
+const REGISTERED_COMMANDS_FILE: &str = "commands.json";
+
+/// Hashes of the command definitions last registered with Discord, so a restart
+/// only sends the commands that actually changed.
+#[derive(Serialize, Deserialize, Default)]
+struct Registered {
+    guild_id: Option<u64>,
+    /// Definition hash per command name.
+    hashes: HashMap<String, String>,
+}
+
+/// Brings the guild's slash commands in line with the registry, tracking what
+/// was registered in `partition`. The first run, a new `GUILD_ID` or `force`
+/// overwrites them in bulk; otherwise only changed commands are created or
+/// updated and removed ones deleted.
+pub async fn sync(
+    ctx: &Context,
+    partition: &Partition,
+    guild_id: GuildId,
+    force: bool,
+) -> Result<(), String> {
+    let state: JsonStore<Registered> = JsonStore::open(partition, REGISTERED_COMMANDS_FILE)?;
+    let current: Vec<_> = commands::COMMANDS
+        .iter()
+        .map(|spec| (spec, definition_hash(spec)))
+        .collect();
+
+    let (same_guild, previous) = {
+        let state = state.read().await;
+        (state.guild_id == Some(guild_id.0), state.hashes.clone())
+    };
+    if force || !same_guild || previous.is_empty() {
+        return register_all(ctx, guild_id, &state, current).await;
+    }
+
+    let changed: Vec<_> = current
+        .into_iter()
+        .filter(|(spec, hash)| previous.get(spec.name) != Some(hash))
+        .collect();
+    let removed: Vec<_> = previous
+        .keys()
+        .filter(|name| commands::find(name).is_none())
+        .cloned()
+        .collect();
+    if changed.is_empty() && removed.is_empty() {
+        log::info!("Slash commands are up to date");
+        return Ok(());
+    }
+
+    // Creating a command with an existing name overwrites it, so this both adds
+    // new commands and edits changed ones.
+    for (spec, hash) in changed {
+        guild_id
+            .create_application_command(&ctx.http, |command| spec.register(command))
+            .await
+            .map_err(|e| format!("Error registering /{}: {:?}", spec.name, e))?;
+        state
+            .update(|state| state.hashes.insert(spec.name.to_string(), hash))
+            .await?;
+        log::info!("Registered /{}", spec.name);
+    }
+
+    if !removed.is_empty() {
+        let registered = guild_id
+            .get_application_commands(&ctx.http)
+            .await
+            .map_err(|e| format!("Error fetching registered commands: {:?}", e))?;
+        for name in removed {
+            if let Some(command) = registered.iter().find(|command| command.name == name) {
+                guild_id
+                    .delete_application_command(&ctx.http, command.id)
+                    .await
+                    .map_err(|e| format!("Error deleting /{}: {:?}", name, e))?;
+            }
+            state.update(|state| state.hashes.remove(&name)).await?;
+            log::info!("Deleted /{}", name);
+        }
+    }
+
+    Ok(())
+}
+
+async fn register_all(
+    ctx: &Context,
+    guild_id: GuildId,
+    state: &JsonStore<Registered>,
+    current: Vec<(&'static CommandSpec, String)>,
+) -> Result<(), String> {
+    let commands = guild_id
+        .set_application_commands(&ctx.http, |commands| {
+            for (spec, _) in &current {
+                commands.create_application_command(|command| spec.register(command));
+            }
+            commands
+        })
+        .await
+        .map_err(|e| format!("Error registering commands: {:?}", e))?;
+
+    state
+        .update(|state| {
+            state.guild_id = Some(guild_id.0);
+            state.hashes = current
+                .into_iter()
+                .map(|(spec, hash)| (spec.name.to_string(), hash))
+                .collect();
+        })
+        .await?;
+
+    log::info!("Registered the following slash commands: {:#?}", commands);
+    Ok(())
+}
+
+/// A stable hash of the JSON Discord receives for `spec`. Objects serialize with
+/// sorted keys, so the hash only changes when the definition does.
+fn definition_hash(spec: &CommandSpec) -> String {
+    let mut command = CreateApplicationCommand::default();
+    spec.register(&mut command);
+    let json = serde_json::to_value(&command.0)
+        .map(|value| value.to_string())
+        .unwrap_or_default();
+
+    // FNV-1a, which unlike the standard library's hasher is stable across
+    // Rust releases.
+    let hash = json.bytes().fold(0xcbf29ce484222325_u64, |hash, byte| {
+        (hash ^ byte as u64).wrapping_mul(0x100000001b3)
+    });
+    format!("{:016x}", hash)
+}
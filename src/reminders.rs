@@ -0,0 +1,78 @@
+use crate::{
+    cache::Cache,
+    orders::{self, Order},
+    outbound, store,
+};
+use serenity::{builder::CreateEmbed, model::id::ChannelId, prelude::*};
+use std::time::Duration;
+
+/// How often quote reminders are checked for.
+const CHECK_INTERVAL_SECS: u64 = 60;
+
+/// Starts sending the quote reminders set with `/remindme` once they are due,
+/// as long as the quote hasn't been taken up or cancelled. Call it once per
+/// bot; instances sharing `cache` take turns, so no reminder is sent twice.
+pub fn start(ctx: Context, cache: Cache) {
+    tokio::spawn(async move {
+        let interval = Duration::from_secs(CHECK_INTERVAL_SECS);
+        loop {
+            if cache.lease("reminders", interval).await.is_some() {
+                if let Err(error) = check(&ctx).await {
+                    log::error!("Error sending quote reminders: {}", error);
+                }
+            }
+            tokio::time::sleep(interval).await;
+        }
+    });
+}
+
+async fn check(ctx: &Context) -> Result<(), String> {
+    let orders = orders::store(ctx).await?;
+    for order in orders.reminders_due(store::now()).await {
+        // Cleared first, so a reminder that can't be sent isn't retried every
+        // minute.
+        orders
+            .update(order.id, |order| order.reminder = None)
+            .await?;
+        if let Err(error) = remind(ctx, &order).await {
+            log::error!("{}", error);
+        }
+    }
+    Ok(())
+}
+
+/// Pings the buyer, the staff member handling the quote and whoever set the
+/// reminder.
+async fn remind(ctx: &Context, order: &Order) -> Result<(), String> {
+    let reminder = match &order.reminder {
+        Some(reminder) => reminder,
+        None => return Ok(()),
+    };
+    let mut pinged = vec![order.buyer_id];
+    for id in order.claimed_by.into_iter().chain([reminder.set_by]) {
+        if !pinged.contains(&id) {
+            pinged.push(id);
+        }
+    }
+    let mentions: Vec<String> = pinged.iter().map(|id| format!("<@{}>", id)).collect();
+
+    let embed = CreateEmbed::default()
+        .title(format!("Quote #{} Reminder", order.id))
+        .description(format!(
+            "<@{}>'s quote for {} R$ from <t:{}:f> is still open. Ask staff to open the order to go ahead with it.",
+            order.buyer_id, order.robux, order.created_at
+        ))
+        .field("Total", format!("£{:.2}", order.total_gbp), true)
+        .field("Delivery", order.method.label(), true)
+        .color(0x0096FF)
+        .clone();
+
+    outbound::send(&ctx.http, || {
+        ChannelId(reminder.channel_id).send_message(&ctx.http, |message| {
+            message.content(mentions.join(" ")).set_embed(embed.clone())
+        })
+    })
+    .await
+    .map_err(|e| format!("Error sending reminder for quote #{}: {:?}", order.id, e))?;
+    Ok(())
+}
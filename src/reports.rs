@@ -0,0 +1,184 @@
+use crate::{
+    cache::Cache,
+    orders, outbound, owner_id,
+    period::Period,
+    rates,
+    settings::SettingsKey,
+    store::{JsonStore, Partition},
+};
+use chrono::{Datelike, Duration as ChronoDuration, Months, NaiveDate, Utc};
+use serde::{Deserialize, Serialize};
+use serenity::{
+    builder::CreateEmbed,
+    model::id::{ChannelId, UserId},
+    prelude::*,
+};
+use std::time::Duration;
+
+const REPORT_STATE_FILE: &str = "reports.json";
+const RETRY_SECS: u64 = 3600;
+
+/// The last month a report was delivered for, so a restart neither skips nor
+/// repeats a report.
+#[derive(Serialize, Deserialize, Default)]
+struct ReportState {
+    last_sent: Option<String>,
+}
+
+/// Starts the monthly report scheduler for the bot whose data is in
+/// `partition`. Call it once per bot; instances sharing `cache` take turns, so
+/// each report is sent once.
+pub fn start(ctx: Context, partition: &Partition, cache: Cache) {
+    let state = match JsonStore::open(partition, REPORT_STATE_FILE) {
+        Ok(state) => state,
+        Err(error) => {
+            log::error!("Monthly reports disabled: {}", error);
+            return;
+        }
+    };
+
+    tokio::spawn(schedule(ctx, state, cache));
+}
+
+async fn schedule(ctx: Context, state: JsonStore<ReportState>, cache: Cache) {
+    loop {
+        let today = Utc::now().date_naive();
+        let this_month = today.with_day(1).unwrap_or(today);
+        let last_month = this_month - ChronoDuration::days(1);
+        let period = Period::month(last_month.year(), last_month.month());
+
+        let mut delay = until_next_month(this_month);
+        // Another instance sharing the data directory may have sent it.
+        if let Err(error) = state.reload().await {
+            log::error!("Error reading report state: {}", error);
+        }
+        let due = state.read().await.last_sent.as_deref() != Some(period.label.as_str());
+        // The lease is left to expire, so the others don't retry until this
+        // instance would have.
+        if due
+            && cache
+                .lease("reports", Duration::from_secs(RETRY_SECS))
+                .await
+                .is_some()
+        {
+            match send(&ctx, &period).await {
+                Ok(true) => {
+                    let label = period.label.clone();
+                    if let Err(error) = state.update(|state| state.last_sent = Some(label)).await {
+                        log::error!("Error saving report state: {}", error);
+                    }
+                }
+                Ok(false) => {}
+                Err(error) => {
+                    log::error!("Error sending monthly report: {}", error);
+                    delay = delay.min(Duration::from_secs(RETRY_SECS));
+                }
+            }
+        }
+
+        tokio::time::sleep(delay).await;
+    }
+}
+
+/// Sends the report for `period` to the configured channel, or to the owner by
+/// DM. Returns whether it was delivered.
+async fn send(ctx: &Context, period: &Period) -> Result<bool, String> {
+    let settings = match ctx.data.read().await.get::<SettingsKey>().cloned() {
+        Some(settings) => settings,
+        None => return Ok(false),
+    };
+    let (enabled, channel_id) = {
+        let settings = settings.read().await;
+        (settings.reports.enabled, settings.reports.channel_id)
+    };
+    if !enabled {
+        return Ok(false);
+    }
+
+    let embed = build(ctx, period).await?;
+    let channel_id = match (channel_id, owner_id()) {
+        (Some(channel_id), _) => ChannelId(channel_id),
+        (None, Some(owner_id)) => {
+            UserId(owner_id)
+                .create_dm_channel(&ctx.http)
+                .await
+                .map_err(|e| format!("Error opening DM with the owner: {:?}", e))?
+                .id
+        }
+        (None, None) => {
+            log::warn!("Monthly report skipped: set reports.channel_id or OWNER_ID");
+            return Ok(false);
+        }
+    };
+
+    outbound::send(&ctx.http, || {
+        channel_id.send_message(&ctx.http, |message| message.set_embed(embed.clone()))
+    })
+    .await
+    .map_err(|e| format!("Error sending monthly report: {:?}", e))?;
+    Ok(true)
+}
+
+/// Summarizes the paid orders in `period`. FX gains and losses compare the USD
+/// each order was quoted at with the value of its GBP total at today's
+/// mid-market rate.
+async fn build(ctx: &Context, period: &Period) -> Result<CreateEmbed, String> {
+    let orders: Vec<_> = orders::store(ctx)
+        .await?
+        .in_period(period)
+        .await
+        .into_iter()
+        .filter(|order| order.status.is_paid())
+        .collect();
+    let summary = orders::summarize(&orders, 0);
+    let gbp_to_usd = rates::service(ctx).await?.get("GBP", "USD").await?;
+    let fx_gain: f64 = orders
+        .iter()
+        .map(|order| order.net_usd() - order.net_gbp() * gbp_to_usd.value)
+        .sum();
+
+    Ok(CreateEmbed::default()
+        .title(format!("Monthly Report: {}", period.label))
+        .field("Orders", summary.order_count, true)
+        .field("Robux Sold", format!("{} R$", summary.robux), true)
+        .field(
+            "Average Order",
+            format!("{:.0} R$", summary.average_robux()),
+            true,
+        )
+        .field("Revenue (GBP)", format!("£{:.2}", summary.net_gbp()), true)
+        .field("Revenue (USD)", format!("${:.2}", summary.net_usd()), true)
+        .field(
+            "Marketplace Fees",
+            format!("{} R$", summary.fee_robux),
+            true,
+        )
+        .field(
+            "FX Gains/Losses",
+            format!(
+                "{}${:.2} vs today's rate of {:.4} GBP/USD",
+                if fx_gain < 0.0 { "-" } else { "+" },
+                fx_gain.abs(),
+                gbp_to_usd.value
+            ),
+            false,
+        )
+        .color(0x0096FF)
+        .clone())
+}
+
+/// Time until shortly after midnight UTC on the first of the month following
+/// `this_month`.
+fn until_next_month(this_month: NaiveDate) -> Duration {
+    let next_month = this_month
+        .checked_add_months(Months::new(1))
+        .unwrap_or(this_month);
+    let next_run = next_month
+        .and_hms_opt(0, 1, 0)
+        .unwrap_or_default()
+        .and_utc();
+
+    (next_run - Utc::now())
+        .to_std()
+        .unwrap_or(Duration::from_secs(60))
+}
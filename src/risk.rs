@@ -0,0 +1,109 @@
+use crate::{
+    store::{self, JsonStore, Partition},
+    DISCORD_EPOCH_MS,
+};
+use serde::{Deserialize, Serialize};
+use serenity::prelude::*;
+use std::sync::Arc;
+
+const FLAGS_FILE: &str = "flags.json";
+
+/// A member staff have marked as a chargeback risk.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct UserFlag {
+    pub guild_id: u64,
+    pub user_id: u64,
+    pub reason: String,
+    pub flagged_by: u64,
+    pub flagged_at: u64,
+}
+
+#[derive(Serialize, Deserialize, Default)]
+struct FlagBook {
+    flags: Vec<UserFlag>,
+}
+
+/// Members flagged per guild, persisted in the data directory.
+pub struct FlagStore {
+    book: JsonStore<FlagBook>,
+}
+
+impl FlagStore {
+    pub fn open(partition: &Partition) -> Result<Self, String> {
+        Ok(Self {
+            book: JsonStore::open(partition, FLAGS_FILE)?,
+        })
+    }
+
+    /// Flags `flag.user_id`, replacing any earlier flag in the guild.
+    pub async fn flag(&self, flag: UserFlag) -> Result<(), String> {
+        self.book
+            .update(|book| {
+                book.flags.retain(|existing| {
+                    existing.guild_id != flag.guild_id || existing.user_id != flag.user_id
+                });
+                book.flags.push(flag);
+            })
+            .await
+    }
+
+    /// Removes `user_id`'s flag in `guild_id`, returning it if there was one.
+    pub async fn unflag(&self, guild_id: u64, user_id: u64) -> Result<Option<UserFlag>, String> {
+        self.book
+            .update(|book| {
+                let index = book
+                    .flags
+                    .iter()
+                    .position(|flag| flag.guild_id == guild_id && flag.user_id == user_id)?;
+                Some(book.flags.remove(index))
+            })
+            .await
+    }
+
+    pub async fn get(&self, guild_id: u64, user_id: u64) -> Option<UserFlag> {
+        self.book
+            .read()
+            .await
+            .flags
+            .iter()
+            .find(|flag| flag.guild_id == guild_id && flag.user_id == user_id)
+            .cloned()
+    }
+
+    /// `guild_id`'s flags, newest first.
+    pub async fn in_guild(&self, guild_id: u64) -> Vec<UserFlag> {
+        let mut flags: Vec<_> = self
+            .book
+            .read()
+            .await
+            .flags
+            .iter()
+            .filter(|flag| flag.guild_id == guild_id)
+            .cloned()
+            .collect();
+        flags.sort_by(|a, b| b.flagged_at.cmp(&a.flagged_at));
+        flags
+    }
+}
+
+/// How old the Discord account `user_id` is, in whole days, read from the
+/// creation time in its snowflake.
+pub fn account_age_days(user_id: u64) -> u64 {
+    let created_at = ((user_id >> 22) + DISCORD_EPOCH_MS) / 1000;
+    store::now().saturating_sub(created_at) / (24 * 3600)
+}
+
+pub struct FlagsKey;
+
+impl TypeMapKey for FlagsKey {
+    type Value = Arc<FlagStore>;
+}
+
+pub async fn store(ctx: &Context) -> Result<Arc<FlagStore>, String> {
+    ctx.data
+        .read()
+        .await
+        .get::<FlagsKey>()
+        .cloned()
+        .ok_or_else(|| "Risk flags unavailable".to_string())
+}
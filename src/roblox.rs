@@ -0,0 +1,316 @@
+use crate::{
+    breaker::{BreakerState, CircuitBreaker},
+    trace,
+};
+use serde::Deserialize;
+use serde_json::json;
+use std::{sync::OnceLock, time::Duration};
+
+const GAMEPASS_INFO_URL: &str = "https://apis.roblox.com/game-passes/v1/game-passes";
+const USERNAMES_URL: &str = "https://users.roblox.com/v1/usernames/users";
+const USERS_URL: &str = "https://users.roblox.com/v1/users";
+const INVENTORY_URL: &str = "https://inventory.roblox.com/v1/users";
+const REQUEST_TIMEOUT: Duration = Duration::from_secs(10);
+const BREAKER_FAILURE_THRESHOLD: u32 = 3;
+const BREAKER_COOLDOWN_SECS: u64 = 60;
+/// Most limiteds read from one inventory, 100 per page.
+pub const MAX_COLLECTIBLES: usize = 1_000;
+
+fn client() -> &'static reqwest::Client {
+    static CLIENT: OnceLock<reqwest::Client> = OnceLock::new();
+    CLIENT.get_or_init(|| {
+        reqwest::Client::builder()
+            .timeout(REQUEST_TIMEOUT)
+            .build()
+            .unwrap_or_default()
+    })
+}
+
+fn breaker() -> &'static CircuitBreaker {
+    static BREAKER: OnceLock<CircuitBreaker> = OnceLock::new();
+    BREAKER.get_or_init(|| {
+        CircuitBreaker::new(
+            BREAKER_FAILURE_THRESHOLD,
+            Duration::from_secs(BREAKER_COOLDOWN_SECS),
+        )
+    })
+}
+
+pub fn breaker_state() -> BreakerState {
+    breaker().state()
+}
+
+/// Sends a request to Roblox, short-circuiting while the breaker is open. Only
+/// transport failures, rate limiting and server errors count against the
+/// breaker; any other answer means Roblox is up.
+async fn send(name: &str, request: reqwest::RequestBuilder) -> Result<reqwest::Response, String> {
+    if !breaker().allow() {
+        return Err(
+            "Roblox is temporarily unavailable after repeated failures; try again in a minute"
+                .to_string(),
+        );
+    }
+    match trace::send(name, Vec::new(), request).await {
+        Ok(response) => {
+            let status = response.status();
+            if status.is_server_error() || status.as_u16() == 429 {
+                breaker().record_failure();
+            } else {
+                breaker().record_success();
+            }
+            Ok(response)
+        }
+        Err(error) => {
+            breaker().record_failure();
+            Err(format!("Error contacting Roblox: {}", error))
+        }
+    }
+}
+
+/// A gamepass as listed on Roblox.
+#[derive(Deserialize)]
+#[serde(rename_all = "PascalCase")]
+pub struct Gamepass {
+    pub name: String,
+    /// Unset when the gamepass is off sale.
+    #[serde(default)]
+    pub price_in_robux: Option<u64>,
+    #[serde(default)]
+    pub is_for_sale: bool,
+    pub creator: Creator,
+}
+
+#[derive(Deserialize)]
+#[serde(rename_all = "PascalCase")]
+pub struct Creator {
+    pub name: String,
+}
+
+/// A Roblox account.
+#[derive(Deserialize, Clone)]
+pub struct User {
+    pub id: u64,
+    pub name: String,
+}
+
+#[derive(Deserialize)]
+struct Page<T> {
+    data: Vec<T>,
+}
+
+/// Reads a gamepass ID from either the bare ID or a gamepass link, e.g.
+/// `https://www.roblox.com/game-pass/123456/Robux`.
+pub fn parse_gamepass_id(input: &str) -> Option<u64> {
+    let input = input.trim();
+    if let Ok(id) = input.parse() {
+        return Some(id);
+    }
+    let mut segments = input.split('/');
+    segments.find(|segment| segment.eq_ignore_ascii_case("game-pass"))?;
+    segments.next()?.parse().ok()
+}
+
+/// The public link to gamepass `id`.
+pub fn gamepass_url(id: u64) -> String {
+    format!("https://www.roblox.com/game-pass/{}", id)
+}
+
+/// Looks gamepass `id` up on Roblox.
+pub async fn gamepass(id: u64) -> Result<Gamepass, String> {
+    let response = send(
+        "roblox.gamepass",
+        client().get(format!("{}/{}/product-info", GAMEPASS_INFO_URL, id)),
+    )
+    .await?;
+
+    let status = response.status();
+    if status.as_u16() == 404 || status.as_u16() == 400 {
+        return Err(format!("Gamepass {} doesn't exist", id));
+    }
+    if !status.is_success() {
+        return Err(format!("Roblox returned {}", status));
+    }
+
+    response
+        .json()
+        .await
+        .map_err(|e| format!("Error reading gamepass {}: {}", id, e))
+}
+
+/// Looks a Roblox account up by username.
+pub async fn user(username: &str) -> Result<User, String> {
+    let response = send(
+        "roblox.user",
+        client()
+            .post(USERNAMES_URL)
+            .json(&json!({ "usernames": [username], "excludeBannedUsers": false })),
+    )
+    .await?;
+    if !response.status().is_success() {
+        return Err(format!("Roblox returned {}", response.status()));
+    }
+
+    let users: Page<User> = response
+        .json()
+        .await
+        .map_err(|e| format!("Error reading Roblox user {}: {}", username, e))?;
+    users
+        .data
+        .into_iter()
+        .next()
+        .ok_or_else(|| format!("No Roblox account is called {}", username))
+}
+
+/// Looks a Roblox account up by ID.
+pub async fn user_by_id(user_id: u64) -> Result<User, String> {
+    let response = send(
+        "roblox.user_by_id",
+        client().get(format!("{}/{}", USERS_URL, user_id)),
+    )
+    .await?;
+    if !response.status().is_success() {
+        return Err(format!("Roblox returned {}", response.status()));
+    }
+
+    response
+        .json()
+        .await
+        .map_err(|e| format!("Error reading Roblox user {}: {}", user_id, e))
+}
+
+/// The About text on `user_id`'s profile.
+pub async fn description(user_id: u64) -> Result<String, String> {
+    #[derive(Deserialize)]
+    struct Profile {
+        #[serde(default)]
+        description: String,
+    }
+
+    let response = send(
+        "roblox.description",
+        client().get(format!("{}/{}", USERS_URL, user_id)),
+    )
+    .await?;
+    if !response.status().is_success() {
+        return Err(format!("Roblox returned {}", response.status()));
+    }
+
+    let profile: Profile = response
+        .json()
+        .await
+        .map_err(|e| format!("Error reading Roblox profile: {}", e))?;
+    Ok(profile.description)
+}
+
+/// What Roblox says publicly about an account's standing.
+#[derive(Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct AccountStatus {
+    #[serde(default)]
+    pub is_banned: bool,
+    /// When the account was made, e.g. `2016-03-01T12:00:00.000Z`.
+    #[serde(default)]
+    pub created: String,
+}
+
+/// Whether `user_id` is banned, and when it was made.
+pub async fn account_status(user_id: u64) -> Result<AccountStatus, String> {
+    let response = send(
+        "roblox.account_status",
+        client().get(format!("{}/{}", USERS_URL, user_id)),
+    )
+    .await?;
+    if !response.status().is_success() {
+        return Err(format!("Roblox returned {}", response.status()));
+    }
+
+    response
+        .json()
+        .await
+        .map_err(|e| format!("Error reading Roblox account {}: {}", user_id, e))
+}
+
+/// The public link to `user_id`'s profile.
+pub fn profile_url(user_id: u64) -> String {
+    format!("https://www.roblox.com/users/{}/profile", user_id)
+}
+
+/// One copy of a limited item in someone's inventory.
+#[derive(Deserialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct Collectible {
+    pub asset_id: u64,
+    pub name: String,
+    /// The item's recent average price, unset for items that never sold.
+    #[serde(default)]
+    pub recent_average_price: Option<u64>,
+}
+
+/// The limiteds `user_id` owns, one entry per copy, up to `MAX_COLLECTIBLES`.
+pub async fn collectibles(user_id: u64) -> Result<Vec<Collectible>, String> {
+    #[derive(Deserialize)]
+    #[serde(rename_all = "camelCase")]
+    struct CollectiblePage {
+        data: Vec<Collectible>,
+        #[serde(default)]
+        next_page_cursor: Option<String>,
+    }
+
+    let mut collectibles = Vec::new();
+    let mut cursor = String::new();
+    while collectibles.len() < MAX_COLLECTIBLES {
+        let response = send(
+            "roblox.collectibles",
+            client()
+                .get(format!("{}/{}/assets/collectibles", INVENTORY_URL, user_id))
+                .query(&[
+                    ("limit", "100"),
+                    ("sortOrder", "Asc"),
+                    ("cursor", cursor.as_str()),
+                ]),
+        )
+        .await?;
+        if response.status().as_u16() == 403 {
+            return Err("That Roblox account's inventory is private".to_string());
+        }
+        if !response.status().is_success() {
+            return Err(format!("Roblox returned {}", response.status()));
+        }
+
+        let page: CollectiblePage = response
+            .json()
+            .await
+            .map_err(|e| format!("Error reading Roblox inventory: {}", e))?;
+        collectibles.extend(page.data);
+        match page.next_page_cursor.filter(|next| !next.is_empty()) {
+            Some(next) => cursor = next,
+            None => break,
+        }
+    }
+    collectibles.truncate(MAX_COLLECTIBLES);
+    Ok(collectibles)
+}
+
+/// Whether `user_id` owns gamepass `gamepass_id`, i.e. has bought it.
+pub async fn owns_gamepass(user_id: u64, gamepass_id: u64) -> Result<bool, String> {
+    let response = send(
+        "roblox.owns_gamepass",
+        client().get(format!(
+            "{}/{}/items/GamePass/{}",
+            INVENTORY_URL, user_id, gamepass_id
+        )),
+    )
+    .await?;
+    if response.status().as_u16() == 403 {
+        return Err("That Roblox account's inventory is private".to_string());
+    }
+    if !response.status().is_success() {
+        return Err(format!("Roblox returned {}", response.status()));
+    }
+
+    let items: Page<serde_json::Value> = response
+        .json()
+        .await
+        .map_err(|e| format!("Error reading Roblox inventory: {}", e))?;
+    Ok(!items.data.is_empty())
+}
@@ -0,0 +1,210 @@
+use crate::trace;
+use serde::Deserialize;
+use std::{
+    collections::HashMap,
+    sync::{Arc, OnceLock},
+    time::{Duration, Instant},
+};
+use tokio::sync::Mutex;
+
+const ITEM_DETAILS_URL: &str = "https://api.rolimons.com/items/v1/itemdetails";
+const REQUEST_TIMEOUT: Duration = Duration::from_secs(15);
+/// How long the item list is reused. Rolimons rate-limits the endpoint and
+/// only refreshes values every few minutes.
+const CACHE_TTL: Duration = Duration::from_secs(300);
+/// Items listed when a search matches more than one.
+const MAX_SUGGESTIONS: usize = 5;
+
+fn client() -> &'static reqwest::Client {
+    static CLIENT: OnceLock<reqwest::Client> = OnceLock::new();
+    CLIENT.get_or_init(|| {
+        reqwest::Client::builder()
+            .timeout(REQUEST_TIMEOUT)
+            .build()
+            .unwrap_or_default()
+    })
+}
+
+/// A limited item as Rolimons tracks it.
+#[derive(Clone)]
+pub struct Item {
+    pub id: u64,
+    pub name: String,
+    /// Short name traders use, e.g. `DE` for Dominus Empyreus; may be empty.
+    pub acronym: String,
+    /// Recent average price, in Robux.
+    pub rap: u64,
+    /// Rolimons' value, in Robux, for items it has valued.
+    pub value: Option<u64>,
+    pub demand: Option<&'static str>,
+    pub trend: Option<&'static str>,
+    /// Whether the RAP is being pushed up artificially.
+    pub projected: bool,
+    pub hyped: bool,
+    pub rare: bool,
+}
+
+impl Item {
+    /// Reads one entry of the item details list: `[name, acronym, rap, value,
+    /// default_value, demand, trend, projected, hyped, rare]`, with -1 for
+    /// anything unset.
+    fn parse(id: u64, details: &[serde_json::Value]) -> Option<Self> {
+        let number = |index: usize| details.get(index).and_then(|value| value.as_i64());
+        let flag = |index: usize| number(index) == Some(1);
+        let level = |index: usize, labels: &[&'static str]| {
+            number(index)
+                .and_then(|level| usize::try_from(level).ok())
+                .and_then(|level| labels.get(level).copied())
+        };
+        Some(Self {
+            id,
+            name: details.first()?.as_str()?.to_string(),
+            acronym: details
+                .get(1)
+                .and_then(|acronym| acronym.as_str())
+                .unwrap_or_default()
+                .to_string(),
+            rap: number(2).and_then(|rap| u64::try_from(rap).ok())?,
+            value: number(3).and_then(|value| u64::try_from(value).ok()),
+            demand: level(5, &["Terrible", "Low", "Normal", "High", "Amazing"]),
+            trend: level(
+                6,
+                &["Lowering", "Unstable", "Stable", "Raising", "Fluctuating"],
+            ),
+            projected: flag(7),
+            hyped: flag(8),
+            rare: flag(9),
+        })
+    }
+
+    /// The item's page on Rolimons.
+    pub fn url(&self) -> String {
+        format!("https://www.rolimons.com/item/{}", self.id)
+    }
+}
+
+#[derive(Deserialize)]
+struct ItemDetails {
+    #[serde(default)]
+    success: bool,
+    #[serde(default)]
+    items: HashMap<String, Vec<serde_json::Value>>,
+}
+
+struct Catalog {
+    fetched_at: Instant,
+    items: Arc<Vec<Item>>,
+}
+
+/// Every item Rolimons tracks, fetched at most once per `CACHE_TTL`. A failed
+/// refresh falls back to the last list, however old.
+async fn items() -> Result<Arc<Vec<Item>>, String> {
+    static CATALOG: OnceLock<Mutex<Option<Catalog>>> = OnceLock::new();
+    // Held across the fetch, so concurrent lookups share one request.
+    let mut catalog = CATALOG.get_or_init(|| Mutex::new(None)).lock().await;
+    if let Some(catalog) = catalog.as_ref() {
+        if catalog.fetched_at.elapsed() < CACHE_TTL {
+            return Ok(catalog.items.clone());
+        }
+    }
+
+    match fetch().await {
+        Ok(items) => {
+            let items = Arc::new(items);
+            *catalog = Some(Catalog {
+                fetched_at: Instant::now(),
+                items: items.clone(),
+            });
+            Ok(items)
+        }
+        Err(error) => match catalog.as_ref() {
+            Some(stale) => {
+                log::warn!("Using old Rolimons item list: {}", error);
+                Ok(stale.items.clone())
+            }
+            None => Err(error),
+        },
+    }
+}
+
+async fn fetch() -> Result<Vec<Item>, String> {
+    let response = trace::send("rolimons.items", Vec::new(), client().get(ITEM_DETAILS_URL))
+        .await
+        .map_err(|e| format!("Error contacting Rolimons: {}", e))?;
+    if response.status().as_u16() == 429 {
+        return Err("Rolimons is rate limiting requests; try again in a minute".to_string());
+    }
+    if !response.status().is_success() {
+        return Err(format!("Rolimons returned {}", response.status()));
+    }
+
+    let details: ItemDetails = response
+        .json()
+        .await
+        .map_err(|e| format!("Error reading Rolimons items: {}", e))?;
+    if !details.success {
+        return Err("Rolimons couldn't list items".to_string());
+    }
+    Ok(details
+        .items
+        .iter()
+        .filter_map(|(id, details)| Item::parse(id.parse().ok()?, details))
+        .collect())
+}
+
+/// The tracked items among `ids`, by ID.
+pub async fn lookup(ids: &[u64]) -> Result<HashMap<u64, Item>, String> {
+    Ok(items()
+        .await?
+        .iter()
+        .filter(|item| ids.contains(&item.id))
+        .map(|item| (item.id, item.clone()))
+        .collect())
+}
+
+/// Finds the item `query` names: its ID, its exact name or acronym, or else
+/// the only item whose name contains it.
+pub async fn find(query: &str) -> Result<Item, String> {
+    let query = query.trim();
+    if query.is_empty() {
+        return Err("Give an item's name, acronym or ID".to_string());
+    }
+    let items = items().await?;
+    if let Ok(id) = query.parse::<u64>() {
+        return items
+            .iter()
+            .find(|item| item.id == id)
+            .cloned()
+            .ok_or_else(|| format!("Rolimons doesn't track item {}", id));
+    }
+
+    if let Some(item) = items.iter().find(|item| {
+        item.name.eq_ignore_ascii_case(query) || item.acronym.eq_ignore_ascii_case(query)
+    }) {
+        return Ok(item.clone());
+    }
+    let lowercase = query.to_lowercase();
+    let mut matches: Vec<&Item> = items
+        .iter()
+        .filter(|item| item.name.to_lowercase().contains(&lowercase))
+        .collect();
+    match matches.len() {
+        0 => Err(format!("No limited item matches '{}'", query)),
+        1 => Ok(matches[0].clone()),
+        count => {
+            // The most traded first, as they're the likeliest meant.
+            matches.sort_by(|a, b| b.rap.cmp(&a.rap));
+            let names: Vec<&str> = matches
+                .iter()
+                .take(MAX_SUGGESTIONS)
+                .map(|item| item.name.as_str())
+                .collect();
+            Err(format!(
+                "{} items match '{}', e.g. {}. Try the full name, acronym or ID.",
+                count,
+                query,
+                names.join(", ")
+            ))
+        }
+    }
+}
@@ -0,0 +1,859 @@
+use crate::{
+    identity,
+    pricing::{RateCard, RobuxPackage, OFFICIAL_PACKAGES},
+    store::{JsonStore, Partition},
+    ROBUX_MARKUP_RATE, ROBUX_TO_GBP_RATE,
+};
+use serde::{Deserialize, Serialize};
+use serenity::{model::id::GuildId, prelude::*};
+use std::{
+    collections::{HashMap, HashSet},
+    sync::Arc,
+};
+
+const SETTINGS_FILE: &str = "settings.json";
+/// Hours a claimed order may sit untouched before it goes back in the queue.
+const DEFAULT_CLAIM_TIMEOUT_HOURS: u64 = 24;
+const DEFAULT_NEW_ACCOUNT_DAYS: u64 = 30;
+const DEFAULT_FIRST_ORDER_LIMIT_GBP: f64 = 100.0;
+/// Robux amounts past which quotes and orders are flagged for a second look.
+const DEFAULT_LARGE_AMOUNT_ROBUX: u64 = 100_000;
+const DEFAULT_DISPLAY_CURRENCIES: [&str; 2] = ["GBP", "USD"];
+/// Most currencies `/price` shows at once, keeping its embed readable.
+pub const MAX_DISPLAY_CURRENCIES: usize = 8;
+
+/// Bot-wide settings persisted in the data directory.
+#[derive(Serialize, Deserialize)]
+pub struct Settings {
+    #[serde(default)]
+    pub presence: PresenceSettings,
+    /// Rates used when the exchange API is unavailable and no last known good
+    /// rate has been recorded, keyed by pair (e.g. `GBP/USD`).
+    #[serde(default = "default_fallback_rates")]
+    pub fallback_rates: HashMap<String, FallbackRate>,
+    #[serde(default)]
+    pub rate_provider: RateProviderSettings,
+    #[serde(default)]
+    pub reports: ReportSettings,
+    #[serde(default)]
+    pub maintenance: MaintenanceSettings,
+    #[serde(default)]
+    pub alerts: AlertSettings,
+    #[serde(default)]
+    pub updates: UpdateSettings,
+    /// Per-guild overrides, keyed by guild ID.
+    #[serde(default)]
+    pub guilds: HashMap<u64, GuildSettings>,
+    /// Per-user preferences, keyed by user ID.
+    #[serde(default)]
+    pub users: HashMap<u64, UserSettings>,
+}
+
+impl Default for Settings {
+    fn default() -> Self {
+        Self {
+            presence: PresenceSettings::default(),
+            fallback_rates: default_fallback_rates(),
+            rate_provider: RateProviderSettings::default(),
+            reports: ReportSettings::default(),
+            maintenance: MaintenanceSettings::default(),
+            alerts: AlertSettings::default(),
+            updates: UpdateSettings::default(),
+            guilds: HashMap::new(),
+            users: HashMap::new(),
+        }
+    }
+}
+
+impl Settings {
+    /// Every currency pair commands are likely to need: GBP/USD, each guild's
+    /// display and role pricing currencies, and the fallback table's pairs.
+    pub fn currency_pairs(&self) -> Vec<(String, String)> {
+        let mut pairs = vec![("GBP".to_string(), "USD".to_string())];
+        for guild in self.guilds.values() {
+            let role_currencies = guild
+                .role_pricing
+                .iter()
+                .filter_map(|pricing| pricing.currency.clone());
+            for currency in guild
+                .display_currencies()
+                .into_iter()
+                .chain(role_currencies)
+            {
+                pairs.push(("GBP".to_string(), currency.to_uppercase()));
+            }
+        }
+        pairs.extend(self.fallback_rates.keys().filter_map(|pair| {
+            let (base, quote) = pair.split_once('/')?;
+            Some((base.to_uppercase(), quote.to_uppercase()))
+        }));
+        pairs.retain(|(base, quote)| base != quote);
+        pairs.sort();
+        pairs.dedup();
+        pairs
+    }
+
+    /// Minutes east of UTC that times are written in for `user_id`: their own
+    /// timezone, then `guild_id`'s, then UTC.
+    pub fn utc_offset(&self, guild_id: Option<GuildId>, user_id: u64) -> i32 {
+        self.users
+            .get(&user_id)
+            .and_then(|user| user.utc_offset_minutes)
+            .or_else(|| self.guild(guild_id).utc_offset_minutes)
+            .unwrap_or(0)
+    }
+
+    /// The settings for `guild_id`, or the defaults outside a guild or when the
+    /// guild has not configured anything.
+    pub fn guild(&self, guild_id: Option<GuildId>) -> GuildSettings {
+        guild_id
+            .and_then(|guild_id| self.guilds.get(&guild_id.0))
+            .cloned()
+            .unwrap_or_default()
+    }
+}
+
+#[derive(Serialize, Deserialize, Clone, Default)]
+pub struct GuildSettings {
+    /// Percentage added on top of the mid-market exchange rate in conversions.
+    #[serde(default)]
+    pub fx_margin_percent: f64,
+    /// Users who opted out of appearing on the leaderboard.
+    #[serde(default)]
+    pub leaderboard_opt_outs: HashSet<u64>,
+    /// Consumption tax shown as a separate line on price embeds, when enabled.
+    #[serde(default)]
+    pub tax: Option<TaxSettings>,
+    /// Whether successful calculation results are only shown to the invoker.
+    #[serde(default)]
+    pub ephemeral_results: bool,
+    /// Pricing overrides for members holding particular roles.
+    #[serde(default)]
+    pub role_pricing: Vec<RolePricing>,
+    /// Overrides the default GBP price per 1k R$ (b/t).
+    #[serde(default)]
+    pub gbp_per_1k: Option<f64>,
+    /// Overrides the default a/t markup, in percent.
+    #[serde(default)]
+    pub markup_percent: Option<f64>,
+    /// Named sellers with their own rate cards, selectable on `/price`.
+    #[serde(default)]
+    pub sellers: Vec<SellerProfile>,
+    /// Ways buyers can pay, with their fees, selectable on `/price`.
+    #[serde(default)]
+    pub payment_methods: Vec<PaymentMethod>,
+    /// Roblox's packs `/price` shows savings against, overriding the
+    /// standard ones; empty hides the savings.
+    #[serde(default)]
+    pub official_packages: Option<Vec<RobuxPackage>>,
+    /// Commands turned off in this guild, by name.
+    #[serde(default)]
+    pub disabled_commands: HashSet<String>,
+    /// Where rate changes are announced, if anywhere.
+    #[serde(default)]
+    pub announcements: Option<AnnouncementSettings>,
+    /// Verification bot consulted for members who haven't used `/link`.
+    #[serde(default)]
+    pub identity: Option<IdentitySettings>,
+    /// Hours a claimed order may go without activity before it is released,
+    /// overriding the default.
+    #[serde(default)]
+    pub claim_timeout_hours: Option<u64>,
+    /// Delivery deadline for paid orders, when enforced.
+    #[serde(default)]
+    pub sla: Option<SlaSettings>,
+    /// What buyers are sent when their order is completed.
+    #[serde(default)]
+    pub receipts: ReceiptSettings,
+    /// Role given to buyers once they have completed an order.
+    #[serde(default)]
+    pub customer_role_id: Option<u64>,
+    /// Roles given to buyers as their total spend in the guild grows.
+    #[serde(default)]
+    pub spend_tiers: Vec<SpendTier>,
+    /// Where tier upgrades are announced, if anywhere.
+    #[serde(default)]
+    pub spend_tier_channel_id: Option<u64>,
+    /// When orders are automatically marked high-risk.
+    #[serde(default)]
+    pub risk: RiskSettings,
+    /// Where payments received through webhooks are reported.
+    #[serde(default)]
+    pub payments_channel_id: Option<u64>,
+    /// ISO codes of the currencies `/price` shows amounts in, in order.
+    #[serde(default)]
+    pub display_currencies: Vec<String>,
+    /// Robux amounts past which quotes and orders are flagged, overriding the
+    /// default; 0 turns the warning off.
+    #[serde(default)]
+    pub large_amount_robux: Option<u64>,
+    /// Locale responses use for members whose Discord language has no
+    /// translation, e.g. `de`. English when unset.
+    #[serde(default)]
+    pub language: Option<String>,
+    /// Minutes east of UTC that times are written in where Discord can't show
+    /// them in each viewer's local time, for members without their own.
+    #[serde(default)]
+    pub utc_offset_minutes: Option<i32>,
+    /// How often members may use each command, and who is exempt.
+    #[serde(default)]
+    pub cooldowns: CooldownSettings,
+}
+
+#[derive(Serialize, Deserialize, Clone, Default)]
+pub struct UserSettings {
+    /// Minutes east of UTC, set with `/timezone`.
+    #[serde(default)]
+    pub utc_offset_minutes: Option<i32>,
+}
+
+impl GuildSettings {
+    /// The markup applied to a/t prices as a fraction, e.g. 0.3.
+    pub fn markup_rate(&self) -> f64 {
+        self.markup_percent
+            .map_or(ROBUX_MARKUP_RATE, |percent| percent / 100.0)
+    }
+
+    /// GBP charged per Robux b/t, e.g. 0.0035.
+    pub fn gbp_per_robux(&self) -> f64 {
+        self.gbp_per_1k
+            .map_or(ROBUX_TO_GBP_RATE, |gbp_per_1k| gbp_per_1k / 1000.0)
+    }
+
+    /// Seconds a claimed order may go without activity before it is released.
+    pub fn claim_timeout_secs(&self) -> u64 {
+        self.claim_timeout_hours
+            .unwrap_or(DEFAULT_CLAIM_TIMEOUT_HOURS)
+            * 3600
+    }
+
+    /// The currencies `/price` shows, GBP and USD unless the guild chose its
+    /// own.
+    pub fn display_currencies(&self) -> Vec<String> {
+        if self.display_currencies.is_empty() {
+            DEFAULT_DISPLAY_CURRENCIES
+                .iter()
+                .map(|currency| currency.to_string())
+                .collect()
+        } else {
+            self.display_currencies.clone()
+        }
+    }
+
+    /// Robux past which an amount is flagged as unusually large, or `None`
+    /// when the guild turned the warning off.
+    pub fn large_amount_threshold(&self) -> Option<u64> {
+        match self
+            .large_amount_robux
+            .unwrap_or(DEFAULT_LARGE_AMOUNT_ROBUX)
+        {
+            0 => None,
+            robux => Some(robux),
+        }
+    }
+
+    /// The guild's default prices.
+    pub fn rate_card(&self) -> RateCard {
+        RateCard {
+            gbp_per_robux: self.gbp_per_robux(),
+            markup: self.markup_rate(),
+        }
+    }
+
+    /// Looks a seller up by name, ignoring case.
+    pub fn seller(&self, name: &str) -> Option<&SellerProfile> {
+        self.sellers
+            .iter()
+            .find(|seller| seller.name.eq_ignore_ascii_case(name.trim()))
+    }
+
+    /// The Roblox packs prices are compared with, smallest first.
+    pub fn official_packages(&self) -> Vec<RobuxPackage> {
+        let mut packages = self
+            .official_packages
+            .clone()
+            .unwrap_or_else(|| OFFICIAL_PACKAGES.to_vec());
+        packages.sort_by_key(|package| package.robux);
+        packages
+    }
+
+    /// Looks a payment method up by name, ignoring case.
+    pub fn payment_method(&self, name: &str) -> Option<&PaymentMethod> {
+        self.payment_methods
+            .iter()
+            .find(|method| method.name.eq_ignore_ascii_case(name.trim()))
+    }
+
+    /// `seller`'s prices, falling back to the guild's markup.
+    pub fn seller_rate_card(&self, seller: &SellerProfile) -> RateCard {
+        RateCard {
+            gbp_per_robux: seller.gbp_per_1k / 1000.0,
+            markup: seller
+                .markup_percent
+                .map_or(self.markup_rate(), |percent| percent / 100.0),
+        }
+    }
+
+    /// The override for a member with `roles`: the largest discount among their
+    /// roles, with ties going to the rule configured first.
+    pub fn role_pricing_for(&self, roles: &[u64]) -> Option<&RolePricing> {
+        self.role_pricing
+            .iter()
+            .filter(|pricing| roles.contains(&pricing.role_id))
+            .reduce(|best, pricing| {
+                if pricing.discount_percent > best.discount_percent {
+                    pricing
+                } else {
+                    best
+                }
+            })
+    }
+
+    /// The highest tier `spend_gbp` reaches, if any.
+    pub fn spend_tier_for(&self, spend_gbp: f64) -> Option<&SpendTier> {
+        self.spend_tiers
+            .iter()
+            .filter(|tier| spend_gbp >= tier.threshold_gbp)
+            .reduce(|best, tier| {
+                if tier.threshold_gbp > best.threshold_gbp {
+                    tier
+                } else {
+                    best
+                }
+            })
+    }
+}
+
+/// A named seller, e.g. a staff member whose stock cost differs from the others'.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct SellerProfile {
+    pub name: String,
+    /// GBP charged per 1,000 Robux before tax.
+    pub gbp_per_1k: f64,
+    /// Overrides the guild's markup, in percent.
+    #[serde(default)]
+    pub markup_percent: Option<f64>,
+    /// The Roblox account the seller buys gamepasses with.
+    #[serde(default)]
+    pub roblox_username: Option<String>,
+}
+
+impl SellerProfile {
+    /// e.g. `£3.50 per 1k R$, 25% markup, buys as AlexRBX`.
+    pub fn describe(&self) -> String {
+        format!(
+            "£{:.2} per 1k R${}{}",
+            self.gbp_per_1k,
+            self.markup_percent
+                .map(|markup| format!(", {}% markup", markup))
+                .unwrap_or_default(),
+            self.roblox_username
+                .as_ref()
+                .map(|username| format!(", buys as {}", username))
+                .unwrap_or_default()
+        )
+    }
+}
+
+/// A way buyers can pay, e.g. PayPal, and what it adds to the price.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct PaymentMethod {
+    pub name: String,
+    /// Percentage added to the price, e.g. to cover a processor's fee.
+    #[serde(default)]
+    pub surcharge_percent: f64,
+    /// GBP added after the surcharge.
+    #[serde(default)]
+    pub fixed_fee_gbp: f64,
+    /// ISO codes of the currencies it takes; any when empty.
+    #[serde(default)]
+    pub currencies: Vec<String>,
+    /// Whether buyers can currently choose it.
+    #[serde(default = "default_true")]
+    pub enabled: bool,
+}
+
+impl PaymentMethod {
+    /// What a buyer paying `gbp` through this method is charged.
+    pub fn total(&self, gbp: f64) -> f64 {
+        gbp * (1.0 + self.surcharge_percent / 100.0) + self.fixed_fee_gbp
+    }
+
+    pub fn accepts(&self, currency: &str) -> bool {
+        self.currencies.is_empty() || self.currencies.iter().any(|code| code == currency)
+    }
+
+    /// The fee alone, e.g. `3.4% + £0.20` or `no fee`.
+    pub fn fee(&self) -> String {
+        match (self.surcharge_percent > 0.0, self.fixed_fee_gbp > 0.0) {
+            (true, true) => format!("{}% + £{:.2}", self.surcharge_percent, self.fixed_fee_gbp),
+            (true, false) => format!("{}%", self.surcharge_percent),
+            (false, true) => format!("£{:.2}", self.fixed_fee_gbp),
+            (false, false) => "no fee".to_string(),
+        }
+    }
+
+    /// e.g. `3.4% + £0.20, GBP/EUR only, disabled`.
+    pub fn describe(&self) -> String {
+        format!(
+            "{}{}{}",
+            self.fee(),
+            if self.currencies.is_empty() {
+                String::new()
+            } else {
+                format!(", {} only", self.currencies.join("/"))
+            },
+            if self.enabled { "" } else { ", disabled" }
+        )
+    }
+}
+
+#[derive(Serialize, Deserialize, Clone)]
+pub struct RolePricing {
+    pub role_id: u64,
+    /// Percentage taken off the GBP price.
+    #[serde(default)]
+    pub discount_percent: f64,
+    /// Extra currency the price is shown in, e.g. `EUR`.
+    #[serde(default)]
+    pub currency: Option<String>,
+}
+
+impl RolePricing {
+    /// e.g. `5% discount, priced in EUR`.
+    pub fn describe(&self) -> String {
+        format!(
+            "{}% discount{}",
+            self.discount_percent,
+            self.currency
+                .as_ref()
+                .map(|currency| format!(", priced in {}", currency))
+                .unwrap_or_default()
+        )
+    }
+}
+
+/// A role buyers earn once they have spent `threshold_gbp` in total.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct SpendTier {
+    pub role_id: u64,
+    pub threshold_gbp: f64,
+}
+
+#[derive(Serialize, Deserialize, Clone)]
+pub struct IdentitySettings {
+    pub provider: identity::Provider,
+    /// The guild's key for the provider's API.
+    pub api_key: String,
+}
+
+#[derive(Serialize, Deserialize, Clone)]
+pub struct AnnouncementSettings {
+    pub channel_id: u64,
+    /// Role mentioned in announcements when `ping` is on.
+    #[serde(default)]
+    pub role_id: Option<u64>,
+    #[serde(default)]
+    pub ping: bool,
+}
+
+impl AnnouncementSettings {
+    /// e.g. `<#123>, pinging <@&456>`.
+    pub fn describe(&self) -> String {
+        match self.role_id.filter(|_| self.ping) {
+            Some(role_id) => format!("<#{}>, pinging <@&{}>", self.channel_id, role_id),
+            None => format!("<#{}>", self.channel_id),
+        }
+    }
+}
+
+#[derive(Serialize, Deserialize, Clone, Default)]
+pub struct CooldownSettings {
+    /// Seconds a member has to wait between uses of the same command. Off
+    /// when unset or 0.
+    #[serde(default)]
+    pub seconds: Option<u64>,
+    /// Roles exempt from the cooldown, e.g. staff and boosters.
+    #[serde(default)]
+    pub bypass_role_ids: Vec<u64>,
+}
+
+impl CooldownSettings {
+    /// The cooldown for a member with `roles`, or `None` if they don't have
+    /// one because cooldowns are off or one of their roles bypasses them.
+    pub fn seconds_for(&self, roles: &[u64]) -> Option<u64> {
+        self.seconds
+            .filter(|&seconds| seconds > 0)
+            .filter(|_| !roles.iter().any(|role| self.bypass_role_ids.contains(role)))
+    }
+}
+
+#[derive(Serialize, Deserialize, Clone, Default)]
+pub struct ReceiptSettings {
+    /// Reminder to leave a vouch, e.g. "Vouch with `+vouch {staff}` in
+    /// #vouches". `{staff}` becomes a mention of whoever served the buyer.
+    #[serde(default)]
+    pub vouch: Option<String>,
+    /// Whether receipts come with a PDF invoice.
+    #[serde(default)]
+    pub pdf: bool,
+}
+
+impl ReceiptSettings {
+    /// The vouch reminder with `{staff}` filled in, or a generic one.
+    pub fn vouch_reminder(&self, staff_id: Option<u64>) -> String {
+        let staff = staff_id.map_or("the staff member who served you".to_string(), |staff_id| {
+            format!("<@{}>", staff_id)
+        });
+        match &self.vouch {
+            Some(vouch) => vouch.replace("{staff}", &staff),
+            None => format!("Happy with your order? Leave a vouch for {}!", staff),
+        }
+    }
+}
+
+#[derive(Serialize, Deserialize, Clone)]
+pub struct SlaSettings {
+    /// Hours from payment within which an order must be delivered.
+    pub delivery_hours: u64,
+    /// Channel breaches are escalated to; when unset only the assigned staff
+    /// member hears about them.
+    #[serde(default)]
+    pub channel_id: Option<u64>,
+}
+
+impl SlaSettings {
+    pub fn delivery_secs(&self) -> u64 {
+        self.delivery_hours * 3600
+    }
+
+    /// e.g. `48 hours from payment, escalating to <#123>`.
+    pub fn describe(&self) -> String {
+        match self.channel_id {
+            Some(channel_id) => format!(
+                "{} hours from payment, escalating to <#{}>",
+                self.delivery_hours, channel_id
+            ),
+            None => format!("{} hours from payment", self.delivery_hours),
+        }
+    }
+}
+
+/// Thresholds for marking orders high-risk. A threshold of 0 turns its check
+/// off.
+#[derive(Serialize, Deserialize, Clone, Default)]
+pub struct RiskSettings {
+    /// Discord accounts younger than this many days are high-risk.
+    #[serde(default)]
+    pub new_account_days: Option<u64>,
+    /// First purchases over this many GBP are high-risk.
+    #[serde(default)]
+    pub first_order_limit_gbp: Option<f64>,
+}
+
+impl RiskSettings {
+    pub fn new_account_days(&self) -> u64 {
+        self.new_account_days.unwrap_or(DEFAULT_NEW_ACCOUNT_DAYS)
+    }
+
+    pub fn first_order_limit_gbp(&self) -> f64 {
+        self.first_order_limit_gbp
+            .unwrap_or(DEFAULT_FIRST_ORDER_LIMIT_GBP)
+    }
+
+    /// e.g. `accounts under 30 days old, first purchases over £100.00`.
+    pub fn describe(&self) -> String {
+        let mut checks = Vec::new();
+        if self.new_account_days() > 0 {
+            checks.push(format!(
+                "accounts under {} days old",
+                self.new_account_days()
+            ));
+        }
+        if self.first_order_limit_gbp() > 0.0 {
+            checks.push(format!(
+                "first purchases over £{:.2}",
+                self.first_order_limit_gbp()
+            ));
+        }
+        checks.push("buyers with earlier disputes".to_string());
+        checks.push("flagged members".to_string());
+        checks.join(", ")
+    }
+}
+
+#[derive(Serialize, Deserialize, Clone)]
+pub struct TaxSettings {
+    pub rate_percent: f64,
+    /// Name of the tax, e.g. `VAT`.
+    pub label: String,
+}
+
+impl TaxSettings {
+    /// e.g. `20% VAT`.
+    pub fn describe(&self) -> String {
+        format!("{}% {}", self.rate_percent, self.label)
+    }
+
+    /// Tax due on a net amount.
+    pub fn on(&self, net: f64) -> f64 {
+        net * self.rate_percent / 100.0
+    }
+}
+
+#[derive(Serialize, Deserialize)]
+pub struct PresenceSettings {
+    #[serde(default = "default_true")]
+    pub enabled: bool,
+    #[serde(default = "default_presence_interval")]
+    pub interval_secs: u64,
+    #[serde(default = "default_presence_entries")]
+    pub entries: Vec<PresenceEntry>,
+}
+
+impl Default for PresenceSettings {
+    fn default() -> Self {
+        Self {
+            enabled: true,
+            interval_secs: default_presence_interval(),
+            entries: default_presence_entries(),
+        }
+    }
+}
+
+/// The monthly revenue report, posted on the first of each month.
+#[derive(Serialize, Deserialize)]
+pub struct ReportSettings {
+    #[serde(default = "default_true")]
+    pub enabled: bool,
+    /// Channel to post the report in; when unset it is sent to `OWNER_ID` by DM.
+    #[serde(default)]
+    pub channel_id: Option<u64>,
+}
+
+impl Default for ReportSettings {
+    fn default() -> Self {
+        Self {
+            enabled: true,
+            channel_id: None,
+        }
+    }
+}
+
+/// Maintenance mode, toggled by the owner with `/maintenance`. While enabled
+/// every other user's interactions are answered with the notice.
+#[derive(Serialize, Deserialize, Default)]
+pub struct MaintenanceSettings {
+    #[serde(default)]
+    pub enabled: bool,
+    #[serde(default)]
+    pub message: Option<String>,
+}
+
+impl MaintenanceSettings {
+    pub fn notice(&self) -> String {
+        self.message.clone().unwrap_or_else(|| {
+            "The bot is down for maintenance. Please try again later.".to_string()
+        })
+    }
+}
+
+/// A stat the rotating presence can display.
+#[derive(Serialize, Deserialize, Clone, Copy, PartialEq, Eq)]
+#[serde(rename_all = "snake_case")]
+pub enum PresenceEntry {
+    RobuxPrice,
+    ExchangeRate,
+    ServerCount,
+}
+
+#[derive(Serialize, Deserialize, Clone, Copy)]
+pub struct FallbackRate {
+    pub value: f64,
+    /// Unix timestamp of when the rate was last checked by the operator.
+    #[serde(default)]
+    pub as_of: Option<u64>,
+}
+
+/// Which exchange rate API to use. Built-in kinds only need overriding fields
+/// when they deviate from the provider's public defaults; `custom` requires `url`
+/// and `rates_path`.
+#[derive(Serialize, Deserialize, Clone, Default)]
+pub struct RateProviderSettings {
+    #[serde(default)]
+    pub kind: RateProviderKind,
+    /// Display name shown in rate diagnostics.
+    #[serde(default)]
+    pub name: Option<String>,
+    /// URL template; `{base}` is replaced with the base currency and `{key}`
+    /// with the active API key.
+    #[serde(default)]
+    pub url: Option<String>,
+    #[serde(default)]
+    pub auth: RateAuth,
+    /// JSONPath-style path (e.g. `$.data.rates`) to the object mapping quote
+    /// currencies to rates.
+    #[serde(default)]
+    pub rates_path: Option<String>,
+    /// JSONPath-style path to an error message that is only present when the
+    /// request failed.
+    #[serde(default)]
+    pub error_path: Option<String>,
+    /// Calls each API key may make per month, used for local quota counting.
+    #[serde(default)]
+    pub monthly_quota: Option<u64>,
+    /// Response header reporting the calls remaining on the current key.
+    #[serde(default)]
+    pub quota_header: Option<String>,
+    /// Error text identifying a key that has run out of quota; HTTP 429 is always
+    /// treated as such.
+    #[serde(default)]
+    pub quota_error: Option<String>,
+}
+
+#[derive(Serialize, Deserialize, Clone, Copy, Default, PartialEq, Eq)]
+#[serde(rename_all = "snake_case")]
+pub enum RateProviderKind {
+    #[default]
+    OpenErApi,
+    ExchangeRateApi,
+    Frankfurter,
+    Custom,
+}
+
+/// How the API key is sent, in addition to any `{key}` in the URL template.
+#[derive(Serialize, Deserialize, Clone, Default)]
+#[serde(tag = "style", rename_all = "snake_case")]
+pub enum RateAuth {
+    #[default]
+    None,
+    Header {
+        name: String,
+    },
+    Query {
+        name: String,
+    },
+    Bearer,
+}
+
+/// When the owner is alerted about slow or failing commands.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct AlertSettings {
+    #[serde(default = "default_true")]
+    pub enabled: bool,
+    /// Channel to post alerts in; when unset they are sent to `OWNER_ID` by DM.
+    #[serde(default)]
+    pub channel_id: Option<u64>,
+    /// Minutes a command's figures are judged over.
+    #[serde(default = "default_alert_window_minutes")]
+    pub window_minutes: u64,
+    /// Calls a command needs within the window before it is judged at all.
+    #[serde(default = "default_alert_min_calls")]
+    pub min_calls: usize,
+    /// A command is slow when its P95 handling time is over this.
+    #[serde(default = "default_alert_p95_ms")]
+    pub p95_ms: u64,
+    /// A command is failing when more than this percentage of calls error.
+    #[serde(default = "default_alert_error_rate_percent")]
+    pub error_rate_percent: f64,
+}
+
+impl Default for AlertSettings {
+    fn default() -> Self {
+        Self {
+            enabled: true,
+            channel_id: None,
+            window_minutes: default_alert_window_minutes(),
+            min_calls: default_alert_min_calls(),
+            p95_ms: default_alert_p95_ms(),
+            error_rate_percent: default_alert_error_rate_percent(),
+        }
+    }
+}
+
+/// How often GitHub is checked for a newer release of the bot.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct UpdateSettings {
+    #[serde(default = "default_true")]
+    pub enabled: bool,
+    #[serde(default = "default_update_interval_hours")]
+    pub interval_hours: u64,
+}
+
+impl Default for UpdateSettings {
+    fn default() -> Self {
+        Self {
+            enabled: true,
+            interval_hours: default_update_interval_hours(),
+        }
+    }
+}
+
+fn default_update_interval_hours() -> u64 {
+    12
+}
+
+fn default_alert_window_minutes() -> u64 {
+    5
+}
+
+fn default_alert_min_calls() -> usize {
+    5
+}
+
+/// Under Discord's three-second response window, so alerts come before
+/// replies start arriving late.
+fn default_alert_p95_ms() -> u64 {
+    2500
+}
+
+fn default_alert_error_rate_percent() -> f64 {
+    25.0
+}
+
+fn default_true() -> bool {
+    true
+}
+
+fn default_presence_interval() -> u64 {
+    60
+}
+
+fn default_presence_entries() -> Vec<PresenceEntry> {
+    vec![
+        PresenceEntry::RobuxPrice,
+        PresenceEntry::ExchangeRate,
+        PresenceEntry::ServerCount,
+    ]
+}
+
+fn default_fallback_rates() -> HashMap<String, FallbackRate> {
+    HashMap::from([(
+        "GBP/USD".to_string(),
+        FallbackRate {
+            value: 1.38,
+            as_of: None,
+        },
+    )])
+}
+
+pub type SettingsStore = JsonStore<Settings>;
+
+pub struct SettingsKey;
+
+impl TypeMapKey for SettingsKey {
+    type Value = Arc<SettingsStore>;
+}
+
+pub async fn store(ctx: &Context) -> Result<Arc<SettingsStore>, String> {
+    ctx.data
+        .read()
+        .await
+        .get::<SettingsKey>()
+        .cloned()
+        .ok_or_else(|| "Settings unavailable".to_string())
+}
+
+pub fn open(partition: &Partition) -> Result<SettingsStore, String> {
+    JsonStore::open(partition, SETTINGS_FILE)
+}
@@ -0,0 +1,46 @@
+use std::{
+    collections::HashMap,
+    future::Future,
+    sync::{Arc, Mutex},
+};
+use tokio::sync::OnceCell;
+
+/// Deduplicates concurrent calls for the same key: while a call is in flight,
+/// later callers wait for and share its result instead of starting their own.
+pub struct Group<T> {
+    calls: Mutex<HashMap<String, Arc<OnceCell<T>>>>,
+}
+
+impl<T: Clone> Group<T> {
+    pub fn new() -> Self {
+        Self {
+            calls: Mutex::new(HashMap::new()),
+        }
+    }
+
+    pub async fn run<F, Fut>(&self, key: &str, f: F) -> T
+    where
+        F: FnOnce() -> Fut,
+        Fut: Future<Output = T>,
+    {
+        let call = self
+            .calls
+            .lock()
+            .unwrap()
+            .entry(key.to_string())
+            .or_default()
+            .clone();
+
+        let result = call.get_or_init(f).await.clone();
+
+        let mut calls = self.calls.lock().unwrap();
+        if calls
+            .get(key)
+            .is_some_and(|current| Arc::ptr_eq(current, &call))
+        {
+            calls.remove(key);
+        }
+
+        result
+    }
+}
@@ -0,0 +1,141 @@
+use crate::{
+    cache::Cache,
+    orders::{self, Order},
+    outbound,
+    settings::{SettingsKey, SlaSettings},
+    store,
+};
+use serenity::{
+    builder::CreateEmbed,
+    model::id::{ChannelId, GuildId, UserId},
+    prelude::*,
+};
+use std::time::Duration;
+
+/// How often delivery deadlines are checked.
+const CHECK_INTERVAL_SECS: u64 = 300;
+/// Staff are reminded once this share of the delivery window is left.
+const REMINDER_FRACTION: u64 = 4;
+
+/// Starts checking paid orders against their guild's delivery deadline:
+/// the assigned staff member is reminded as the deadline nears, and breaches
+/// are escalated to the guild's SLA channel. Call it once per bot; instances
+/// sharing `cache` take turns, so no reminder is sent twice.
+pub fn start(ctx: Context, cache: Cache) {
+    tokio::spawn(async move {
+        let interval = Duration::from_secs(CHECK_INTERVAL_SECS);
+        loop {
+            if cache.lease("sla", interval).await.is_some() {
+                if let Err(error) = check(&ctx).await {
+                    log::error!("Error checking delivery deadlines: {}", error);
+                }
+            }
+            tokio::time::sleep(interval).await;
+        }
+    });
+}
+
+async fn check(ctx: &Context) -> Result<(), String> {
+    let settings = match ctx.data.read().await.get::<SettingsKey>().cloned() {
+        Some(settings) => settings,
+        None => return Ok(()),
+    };
+    let orders = orders::store(ctx).await?;
+    let now = store::now();
+
+    for order in orders.awaiting_delivery().await {
+        let sla = match settings.read().await.guild(order.guild_id.map(GuildId)).sla {
+            Some(sla) => sla,
+            None => continue,
+        };
+        let deadline = match order.delivery_deadline(sla.delivery_secs()) {
+            Some(deadline) => deadline,
+            None => continue,
+        };
+
+        if now > deadline && order.sla_escalated_at.is_none() {
+            if let Err(error) = escalate(ctx, &order, &sla, deadline).await {
+                log::error!("{}", error);
+                continue;
+            }
+            orders
+                .update(order.id, |order| order.sla_escalated_at = Some(now))
+                .await?;
+        } else if now + sla.delivery_secs() / REMINDER_FRACTION > deadline
+            && order.sla_reminded_at.is_none()
+        {
+            if let Some(staff_id) = order.claimed_by {
+                let embed = CreateEmbed::default()
+                    .title(format!("Order #{} Due Soon", order.id))
+                    .description(format!(
+                        "<@{}>'s order for {} R$ has to be delivered <t:{}:R>.",
+                        order.buyer_id, order.robux, deadline
+                    ))
+                    .color(0xFFA500)
+                    .clone();
+                notify_staff(ctx, staff_id, &embed).await;
+            }
+            orders
+                .update(order.id, |order| order.sla_reminded_at = Some(now))
+                .await?;
+        }
+    }
+    Ok(())
+}
+
+/// Tells the assigned staff member, and the guild's SLA channel if it has one,
+/// that `order` missed its delivery deadline.
+async fn escalate(
+    ctx: &Context,
+    order: &Order,
+    sla: &SlaSettings,
+    deadline: u64,
+) -> Result<(), String> {
+    let assignee = match order.claimed_by {
+        Some(staff_id) => format!("<@{}>", staff_id),
+        None => "nobody".to_string(),
+    };
+    let embed = CreateEmbed::default()
+        .title(format!("Order #{} Overdue", order.id))
+        .description(format!(
+            "<@{}>'s order for {} R$ was due <t:{}:R>, {} hours after payment, and hasn't been delivered.",
+            order.buyer_id, order.robux, deadline, sla.delivery_hours
+        ))
+        .field("Assigned To", &assignee, true)
+        .field("Status", order.status.label(), true)
+        .color(0xFF0000)
+        .clone();
+
+    if let Some(staff_id) = order.claimed_by {
+        notify_staff(ctx, staff_id, &embed).await;
+    }
+    if let Some(channel_id) = sla.channel_id {
+        outbound::send(&ctx.http, || {
+            ChannelId(channel_id).send_message(&ctx.http, |message| {
+                if let Some(staff_id) = order.claimed_by {
+                    message.content(format!("<@{}>", staff_id));
+                }
+                message.set_embed(embed.clone())
+            })
+        })
+        .await
+        .map_err(|e| format!("Error escalating order #{}: {:?}", order.id, e))?;
+    }
+    Ok(())
+}
+
+async fn notify_staff(ctx: &Context, staff_id: u64, embed: &CreateEmbed) {
+    let result = match UserId(staff_id).create_dm_channel(&ctx.http).await {
+        Ok(channel) => outbound::send(&ctx.http, || {
+            channel
+                .id
+                .send_message(&ctx.http, |message| message.set_embed(embed.clone()))
+        })
+        .await
+        .map(|_| ()),
+        Err(error) => Err(error),
+    };
+    if let Err(error) = result {
+        log::error!("Error notifying staff member {}: {:?}", staff_id, error);
+    }
+}
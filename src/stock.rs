@@ -0,0 +1,86 @@
+use crate::{
+    cache::Cache,
+    store::{self, JsonStore, Partition},
+};
+use serde::{Deserialize, Serialize};
+use serenity::prelude::*;
+use std::sync::Arc;
+
+const STOCK_FILE: &str = "stock.json";
+
+/// A change to a seller's Robux stock. Positive amounts are restocks, negative
+/// ones sales or corrections.
+#[derive(Serialize, Deserialize, Clone)]
+pub struct StockEntry {
+    pub guild_id: u64,
+    pub seller: String,
+    pub robux: i64,
+    #[serde(default)]
+    pub note: String,
+    pub actor_id: u64,
+    /// Unix timestamp of the entry.
+    pub created_at: u64,
+}
+
+#[derive(Serialize, Deserialize, Default)]
+struct Ledger {
+    entries: Vec<StockEntry>,
+}
+
+/// Every seller's stock movements, persisted in the data directory. A seller's
+/// stock is the sum of their entries. Instances sharing a cache take turns
+/// recording movements, so none is lost.
+pub struct StockStore {
+    ledger: JsonStore<Ledger>,
+}
+
+impl StockStore {
+    pub fn open(partition: &Partition, cache: &Cache) -> Result<Self, String> {
+        Ok(Self {
+            ledger: JsonStore::open(partition, STOCK_FILE)?.shared(cache),
+        })
+    }
+
+    /// Stamps `entry` with the current time and persists it, returning the
+    /// seller's new balance.
+    pub async fn record(&self, entry: StockEntry) -> Result<i64, String> {
+        let (guild_id, seller) = (entry.guild_id, entry.seller.clone());
+        self.ledger
+            .update(|ledger| {
+                ledger.entries.push(StockEntry {
+                    created_at: store::now(),
+                    ..entry
+                });
+                balance_of(ledger, guild_id, &seller)
+            })
+            .await
+    }
+
+    /// The Robux `seller` has in stock in `guild_id`.
+    pub async fn balance(&self, guild_id: u64, seller: &str) -> i64 {
+        balance_of(&*self.ledger.read().await, guild_id, seller)
+    }
+}
+
+fn balance_of(ledger: &Ledger, guild_id: u64, seller: &str) -> i64 {
+    ledger
+        .entries
+        .iter()
+        .filter(|entry| entry.guild_id == guild_id && entry.seller.eq_ignore_ascii_case(seller))
+        .fold(0i64, |balance, entry| balance.saturating_add(entry.robux))
+}
+
+pub struct StockKey;
+
+impl TypeMapKey for StockKey {
+    type Value = Arc<StockStore>;
+}
+
+pub async fn store(ctx: &Context) -> Result<Arc<StockStore>, String> {
+    ctx.data
+        .read()
+        .await
+        .get::<StockKey>()
+        .cloned()
+        .ok_or_else(|| "Stock ledger unavailable".to_string())
+}
@@ -0,0 +1,220 @@
+use crate::{cache::Cache, trace};
+use serde::{de::DeserializeOwned, Deserialize, Serialize};
+use std::{
+    env, fs,
+    path::{Path, PathBuf},
+    time::{Duration, SystemTime, UNIX_EPOCH},
+};
+use tokio::sync::{RwLock, RwLockReadGuard};
+
+const DEFAULT_DATA_DIR: &str = "data";
+/// Records which tenant a data directory belongs to.
+const CLAIM_FILE: &str = "tenant.json";
+/// How long a shared document's lock may be held before it expires, in case
+/// the instance holding it dies mid-update.
+const LOCK_TTL: Duration = Duration::from_secs(10);
+/// How long an update waits for another instance to finish with a shared
+/// document.
+const LOCK_WAIT: Duration = Duration::from_secs(10);
+
+/// Directory holding the bot's persisted JSON documents, configurable via `DATA_DIR`.
+pub fn data_dir() -> PathBuf {
+    env::var("DATA_DIR")
+        .map(PathBuf::from)
+        .unwrap_or_else(|_| PathBuf::from(DEFAULT_DATA_DIR))
+}
+
+/// One tenant's share of the data directory. Every store is opened in a
+/// partition, and a directory belongs to the first tenant to claim it, so one
+/// bot can never read or overwrite another's settings, orders or stock, even
+/// when `DATA_DIR` or a tenant's name is misconfigured.
+pub struct Partition {
+    dir: PathBuf,
+}
+
+#[derive(Serialize, Deserialize, Default)]
+struct Claim {
+    tenant: Option<String>,
+}
+
+impl Partition {
+    /// Claims `dir` for `tenant` (empty for the unnamed bot), failing if
+    /// another tenant already has.
+    pub fn claim(tenant: &str, dir: PathBuf) -> Result<Self, String> {
+        let path = dir.join(CLAIM_FILE);
+        let claim: Claim = load(&path)?;
+        match claim.tenant {
+            Some(owner) if owner != tenant => {
+                let name = |tenant: &str| {
+                    if tenant.is_empty() {
+                        "the unnamed bot".to_string()
+                    } else {
+                        format!("tenant {}", tenant)
+                    }
+                };
+                return Err(format!(
+                    "{} belongs to {}, not {}",
+                    dir.display(),
+                    name(&owner),
+                    name(tenant)
+                ));
+            }
+            Some(_) => {}
+            None => write_atomically(
+                &path,
+                &Claim {
+                    tenant: Some(tenant.to_string()),
+                },
+            )?,
+        }
+        Ok(Self { dir })
+    }
+
+    /// The data directory itself, for state that belongs to the process rather
+    /// than any one bot, such as update checks.
+    pub fn shared() -> Self {
+        Self { dir: data_dir() }
+    }
+
+    pub fn dir(&self) -> &Path {
+        &self.dir
+    }
+}
+
+/// The current Unix timestamp, used to stamp persisted records.
+pub fn now() -> u64 {
+    SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map(|d| d.as_secs())
+        .unwrap_or_default()
+}
+
+/// A serde document persisted as a single JSON file.
+///
+/// Reads are served from memory; every update is written back to disk before the
+/// write lock is released so concurrent updates never interleave on disk.
+///
+/// A document that several instances of the bot write (see
+/// [`JsonStore::shared`]) is instead updated under a lock in the shared cache,
+/// and reloaded from disk first so no instance's write is lost.
+pub struct JsonStore<T> {
+    path: PathBuf,
+    data: RwLock<T>,
+    lock: Option<Cache>,
+}
+
+impl<T> JsonStore<T>
+where
+    T: Serialize + DeserializeOwned + Default,
+{
+    /// Opens `file_name` inside `partition`. When the file does not exist yet
+    /// it is created from `T::default()`, giving operators a template to edit.
+    pub fn open(partition: &Partition, file_name: &str) -> Result<Self, String> {
+        let path = partition.dir().join(file_name);
+        let data = load(&path)?;
+
+        Ok(Self {
+            path,
+            data: RwLock::new(data),
+            lock: None,
+        })
+    }
+
+    /// Serializes updates with other instances through `cache`, when it is
+    /// shared between them.
+    pub fn shared(mut self, cache: &Cache) -> Self {
+        if cache.is_shared() {
+            self.lock = Some(cache.clone());
+        }
+        self
+    }
+
+    /// Replaces the in-memory document with the file's current contents, picking
+    /// up edits made by hand. On error the document is left unchanged.
+    pub async fn reload(&self) -> Result<(), String> {
+        let data = load(&self.path)?;
+        *self.data.write().await = data;
+        Ok(())
+    }
+
+    pub async fn read(&self) -> RwLockReadGuard<'_, T> {
+        self.data.read().await
+    }
+
+    /// Reads the document without waiting, for callers outside an async
+    /// context such as constructors. `None` while an update holds the lock.
+    pub fn try_read(&self) -> Option<RwLockReadGuard<'_, T>> {
+        self.data.try_read().ok()
+    }
+
+    /// Applies `f` to the document and persists the result.
+    pub async fn update<R>(&self, f: impl FnOnce(&mut T) -> R) -> Result<R, String> {
+        let file = self
+            .path
+            .file_name()
+            .map(|file| file.to_string_lossy().into_owned())
+            .unwrap_or_default();
+        let lock_name = format!("store:{}", file);
+        trace::span(
+            "store.update",
+            trace::Kind::Internal,
+            vec![("store.file", file)],
+            async {
+                let mut data = self.data.write().await;
+                let lease = match &self.lock {
+                    Some(cache) => {
+                        let lease = cache.lock(&lock_name, LOCK_TTL, LOCK_WAIT).await?;
+                        match load(&self.path) {
+                            Ok(latest) => *data = latest,
+                            Err(error) => {
+                                lease.release().await;
+                                return Err(error);
+                            }
+                        }
+                        Some(lease)
+                    }
+                    None => None,
+                };
+                let result = f(&mut data);
+                let written = write_atomically(&self.path, &*data);
+                if let Some(lease) = lease {
+                    lease.release().await;
+                }
+                written.map(|()| result)
+            },
+        )
+        .await
+    }
+}
+
+fn load<T>(path: &Path) -> Result<T, String>
+where
+    T: Serialize + DeserializeOwned + Default,
+{
+    match fs::read_to_string(path) {
+        Ok(contents) => serde_json::from_str(&contents)
+            .map_err(|e| format!("Error parsing {}: {}", path.display(), e)),
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => {
+            let data = T::default();
+            write_atomically(path, &data)?;
+            Ok(data)
+        }
+        Err(e) => Err(format!("Error reading {}: {}", path.display(), e)),
+    }
+}
+
+/// Writes `value` to `path` through a temporary file, so a crash never leaves
+/// a document half-written.
+pub fn write_atomically<T: Serialize>(path: &Path, value: &T) -> Result<(), String> {
+    if let Some(parent) = path.parent() {
+        fs::create_dir_all(parent)
+            .map_err(|e| format!("Error creating {}: {}", parent.display(), e))?;
+    }
+
+    let contents = serde_json::to_string_pretty(value)
+        .map_err(|e| format!("Error serializing {}: {}", path.display(), e))?;
+    let tmp_path = path.with_extension("json.tmp");
+    fs::write(&tmp_path, contents)
+        .map_err(|e| format!("Error writing {}: {}", tmp_path.display(), e))?;
+    fs::rename(&tmp_path, path).map_err(|e| format!("Error writing {}: {}", path.display(), e))
+}
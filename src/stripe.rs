@@ -0,0 +1,204 @@
+use crate::{
+    orders::{Order, Payment},
+    store,
+    tenant::{self, Tenant},
+    webhooks::{Request, Response},
+};
+use serde::Deserialize;
+use serde_json::Value;
+use serenity::prelude::*;
+use std::{env, sync::OnceLock, time::Duration};
+
+const API_URL: &str = "https://api.stripe.com/v1";
+/// Where buyers land after paying when `STRIPE_SUCCESS_URL` isn't set.
+const DEFAULT_SUCCESS_URL: &str = "https://discord.com/channels/@me";
+const REQUEST_TIMEOUT: Duration = Duration::from_secs(30);
+
+fn client() -> &'static reqwest::Client {
+    static CLIENT: OnceLock<reqwest::Client> = OnceLock::new();
+    CLIENT.get_or_init(|| {
+        reqwest::Client::builder()
+            .timeout(REQUEST_TIMEOUT)
+            .build()
+            .unwrap_or_default()
+    })
+}
+
+/// `tenant`'s Stripe key, from `STRIPE_SECRET_KEY` or `STRIPE_SECRET_KEY_<TENANT>`.
+fn secret_key(tenant: &Tenant) -> Result<String, String> {
+    tenant.var("STRIPE_SECRET_KEY").ok_or_else(|| {
+        format!(
+            "Stripe isn't set up: {} is missing",
+            tenant.var_name("STRIPE_SECRET_KEY")
+        )
+    })
+}
+
+/// A Stripe Checkout page for an order.
+#[derive(Deserialize)]
+pub struct Checkout {
+    pub id: String,
+    pub url: String,
+}
+
+/// Creates a Checkout page charging `order`'s GBP total on `tenant`'s account.
+/// The order ID and the bot's name go in the session's metadata, which is how
+/// its webhook finds the order again.
+pub async fn create_checkout(tenant: &Tenant, order: &Order) -> Result<Checkout, String> {
+    let pence = (order.total_gbp * 100.0).round() as u64;
+    if pence == 0 {
+        return Err(format!("Order #{} has nothing to pay", order.id));
+    }
+    let success_url =
+        env::var("STRIPE_SUCCESS_URL").unwrap_or_else(|_| DEFAULT_SUCCESS_URL.to_string());
+    let order_id = order.id.to_string();
+
+    let response = client()
+        .post(format!("{}/checkout/sessions", API_URL))
+        .bearer_auth(secret_key(tenant)?)
+        .form(&[
+            ("mode", "payment"),
+            ("success_url", success_url.as_str()),
+            ("client_reference_id", order_id.as_str()),
+            ("metadata[order_id]", order_id.as_str()),
+            ("metadata[tenant]", tenant.label()),
+            ("payment_intent_data[metadata][order_id]", order_id.as_str()),
+            ("payment_intent_data[metadata][tenant]", tenant.label()),
+            ("line_items[0][quantity]", "1"),
+            ("line_items[0][price_data][currency]", "gbp"),
+            (
+                "line_items[0][price_data][unit_amount]",
+                pence.to_string().as_str(),
+            ),
+            (
+                "line_items[0][price_data][product_data][name]",
+                format!("{} R$ (order #{})", order.robux, order.id).as_str(),
+            ),
+        ])
+        .send()
+        .await
+        .map_err(|e| format!("Error contacting Stripe: {}", e))?;
+    if !response.status().is_success() {
+        return Err(format!(
+            "Stripe returned {}: {}",
+            response.status(),
+            error_message(response).await
+        ));
+    }
+    response
+        .json()
+        .await
+        .map_err(|e| format!("Error reading Stripe checkout: {}", e))
+}
+
+/// Handles a webhook event. Events are fetched back from Stripe by ID rather
+/// than trusted as sent, so forged requests can't mark orders paid, and
+/// sessions another bot created are refused.
+pub async fn handle_webhook(ctx: &Context, request: &Request) -> Response {
+    let tenant = match tenant::tenant(ctx).await {
+        Ok(tenant) => tenant,
+        Err(error) => {
+            log::error!("Error handling Stripe webhook: {}", error);
+            return Response::new(500, "Unavailable");
+        }
+    };
+    let event_id = match serde_json::from_slice::<Value>(&request.body)
+        .ok()
+        .and_then(|event| event["id"].as_str().map(|id| id.to_string()))
+        .filter(|id| is_event_id(id))
+    {
+        Some(event_id) => event_id,
+        None => return Response::new(400, "Invalid event"),
+    };
+    let event = match retrieve_event(&tenant, &event_id).await {
+        Ok(Some(event)) => event,
+        Ok(None) => return Response::new(401, "Unknown event"),
+        Err(error) => {
+            log::error!("Error retrieving Stripe event {}: {}", event_id, error);
+            return Response::new(500, "Verification failed");
+        }
+    };
+    if event["type"] != "checkout.session.completed" {
+        return Response::ok();
+    }
+
+    let session = &event["data"]["object"];
+    // Sessions for anything the bot didn't create have no order to settle.
+    let order_id = match session["metadata"]["order_id"]
+        .as_str()
+        .and_then(|id| id.parse::<u64>().ok())
+    {
+        Some(order_id) => order_id,
+        None => return Response::ok(),
+    };
+    if !tenant.owns_payment(session["metadata"]["tenant"].as_str()) {
+        log::warn!(
+            "Refusing Stripe checkout {} for another bot: {}",
+            session["id"],
+            session["metadata"]["tenant"]
+        );
+        return Response::new(403, "Checkout is for another bot");
+    }
+    if session["payment_status"] != "paid" {
+        return Response::ok();
+    }
+
+    let text = |value: &Value| value.as_str().unwrap_or("").to_string();
+    let payment = Payment {
+        provider: "Stripe".to_string(),
+        // The payment intent is what Stripe's dashboard and payouts list.
+        reference: session["payment_intent"]
+            .as_str()
+            .map(|id| id.to_string())
+            .unwrap_or_else(|| text(&session["id"])),
+        amount: session["amount_total"].as_u64().unwrap_or(0) as f64 / 100.0,
+        currency: text(&session["currency"]).to_uppercase(),
+        recorded_at: store::now(),
+        recorded_by: None,
+    };
+    let note = format!("Checkout {}", text(&session["id"]));
+    crate::settle_payment(ctx, payment, Some(order_id), &note).await;
+    Response::ok()
+}
+
+/// Whether `id` looks like a Stripe event ID, `evt_` and letters and digits,
+/// so nothing else can reach the API path it's put in.
+fn is_event_id(id: &str) -> bool {
+    id.strip_prefix("evt_").map_or(false, |rest| {
+        !rest.is_empty() && rest.bytes().all(|byte| byte.is_ascii_alphanumeric())
+    })
+}
+
+/// The event with `id` as Stripe has it, or `None` if Stripe doesn't know it.
+async fn retrieve_event(tenant: &Tenant, id: &str) -> Result<Option<Value>, String> {
+    if !is_event_id(id) {
+        return Err(format!("Invalid Stripe event ID '{}'", id));
+    }
+    let response = client()
+        .get(format!("{}/events/{}", API_URL, id))
+        .bearer_auth(secret_key(tenant)?)
+        .send()
+        .await
+        .map_err(|e| format!("Error contacting Stripe: {}", e))?;
+    if response.status().as_u16() == 404 {
+        return Ok(None);
+    }
+    if !response.status().is_success() {
+        return Err(format!("Stripe returned {}", response.status()));
+    }
+    response
+        .json()
+        .await
+        .map(Some)
+        .map_err(|e| format!("Error reading Stripe event: {}", e))
+}
+
+/// The message in a Stripe error response, e.g. an invalid API key.
+async fn error_message(response: reqwest::Response) -> String {
+    response
+        .json::<Value>()
+        .await
+        .ok()
+        .and_then(|error| error["error"]["message"].as_str().map(|m| m.to_string()))
+        .unwrap_or_else(|| "no details".to_string())
+}
@@ -0,0 +1,188 @@
+use crate::{
+    cache::Cache,
+    store::{self, Partition},
+};
+use serenity::{model::id::GuildId, prelude::*};
+use std::{
+    collections::HashSet,
+    env,
+    sync::{
+        atomic::{AtomicBool, Ordering},
+        Arc,
+    },
+};
+
+/// One bot identity run by this process, with its own token, command guild
+/// and data partition, so its settings, orders and stock never mix with
+/// another's.
+pub struct Tenant {
+    /// Empty for the single bot configured by `DISCORD_TOKEN`.
+    pub name: String,
+    pub token: String,
+    /// The guild its slash commands are registered in.
+    pub guild_id: Option<GuildId>,
+    /// The guilds it serves, when limited to a group of servers.
+    pub guilds: Option<HashSet<u64>>,
+    pub partition: Partition,
+    /// The process's cache, with keys kept apart from other bots'.
+    pub cache: Cache,
+    ready: AtomicBool,
+}
+
+impl Tenant {
+    /// The name for logs and webhook paths, `default` for the unnamed bot.
+    pub fn label(&self) -> &str {
+        if self.name.is_empty() {
+            "default"
+        } else {
+            &self.name
+        }
+    }
+
+    /// Whether the bot serves `guild_id`: any guild unless it is limited to a
+    /// group, and always its command guild. DMs are always served.
+    pub fn serves(&self, guild_id: Option<GuildId>) -> bool {
+        match (guild_id, &self.guilds) {
+            (Some(guild_id), Some(guilds)) => {
+                guilds.contains(&guild_id.0) || self.guild_id == Some(guild_id)
+            }
+            _ => true,
+        }
+    }
+
+    /// The environment variable holding this bot's `name` setting:
+    /// `<NAME>_<TENANT>` for a named bot (e.g. `STRIPE_SECRET_KEY_SHOP_A`) and
+    /// `name` itself only for the unnamed one, so bots never share an account.
+    pub fn var_name(&self, name: &str) -> String {
+        if self.name.is_empty() {
+            name.to_string()
+        } else {
+            format!("{}_{}", name, env_suffix(&self.name))
+        }
+    }
+
+    /// This bot's value of `name`, as `var_name` finds it, unless blank.
+    pub fn var(&self, name: &str) -> Option<String> {
+        env::var(self.var_name(name))
+            .ok()
+            .map(|value| value.trim().to_string())
+            .filter(|value| !value.is_empty())
+    }
+
+    /// Whether a payment tagged for bot `label` belongs to this one. Untagged
+    /// payments, from before tags were added, only go to the unnamed bot.
+    pub fn owns_payment(&self, label: Option<&str>) -> bool {
+        match label {
+            Some(label) => label == self.label(),
+            None => self.name.is_empty(),
+        }
+    }
+
+    /// Whether this is the bot's first `ready` event, so background tasks are
+    /// started once per bot rather than again after every reconnect.
+    pub fn first_ready(&self) -> bool {
+        !self.ready.swap(true, Ordering::SeqCst)
+    }
+}
+
+pub struct TenantKey;
+
+impl TypeMapKey for TenantKey {
+    type Value = Arc<Tenant>;
+}
+
+pub async fn tenant(ctx: &Context) -> Result<Arc<Tenant>, String> {
+    ctx.data
+        .read()
+        .await
+        .get::<TenantKey>()
+        .cloned()
+        .ok_or_else(|| "Tenant unavailable".to_string())
+}
+
+/// The bots to run. `TENANTS` lists names (e.g. `shop-a,shop-b`), each
+/// configured by `DISCORD_TOKEN_<NAME>`, `GUILD_ID_<NAME>` and optionally
+/// `GUILDS_<NAME>` (e.g. `DISCORD_TOKEN_SHOP_A`) and kept in
+/// `<DATA_DIR>/tenants/<name>`. Without it, a single bot runs from
+/// `DISCORD_TOKEN`, `GUILD_ID` and `GUILDS` in `DATA_DIR`. All of them share
+/// `cache`.
+pub fn configured(cache: &Cache) -> Result<Vec<Tenant>, String> {
+    let names = env::var("TENANTS").unwrap_or_default();
+    let names: Vec<&str> = names
+        .split(',')
+        .map(str::trim)
+        .filter(|name| !name.is_empty())
+        .collect();
+    if names.is_empty() {
+        return Ok(vec![Tenant {
+            name: String::new(),
+            token: env::var("DISCORD_TOKEN").map_err(|_| "DISCORD_TOKEN is not set")?,
+            guild_id: guild_id("GUILD_ID")?,
+            guilds: guilds("GUILDS")?,
+            partition: Partition::claim("", store::data_dir())?,
+            cache: cache.scoped("default"),
+            ready: AtomicBool::new(false),
+        }]);
+    }
+
+    let mut tenants: Vec<Tenant> = Vec::with_capacity(names.len());
+    for name in names {
+        if !name
+            .chars()
+            .all(|c| c.is_ascii_lowercase() || c.is_ascii_digit() || c == '-')
+        {
+            return Err(format!(
+                "Tenant name {} may only use lowercase letters, digits and dashes",
+                name
+            ));
+        }
+        if tenants.iter().any(|tenant| tenant.name == name) {
+            return Err(format!("Tenant {} is listed twice", name));
+        }
+        let suffix = env_suffix(name);
+        let token_var = format!("DISCORD_TOKEN_{}", suffix);
+        tenants.push(Tenant {
+            name: name.to_string(),
+            token: env::var(&token_var).map_err(|_| format!("{} is not set", token_var))?,
+            guild_id: guild_id(&format!("GUILD_ID_{}", suffix))?,
+            guilds: guilds(&format!("GUILDS_{}", suffix))?,
+            partition: Partition::claim(name, store::data_dir().join("tenants").join(name))?,
+            cache: cache.scoped(name),
+            ready: AtomicBool::new(false),
+        });
+    }
+    Ok(tenants)
+}
+
+/// How tenant `name` appears in environment variable names, e.g. `SHOP_A`.
+fn env_suffix(name: &str) -> String {
+    name.to_uppercase().replace('-', "_")
+}
+
+/// A comma-separated list of guild IDs, or `None` when `var` is unset.
+fn guilds(var: &str) -> Result<Option<HashSet<u64>>, String> {
+    let list = match env::var(var) {
+        Ok(list) => list,
+        Err(_) => return Ok(None),
+    };
+    list.split(',')
+        .map(str::trim)
+        .filter(|id| !id.is_empty())
+        .map(|id| {
+            id.parse()
+                .map_err(|_| format!("{} contains {}, which is not a guild ID", var, id))
+        })
+        .collect::<Result<_, _>>()
+        .map(Some)
+}
+
+fn guild_id(var: &str) -> Result<Option<GuildId>, String> {
+    match env::var(var) {
+        Ok(id) => id
+            .trim()
+            .parse()
+            .map(|id| Some(GuildId(id)))
+            .map_err(|_| format!("{} is not a guild ID", var)),
+        Err(_) => Ok(None),
+    }
+}
@@ -0,0 +1,73 @@
+use chrono::{DateTime, FixedOffset, Offset, Utc};
+
+/// The widest offsets in use, UTC-12 and UTC+14.
+const MIN_OFFSET_MINUTES: i32 = -12 * 60;
+const MAX_OFFSET_MINUTES: i32 = 14 * 60;
+
+/// Parses a UTC offset as people write it: `UTC`, `UTC+1`, `GMT-4`, `+05:30`
+/// or `-0330`, into minutes east of UTC. Named zones aren't supported, so
+/// offsets have to be changed by hand when daylight saving starts or ends.
+pub fn parse(input: &str) -> Result<i32, String> {
+    let invalid = || {
+        format!(
+            "Invalid timezone '{}'. Use a UTC offset like UTC+1, UTC-5 or UTC+5:30.",
+            input.trim()
+        )
+    };
+    let normalized = input.trim().to_uppercase().replace(' ', "");
+    let offset = normalized
+        .strip_prefix("UTC")
+        .or_else(|| normalized.strip_prefix("GMT"))
+        .unwrap_or(&normalized);
+    if offset.is_empty() || offset == "0" {
+        return Ok(0);
+    }
+
+    let (sign, offset) = match (offset.strip_prefix('+'), offset.strip_prefix('-')) {
+        (Some(offset), _) => (1, offset),
+        (_, Some(offset)) => (-1, offset),
+        _ => return Err(invalid()),
+    };
+    if !offset.chars().all(|c| c.is_ascii_digit() || c == ':') {
+        return Err(invalid());
+    }
+    let (hours, minutes) = match offset.split_once(':') {
+        Some((hours, minutes)) => (hours, minutes),
+        None if offset.len() > 2 => offset.split_at(offset.len() - 2),
+        None => (offset, "0"),
+    };
+    let hours: i32 = hours.parse().map_err(|_| invalid())?;
+    let minutes: i32 = minutes.parse().map_err(|_| invalid())?;
+    if minutes >= 60 {
+        return Err(invalid());
+    }
+
+    let offset = sign * (hours * 60 + minutes);
+    if !(MIN_OFFSET_MINUTES..=MAX_OFFSET_MINUTES).contains(&offset) {
+        return Err("UTC offsets run from UTC-12 to UTC+14".to_string());
+    }
+    Ok(offset)
+}
+
+/// e.g. `UTC+05:30`, or just `UTC` for no offset.
+pub fn label(offset_minutes: i32) -> String {
+    if offset_minutes == 0 {
+        return "UTC".to_string();
+    }
+    format!(
+        "UTC{}{:02}:{:02}",
+        if offset_minutes < 0 { '-' } else { '+' },
+        offset_minutes.abs() / 60,
+        offset_minutes.abs() % 60
+    )
+}
+
+/// The Unix timestamp `at` as local time `offset_minutes` east of UTC, written
+/// with chrono's `format`. For text Discord doesn't render timestamp markup
+/// in, such as embed footers and PDFs; anywhere else, use `<t:…>` instead.
+pub fn format(at: u64, offset_minutes: i32, format: &str) -> String {
+    let offset = FixedOffset::east_opt(offset_minutes * 60).unwrap_or_else(|| Utc.fix());
+    DateTime::from_timestamp(at as i64, 0)
+        .map(|at| at.with_timezone(&offset).format(format).to_string())
+        .unwrap_or_default()
+}
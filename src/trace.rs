@@ -0,0 +1,296 @@
+use serde_json::{json, Value};
+use std::{
+    collections::hash_map::RandomState,
+    env,
+    fmt::Display,
+    future::Future,
+    hash::{BuildHasher, Hasher},
+    sync::{
+        atomic::{AtomicU64, Ordering},
+        OnceLock,
+    },
+    time::{Duration, SystemTime, UNIX_EPOCH},
+};
+use tokio::sync::mpsc;
+
+const DEFAULT_SERVICE_NAME: &str = "robuxcalculatorbot";
+/// Spans waiting to be exported; beyond this they are dropped rather than
+/// slowing commands down.
+const QUEUE_SIZE: usize = 4096;
+const MAX_BATCH: usize = 512;
+const BATCH_INTERVAL: Duration = Duration::from_secs(5);
+const EXPORT_TIMEOUT: Duration = Duration::from_secs(10);
+
+static EXPORTER: OnceLock<mpsc::Sender<FinishedSpan>> = OnceLock::new();
+
+tokio::task_local! {
+    /// The span the current task is running in.
+    static CURRENT: SpanContext;
+}
+
+#[derive(Clone, Copy)]
+struct SpanContext {
+    trace_id: u128,
+    span_id: u64,
+}
+
+/// What a span represents, as in OTLP's `SpanKind`.
+#[derive(Clone, Copy)]
+pub enum Kind {
+    Internal = 1,
+    /// Handling a request, e.g. a slash command.
+    Server = 2,
+    /// A call to another service, e.g. an HTTP API.
+    Client = 3,
+}
+
+type Attributes = Vec<(&'static str, String)>;
+
+struct FinishedSpan {
+    context: SpanContext,
+    parent_span_id: Option<u64>,
+    name: String,
+    kind: Kind,
+    start: u128,
+    end: u128,
+    attributes: Attributes,
+    error: Option<String>,
+}
+
+/// A span that has started but not finished yet.
+struct Started {
+    context: SpanContext,
+    parent_span_id: Option<u64>,
+    start: u128,
+}
+
+impl Started {
+    /// A child of the current task's span, or the root of a new trace.
+    fn now() -> Self {
+        let parent = CURRENT.try_with(|context| *context).ok();
+        Self {
+            context: SpanContext {
+                trace_id: parent.map_or_else(
+                    || random_id() as u128 | (random_id() as u128) << 64,
+                    |parent| parent.trace_id,
+                ),
+                span_id: random_id(),
+            },
+            parent_span_id: parent.map(|parent| parent.span_id),
+            start: now_nanos(),
+        }
+    }
+
+    fn finish(self, name: String, kind: Kind, attributes: Attributes, error: Option<String>) {
+        if let Some(exporter) = EXPORTER.get() {
+            let _ = exporter.try_send(FinishedSpan {
+                context: self.context,
+                parent_span_id: self.parent_span_id,
+                name,
+                kind,
+                start: self.start,
+                end: now_nanos(),
+                attributes,
+                error,
+            });
+        }
+    }
+}
+
+/// Starts exporting spans over OTLP/HTTP (JSON) to
+/// `OTEL_EXPORTER_OTLP_TRACES_ENDPOINT`, or `/v1/traces` under
+/// `OTEL_EXPORTER_OTLP_ENDPOINT`, with any `OTEL_EXPORTER_OTLP_HEADERS`
+/// (`name=value,…`). Tracing is off, costing nothing, when neither is set.
+pub fn start() {
+    let endpoint = match env::var("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") {
+        Ok(endpoint) => endpoint,
+        Err(_) => match env::var("OTEL_EXPORTER_OTLP_ENDPOINT") {
+            Ok(endpoint) => format!("{}/v1/traces", endpoint.trim_end_matches('/')),
+            Err(_) => return,
+        },
+    };
+    let (sender, receiver) = mpsc::channel(QUEUE_SIZE);
+    if EXPORTER.set(sender).is_err() {
+        return;
+    }
+    log::info!("Exporting traces to {}", endpoint);
+    tokio::spawn(export(receiver, endpoint));
+}
+
+/// Runs `future` in a span called `name`. Spans started inside it, in the
+/// same task, become its children; an `Err` marks the span as failed.
+pub async fn span<T, E: Display>(
+    name: impl Into<String>,
+    kind: Kind,
+    attributes: Attributes,
+    future: impl Future<Output = Result<T, E>>,
+) -> Result<T, E> {
+    if EXPORTER.get().is_none() {
+        return future.await;
+    }
+    let started = Started::now();
+    let result = CURRENT.scope(started.context, future).await;
+    let error = result.as_ref().err().map(|error| error.to_string());
+    started.finish(name.into(), kind, attributes, error);
+    result
+}
+
+/// Sends `request` in a client span called `name`, logging it at debug level.
+/// Only the host is recorded, never the full URL, since some APIs take their
+/// key in the path.
+pub async fn send(
+    name: &str,
+    mut attributes: Attributes,
+    request: reqwest::RequestBuilder,
+) -> reqwest::Result<reqwest::Response> {
+    let (client, request) = request.build_split();
+    let request = request?;
+    let method = request.method().to_string();
+    let host = request.url().host_str().unwrap_or_default().to_string();
+
+    let started = Started::now();
+    let response = client.execute(request).await;
+    match &response {
+        Ok(response) => log::debug!("{}: {} {} {}", name, method, host, response.status()),
+        Err(error) => log::debug!("{}: {} {} failed: {}", name, method, host, error),
+    }
+    if EXPORTER.get().is_none() {
+        return response;
+    }
+
+    attributes.push(("http.request.method", method));
+    attributes.push(("server.address", host));
+    let error = match &response {
+        Ok(response) => {
+            attributes.push((
+                "http.response.status_code",
+                response.status().as_u16().to_string(),
+            ));
+            response
+                .status()
+                .is_server_error()
+                .then(|| response.status().to_string())
+        }
+        Err(error) => Some(error.to_string()),
+    };
+    started.finish(name.to_string(), Kind::Client, attributes, error);
+    response
+}
+
+async fn export(mut spans: mpsc::Receiver<FinishedSpan>, endpoint: String) {
+    let client = reqwest::Client::builder()
+        .timeout(EXPORT_TIMEOUT)
+        .build()
+        .unwrap_or_default();
+    let headers: Vec<(String, String)> = env::var("OTEL_EXPORTER_OTLP_HEADERS")
+        .unwrap_or_default()
+        .split(',')
+        .filter_map(|header| {
+            let (name, value) = header.split_once('=')?;
+            Some((name.trim().to_string(), value.trim().to_string()))
+        })
+        .collect();
+    let service_name =
+        env::var("OTEL_SERVICE_NAME").unwrap_or_else(|_| DEFAULT_SERVICE_NAME.to_string());
+
+    let mut batch = Vec::new();
+    let mut interval = tokio::time::interval(BATCH_INTERVAL);
+    // Only the first of a run of failures is logged, so a collector that's
+    // down doesn't flood the log every few seconds.
+    let mut failing = false;
+    loop {
+        tokio::select! {
+            span = spans.recv() => match span {
+                Some(span) => {
+                    batch.push(span);
+                    if batch.len() < MAX_BATCH {
+                        continue;
+                    }
+                }
+                None => return,
+            },
+            _ = interval.tick() => {}
+        }
+        if batch.is_empty() {
+            continue;
+        }
+
+        let body = encode(&service_name, &std::mem::take(&mut batch));
+        let mut request = client.post(&endpoint).json(&body);
+        for (name, value) in &headers {
+            request = request.header(name, value);
+        }
+        let result = match request.send().await {
+            Ok(response) if response.status().is_success() => Ok(()),
+            Ok(response) => Err(format!("collector returned {}", response.status())),
+            Err(error) => Err(error.to_string()),
+        };
+        match result {
+            Ok(()) => failing = false,
+            Err(error) if !failing => {
+                log::error!("Error exporting traces: {}", error);
+                failing = true;
+            }
+            Err(_) => {}
+        }
+    }
+}
+
+/// `spans` as an OTLP `ExportTraceServiceRequest` in its JSON encoding.
+fn encode(service_name: &str, spans: &[FinishedSpan]) -> Value {
+    let attributes = |attributes: &[(&str, String)]| {
+        attributes
+            .iter()
+            .map(|(key, value)| json!({ "key": key, "value": { "stringValue": value } }))
+            .collect::<Vec<_>>()
+    };
+    let spans: Vec<_> = spans
+        .iter()
+        .map(|span| {
+            let mut encoded = json!({
+                "traceId": format!("{:032x}", span.context.trace_id),
+                "spanId": format!("{:016x}", span.context.span_id),
+                "name": span.name,
+                "kind": span.kind as u8,
+                "startTimeUnixNano": span.start.to_string(),
+                "endTimeUnixNano": span.end.to_string(),
+                "attributes": attributes(&span.attributes),
+                "status": match &span.error {
+                    Some(error) => json!({ "code": 2, "message": error }),
+                    None => json!({ "code": 1 }),
+                },
+            });
+            if let Some(parent_span_id) = span.parent_span_id {
+                encoded["parentSpanId"] = json!(format!("{:016x}", parent_span_id));
+            }
+            encoded
+        })
+        .collect();
+
+    json!({
+        "resourceSpans": [{
+            "resource": { "attributes": attributes(&[("service.name", service_name.to_string())]) },
+            "scopeSpans": [{
+                "scope": { "name": DEFAULT_SERVICE_NAME },
+                "spans": spans,
+            }],
+        }],
+    })
+}
+
+/// A random, non-zero ID. Each `RandomState` is keyed differently, which is
+/// random enough for telling spans apart without another dependency.
+fn random_id() -> u64 {
+    static COUNTER: AtomicU64 = AtomicU64::new(0);
+    let mut hasher = RandomState::new().build_hasher();
+    hasher.write_u64(COUNTER.fetch_add(1, Ordering::Relaxed));
+    hasher.write_u128(now_nanos());
+    hasher.finish().max(1)
+}
+
+fn now_nanos() -> u128 {
+    SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map(|d| d.as_nanos())
+        .unwrap_or_default()
+}
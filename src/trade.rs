@@ -0,0 +1,169 @@
+use crate::{amount, rolimons};
+
+const MAX_PARTS: usize = 20;
+const MAX_COPIES: u64 = 100;
+/// Largest Robux amount one side can add; well past any real trade.
+const MAX_ROBUX: u64 = 100_000_000;
+
+/// One side of a trade: limited items plus any Robux added.
+pub struct Bundle {
+    pub items: Vec<(rolimons::Item, u64)>,
+    pub robux: u64,
+}
+
+impl Bundle {
+    /// Rolimons' value of the items, or their RAP where it hasn't valued one,
+    /// plus the Robux.
+    pub fn value(&self) -> u64 {
+        self.items
+            .iter()
+            .map(|(item, copies)| copies * item.value.unwrap_or(item.rap))
+            .sum::<u64>()
+            + self.robux
+    }
+
+    /// The RAP of the items plus the Robux.
+    pub fn rap(&self) -> u64 {
+        self.items
+            .iter()
+            .map(|(item, copies)| copies * item.rap)
+            .sum::<u64>()
+            + self.robux
+    }
+
+    pub fn has_projected(&self) -> bool {
+        self.items.iter().any(|(item, _)| item.projected)
+    }
+
+    /// A line per item, then the Robux, e.g. `DE ×2: 1,200,000 R$`.
+    pub fn lines(&self) -> Vec<String> {
+        let mut lines: Vec<String> = self
+            .items
+            .iter()
+            .map(|(item, copies)| {
+                format!(
+                    "{}{}{}: {} R$",
+                    item.name,
+                    if *copies > 1 {
+                        format!(" ×{}", copies)
+                    } else {
+                        String::new()
+                    },
+                    if item.projected { " ⚠️" } else { "" },
+                    group(copies * item.value.unwrap_or(item.rap))
+                )
+            })
+            .collect();
+        if self.robux > 0 {
+            lines.push(format!("Robux: {} R$", group(self.robux)));
+        }
+        lines
+    }
+}
+
+/// Parses a comma-separated bundle such as `2x DE, Valk, 15,000`. Amounts are
+/// Robux; anything else is looked up on Rolimons by name, acronym or ID, with
+/// an optional `2x` before or `x2` after for several copies.
+pub async fn parse(input: &str) -> Result<Bundle, String> {
+    let input = join_thousands(input);
+    let parts: Vec<&str> = input
+        .split(',')
+        .map(str::trim)
+        .filter(|part| !part.is_empty())
+        .collect();
+    if parts.is_empty() {
+        return Err("List items or Robux amounts, separated by commas".to_string());
+    }
+    if parts.len() > MAX_PARTS {
+        return Err(format!(
+            "A side can list at most {} items and amounts",
+            MAX_PARTS
+        ));
+    }
+
+    let mut bundle = Bundle {
+        items: Vec::new(),
+        robux: 0,
+    };
+    for part in parts {
+        if let Ok(robux) = amount::parse(part) {
+            if robux.fract() != 0.0 || robux < 1.0 {
+                return Err(format!("'{}' isn't a whole number of Robux", part));
+            }
+            bundle.robux += robux as u64;
+            if bundle.robux > MAX_ROBUX {
+                return Err(format!("A side can add at most {} R$", group(MAX_ROBUX)));
+            }
+            continue;
+        }
+
+        let (copies, query) = split_copies(part);
+        if !(1..=MAX_COPIES).contains(&copies) {
+            return Err(format!(
+                "Copies must be between 1 and {}; '{}' asks for {}",
+                MAX_COPIES, part, copies
+            ));
+        }
+        let item = rolimons::find(query).await?;
+        match bundle
+            .items
+            .iter_mut()
+            .find(|(listed, _)| listed.id == item.id)
+        {
+            Some((_, listed)) if *listed + copies > MAX_COPIES => {
+                return Err(format!(
+                    "A side can list at most {} copies of {}",
+                    MAX_COPIES, item.name
+                ));
+            }
+            Some((_, listed)) => *listed += copies,
+            None => bundle.items.push((item, copies)),
+        }
+    }
+    Ok(bundle)
+}
+
+/// Splits a `2x` prefix or `x2` suffix off `part`, returning the copies and
+/// the rest. Without either, it's one copy of all of `part`.
+fn split_copies(part: &str) -> (u64, &str) {
+    let is_times = |c: char| matches!(c, 'x' | 'X' | '×');
+    if let Some((count, rest)) = part.split_once(is_times) {
+        if let Ok(copies) = count.trim().parse::<u64>() {
+            if !rest.trim().is_empty() {
+                return (copies, rest.trim());
+            }
+        }
+    }
+    if let Some((rest, count)) = part.rsplit_once(is_times) {
+        if let Ok(copies) = count.trim().parse::<u64>() {
+            if !rest.trim().is_empty() {
+                return (copies, rest.trim());
+            }
+        }
+    }
+    (1, part)
+}
+
+/// Drops commas used as thousands separators, as in `15,000`, so they don't
+/// split an amount in two. A comma between digits with exactly three after it
+/// counts as one.
+fn join_thousands(input: &str) -> String {
+    let chars: Vec<char> = input.chars().collect();
+    chars
+        .iter()
+        .enumerate()
+        .filter(|(index, c)| {
+            let digit = |offset: usize| chars.get(offset).is_some_and(|c| c.is_ascii_digit());
+            !(**c == ','
+                && *index > 0
+                && digit(index - 1)
+                && (index + 1..=index + 3).all(digit)
+                && !digit(index + 4))
+        })
+        .map(|(_, c)| *c)
+        .collect()
+}
+
+fn group(robux: u64) -> String {
+    amount::group_thousands(robux as f64)
+}
@@ -0,0 +1,200 @@
+use crate::{
+    outbound, owner_id,
+    settings::SettingsKey,
+    store::{JsonStore, Partition},
+    trace,
+};
+use serde::{Deserialize, Serialize};
+use serenity::{builder::CreateEmbed, model::id::UserId, prelude::*};
+use std::{
+    sync::{
+        atomic::{AtomicBool, Ordering},
+        OnceLock, RwLock,
+    },
+    time::Duration,
+};
+
+const UPDATE_STATE_FILE: &str = "updates.json";
+const RELEASES_API_URL: &str = "https://api.github.com/repos";
+const REQUEST_TIMEOUT: Duration = Duration::from_secs(10);
+/// Delay before the first check, so it doesn't compete with startup.
+const FIRST_CHECK_DELAY: Duration = Duration::from_secs(60);
+
+static STARTED: AtomicBool = AtomicBool::new(false);
+static AVAILABLE: RwLock<Option<Release>> = RwLock::new(None);
+
+/// The newest release the owner has been told about, so each is announced
+/// once rather than after every restart.
+#[derive(Serialize, Deserialize, Default)]
+struct UpdateState {
+    notified: Option<String>,
+}
+
+/// A published release on GitHub.
+#[derive(Deserialize, Clone)]
+pub struct Release {
+    pub tag_name: String,
+    #[serde(default)]
+    pub name: Option<String>,
+    pub html_url: String,
+}
+
+fn client() -> &'static reqwest::Client {
+    static CLIENT: OnceLock<reqwest::Client> = OnceLock::new();
+    CLIENT.get_or_init(|| {
+        reqwest::Client::builder()
+            .timeout(REQUEST_TIMEOUT)
+            // GitHub's API refuses requests without a user agent.
+            .user_agent(concat!(
+                env!("CARGO_PKG_NAME"),
+                "/",
+                env!("CARGO_PKG_VERSION")
+            ))
+            .build()
+            .unwrap_or_default()
+    })
+}
+
+/// Starts checking GitHub for newer releases. Later calls (e.g. after a
+/// reconnect) are no-ops.
+pub fn start(ctx: Context) {
+    if STARTED.swap(true, Ordering::SeqCst) {
+        return;
+    }
+
+    let state = match JsonStore::open(&Partition::shared(), UPDATE_STATE_FILE) {
+        Ok(state) => state,
+        Err(error) => {
+            log::error!("Update checks disabled: {}", error);
+            return;
+        }
+    };
+
+    tokio::spawn(schedule(ctx, state));
+}
+
+/// The newest release, when it is newer than the running build.
+pub fn available() -> Option<Release> {
+    AVAILABLE.read().ok()?.clone()
+}
+
+async fn schedule(ctx: Context, state: JsonStore<UpdateState>) {
+    tokio::time::sleep(FIRST_CHECK_DELAY).await;
+    loop {
+        let settings = match ctx.data.read().await.get::<SettingsKey>().cloned() {
+            Some(settings) => settings,
+            None => return,
+        };
+        let (enabled, interval_hours) = {
+            let settings = settings.read().await;
+            (settings.updates.enabled, settings.updates.interval_hours)
+        };
+
+        if enabled {
+            if let Err(error) = check(&ctx, &state).await {
+                log::warn!("Error checking for updates: {}", error);
+            }
+        }
+        tokio::time::sleep(Duration::from_secs(interval_hours.max(1) * 3600)).await;
+    }
+}
+
+/// Fetches the latest release and, when it is newer than this build, records
+/// it for `/about` and tells the owner about it once.
+async fn check(ctx: &Context, state: &JsonStore<UpdateState>) -> Result<(), String> {
+    let release = match latest_release().await? {
+        Some(release) if is_newer(&release.tag_name, env!("CARGO_PKG_VERSION")) => release,
+        _ => {
+            if let Ok(mut available) = AVAILABLE.write() {
+                *available = None;
+            }
+            return Ok(());
+        }
+    };
+    if let Ok(mut available) = AVAILABLE.write() {
+        *available = Some(release.clone());
+    }
+
+    if state.read().await.notified.as_deref() == Some(release.tag_name.as_str()) {
+        return Ok(());
+    }
+    let owner_id = match owner_id() {
+        Some(owner_id) => owner_id,
+        None => return Ok(()),
+    };
+    let channel = UserId(owner_id)
+        .create_dm_channel(&ctx.http)
+        .await
+        .map_err(|e| format!("Error opening DM with the owner: {:?}", e))?;
+
+    let embed = CreateEmbed::default()
+        .title("Update Available")
+        .description(format!(
+            "[{}]({}) is out. This bot is running {} (`{}`).",
+            release.name.as_deref().unwrap_or(&release.tag_name),
+            release.html_url,
+            env!("CARGO_PKG_VERSION"),
+            env!("BUILD_COMMIT")
+        ))
+        .footer(|footer| footer.text("You'll only be told once about each release"))
+        .color(0x0096FF)
+        .clone();
+    outbound::send(&ctx.http, || {
+        channel.send_message(&ctx.http, |message| message.set_embed(embed.clone()))
+    })
+    .await
+    .map_err(|e| format!("Error sending update notice: {:?}", e))?;
+
+    let tag = release.tag_name.clone();
+    state.update(|state| state.notified = Some(tag)).await
+}
+
+/// The repository's latest published release, or `None` if it has none yet.
+async fn latest_release() -> Result<Option<Release>, String> {
+    let repository = env!("CARGO_PKG_REPOSITORY")
+        .trim_start_matches("https://github.com/")
+        .trim_end_matches('/');
+    let response = trace::send(
+        "github.latest_release",
+        Vec::new(),
+        client().get(format!(
+            "{}/{}/releases/latest",
+            RELEASES_API_URL, repository
+        )),
+    )
+    .await
+    .map_err(|e| format!("Error contacting GitHub: {}", e))?;
+
+    if response.status() == reqwest::StatusCode::NOT_FOUND {
+        return Ok(None);
+    }
+    if !response.status().is_success() {
+        return Err(format!("GitHub returned {}", response.status()));
+    }
+    response
+        .json()
+        .await
+        .map(Some)
+        .map_err(|e| format!("Error parsing GitHub release: {}", e))
+}
+
+/// Whether release `tag` (e.g. `v1.4.0`) is a later version than `current`.
+/// Tags that aren't versions never count as newer.
+fn is_newer(tag: &str, current: &str) -> bool {
+    match (version(tag), version(current)) {
+        (Some(tag), Some(current)) => tag > current,
+        _ => false,
+    }
+}
+
+/// The numeric `major.minor.patch` part of a version, ignoring a leading `v`
+/// and any pre-release or build suffix.
+fn version(input: &str) -> Option<(u64, u64, u64)> {
+    let input = input.trim().trim_start_matches(['v', 'V']);
+    let core = input.split(['-', '+']).next()?;
+    let mut parts = core.split('.').map(|part| part.parse::<u64>().ok());
+    let major = parts.next()??;
+    let minor = parts.next().unwrap_or(Some(0))?;
+    let patch = parts.next().unwrap_or(Some(0))?;
+    Some((major, minor, patch))
+}
@@ -0,0 +1,368 @@
+use crate::{paypal, stripe};
+use serenity::prelude::*;
+use std::{
+    collections::HashMap,
+    env,
+    sync::{
+        atomic::{AtomicBool, Ordering},
+        RwLock,
+    },
+    time::Duration,
+};
+use tokio::{
+    io::{AsyncBufReadExt, AsyncReadExt, AsyncWriteExt, BufReader},
+    net::{TcpListener, TcpStream},
+};
+
+/// Payment providers' notifications are small; anything bigger is refused.
+const MAX_BODY_BYTES: usize = 1024 * 1024;
+const MAX_HEADER_LINES: usize = 100;
+pub const READ_TIMEOUT: Duration = Duration::from_secs(10);
+
+/// An HTTP request from a payment provider, or to the diagnostics listener.
+pub struct Request {
+    pub method: String,
+    pub path: String,
+    /// Everything after the `?` in the request target, if anything.
+    pub query: String,
+    /// Header values by lowercase name.
+    pub headers: HashMap<String, String>,
+    pub body: Vec<u8>,
+}
+
+impl Request {
+    pub fn header(&self, name: &str) -> Option<&str> {
+        self.headers.get(name).map(|value| value.as_str())
+    }
+}
+
+pub struct Response {
+    pub status: u16,
+    pub body: String,
+}
+
+impl Response {
+    pub fn ok() -> Self {
+        Self::new(200, "OK")
+    }
+
+    pub fn new(status: u16, body: &str) -> Self {
+        Self {
+            status,
+            body: body.to_string(),
+        }
+    }
+}
+
+static STARTED: AtomicBool = AtomicBool::new(false);
+/// Each bot's context by tenant name, so notifications reach the bot whose
+/// orders they are for.
+static TENANTS: RwLock<Vec<(String, Context)>> = RwLock::new(Vec::new());
+
+/// Starts the payment webhook listener on `WEBHOOK_PORT`, if it is set, and
+/// routes notifications under `/<tenant>/` to that bot (plain paths go to the
+/// unnamed bot). It speaks just enough HTTP/1.1 for providers'
+/// notifications, so it should sit behind a reverse proxy that terminates
+/// TLS. The listener is shared, so later calls only add their bot.
+pub fn start(ctx: Context, tenant: &str) {
+    if let Ok(mut tenants) = TENANTS.write() {
+        tenants.retain(|(name, _)| name != tenant);
+        tenants.push((tenant.to_string(), ctx));
+    }
+    let port = match env::var("WEBHOOK_PORT")
+        .ok()
+        .and_then(|port| port.parse::<u16>().ok())
+    {
+        Some(port) => port,
+        None => return,
+    };
+    if STARTED.swap(true, Ordering::SeqCst) {
+        return;
+    }
+
+    tokio::spawn(async move {
+        let listener = match TcpListener::bind(("0.0.0.0", port)).await {
+            Ok(listener) => listener,
+            Err(error) => {
+                log::error!("Error listening for webhooks on port {}: {}", port, error);
+                return;
+            }
+        };
+        log::info!("Listening for payment webhooks on port {}", port);
+        loop {
+            let stream = match listener.accept().await {
+                Ok((stream, _)) => stream,
+                Err(error) => {
+                    log::error!("Error accepting webhook connection: {}", error);
+                    continue;
+                }
+            };
+            tokio::spawn(async move {
+                if let Err(error) = serve(stream).await {
+                    log::error!("Error serving webhook: {}", error);
+                }
+            });
+        }
+    });
+}
+
+async fn serve(mut stream: TcpStream) -> Result<(), String> {
+    let response = match tokio::time::timeout(READ_TIMEOUT, read_request(&mut stream)).await {
+        Ok(Ok(mut request)) => match tenant_for(&mut request) {
+            Some(ctx) => route(&ctx, &request).await,
+            None => Response::new(404, "Not Found"),
+        },
+        Ok(Err(error)) => {
+            log::error!("Bad webhook request: {}", error);
+            Response::new(400, "Bad Request")
+        }
+        Err(_) => Response::new(408, "Request Timeout"),
+    };
+    write_response(&mut stream, &response).await
+}
+
+/// The bot `request` is for, by the tenant name its path starts with, which
+/// is then stripped. Paths without one are for the unnamed bot.
+fn tenant_for(request: &mut Request) -> Option<Context> {
+    let tenants = TENANTS.read().ok()?;
+    if let Some((first, rest)) = request.path.trim_start_matches('/').split_once('/') {
+        if let Some((_, ctx)) = tenants
+            .iter()
+            .find(|(name, _)| !name.is_empty() && name == first)
+        {
+            let ctx = ctx.clone();
+            request.path = format!("/{}", rest);
+            return Some(ctx);
+        }
+    }
+    tenants
+        .iter()
+        .find(|(name, _)| name.is_empty())
+        .map(|(_, ctx)| ctx.clone())
+}
+
+/// Writes `response` as plain text and ends the exchange.
+pub async fn write_response(stream: &mut TcpStream, response: &Response) -> Result<(), String> {
+    let reply = format!(
+        "HTTP/1.1 {} {}\r\nContent-Type: text/plain\r\nContent-Length: {}\r\nConnection: close\r\n\r\n{}",
+        response.status,
+        reason_phrase(response.status),
+        response.body.len(),
+        response.body
+    );
+    stream
+        .write_all(reply.as_bytes())
+        .await
+        .map_err(|e| format!("Error writing response: {}", e))
+}
+
+async fn route(ctx: &Context, request: &Request) -> Response {
+    if request.method != "POST" {
+        return Response::new(405, "Method Not Allowed");
+    }
+    match request.path.as_str() {
+        "/paypal/ipn" => paypal::handle_ipn(ctx, request).await,
+        "/paypal/webhook" => paypal::handle_webhook(ctx, request).await,
+        "/stripe/webhook" => stripe::handle_webhook(ctx, request).await,
+        _ => Response::new(404, "Not Found"),
+    }
+}
+
+/// Reads one request, refusing bodies over `MAX_BODY_BYTES`. Callers should
+/// bound it with `READ_TIMEOUT`.
+pub async fn read_request(stream: &mut TcpStream) -> Result<Request, String> {
+    let mut reader = BufReader::new(stream);
+    let mut line = String::new();
+    reader
+        .read_line(&mut line)
+        .await
+        .map_err(|e| format!("Error reading request line: {}", e))?;
+    let mut parts = line.split_whitespace();
+    let method = parts.next().ok_or("Missing method")?.to_string();
+    let target = parts.next().ok_or("Missing path")?;
+    let (path, query) = target.split_once('?').unwrap_or((target, ""));
+    let (path, query) = (path.to_string(), query.to_string());
+
+    let mut headers = HashMap::new();
+    for _ in 0..MAX_HEADER_LINES {
+        line.clear();
+        reader
+            .read_line(&mut line)
+            .await
+            .map_err(|e| format!("Error reading headers: {}", e))?;
+        let header = line.trim_end();
+        if header.is_empty() {
+            break;
+        }
+        if let Some((name, value)) = header.split_once(':') {
+            headers.insert(name.trim().to_lowercase(), value.trim().to_string());
+        }
+    }
+
+    let length: usize = headers
+        .get("content-length")
+        .and_then(|length| length.parse().ok())
+        .unwrap_or(0);
+    if length > MAX_BODY_BYTES {
+        return Err(format!("Body of {} bytes is too large", length));
+    }
+    let mut body = vec![0; length];
+    reader
+        .read_exact(&mut body)
+        .await
+        .map_err(|e| format!("Error reading body: {}", e))?;
+
+    Ok(Request {
+        method,
+        path,
+        query,
+        headers,
+        body,
+    })
+}
+
+fn reason_phrase(status: u16) -> &'static str {
+    match status {
+        200 => "OK",
+        400 => "Bad Request",
+        401 => "Unauthorized",
+        403 => "Forbidden",
+        404 => "Not Found",
+        405 => "Method Not Allowed",
+        408 => "Request Timeout",
+        503 => "Service Unavailable",
+        _ => "Internal Server Error",
+    }
+}
+
+/// Decodes an `application/x-www-form-urlencoded` body into its fields.
+/// A field sent more than once keeps its last value.
+pub fn parse_form(body: &[u8]) -> HashMap<String, String> {
+    String::from_utf8_lossy(body)
+        .split('&')
+        .filter_map(|pair| {
+            let (name, value) = pair.split_once('=').unwrap_or((pair, ""));
+            (!name.is_empty()).then(|| (percent_decode(name), percent_decode(value)))
+        })
+        .collect()
+}
+
+fn percent_decode(input: &str) -> String {
+    let bytes = input.as_bytes();
+    let mut decoded = Vec::with_capacity(bytes.len());
+    let mut i = 0;
+    while i < bytes.len() {
+        match bytes[i] {
+            b'+' => decoded.push(b' '),
+            // `from_str_radix` would also take a sign, as in `%+1`.
+            b'%' if i + 3 <= bytes.len()
+                && bytes[i + 1..i + 3].iter().all(u8::is_ascii_hexdigit) =>
+            {
+                let hex = std::str::from_utf8(&bytes[i + 1..i + 3]).unwrap_or("");
+                decoded.push(u8::from_str_radix(hex, 16).unwrap_or(b'%'));
+                i += 2;
+            }
+            byte => decoded.push(byte),
+        }
+        i += 1;
+    }
+    String::from_utf8_lossy(&decoded).into_owned()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn escapes_and_plus_signs_decode() {
+        assert_eq!(percent_decode("a+b%20c"), "a b c");
+        assert_eq!(percent_decode("%2B1%2b2"), "+1+2");
+        assert_eq!(percent_decode("caf%C3%A9"), "café");
+        assert_eq!(percent_decode(""), "");
+    }
+
+    #[test]
+    fn truncated_and_invalid_escapes_are_kept() {
+        assert_eq!(percent_decode("%"), "%");
+        assert_eq!(percent_decode("100%"), "100%");
+        assert_eq!(percent_decode("%4"), "%4");
+        assert_eq!(percent_decode("a%4"), "a%4");
+        assert_eq!(percent_decode("%zz"), "%zz");
+        assert_eq!(percent_decode("%+1"), "% 1");
+        assert_eq!(percent_decode("%%41"), "%A");
+        assert_eq!(percent_decode("%FF"), "\u{FFFD}");
+    }
+
+    #[test]
+    fn forms_decode_names_and_values() {
+        let form = parse_form(b"payment_status=Completed&custom=Order+%2342&mc_gross=12.50");
+        assert_eq!(form.len(), 3);
+        assert_eq!(form["payment_status"], "Completed");
+        assert_eq!(form["custom"], "Order #42");
+        assert_eq!(form["mc_gross"], "12.50");
+    }
+
+    #[test]
+    fn forms_skip_empty_names_and_keep_the_last_duplicate() {
+        let form = parse_form(b"=x&&flag&txn_id=1&txn_id=2&a%3Db=c%26d");
+        assert_eq!(form.len(), 3);
+        assert_eq!(form["flag"], "");
+        assert_eq!(form["txn_id"], "2");
+        assert_eq!(form["a=b"], "c&d");
+        assert!(parse_form(b"").is_empty());
+    }
+
+    /// Sends `raw` to `read_request` over a local connection.
+    async fn read(raw: Vec<u8>) -> Result<Request, String> {
+        let listener = TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let address = listener.local_addr().unwrap();
+        let client = tokio::spawn(async move {
+            let mut stream = TcpStream::connect(address).await.unwrap();
+            // The server may refuse the request before reading all of it.
+            let _ = stream.write_all(&raw).await;
+            let _ = stream.shutdown().await;
+        });
+        let (mut stream, _) = listener.accept().await.unwrap();
+        let request = read_request(&mut stream).await;
+        let _ = client.await;
+        request
+    }
+
+    #[tokio::test]
+    async fn requests_are_read_with_their_body() {
+        let request = read(
+            b"POST /paypal/ipn?x=1 HTTP/1.1\r\nContent-Type: text/plain\r\nContent-Length: 5\r\n\r\nhello"
+                .to_vec(),
+        )
+        .await
+        .unwrap();
+        assert_eq!(request.method, "POST");
+        assert_eq!(request.path, "/paypal/ipn");
+        assert_eq!(request.query, "x=1");
+        assert_eq!(request.header("content-type"), Some("text/plain"));
+        assert_eq!(request.body, b"hello");
+    }
+
+    #[tokio::test]
+    async fn oversize_bodies_are_refused() {
+        let head = format!(
+            "POST /stripe HTTP/1.1\r\nContent-Length: {}\r\n\r\n",
+            MAX_BODY_BYTES + 1
+        );
+        assert!(read(head.into_bytes()).await.is_err());
+
+        let mut raw = format!(
+            "POST /stripe HTTP/1.1\r\nContent-Length: {}\r\n\r\n",
+            MAX_BODY_BYTES
+        )
+        .into_bytes();
+        raw.resize(raw.len() + MAX_BODY_BYTES, b'a');
+        assert_eq!(read(raw).await.unwrap().body.len(), MAX_BODY_BYTES);
+    }
+
+    #[tokio::test]
+    async fn truncated_bodies_are_refused() {
+        let raw = b"POST /stripe HTTP/1.1\r\nContent-Length: 10\r\n\r\nshort".to_vec();
+        assert!(read(raw).await.is_err());
+    }
+}